@@ -0,0 +1,220 @@
+// Package aggregate publishes differentially private statistics over
+// receipts.
+//
+// Stats counts receipts per time bucket and per policy, then adds Laplace
+// noise calibrated to epsilon, so operators can publish transparency figures
+// without revealing any one customer's volume. Each customer's contribution
+// is bounded before noising: at most MaxPerCustomer receipts per customer
+// and bucket, and at most MaxPolicies policies per receipt, are counted.
+package aggregate
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Defaults applied by Stats
+const (
+	DefaultBucket         = 24 * time.Hour
+	DefaultMaxPolicies    = 4
+	DefaultMaxPerCustomer = 100
+)
+
+// Options configures Stats
+type Options struct {
+	// Bucket is the width of the time buckets; defaults to a day
+	Bucket time.Duration
+
+	// Policies lists the policy IDs to count. When empty the policies seen
+	// in the receipts are counted, which reveals that each was claimed
+	Policies []string
+
+	// MaxPolicies bounds the policies counted per receipt
+	MaxPolicies int
+
+	// Customer, when set, names the customer a receipt was issued for, such
+	// as a tenant label. Privacy then covers each customer's whole volume;
+	// otherwise it covers single receipts
+	Customer func(receipt *tecp.Receipt) string
+
+	// MaxPerCustomer bounds the receipts counted per customer and bucket;
+	// defaults to DefaultMaxPerCustomer
+	MaxPerCustomer int
+
+	// Budget, when set, is charged epsilon and refuses queries beyond it
+	Budget *Budget
+}
+
+// Budget tracks the privacy budget spent on published statistics
+type Budget struct {
+	mu    sync.Mutex
+	total float64
+	spent float64
+}
+
+// NewBudget returns a budget allowing a total epsilon
+func NewBudget(total float64) *Budget {
+	return &Budget{total: total}
+}
+
+// Spend charges epsilon, failing when the budget would be exceeded
+func (b *Budget) Spend(epsilon float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spent+epsilon > b.total {
+		return fmt.Errorf("aggregate: privacy budget exhausted: %.3g of %.3g spent", b.spent, b.total)
+	}
+	b.spent += epsilon
+	return nil
+}
+
+// Remaining returns the unspent epsilon
+func (b *Budget) Remaining() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.total - b.spent
+}
+
+// Bucket holds the noised counts of one time bucket
+type Bucket struct {
+	Start    time.Time        `json:"start"`
+	Receipts int64            `json:"receipts"`
+	Policies map[string]int64 `json:"policies"`
+}
+
+// Statistics are noised receipt counts, safe to publish
+type Statistics struct {
+	Epsilon float64  `json:"epsilon"`
+	Bucket  string   `json:"bucket"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Stats counts receipts per time bucket and policy with epsilon-differential
+// privacy. Every bucket between the first and last
+// receipt is reported, empty or not, so gaps do not leak activity
+func Stats(receipts []*tecp.Receipt, epsilon float64, options Options) (*Statistics, error) {
+	if epsilon <= 0 || math.IsInf(epsilon, 0) || math.IsNaN(epsilon) {
+		return nil, fmt.Errorf("aggregate: epsilon must be positive")
+	}
+	width := options.Bucket
+	if width <= 0 {
+		width = DefaultBucket
+	}
+	maxPolicies := options.MaxPolicies
+	if maxPolicies <= 0 {
+		maxPolicies = DefaultMaxPolicies
+	}
+	maxPerCustomer := 1
+	if options.Customer != nil {
+		maxPerCustomer = options.MaxPerCustomer
+		if maxPerCustomer <= 0 {
+			maxPerCustomer = DefaultMaxPerCustomer
+		}
+	}
+	if options.Budget != nil {
+		if err := options.Budget.Spend(epsilon); err != nil {
+			return nil, err
+		}
+	}
+
+	policies := make(map[string]bool)
+	for _, id := range options.Policies {
+		policies[id] = true
+	}
+	observe := len(policies) == 0
+
+	type customerBucket struct {
+		customer string
+		bucket   int64
+	}
+	contributions := make(map[customerBucket]int)
+	counts := make(map[int64]*Bucket)
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+
+	for _, receipt := range receipts {
+		bucket := time.UnixMilli(receipt.Timestamp).Truncate(width).UnixMilli()
+		first, last = min(first, bucket), max(last, bucket)
+
+		if options.Customer != nil {
+			key := customerBucket{options.Customer(receipt), bucket}
+			if contributions[key] >= maxPerCustomer {
+				continue
+			}
+			contributions[key]++
+		}
+
+		b, ok := counts[bucket]
+		if !ok {
+			b = &Bucket{Policies: make(map[string]int64)}
+			counts[bucket] = b
+		}
+		b.Receipts++
+		counted := 0
+		for _, id := range receipt.PolicyIDs {
+			if counted == maxPolicies {
+				break
+			}
+			if observe {
+				policies[id] = true
+			} else if !policies[id] {
+				continue
+			}
+			b.Policies[id]++
+			counted++
+		}
+	}
+
+	stats := &Statistics{Epsilon: epsilon, Bucket: width.String(), Buckets: []Bucket{}}
+	if len(receipts) == 0 {
+		return stats, nil
+	}
+
+	// One customer changes at most maxPerCustomer receipts per bucket, each
+	// touching the total and up to maxPolicies policy counts
+	scale := float64(maxPerCustomer*(1+maxPolicies)) / epsilon
+
+	ids := make([]string, 0, len(policies))
+	for id := range policies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for start := first; start <= last; start += width.Milliseconds() {
+		exact, ok := counts[start]
+		if !ok {
+			exact = &Bucket{Policies: map[string]int64{}}
+		}
+		noised := Bucket{Start: time.UnixMilli(start).UTC(), Policies: make(map[string]int64, len(ids))}
+		var err error
+		if noised.Receipts, err = noisy(exact.Receipts, scale); err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if noised.Policies[id], err = noisy(exact.Policies[id], scale); err != nil {
+				return nil, err
+			}
+		}
+		stats.Buckets = append(stats.Buckets, noised)
+	}
+	return stats, nil
+}
+
+// noisy adds Laplace noise of the given scale to a count, rounding and
+// clamping the result to a non-negative integer
+func noisy(count int64, scale float64) (int64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, fmt.Errorf("aggregate: failed to sample noise: %w", err)
+	}
+	// u is uniform in (-0.5, 0.5)
+	u := (float64(binary.BigEndian.Uint64(buf[:])>>11)+0.5)/(1<<53) - 0.5
+	noise := -scale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+	return max(0, int64(math.Round(float64(count)+noise))), nil
+}
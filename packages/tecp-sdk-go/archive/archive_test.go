@@ -0,0 +1,193 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// writeArchive writes two JSONL files of receipts to a fresh directory
+func writeArchive(t *testing.T) string {
+	t.Helper()
+	priv, _, err := tecp.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := tecp.NewClient(tecp.WithSigner(priv))
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "2026"), 0o755)
+	for f, name := range []string{"2026/01.jsonl", "2026/02.jsonl"} {
+		var lines bytes.Buffer
+		for i := 0; i < 3; i++ {
+			receipt, err := client.CreateReceipt(tecp.CreateReceiptOptions{Input: []byte(fmt.Sprintf("%d/%d", f, i)), Output: []byte("out"), CodeRef: "git:abc"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := receipt.ToJSON()
+			lines.Write(append(data, '\n'))
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), lines.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func sealArchive(t *testing.T, dir string, key ed25519.PrivateKey) {
+	t.Helper()
+	manifest, err := Build(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manifest.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := manifest.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	editFile := func(name string, fn func([]byte) []byte) func(t *testing.T, dir string) {
+		return func(t *testing.T, dir string) {
+			path := filepath.Join(dir, name)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, fn(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tests := []struct {
+		name     string
+		tamper   func(t *testing.T, dir string)
+		problems []string
+	}{
+		{"intact", func(t *testing.T, dir string) {}, nil},
+		{"hidden files ignored", func(t *testing.T, dir string) {
+			os.WriteFile(filepath.Join(dir, ".DS_Store"), []byte("x"), 0o644)
+		}, nil},
+		{"altered receipt", editFile("2026/01.jsonl", func(data []byte) []byte {
+			return bytes.Replace(data, []byte(`"git:abc"`), []byte(`"git:xyz"`), 1)
+		}), []string{ProblemHash, ProblemMerkleRoot}},
+		{"truncated file", editFile("2026/02.jsonl", func(data []byte) []byte {
+			return data[:bytes.IndexByte(data, '\n')+1]
+		}), []string{ProblemHash, ProblemCount, ProblemMerkleRoot}},
+		{"reordered receipts", editFile("2026/02.jsonl", func(data []byte) []byte {
+			lines := bytes.SplitAfter(data, []byte("\n"))
+			return bytes.Join([][]byte{lines[1], lines[0], lines[2]}, nil)
+		}), []string{ProblemHash, ProblemMerkleRoot}},
+		{"trailing garbage", editFile("2026/01.jsonl", func(data []byte) []byte {
+			return append(data, []byte("{not json}\n")...)
+		}), []string{ProblemHash, ProblemUndecodable}},
+		{"removed file", func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, "2026/01.jsonl"))
+		}, []string{ProblemMissing, ProblemCount, ProblemMerkleRoot}},
+		{"added file", func(t *testing.T, dir string) {
+			data, _ := os.ReadFile(filepath.Join(dir, "2026/01.jsonl"))
+			os.WriteFile(filepath.Join(dir, "2026/03.jsonl"), data, 0o644)
+		}, []string{ProblemUnlisted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeArchive(t)
+			sealArchive(t, dir, key)
+			tt.tamper(t, dir)
+
+			report, err := Verify(dir, VerifyOptions{PublicKey: publicKey})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.problems) == 0 {
+				if !report.OK() || report.Receipts != 6 || report.Sampled != 6 {
+					t.Fatalf("intact archive failed verification: %+v", report)
+				}
+				return
+			}
+			for _, kind := range tt.problems {
+				found := false
+				for _, problem := range report.Problems {
+					found = found || problem.Kind == kind
+				}
+				if !found {
+					t.Fatalf("no %s problem reported: %+v", kind, report.Problems)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifySamplesReceipts(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	dir := writeArchive(t)
+
+	// A receipt altered before sealing matches the manifest but not its signature
+	path := filepath.Join(dir, "2026/01.jsonl")
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, bytes.Replace(data, []byte(`"git:abc"`), []byte(`"git:xyz"`), 1), 0o644)
+	sealArchive(t, dir, key)
+
+	report, err := Verify(dir, VerifyOptions{PublicKey: key.Public().(ed25519.PublicKey), Sample: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || report.Problems[0].Kind != ProblemInvalid {
+		t.Fatalf("invalid receipt not found by sampling: %+v", report)
+	}
+
+	skipped, err := Verify(dir, VerifyOptions{PublicKey: key.Public().(ed25519.PublicKey), Sample: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skipped.OK() || skipped.Sampled != 0 {
+		t.Fatalf("Sample -1 verified receipts: %+v", skipped)
+	}
+}
+
+func TestVerifyRejectsManifest(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, dir string)
+	}{
+		{"signed by another archiver", func(t *testing.T, dir string) { sealArchive(t, dir, other) }},
+		{"altered after signing", func(t *testing.T, dir string) {
+			manifest, _ := ReadManifest(dir)
+			manifest.Receipts--
+			manifest.Write(dir)
+		}},
+		{"unsigned", func(t *testing.T, dir string) {
+			manifest, _ := ReadManifest(dir)
+			manifest.Signature = ""
+			manifest.Write(dir)
+		}},
+		{"missing", func(t *testing.T, dir string) { os.Remove(filepath.Join(dir, ManifestName)) }},
+		{"malformed", func(t *testing.T, dir string) {
+			os.WriteFile(filepath.Join(dir, ManifestName), []byte("{"), 0o644)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeArchive(t)
+			sealArchive(t, dir, key)
+			tt.tamper(t, dir)
+			if _, err := Verify(dir, VerifyOptions{PublicKey: publicKey}); err == nil {
+				t.Fatal("archive accepted")
+			}
+		})
+	}
+}
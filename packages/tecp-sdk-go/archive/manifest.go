@@ -0,0 +1,265 @@
+// Package archive seals receipt archives with signed integrity manifests.
+//
+// An archive is a directory of receipt files in any format tecp.NewDecoder
+// reads: JSONL, CBOR sequences or length-prefixed records, including an
+// unencrypted store.DirStore directory. Its manifest lists every file with
+// its SHA-256 hash and receipt count, and commits to the receipts
+// themselves with the Merkle root of their receipt hashes, in file order.
+// The manifest is signed by the archiver, so a verifier holding the
+// archiver's key detects added, removed, truncated or altered files:
+//
+//	manifest, err := archive.Build(dir)
+//	err = manifest.Sign(archiverKey)
+//	err = manifest.Write(dir)
+//
+//	report, err := archive.Verify(dir, archive.VerifyOptions{PublicKey: archiverKey.Public().(ed25519.PublicKey)})
+package archive
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// ManifestName is the manifest's file name in the archive directory
+const ManifestName = "MANIFEST.json"
+
+// Version identifies the manifest format
+const Version = "TECP-ARCHIVE-0.1"
+
+// File is an archive file in the manifest
+type File struct {
+	// Path is the file's slash-separated path within the archive
+	Path string `json:"path"`
+
+	// SHA256 is the hex SHA-256 hash of the file's content
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Receipts int    `json:"receipts"`
+}
+
+// Manifest describes an archive's content
+type Manifest struct {
+	Version string `json:"version"`
+	Created int64  `json:"created"`
+	Files   []File `json:"files"`
+
+	// Receipts is the number of receipts in the archive
+	Receipts int `json:"receipts"`
+
+	// MerkleRoot is the hex Merkle tree root over the receipts' hashes, in
+	// file and stream order
+	MerkleRoot string `json:"merkle_root"`
+
+	// PublicKey and Signature are the archiver's base64 Ed25519 key and
+	// its signature over the manifest
+	PublicKey string `json:"pubkey,omitempty"`
+	Signature string `json:"sig,omitempty"`
+}
+
+// Build scans the archive in dir and returns its unsigned manifest. Hidden
+// files and an existing manifest are skipped. Every receipt must decode
+func Build(dir string) (*Manifest, error) {
+	paths, err := archiveFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Version: Version, Created: time.Now().UnixMilli(), Files: []File{}}
+	tree := &merkle.Tree{}
+	for _, path := range paths {
+		file, err := scanFile(dir, path, func(receipt *tecp.Receipt, err error) error {
+			if err != nil {
+				return err
+			}
+			receiptHash, err := tecp.ReceiptHash(receipt)
+			if err != nil {
+				return err
+			}
+			tree.Append(merkle.LeafHash(receiptHash))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, *file)
+		manifest.Receipts += file.Receipts
+	}
+	manifest.MerkleRoot = hex.EncodeToString(tree.Root())
+	return manifest, nil
+}
+
+// Sign signs the manifest with the archiver's key
+func (m *Manifest) Sign(key ed25519.PrivateKey) error {
+	m.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	statement, err := m.statement()
+	if err != nil {
+		return err
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, statement))
+	return nil
+}
+
+// VerifySignature checks the manifest's signature. When publicKey is set
+// the manifest must be signed with it; otherwise the embedded key is used,
+// which only proves the manifest was not altered since it was signed
+func (m *Manifest) VerifySignature(publicKey ed25519.PublicKey) error {
+	if m.Version != Version {
+		return fmt.Errorf("archive: unsupported manifest version: %s", m.Version)
+	}
+	if m.Signature == "" {
+		return fmt.Errorf("archive: manifest is not signed")
+	}
+	embedded, err := base64.StdEncoding.DecodeString(m.PublicKey)
+	if err != nil || len(embedded) != ed25519.PublicKeySize {
+		return fmt.Errorf("archive: invalid manifest key")
+	}
+	if publicKey != nil && !publicKey.Equal(ed25519.PublicKey(embedded)) {
+		return fmt.Errorf("archive: manifest is not signed with the archiver's key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("archive: invalid signature encoding: %w", err)
+	}
+	statement, err := m.statement()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(embedded, statement, signature) {
+		return fmt.Errorf("archive: manifest has an invalid signature")
+	}
+	return nil
+}
+
+// Write writes the manifest to the archive in dir
+func (m *Manifest) Write(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest of the archive in dir
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("archive: invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// statement is what the archiver signs
+func (m *Manifest) statement() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"version":     m.Version,
+		"created":     m.Created,
+		"files":       m.Files,
+		"receipts":    m.Receipts,
+		"merkle_root": m.MerkleRoot,
+		"pubkey":      m.PublicKey,
+	})
+}
+
+// archiveFiles lists the archive's files, sorted by slash-separated path
+func archiveFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); rel != ManifestName {
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// scanFile hashes an archive file while decoding its receipts, calling fn
+// with each receipt or decoding error. An error from fn stops the scan
+func scanFile(dir, path string, fn func(*tecp.Receipt, error) error) (*File, error) {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	decoder := tecp.NewDecoder(io.TeeReader(f, io.MultiWriter(hash, counter)))
+	file := &File{Path: path}
+	for {
+		receipt, err := decoder.Decode()
+		var itemErr *tecp.DecodeError
+		switch {
+		case err == io.EOF:
+		case err == nil || errors.As(err, &itemErr):
+			if err == nil {
+				file.Receipts++
+			}
+			if err := fn(receipt, err); err != nil {
+				return nil, fmt.Errorf("archive: %s: %w", path, err)
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("archive: %s: %w", path, err)
+		}
+		break
+	}
+
+	// Hash whatever the decoder did not consume
+	if _, err := io.Copy(io.MultiWriter(hash, counter), f); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", path, err)
+	}
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	file.Size = counter.n
+	return file, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
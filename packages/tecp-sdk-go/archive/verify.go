@@ -0,0 +1,185 @@
+package archive
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// DefaultSample is how many receipts Verify spot-verifies by default
+const DefaultSample = 100
+
+// Problem kinds
+const (
+	ProblemMissing     = "missing"
+	ProblemUnlisted    = "unlisted"
+	ProblemHash        = "hash_mismatch"
+	ProblemCount       = "count_mismatch"
+	ProblemUndecodable = "undecodable"
+	ProblemMerkleRoot  = "merkle_root_mismatch"
+	ProblemInvalid     = "invalid_receipt"
+)
+
+// Problem is a discrepancy between an archive and its manifest, or a
+// sampled receipt that failed verification
+type Problem struct {
+	// File is the archive file concerned; empty for the archive as a whole
+	File   string `json:"file,omitempty"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// Report is the outcome of Verify
+type Report struct {
+	Files    int       `json:"files"`
+	Receipts int       `json:"receipts"`
+	Sampled  int       `json:"sampled"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// OK reports whether the archive matched its manifest and every sampled
+// receipt verified
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// VerifyOptions configures Verify
+type VerifyOptions struct {
+	// PublicKey is the archiver's key. Without it the manifest is checked
+	// against its embedded key only
+	PublicKey ed25519.PublicKey
+
+	// Sample is how many receipts, drawn uniformly at random, are verified
+	// with Verifier; it defaults to DefaultSample, and a negative value
+	// verifies none
+	Sample int
+
+	// Verifier and Options verify the sampled receipts. Verifier defaults
+	// to tecp.NewClient()
+	Verifier *tecp.Client
+	Options  tecp.VerifyOptions
+}
+
+// Verify checks the archive in dir against its signed manifest: every
+// listed file must be present with its hash and receipt count, no file may
+// be unlisted, and the receipts must match the manifest's count and Merkle
+// root. A random sample of receipts is then fully verified. The error is
+// reserved for a missing, malformed or badly signed manifest
+func Verify(dir string, options VerifyOptions) (*Report, error) {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.VerifySignature(options.PublicKey); err != nil {
+		return nil, err
+	}
+	sampleSize := options.Sample
+	if sampleSize == 0 {
+		sampleSize = DefaultSample
+	}
+
+	paths, err := archiveFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		present[path] = true
+	}
+
+	report := &Report{}
+	problem := func(file, kind, format string, args ...interface{}) {
+		report.Problems = append(report.Problems, Problem{File: file, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	tree := &merkle.Tree{}
+	var sample []*tecp.Receipt
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, expected := range manifest.Files {
+		listed[expected.Path] = true
+		if !present[expected.Path] {
+			problem(expected.Path, ProblemMissing, "file listed in the manifest is missing")
+			continue
+		}
+		report.Files++
+
+		file, err := scanFile(dir, expected.Path, func(receipt *tecp.Receipt, err error) error {
+			if err != nil {
+				problem(expected.Path, ProblemUndecodable, "%v", err)
+				return nil
+			}
+			report.Receipts++
+			if receiptHash, err := tecp.ReceiptHash(receipt); err == nil {
+				tree.Append(merkle.LeafHash(receiptHash))
+			} else {
+				problem(expected.Path, ProblemUndecodable, "%v", err)
+			}
+			// Reservoir sampling keeps a uniform sample of the stream
+			switch {
+			case sampleSize < 0:
+			case len(sample) < sampleSize:
+				sample = append(sample, receipt)
+			default:
+				if i := randomIndex(report.Receipts); i < sampleSize {
+					sample[i] = receipt
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			problem(expected.Path, ProblemUndecodable, "%v", err)
+			continue
+		}
+		if file.SHA256 != expected.SHA256 || file.Size != expected.Size {
+			problem(expected.Path, ProblemHash, "file hash %s does not match the manifest's %s", file.SHA256, expected.SHA256)
+		}
+		if file.Receipts != expected.Receipts {
+			problem(expected.Path, ProblemCount, "file holds %d receipts, the manifest lists %d", file.Receipts, expected.Receipts)
+		}
+	}
+	for _, path := range paths {
+		if !listed[path] {
+			problem(path, ProblemUnlisted, "file is not listed in the manifest")
+		}
+	}
+
+	if report.Receipts != manifest.Receipts {
+		problem("", ProblemCount, "archive holds %d receipts, the manifest lists %d", report.Receipts, manifest.Receipts)
+	}
+	if root := hex.EncodeToString(tree.Root()); root != manifest.MerkleRoot {
+		problem("", ProblemMerkleRoot, "receipts have Merkle root %s, the manifest lists %s", root, manifest.MerkleRoot)
+	}
+
+	verifier := options.Verifier
+	if verifier == nil {
+		verifier = tecp.NewClient()
+	}
+	for _, receipt := range sample {
+		report.Sampled++
+		result, err := verifier.VerifyReceipt(receipt, options.Options)
+		switch {
+		case err != nil:
+			problem("", ProblemInvalid, "%v", err)
+		case !result.Valid:
+			receiptHash, _ := tecp.ReceiptHash(receipt)
+			problem("", ProblemInvalid, "receipt %s: %s", hex.EncodeToString(receiptHash), strings.Join(result.Errors, "; "))
+		}
+	}
+	return report, nil
+}
+
+// randomIndex returns a uniform random index below n, from crypto/rand so
+// archivers cannot predict which receipts are sampled
+func randomIndex(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(i.Int64())
+}
@@ -0,0 +1,223 @@
+// Package billing reconciles billed usage against receipted computations.
+//
+// Receipts carrying a signed metering extension record the usage each
+// computation was metered at and the pricing plan in force. Reconcile
+// matches a billing system's invoice lines to those records by record ID
+// and reports usage billed without a receipt, billed beyond what was
+// receipted, or billed under another plan, so customers can check they are
+// billed only for receipted work. Receipts should be verified first; only
+// signed metering records are considered.
+package billing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Discrepancy kinds
+const (
+	// KindUnreceipted is a billed record no receipt carries
+	KindUnreceipted = "unreceipted"
+
+	// KindUsageExceeded is usage billed beyond the receipted usage
+	KindUsageExceeded = "usage_exceeded"
+
+	// KindPlanMismatch is a record billed under a plan other than the one
+	// its receipts were issued under
+	KindPlanMismatch = "plan_mismatch"
+
+	// KindDuplicate is a record billed on more than one line
+	KindDuplicate = "duplicate"
+)
+
+// Line is one billed metering record
+type Line struct {
+	RecordID string           `json:"record_id"`
+	Usage    map[string]int64 `json:"usage"`
+	PlanHash string           `json:"plan_hash,omitempty"`
+}
+
+// Discrepancy is billed usage the receipts do not support
+type Discrepancy struct {
+	RecordID  string `json:"record_id"`
+	Kind      string `json:"kind"`
+	Unit      string `json:"unit,omitempty"`
+	Billed    int64  `json:"billed,omitempty"`
+	Receipted int64  `json:"receipted,omitempty"`
+}
+
+// Report is the outcome of a reconciliation
+type Report struct {
+	// Lines and Matched count the billed lines and those fully supported
+	// by receipts
+	Lines   int `json:"lines"`
+	Matched int `json:"matched"`
+
+	// Billed and Receipted are the total usage by unit
+	Billed    map[string]int64 `json:"billed"`
+	Receipted map[string]int64 `json:"receipted"`
+
+	Discrepancies []Discrepancy `json:"discrepancies,omitempty"`
+
+	// Unbilled are the receipted record IDs no line bills
+	Unbilled []string `json:"unbilled,omitempty"`
+}
+
+// OK reports whether every billed line is supported by receipts
+func (r *Report) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// record is the receipted usage of one metering record
+type record struct {
+	usage map[string]int64
+	plans map[string]bool
+}
+
+// Reconcile matches billed lines to the metering records of receipts. The
+// usage of receipts sharing a record ID is summed
+func Reconcile(receipts []*tecp.Receipt, lines []Line) *Report {
+	report := &Report{Lines: len(lines), Billed: make(map[string]int64), Receipted: make(map[string]int64)}
+	records := make(map[string]*record)
+	for _, receipt := range receipts {
+		metering, ok := receipt.Metering()
+		if !ok {
+			continue
+		}
+		rec, ok := records[metering.RecordID]
+		if !ok {
+			rec = &record{usage: make(map[string]int64), plans: make(map[string]bool)}
+			records[metering.RecordID] = rec
+		}
+		for unit, quantity := range metering.Usage {
+			rec.usage[unit] += quantity
+			report.Receipted[unit] += quantity
+		}
+		if metering.PlanHash != "" {
+			rec.plans[metering.PlanHash] = true
+		}
+	}
+
+	billed := make(map[string]bool)
+	for _, line := range lines {
+		for unit, quantity := range line.Usage {
+			report.Billed[unit] += quantity
+		}
+		if billed[line.RecordID] {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{RecordID: line.RecordID, Kind: KindDuplicate})
+			continue
+		}
+		billed[line.RecordID] = true
+
+		rec, ok := records[line.RecordID]
+		if !ok {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{RecordID: line.RecordID, Kind: KindUnreceipted})
+			continue
+		}
+		matched := true
+		if line.PlanHash != "" && len(rec.plans) > 0 && (len(rec.plans) > 1 || !rec.plans[line.PlanHash]) {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{RecordID: line.RecordID, Kind: KindPlanMismatch})
+			matched = false
+		}
+		for _, unit := range sortedUnits(line.Usage) {
+			if quantity := line.Usage[unit]; quantity > rec.usage[unit] {
+				report.Discrepancies = append(report.Discrepancies, Discrepancy{
+					RecordID:  line.RecordID,
+					Kind:      KindUsageExceeded,
+					Unit:      unit,
+					Billed:    quantity,
+					Receipted: rec.usage[unit],
+				})
+				matched = false
+			}
+		}
+		if matched {
+			report.Matched++
+		}
+	}
+
+	for id := range records {
+		if !billed[id] {
+			report.Unbilled = append(report.Unbilled, id)
+		}
+	}
+	sort.Strings(report.Unbilled)
+	return report
+}
+
+// ReconcileStore reconciles lines against every receipt in a store
+func ReconcileStore(st store.ReceiptStore, lines []Line) (*Report, error) {
+	var receipts []*tecp.Receipt
+	err := st.Walk(func(key string, receipt *tecp.Receipt) error {
+		receipts = append(receipts, receipt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Reconcile(receipts, lines), nil
+}
+
+// ReadCSV reads billed lines from CSV with a header naming record_id, unit
+// and quantity columns, and optionally plan_hash. Rows of one record are
+// combined into one line
+func ReadCSV(r io.Reader) ([]Line, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("billing: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"record_id", "unit", "quantity"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("billing: CSV has no %s column", name)
+		}
+	}
+	planColumn, hasPlan := columns["plan_hash"]
+
+	var lines []Line
+	index := make(map[string]int)
+	for n, row := range rows[1:] {
+		id := row[columns["record_id"]]
+		quantity, err := strconv.ParseInt(strings.TrimSpace(row[columns["quantity"]]), 10, 64)
+		if err != nil || quantity < 0 {
+			return nil, fmt.Errorf("billing: row %d: invalid quantity", n+2)
+		}
+		i, ok := index[id]
+		if !ok {
+			i = len(lines)
+			index[id] = i
+			lines = append(lines, Line{RecordID: id, Usage: make(map[string]int64)})
+		}
+		lines[i].Usage[row[columns["unit"]]] += quantity
+		if hasPlan && row[planColumn] != "" {
+			if lines[i].PlanHash != "" && lines[i].PlanHash != row[planColumn] {
+				return nil, fmt.Errorf("billing: row %d: record %s billed under two plans", n+2, id)
+			}
+			lines[i].PlanHash = row[planColumn]
+		}
+	}
+	return lines, nil
+}
+
+func sortedUnits(usage map[string]int64) []string {
+	units := make([]string, 0, len(usage))
+	for unit := range usage {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	return units
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+const reportVersion = "TECP-AUDIT-0.1"
+
+// Anomaly kinds
+const (
+	anomalyPolicySpike     = "policy_spike"
+	anomalyUnknownIssuer   = "unknown_issuer"
+	anomalyFailureCluster  = "failure_cluster"
+	anomalyLogInconsistent = "log_inconsistent"
+	anomalyMissingFromLog  = "missing_from_log"
+	anomalyLogUnavailable  = "log_unavailable"
+)
+
+// config is the auditor configuration taken from flags
+type config struct {
+	store    store.ReceiptStore
+	log      *tecplog.Client
+	verifier *tecp.Client
+	options  tecp.VerifyOptions
+
+	// issuers, when set, are the only expected signing keys
+	issuers []ed25519.PublicKey
+
+	lookback    time.Duration
+	mmd         time.Duration
+	spikeFactor float64
+	spikeMin    int
+	clusterMin  int
+	keyID       string
+	signer      tecp.Signer
+	statePath   string
+}
+
+// state is carried between runs
+type state struct {
+	LastRun int64 `json:"last_run"`
+
+	// Baseline is the smoothed hourly rate of receipts per policy
+	Baseline map[string]float64 `json:"baseline"`
+
+	// Issuers are the signing keys seen so far
+	Issuers map[string]bool `json:"issuers"`
+
+	// LogSize and LogRoot describe the log tree seen by the last run
+	LogSize uint64 `json:"log_size,omitempty"`
+	LogRoot string `json:"log_root,omitempty"`
+}
+
+// anomaly is one finding of a run
+type anomaly struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject,omitempty"`
+	Count   int    `json:"count,omitempty"`
+	Detail  string `json:"detail"`
+}
+
+// logAudit summarizes the log cross-check
+type logAudit struct {
+	URL      string `json:"url"`
+	TreeSize uint64 `json:"tree_size"`
+	Root     string `json:"root"`
+	Checked  int    `json:"checked"`
+	Missing  int    `json:"missing"`
+}
+
+// report is the signed outcome of a run
+type report struct {
+	Version   string         `json:"v"`
+	From      int64          `json:"from"`
+	To        int64          `json:"to"`
+	Receipts  int            `json:"receipts"`
+	Failed    int            `json:"failed"`
+	Policies  map[string]int `json:"policies"`
+	Issuers   map[string]int `json:"issuers"`
+	Log       *logAudit      `json:"log,omitempty"`
+	Anomalies []anomaly      `json:"anomalies"`
+	KeyID     string         `json:"kid"`
+	Algorithm tecp.Algorithm `json:"alg,omitempty"`
+	Signature string         `json:"sig,omitempty"`
+}
+
+// signingPayload returns the bytes covered by the report signature: its
+// JSON encoding without the signature
+func (r *report) signingPayload() []byte {
+	unsigned := *r
+	unsigned.Signature = ""
+	payload, _ := json.Marshal(&unsigned)
+	return payload
+}
+
+// loadState reads the state file, returning an empty state when it does not
+// exist yet
+func loadState(path string) (*state, error) {
+	st := &state{Baseline: make(map[string]float64), Issuers: make(map[string]bool)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("invalid state %s: %w", path, err)
+	}
+	if st.Baseline == nil {
+		st.Baseline = make(map[string]float64)
+	}
+	if st.Issuers == nil {
+		st.Issuers = make(map[string]bool)
+	}
+	return st, nil
+}
+
+// saveState replaces the state file
+func saveState(path string, st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// audit runs one audit over the receipts issued since the last run
+func audit(cfg *config, now time.Time) (*report, error) {
+	st, err := loadState(cfg.statePath)
+	if err != nil {
+		return nil, err
+	}
+	from := now.Add(-cfg.lookback).UnixMilli()
+	if st.LastRun > 0 {
+		from = st.LastRun
+	}
+
+	rep := &report{
+		Version:  reportVersion,
+		From:     from,
+		To:       now.UnixMilli(),
+		Policies: make(map[string]int),
+		Issuers:  make(map[string]int),
+		KeyID:    cfg.keyID,
+	}
+	if alg := cfg.signer.Algorithm(); alg != tecp.AlgEdDSA {
+		rep.Algorithm = alg
+	}
+
+	// Verify the receipts of the window
+	var receipts []*tecp.Receipt
+	clusters := make(map[[2]string]int)
+	err = cfg.store.Walk(func(key string, receipt *tecp.Receipt) error {
+		if receipt.Timestamp <= from || receipt.Timestamp > rep.To {
+			return nil
+		}
+		receipts = append(receipts, receipt)
+		rep.Receipts++
+		rep.Issuers[receipt.PublicKey]++
+		for _, id := range receipt.PolicyIDs {
+			rep.Policies[id]++
+		}
+
+		result, err := cfg.verifier.VerifyReceipt(receipt, cfg.options)
+		if err != nil {
+			return err
+		}
+		if !result.Valid {
+			rep.Failed++
+			clusters[[2]string{receipt.PublicKey, failedCheck(result)}]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rep.Anomalies = append(rep.Anomalies, issuerAnomalies(cfg, st, rep.Issuers)...)
+	rep.Anomalies = append(rep.Anomalies, spikeAnomalies(cfg, st, rep)...)
+	for _, key := range sortedClusters(clusters) {
+		if count := clusters[key]; count >= cfg.clusterMin {
+			rep.Anomalies = append(rep.Anomalies, anomaly{
+				Kind:    anomalyFailureCluster,
+				Subject: key[0],
+				Count:   count,
+				Detail:  fmt.Sprintf("%d receipts from one issuer failed the %s check", count, key[1]),
+			})
+		}
+	}
+
+	// Cross-check the log
+	if cfg.log != nil {
+		logRep, anomalies, err := auditLog(cfg, st, receipts, now)
+		if err != nil {
+			rep.Anomalies = append(rep.Anomalies, anomaly{Kind: anomalyLogUnavailable, Subject: cfg.log.URL, Detail: err.Error()})
+		} else {
+			rep.Log = logRep
+			rep.Anomalies = append(rep.Anomalies, anomalies...)
+		}
+	}
+	if rep.Anomalies == nil {
+		rep.Anomalies = []anomaly{}
+	}
+
+	signature, err := cfg.signer.Sign(rep.signingPayload())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign report: %w", err)
+	}
+	rep.Signature = base64.StdEncoding.EncodeToString(signature)
+
+	st.LastRun = rep.To
+	if err := saveState(cfg.statePath, st); err != nil {
+		return nil, fmt.Errorf("failed to save state: %w", err)
+	}
+	return rep, nil
+}
+
+// failedCheck names the first check a failed verification did not pass
+func failedCheck(result *tecp.VerificationResult) string {
+	for _, check := range result.Checks {
+		if !check.Passed() {
+			return check.Name
+		}
+	}
+	return "required"
+}
+
+// issuerAnomalies flags signing keys outside the expected issuers or, when
+// none are configured, keys not seen by earlier runs. The first run only
+// learns the keys
+func issuerAnomalies(cfg *config, st *state, issuers map[string]int) []anomaly {
+	var anomalies []anomaly
+	firstRun := st.LastRun == 0
+	for _, key := range sortedKeys(issuers) {
+		known := st.Issuers[key] || firstRun
+		if cfg.issuers != nil {
+			known = false
+			for _, issuer := range cfg.issuers {
+				if base64.StdEncoding.EncodeToString(issuer) == key {
+					known = true
+					break
+				}
+			}
+		}
+		if !known {
+			anomalies = append(anomalies, anomaly{
+				Kind:    anomalyUnknownIssuer,
+				Subject: key,
+				Count:   issuers[key],
+				Detail:  fmt.Sprintf("%d receipts signed by an unknown key", issuers[key]),
+			})
+		}
+		st.Issuers[key] = true
+	}
+	return anomalies
+}
+
+// spikeAnomalies flags policies claimed at a rate well above their
+// baseline, then folds this run into the baseline
+func spikeAnomalies(cfg *config, st *state, rep *report) []anomaly {
+	hours := float64(rep.To-rep.From) / float64(time.Hour.Milliseconds())
+	if hours <= 0 {
+		return nil
+	}
+
+	var anomalies []anomaly
+	policies := make(map[string]bool)
+	for id := range rep.Policies {
+		policies[id] = true
+	}
+	for id := range st.Baseline {
+		policies[id] = true
+	}
+	for _, id := range sortedKeys(policies) {
+		count := rep.Policies[id]
+		rate := float64(count) / hours
+		baseline, ok := st.Baseline[id]
+		if ok && baseline > 0 && count >= cfg.spikeMin && rate > cfg.spikeFactor*baseline {
+			anomalies = append(anomalies, anomaly{
+				Kind:    anomalyPolicySpike,
+				Subject: id,
+				Count:   count,
+				Detail:  fmt.Sprintf("%.1f receipts/hour against a baseline of %.1f", rate, baseline),
+			})
+		}
+		if ok {
+			st.Baseline[id] = 0.7*baseline + 0.3*rate
+		} else {
+			st.Baseline[id] = rate
+		}
+	}
+	return anomalies
+}
+
+// auditLog downloads the log, checks that it extends the tree seen by the
+// last run and that receipts past the merge delay are included. Every page
+// of leaves is verified against a signed tree head as it is downloaded
+func auditLog(cfg *config, st *state, receipts []*tecp.Receipt, now time.Time) (*logAudit, []anomaly, error) {
+	var tree merkle.Tree
+	leaves := make(map[string]bool)
+	_, err := cfg.log.WalkLeaves(0, func(index uint64, leafHash []byte) error {
+		tree.Append(leafHash)
+		leaves[hex.EncodeToString(leafHash)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rep := &logAudit{URL: cfg.log.URL, TreeSize: tree.Size(), Root: hex.EncodeToString(tree.Root())}
+	var anomalies []anomaly
+
+	// The previous tree must be a prefix of the current one
+	if st.LogSize > 0 {
+		root, err := tree.RootAt(st.LogSize)
+		switch {
+		case err != nil:
+			anomalies = append(anomalies, anomaly{
+				Kind:    anomalyLogInconsistent,
+				Subject: cfg.log.URL,
+				Detail:  fmt.Sprintf("log shrank from %d to %d leaves", st.LogSize, tree.Size()),
+			})
+		case hex.EncodeToString(root) != st.LogRoot:
+			anomalies = append(anomalies, anomaly{
+				Kind:    anomalyLogInconsistent,
+				Subject: cfg.log.URL,
+				Detail:  fmt.Sprintf("tree of %d leaves no longer matches the root seen by the last run", st.LogSize),
+			})
+		}
+	}
+	if len(anomalies) == 0 {
+		st.LogSize, st.LogRoot = rep.TreeSize, rep.Root
+	}
+
+	// Receipts past the merge delay must have been logged
+	deadline := now.Add(-cfg.mmd).UnixMilli()
+	for _, receipt := range receipts {
+		if receipt.Timestamp > deadline {
+			continue
+		}
+		rep.Checked++
+		hash, err := tecp.ReceiptHash(receipt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !leaves[hex.EncodeToString(merkle.LeafHash(hash))] {
+			rep.Missing++
+		}
+	}
+	if rep.Missing > 0 {
+		anomalies = append(anomalies, anomaly{
+			Kind:    anomalyMissingFromLog,
+			Subject: cfg.log.URL,
+			Count:   rep.Missing,
+			Detail:  fmt.Sprintf("%d of %d receipts past the merge delay are not in the log", rep.Missing, rep.Checked),
+		})
+	}
+	return rep, anomalies, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedClusters(clusters map[[2]string]int) [][2]string {
+	keys := make([][2]string, 0, len(clusters))
+	for key := range clusters {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
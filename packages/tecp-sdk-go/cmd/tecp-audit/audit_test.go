@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+func testKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testConfig(t *testing.T, receipts store.ReceiptStore) *config {
+	t.Helper()
+	return &config{
+		store:       receipts,
+		verifier:    tecp.NewClient(),
+		options:     tecp.VerifyOptions{DisabledChecks: []string{tecp.CheckTimestamp}},
+		lookback:    time.Hour,
+		spikeFactor: 3,
+		spikeMin:    20,
+		clusterMin:  2,
+		keyID:       "audit-test",
+		signer:      tecp.NewEd25519Signer(testKey(t)),
+		statePath:   filepath.Join(t.TempDir(), "state.json"),
+	}
+}
+
+// issue creates n receipts signed by key claiming policies
+func issue(t *testing.T, receipts store.ReceiptStore, key ed25519.PrivateKey, n int, policies ...string) []*tecp.Receipt {
+	t.Helper()
+	client := tecp.NewClient(tecp.WithSigner(key))
+	var issued []*tecp.Receipt
+	for i := 0; i < n; i++ {
+		receipt, err := client.CreateReceipt(tecp.CreateReceiptOptions{
+			Input:    []byte{byte(i)},
+			Output:   []byte("out"),
+			CodeRef:  "git:abc",
+			Policies: policies,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := receipts.Put(receipt); err != nil {
+			t.Fatal(err)
+		}
+		issued = append(issued, receipt)
+	}
+	return issued
+}
+
+func anomalyKinds(anomalies []anomaly) []string {
+	kinds := make([]string, 0, len(anomalies))
+	for _, a := range anomalies {
+		kinds = append(kinds, a.Kind)
+	}
+	return kinds
+}
+
+func TestIssuerAnomalies(t *testing.T) {
+	known := testKey(t).Public().(ed25519.PublicKey)
+	other := testKey(t).Public().(ed25519.PublicKey)
+	knownID := base64.StdEncoding.EncodeToString(known)
+	otherID := base64.StdEncoding.EncodeToString(other)
+
+	tests := []struct {
+		name     string
+		lastRun  int64
+		seen     []string
+		expected []ed25519.PublicKey
+		flagged  []string
+	}{
+		{"first run learns keys", 0, nil, nil, nil},
+		{"seen keys", 1, []string{knownID, otherID}, nil, nil},
+		{"new key", 1, []string{knownID}, nil, []string{otherID}},
+		{"expected keys", 1, nil, []ed25519.PublicKey{known, other}, nil},
+		{"unexpected key", 1, nil, []ed25519.PublicKey{known}, []string{otherID}},
+		{"unexpected key on first run", 0, nil, []ed25519.PublicKey{known}, []string{otherID}},
+		{"expected keys override seen keys", 1, []string{knownID, otherID}, []ed25519.PublicKey{known}, []string{otherID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &state{LastRun: tt.lastRun, Issuers: make(map[string]bool)}
+			for _, id := range tt.seen {
+				st.Issuers[id] = true
+			}
+			cfg := &config{issuers: tt.expected}
+			anomalies := issuerAnomalies(cfg, st, map[string]int{knownID: 3, otherID: 2})
+			if len(anomalies) != len(tt.flagged) {
+				t.Fatalf("got %d anomalies, want %d", len(anomalies), len(tt.flagged))
+			}
+			for i, a := range anomalies {
+				if a.Kind != anomalyUnknownIssuer || a.Subject != tt.flagged[i] {
+					t.Errorf("anomaly %d = %s %s, want unknown issuer %s", i, a.Kind, a.Subject, tt.flagged[i])
+				}
+			}
+			if !st.Issuers[knownID] || !st.Issuers[otherID] {
+				t.Error("keys were not recorded in the state")
+			}
+		})
+	}
+}
+
+func TestSpikeAnomalies(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline map[string]float64
+		count    int
+		spike    bool
+		next     float64
+	}{
+		{"no baseline", nil, 100, false, 100},
+		{"at baseline", map[string]float64{"p": 100}, 100, false, 100},
+		{"under factor", map[string]float64{"p": 40}, 100, false, 58},
+		{"over factor", map[string]float64{"p": 10}, 100, true, 37},
+		{"over factor under minimum", map[string]float64{"p": 1}, 10, false, 3.7},
+		{"quiet policy decays", map[string]float64{"p": 10}, 0, false, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &state{Baseline: make(map[string]float64)}
+			for id, rate := range tt.baseline {
+				st.Baseline[id] = rate
+			}
+			rep := &report{From: 0, To: time.Hour.Milliseconds(), Policies: map[string]int{}}
+			if tt.count > 0 {
+				rep.Policies["p"] = tt.count
+			}
+			anomalies := spikeAnomalies(&config{spikeFactor: 3, spikeMin: 20}, st, rep)
+			if spike := len(anomalies) == 1 && anomalies[0].Kind == anomalyPolicySpike; spike != tt.spike || len(anomalies) > 1 {
+				t.Errorf("anomalies = %v, want spike %v", anomalyKinds(anomalies), tt.spike)
+			}
+			if got := st.Baseline["p"]; got < tt.next-1e-9 || got > tt.next+1e-9 {
+				t.Errorf("baseline = %v, want %v", got, tt.next)
+			}
+		})
+	}
+}
+
+func TestAuditSignsReport(t *testing.T) {
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p256Signer, err := tecp.NewECDSASigner(p256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		signer tecp.Signer
+		alg    tecp.Algorithm
+	}{
+		{"Ed25519", tecp.NewEd25519Signer(testKey(t)), ""},
+		{"P-256", p256Signer, tecp.AlgES256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipts := store.NewMemoryStore()
+			cfg := testConfig(t, receipts)
+			cfg.signer = tt.signer
+			issue(t, receipts, testKey(t), 3, "eu_region")
+			for _, receipt := range issue(t, store.NewMemoryStore(), testKey(t), 2) {
+				receipt.CodeRef = "git:forged"
+				if _, err := receipts.Put(receipt); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			now := time.Now().Add(time.Second)
+			rep, err := audit(cfg, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rep.Receipts != 5 || rep.Failed != 2 || rep.Policies["eu_region"] != 3 || len(rep.Issuers) != 2 {
+				t.Errorf("report counts = %d receipts, %d failed, %v, %d issuers", rep.Receipts, rep.Failed, rep.Policies, len(rep.Issuers))
+			}
+			if kinds := anomalyKinds(rep.Anomalies); len(kinds) != 1 || kinds[0] != anomalyFailureCluster {
+				t.Errorf("anomalies = %v, want one failure cluster", kinds)
+			}
+
+			if rep.Algorithm != tt.alg {
+				t.Fatalf("report algorithm = %q, want %q", rep.Algorithm, tt.alg)
+			}
+			signature, err := base64.StdEncoding.DecodeString(rep.Signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			alg := tt.signer.Algorithm()
+			if err := tecp.VerifySignature(alg, tt.signer.PublicKey(), rep.signingPayload(), signature); err != nil {
+				t.Fatalf("report signature does not verify: %v", err)
+			}
+			rep.Failed = 0
+			if tecp.VerifySignature(alg, tt.signer.PublicKey(), rep.signingPayload(), signature) == nil {
+				t.Fatal("altered report still verifies")
+			}
+
+			// The next run starts where this one ended
+			next, err := audit(cfg, now.Add(time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next.From != rep.To || next.Receipts != 0 {
+				t.Errorf("next run covers [%d, %d] with %d receipts, want to start at %d", next.From, next.To, next.Receipts, rep.To)
+			}
+		})
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	server, err := tecplog.NewServer(tecplog.Config{PrivateKey: testKey(t), KeyID: "log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	receipts := store.NewMemoryStore()
+	cfg := testConfig(t, receipts)
+	cfg.log = &tecplog.Client{URL: ts.URL}
+	issued := issue(t, receipts, testKey(t), 4)
+	var prefix merkle.Tree
+	for i, receipt := range issued[:3] {
+		hash, err := tecp.ReceiptHash(receipt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.Append(hash); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			prefix.Append(merkle.LeafHash(hash))
+		}
+	}
+	prefixRoot := hex.EncodeToString(prefix.Root())
+
+	now := time.Now().Add(time.Second)
+	tests := []struct {
+		name    string
+		mmd     time.Duration
+		alter   func(st *state)
+		checked int
+		missing int
+		kinds   []string
+	}{
+		{"within merge delay", time.Hour, nil, 0, 0, nil},
+		{"missing receipt", 0, nil, 4, 1, []string{anomalyMissingFromLog}},
+		{"log extends last run", time.Hour, func(st *state) {
+			st.LogSize, st.LogRoot = 1, prefixRoot
+		}, 0, 0, nil},
+		{"log rewritten", time.Hour, func(st *state) {
+			st.LogSize, st.LogRoot = 2, "00"
+		}, 0, 0, []string{anomalyLogInconsistent}},
+		{"log shrank", time.Hour, func(st *state) {
+			st.LogSize, st.LogRoot = 10, "00"
+		}, 0, 0, []string{anomalyLogInconsistent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.mmd = tt.mmd
+			st := &state{}
+			if tt.alter != nil {
+				tt.alter(st)
+			}
+			rep, anomalies, err := auditLog(cfg, st, issued, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rep.TreeSize != 3 || rep.Checked != tt.checked || rep.Missing != tt.missing {
+				t.Errorf("log audit = size %d, %d checked, %d missing", rep.TreeSize, rep.Checked, rep.Missing)
+			}
+			kinds := anomalyKinds(anomalies)
+			if len(kinds) != len(tt.kinds) {
+				t.Fatalf("anomalies = %v, want %v", kinds, tt.kinds)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.kinds[i] {
+					t.Errorf("anomalies = %v, want %v", kinds, tt.kinds)
+				}
+			}
+			if len(tt.kinds) == 0 && (st.LogSize != rep.TreeSize || st.LogRoot != rep.Root) {
+				t.Error("state was not advanced to the current tree")
+			}
+			if len(tt.kinds) > 0 && tt.kinds[0] == anomalyLogInconsistent && st.LogSize == rep.TreeSize {
+				t.Error("state advanced past an inconsistent log")
+			}
+		})
+	}
+}
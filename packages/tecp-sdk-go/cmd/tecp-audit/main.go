@@ -0,0 +1,163 @@
+// Command tecp-audit continuously audits archived receipts.
+//
+// Each run verifies the receipts archived since the previous run, cross-checks
+// them against a transparency log, and flags anomalies: policies claimed far
+// more often than their baseline, receipts from unknown issuers, clusters of
+// verification failures, a log that rewrote its history, and receipts the
+// log never included. The outcome is written as a signed JSON report, one
+// line per run. State carried between runs lives in the -state file.
+//
+//	tecp-audit -store /var/lib/tecp/receipts -key auditor.pem \
+//		-log https://log.example.com -interval 1h -out audit.jsonl
+//
+// Run once, tecp-audit exits with status 3 when it found anomalies.
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+func main() {
+	var (
+		storeDir    = flag.String("store", "", "receipt archive directory")
+		logURL      = flag.String("log", "", "transparency log URL to cross-check")
+		logKey      = flag.String("log-key", "", "base64 Ed25519 key the log signs tree heads with")
+		keyFile     = flag.String("key", "", "PEM private key signing the reports: Ed25519, P-256, P-384 or secp256k1")
+		keyID       = flag.String("kid", "tecp-audit", "key ID recorded in the reports")
+		issuersFile = flag.String("issuers", "", "JWKS of the expected issuer keys")
+		profile     = flag.String("profile", string(tecp.ProfileV01), "verification profile")
+		statePath   = flag.String("state", "tecp-audit-state.json", "state file carried between runs")
+		out         = flag.String("out", "-", "file the reports are appended to")
+		interval    = flag.Duration("interval", 0, "time between runs; 0 runs once")
+		lookback    = flag.Duration("lookback", 24*time.Hour, "receipts audited by the first run")
+		mmd         = flag.Duration("mmd", tecplog.DefaultMaxMergeDelay, "time receipts may take to appear in the log")
+		spikeFactor = flag.Float64("spike-factor", 3, "policy rate over baseline flagged as a spike")
+		spikeMin    = flag.Int("spike-min", 20, "fewest receipts of a policy flagged as a spike")
+		clusterMin  = flag.Int("cluster-min", 5, "fewest failures of one issuer and check flagged as a cluster")
+	)
+	flag.Parse()
+
+	if *storeDir == "" || *keyFile == "" {
+		log.Fatal("tecp-audit: -store and -key are required")
+	}
+	archive, err := store.NewDirStore(*storeDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	signer, err := tecp.LoadSigner(*keyFile)
+	if err != nil {
+		log.Fatalf("tecp-audit: %v", err)
+	}
+
+	cfg := &config{
+		store:    archive,
+		verifier: tecp.NewClient(),
+		options: tecp.VerifyOptions{
+			Profile: tecp.Profile(*profile),
+			// Archived receipts are older than any freshness limit
+			DisabledChecks: []string{tecp.CheckTimestamp},
+		},
+		lookback:    *lookback,
+		mmd:         *mmd,
+		spikeFactor: *spikeFactor,
+		spikeMin:    *spikeMin,
+		clusterMin:  *clusterMin,
+		keyID:       *keyID,
+		signer:      signer,
+		statePath:   *statePath,
+	}
+	if *logURL != "" {
+		cfg.log = &tecplog.Client{URL: *logURL}
+		if *logKey != "" {
+			publicKey, err := base64.StdEncoding.DecodeString(*logKey)
+			if err != nil || len(publicKey) != ed25519.PublicKeySize {
+				log.Fatal("tecp-audit: invalid -log-key")
+			}
+			cfg.log.PublicKey = publicKey
+		}
+	}
+	if *issuersFile != "" {
+		if cfg.issuers, err = loadIssuers(*issuersFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.OpenFile(*out, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *interval <= 0 {
+		rep, err := audit(cfg, time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := json.NewEncoder(w).Encode(rep); err != nil {
+			log.Fatal(err)
+		}
+		if len(rep.Anomalies) > 0 {
+			os.Exit(3)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		rep, err := audit(cfg, time.Now())
+		if err != nil {
+			log.Printf("tecp-audit: %v", err)
+		} else if err := json.NewEncoder(w).Encode(rep); err != nil {
+			log.Printf("tecp-audit: failed to write report: %v", err)
+		} else {
+			log.Printf("tecp-audit: %d receipts, %d failed, %d anomalies", rep.Receipts, rep.Failed, len(rep.Anomalies))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadIssuers reads the expected issuer keys from a JWKS file
+func loadIssuers(path string) ([]ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jwks tecp.JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("tecp-audit: invalid JWKS %s: %w", path, err)
+	}
+	keys, err := jwks.PublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("tecp-audit: %s: %w", path, err)
+	}
+	issuers := make([]ed25519.PublicKey, 0, len(keys))
+	for _, key := range keys {
+		issuers = append(issuers, key)
+	}
+	return issuers, nil
+}
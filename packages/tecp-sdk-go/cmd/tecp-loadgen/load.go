@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+// Log submission modes
+const (
+	modePromise = "srt"
+	modeEntries = "entries"
+)
+
+// Stages timed by the load generator
+const (
+	stageIssue  = "issue"
+	stageSubmit = "submit"
+	stageVerify = "verify"
+)
+
+// maxPayloadSize bounds generated payloads
+const maxPayloadSize = 64 << 20
+
+// config is the load configuration taken from flags
+type config struct {
+	issuer   *tecp.Client
+	log      *tecplog.Client
+	rate     float64
+	duration time.Duration
+	workers  int
+	mode     string
+	verify   bool
+	policies []string
+	seed     int64
+
+	inputSize  sizeDist
+	outputSize sizeDist
+
+	// replay, when set, are receipts submitted in turn instead of issuing
+	// new ones
+	replay []*tecp.Receipt
+}
+
+// sizeDist draws payload sizes
+type sizeDist func(r *rand.Rand) int
+
+// parseSizeDist parses fixed:N, uniform:MIN,MAX or lognormal:MEDIAN,SIGMA
+func parseSizeDist(spec string) (sizeDist, error) {
+	kind, params, _ := strings.Cut(spec, ":")
+	var values []float64
+	for _, p := range strings.Split(params, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("tecp-loadgen: invalid size distribution %q", spec)
+		}
+		values = append(values, v)
+	}
+
+	clamp := func(v float64) int {
+		return int(math.Max(0, math.Min(v, maxPayloadSize)))
+	}
+	switch {
+	case kind == "fixed" && len(values) == 1:
+		return func(*rand.Rand) int { return clamp(values[0]) }, nil
+	case kind == "uniform" && len(values) == 2 && values[0] <= values[1]:
+		return func(r *rand.Rand) int { return clamp(values[0] + r.Float64()*(values[1]-values[0])) }, nil
+	case kind == "lognormal" && len(values) == 2:
+		// Payload sizes are heavy tailed; most are near the median
+		return func(r *rand.Rand) int { return clamp(values[0] * math.Exp(values[1]*r.NormFloat64())) }, nil
+	}
+	return nil, fmt.Errorf("tecp-loadgen: invalid size distribution %q", spec)
+}
+
+// summary is the outcome of a run
+type summary struct {
+	Duration     string                   `json:"duration"`
+	TargetRate   float64                  `json:"target_rate"`
+	AchievedRate float64                  `json:"achieved_rate"`
+	Scheduled    int                      `json:"scheduled"`
+	Completed    int                      `json:"completed"`
+	Dropped      int                      `json:"dropped"`
+	InputBytes   float64                  `json:"mean_input_bytes,omitempty"`
+	OutputBytes  float64                  `json:"mean_output_bytes,omitempty"`
+	ReceiptBytes float64                  `json:"mean_receipt_bytes"`
+	Stages       map[string]*stageSummary `json:"stages"`
+}
+
+// stageSummary holds one stage's latency percentiles, in milliseconds
+type stageSummary struct {
+	Count      int     `json:"count"`
+	Errors     int     `json:"errors"`
+	Mean       float64 `json:"mean_ms"`
+	P50        float64 `json:"p50_ms"`
+	P90        float64 `json:"p90_ms"`
+	P99        float64 `json:"p99_ms"`
+	P999       float64 `json:"p999_ms"`
+	Max        float64 `json:"max_ms"`
+	FirstError string  `json:"first_error,omitempty"`
+}
+
+// recorder collects the latencies of every stage
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	first     map[string]string
+	completed int
+	input     int64
+	output    int64
+	receipt   int64
+}
+
+func (r *recorder) record(stage string, elapsed time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors[stage]++
+		if r.first[stage] == "" {
+			r.first[stage] = err.Error()
+		}
+		return
+	}
+	r.latencies[stage] = append(r.latencies[stage], elapsed)
+}
+
+// run schedules receipts at the configured rate for the configured duration.
+// Scheduling is open loop: when every worker is busy, the receipt is
+// dropped and counted rather than delayed, so a saturated deployment shows
+// up as dropped load instead of hidden queueing
+func run(cfg *config) *summary {
+	rec := &recorder{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+		first:     make(map[string]string),
+	}
+	seed := cfg.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	jobs := make(chan int, cfg.workers)
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.workers; w++ {
+		wg.Add(1)
+		go func(r *rand.Rand) {
+			defer wg.Done()
+			for i := range jobs {
+				work(cfg, rec, r, i)
+			}
+		}(rand.New(rand.NewSource(seed + int64(w))))
+	}
+
+	s := &summary{TargetRate: cfg.rate, Stages: make(map[string]*stageSummary)}
+	interval := time.Duration(float64(time.Second) / cfg.rate)
+	start := time.Now()
+	deadline := start.Add(cfg.duration)
+	for next := start; next.Before(deadline); next = next.Add(interval) {
+		time.Sleep(time.Until(next))
+		select {
+		case jobs <- s.Scheduled:
+		default:
+			s.Dropped++
+		}
+		s.Scheduled++
+	}
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	s.Duration = elapsed.Round(time.Millisecond).String()
+	s.Completed = rec.completed
+	s.AchievedRate = float64(rec.completed) / elapsed.Seconds()
+	if rec.completed > 0 {
+		s.InputBytes = float64(rec.input) / float64(rec.completed)
+		s.OutputBytes = float64(rec.output) / float64(rec.completed)
+		s.ReceiptBytes = float64(rec.receipt) / float64(rec.completed)
+	}
+	for _, stage := range []string{stageIssue, stageSubmit, stageVerify} {
+		latencies, errors := rec.latencies[stage], rec.errors[stage]
+		if len(latencies) == 0 && errors == 0 {
+			continue
+		}
+		s.Stages[stage] = summarize(latencies, errors, rec.first[stage])
+	}
+	return s
+}
+
+// work issues or replays one receipt and runs it through every stage
+func work(cfg *config, rec *recorder, r *rand.Rand, i int) {
+	var receipt *tecp.Receipt
+	var inputSize, outputSize int
+	if cfg.replay != nil {
+		receipt = cfg.replay[i%len(cfg.replay)]
+	} else {
+		inputSize, outputSize = cfg.inputSize(r), cfg.outputSize(r)
+		input, output := make([]byte, inputSize), make([]byte, outputSize)
+		r.Read(input)
+		r.Read(output)
+
+		began := time.Now()
+		var err error
+		receipt, err = cfg.issuer.CreateReceipt(tecp.CreateReceiptOptions{Input: input, Output: output, Policies: cfg.policies})
+		rec.record(stageIssue, time.Since(began), err)
+		if err != nil {
+			return
+		}
+	}
+
+	if cfg.log != nil {
+		began := time.Now()
+		err := submit(cfg, receipt)
+		rec.record(stageSubmit, time.Since(began), err)
+	}
+
+	if cfg.verify {
+		began := time.Now()
+		result, err := cfg.issuer.VerifyReceipt(receipt, tecp.VerifyOptions{DisabledChecks: []string{tecp.CheckTimestamp}})
+		if err == nil && !result.Valid {
+			err = fmt.Errorf("receipt invalid: %s", strings.Join(result.Errors, "; "))
+		}
+		rec.record(stageVerify, time.Since(began), err)
+	}
+
+	data, _ := receipt.ToJSON()
+	rec.mu.Lock()
+	rec.completed++
+	rec.input += int64(inputSize)
+	rec.output += int64(outputSize)
+	rec.receipt += int64(len(data))
+	rec.mu.Unlock()
+}
+
+// submit sends a receipt's hash to the log
+func submit(cfg *config, receipt *tecp.Receipt) error {
+	hash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return err
+	}
+	if cfg.mode == modeEntries {
+		_, err = cfg.log.Submit(hash)
+	} else {
+		_, err = cfg.log.Promise(hash)
+	}
+	return err
+}
+
+// summarize computes latency percentiles
+func summarize(latencies []time.Duration, errors int, firstError string) *stageSummary {
+	s := &stageSummary{Count: len(latencies), Errors: errors, FirstError: firstError}
+	if len(latencies) == 0 {
+		return s
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	percentile := func(p float64) float64 {
+		return ms(latencies[int(math.Ceil(p*float64(len(latencies))))-1])
+	}
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	s.Mean = ms(total / time.Duration(len(latencies)))
+	s.P50, s.P90, s.P99, s.P999 = percentile(0.5), percentile(0.9), percentile(0.99), percentile(0.999)
+	s.Max = ms(latencies[len(latencies)-1])
+	return s
+}
@@ -0,0 +1,112 @@
+// Command tecp-loadgen load tests receipt issuance, log submission and
+// verification.
+//
+// Receipts are issued at a fixed rate with input and output sizes drawn
+// from configurable distributions, or replayed from a receipt archive, and
+// submitted to a transparency log. A JSON summary of the achieved rate,
+// errors and latency percentiles of each stage is printed at the end:
+//
+//	tecp-loadgen -log https://log.example.com -rate 500 -duration 5m \
+//		-input-size lognormal:2048,1.2 -output-size lognormal:8192,1.5 -verify
+//
+// Size distributions are fixed:N, uniform:MIN,MAX or lognormal:MEDIAN,SIGMA,
+// in bytes. Without -log, only issuance and verification are measured.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+func main() {
+	var (
+		logURL     = flag.String("log", "", "transparency log URL to submit receipts to")
+		logKey     = flag.String("log-key", "", "base64 Ed25519 key the log signs with")
+		apiKey     = flag.String("api-key", "", "API key for logs that require one")
+		mode       = flag.String("mode", modePromise, "log submission: srt (promise) or entries (inclusion proof)")
+		rate       = flag.Float64("rate", 100, "receipts per second")
+		duration   = flag.Duration("duration", 30*time.Second, "length of the run")
+		workers    = flag.Int("workers", 16, "concurrent workers")
+		inputSize  = flag.String("input-size", "lognormal:2048,1.0", "input size distribution")
+		outputSize = flag.String("output-size", "lognormal:4096,1.2", "output size distribution")
+		policies   = flag.String("policies", "no_retention", "comma-separated policies claimed by generated receipts")
+		replay     = flag.String("replay", "", "receipt archive directory to replay instead of generating receipts")
+		verify     = flag.Bool("verify", false, "also verify every receipt")
+		seed       = flag.Int64("seed", 0, "payload generator seed; 0 picks one")
+	)
+	flag.Parse()
+
+	if *rate <= 0 || *workers <= 0 || *duration <= 0 {
+		log.Fatal("tecp-loadgen: -rate, -workers and -duration must be positive")
+	}
+	if *mode != modePromise && *mode != modeEntries {
+		log.Fatalf("tecp-loadgen: unknown -mode %q", *mode)
+	}
+
+	cfg := &config{
+		rate:     *rate,
+		duration: *duration,
+		workers:  *workers,
+		mode:     *mode,
+		verify:   *verify,
+		policies: strings.Split(*policies, ","),
+		seed:     *seed,
+	}
+	var err error
+	if cfg.inputSize, err = parseSizeDist(*inputSize); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.outputSize, err = parseSizeDist(*outputSize); err != nil {
+		log.Fatal(err)
+	}
+
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.issuer = tecp.NewClient(tecp.WithSigner(key))
+
+	if *logURL != "" {
+		cfg.log = &tecplog.Client{URL: *logURL, APIKey: *apiKey}
+		if *logKey != "" {
+			publicKey, err := base64.StdEncoding.DecodeString(*logKey)
+			if err != nil || len(publicKey) != ed25519.PublicKeySize {
+				log.Fatal("tecp-loadgen: invalid -log-key")
+			}
+			cfg.log.PublicKey = publicKey
+		}
+	}
+
+	if *replay != "" {
+		archive, err := store.NewDirStore(*replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := archive.Walk(func(key string, receipt *tecp.Receipt) error {
+			cfg.replay = append(cfg.replay, receipt)
+			return nil
+		}); err != nil {
+			log.Fatal(err)
+		}
+		if len(cfg.replay) == 0 {
+			log.Fatalf("tecp-loadgen: %s holds no receipts", *replay)
+		}
+	}
+
+	summary := run(cfg)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+// runSTH prints the log's verified current tree head
+func runSTH(client *tecplog.Client, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("tecp-log: sth takes no arguments")
+	}
+	sth, err := client.TreeHead()
+	if err != nil {
+		return err
+	}
+	return printJSON(sth)
+}
+
+// runProve prints the verified inclusion proof of a leaf
+func runProve(client *tecplog.Client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("tecp-log: usage: prove <leaf-hash>")
+	}
+	entry, err := hex.DecodeString(args[0])
+	if err != nil || len(entry) != merkle.HashSize {
+		return fmt.Errorf("tecp-log: leaf hash must be %d hex bytes", merkle.HashSize)
+	}
+	response, err := client.Proof(entry)
+	if err != nil {
+		return err
+	}
+	return printJSON(response)
+}
+
+// runConsistency prints the consistency proof between two tree sizes. The
+// second size defaults to the current tree; with -root, the root of the
+// first tree, the proof is verified against the current tree head
+func runConsistency(client *tecplog.Client, args []string) error {
+	flags := flag.NewFlagSet("consistency", flag.ExitOnError)
+	root := flags.String("root", "", "hex root of the tree of size n, to verify the proof against the current tree head")
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return fmt.Errorf("tecp-log: usage: consistency [-root hex] <n> [<m>]")
+	}
+
+	sth, err := client.TreeHead()
+	if err != nil {
+		return err
+	}
+	first, err := strconv.ParseUint(flags.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("tecp-log: invalid tree size %q", flags.Arg(0))
+	}
+	second := sth.Size
+	if flags.NArg() == 2 {
+		if second, err = strconv.ParseUint(flags.Arg(1), 10, 64); err != nil {
+			return fmt.Errorf("tecp-log: invalid tree size %q", flags.Arg(1))
+		}
+	}
+
+	proof, err := client.ConsistencyProof(first, second)
+	if err != nil {
+		return err
+	}
+	output := struct {
+		tecplog.ConsistencyResponse
+		Verified bool `json:"verified"`
+	}{ConsistencyResponse: tecplog.ConsistencyResponse{First: first, Second: second, Proof: hexAll(proof)}}
+
+	if *root != "" {
+		if second != sth.Size {
+			return fmt.Errorf("tecp-log: -root verifies against the current tree of size %d", sth.Size)
+		}
+		firstRoot, err := hex.DecodeString(*root)
+		if err != nil {
+			return fmt.Errorf("tecp-log: invalid -root")
+		}
+		currentRoot, err := hex.DecodeString(sth.Root)
+		if err != nil {
+			return fmt.Errorf("tecp-log: invalid tree head root")
+		}
+		if err := merkle.VerifyConsistency(first, second, proof, firstRoot, currentRoot); err != nil {
+			return fmt.Errorf("tecp-log: consistency proof verification failed: %w", err)
+		}
+		output.Verified = true
+	}
+	return printJSON(output)
+}
+
+// runTail prints new leaves as "index leaf-hash" lines as the log grows,
+// checking that every new tree head extends the previous one
+func runTail(client *tecplog.Client, args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	from := flags.Int64("from", -1, "first leaf index to print; defaults to the current tree size")
+	interval := flags.Duration("interval", 5*time.Second, "time between tree head polls")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var last *tecp.SignedTreeHead
+	next := uint64(0)
+	if *from >= 0 {
+		next = uint64(*from)
+	}
+	for {
+		sth, err := client.TreeHead()
+		if err != nil {
+			return err
+		}
+		if last == nil && *from < 0 {
+			next = sth.Size
+		}
+		if last != nil && (sth.Size != last.Size || sth.Root != last.Root) {
+			if err := client.CheckConsistency(last, sth); err != nil {
+				return err
+			}
+		}
+		last = sth
+
+		if err := printLeaves(client, next, sth.Size); err != nil {
+			return err
+		}
+		next = max(next, sth.Size)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// printLeaves prints the verified leaves in [start, end)
+func printLeaves(client *tecplog.Client, start, end uint64) error {
+	for start < end {
+		page, err := client.Leaves(start, min(end-start, tecplog.MaxLeavesPerPage))
+		if err != nil {
+			return err
+		}
+		for i, leaf := range page.Leaves {
+			if start+uint64(i) >= end {
+				break
+			}
+			fmt.Printf("%d %s\n", start+uint64(i), leaf)
+		}
+		start += uint64(len(page.Leaves))
+	}
+	return nil
+}
+
+// auditResult summarizes an audited range
+type auditResult struct {
+	Start      uint64               `json:"start"`
+	End        uint64               `json:"end"`
+	Leaves     uint64               `json:"leaves"`
+	Duplicates []uint64             `json:"duplicates,omitempty"`
+	STH        *tecp.SignedTreeHead `json:"sth"`
+}
+
+// runAudit downloads the leaves in a range with their range proofs, checks
+// that every page's tree head extends the first, and reports leaves
+// appended more than once. An empty end audits up to the current tree
+func runAudit(client *tecplog.Client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("tecp-log: usage: audit <start>:<end>")
+	}
+	startArg, endArg, ok := strings.Cut(args[0], ":")
+	if !ok {
+		return fmt.Errorf("tecp-log: range must be <start>:<end>")
+	}
+
+	sth, err := client.TreeHead()
+	if err != nil {
+		return err
+	}
+	start, err := strconv.ParseUint(startArg, 10, 64)
+	if err != nil {
+		return fmt.Errorf("tecp-log: invalid range start %q", startArg)
+	}
+	end := sth.Size
+	if endArg != "" {
+		if end, err = strconv.ParseUint(endArg, 10, 64); err != nil {
+			return fmt.Errorf("tecp-log: invalid range end %q", endArg)
+		}
+	}
+	if start > end || end > sth.Size {
+		return fmt.Errorf("tecp-log: range %d:%d is outside the tree of size %d", start, end, sth.Size)
+	}
+
+	result := auditResult{Start: start, End: end, STH: sth}
+	seen := make(map[string]bool)
+	for next := start; next < end; {
+		page, err := client.Leaves(next, min(end-next, tecplog.MaxLeavesPerPage))
+		if err != nil {
+			return err
+		}
+		if page.STH.Size != sth.Size || page.STH.Root != sth.Root {
+			if err := client.CheckConsistency(sth, page.STH); err != nil {
+				return err
+			}
+		}
+		for i, leaf := range page.Leaves {
+			index := next + uint64(i)
+			if index >= end {
+				break
+			}
+			if seen[leaf] {
+				result.Duplicates = append(result.Duplicates, index)
+			}
+			seen[leaf] = true
+			result.Leaves++
+		}
+		next += uint64(len(page.Leaves))
+	}
+
+	if err := printJSON(result); err != nil {
+		return err
+	}
+	if len(result.Duplicates) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// hexAll hex encodes every hash
+func hexAll(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, hash := range hashes {
+		encoded[i] = hex.EncodeToString(hash)
+	}
+	return encoded
+}
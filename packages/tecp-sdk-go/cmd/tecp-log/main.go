@@ -0,0 +1,110 @@
+// Command tecp-log inspects a TECP transparency log.
+//
+//	tecp-log [flags] sth                      fetch and verify the current tree head
+//	tecp-log [flags] prove <leaf-hash>        fetch and verify an inclusion proof
+//	tecp-log [flags] consistency <n> [<m>]    fetch a consistency proof between tree sizes
+//	tecp-log [flags] tail [-from n]           follow new entries
+//	tecp-log [flags] audit <start>:<end>      verify a range of leaves
+//
+// Leaf hashes are hex entry hashes, as returned by tecp.ReceiptHash. With
+// -log-key every tree head is checked against the log's key; without it
+// the key is fetched from the log's JWKS, which proves nothing about the
+// log's honesty and is reported on stderr.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+func main() {
+	var (
+		logURL = flag.String("log", "", "transparency log URL")
+		logKey = flag.String("log-key", "", "base64 Ed25519 key the log signs tree heads with")
+		keyID  = flag.String("kid", "", "key ID in the log's JWKS used when -log-key is not set")
+		tenant = flag.String("tenant", "", "tenant log hosted by the server")
+		apiKey = flag.String("api-key", "", "API key for logs that require one")
+	)
+	flag.Usage = usage
+	flag.Parse()
+
+	if *logURL == "" || flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	client := &tecplog.Client{URL: *logURL, Tenant: *tenant, APIKey: *apiKey}
+	if err := configureKey(client, *logKey, *keyID); err != nil {
+		log.Fatal(err)
+	}
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	var err error
+	switch command {
+	case "sth":
+		err = runSTH(client, args)
+	case "prove":
+		err = runProve(client, args)
+	case "consistency":
+		err = runConsistency(client, args)
+	case "tail":
+		err = runTail(client, args)
+	case "audit":
+		err = runAudit(client, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: tecp-log -log URL [flags] sth | prove <leaf-hash> | consistency <n> [<m>] | tail [-from n] | audit <start>:<end>\n\n")
+	flag.PrintDefaults()
+}
+
+// configureKey sets the key tree heads are verified with, from -log-key or
+// else from the log's own JWKS
+func configureKey(client *tecplog.Client, encoded, keyID string) error {
+	if encoded != "" {
+		publicKey, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("tecp-log: invalid -log-key")
+		}
+		client.PublicKey = publicKey
+		return nil
+	}
+
+	keys, err := client.Keys()
+	if err != nil {
+		return fmt.Errorf("tecp-log: failed to fetch log keys: %w", err)
+	}
+	switch {
+	case keyID != "":
+		client.PublicKey = keys[keyID]
+	case len(keys) == 1:
+		for _, key := range keys {
+			client.PublicKey = key
+		}
+	}
+	if client.PublicKey == nil {
+		return fmt.Errorf("tecp-log: select one of the log's %d keys with -kid", len(keys))
+	}
+	log.Printf("tecp-log: warning: using the key served by the log; pin it with -log-key")
+	return nil
+}
+
+// printJSON writes a value to stdout as indented JSON
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,265 @@
+// Command tecp-pack migrates audit evidence between systems as .tecp-pack
+// containers.
+//
+//	tecp-pack pack -store <dir> -key packer.pem [-bundle bundle.json] [-log URL -log-key <b64>] -out evidence.tecp-pack
+//	tecp-pack unpack -in evidence.tecp-pack -store <dir> [-packer <b64>] [-bundle-out bundle.json]
+//	tecp-pack verify -in evidence.tecp-pack [-packer <b64>] [-bundle-key <b64>] [-log-kid <id> -log-key <b64>]
+//
+// pack bundles every receipt archived in a store with the trust bundle
+// and, with -log, each receipt's inclusion proof and the log's current
+// tree head, in a pack signed with the packer's key. unpack checks a
+// pack's manifest and stores its receipts, with their detached proofs
+// embedded, in a store. verify checks a pack and its evidence and prints
+// the report as JSON; it exits with status 3 when problems were found.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/tecp-protocol/tecp-sdk-go/pack"
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "pack":
+		runPack(args)
+	case "unpack":
+		runUnpack(args)
+	case "verify":
+		runVerify(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: tecp-pack pack|unpack|verify [flags]\n")
+}
+
+// runPack packs an archive's receipts and the evidence verifying them
+func runPack(args []string) {
+	flags := flag.NewFlagSet("pack", flag.ExitOnError)
+	var (
+		storeDir   = flags.String("store", "", "receipt archive directory")
+		keyFile    = flags.String("key", "", "PEM private key signing the pack: Ed25519, P-256, P-384 or secp256k1")
+		bundleFile = flags.String("bundle", "", "trust bundle JSON the receipts verify under")
+		logURL     = flags.String("log", "", "transparency log URL the inclusion proofs are fetched from")
+		logKey     = flags.String("log-key", "", "base64 Ed25519 key the log signs tree heads with")
+		out        = flags.String("out", "", "pack file to write")
+	)
+	flags.Parse(args)
+
+	if *storeDir == "" || *keyFile == "" || *out == "" {
+		log.Fatal("tecp-pack: -store, -key and -out are required")
+	}
+	archive, err := store.NewDirStore(*storeDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	signer, err := tecp.LoadSigner(*keyFile)
+	if err != nil {
+		log.Fatalf("tecp-pack: %v", err)
+	}
+
+	contents := &pack.Contents{Proofs: make(map[string][]tecp.InclusionProof)}
+	if err := archive.Walk(func(_ string, receipt *tecp.Receipt) error {
+		contents.Receipts = append(contents.Receipts, receipt)
+		return nil
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if *bundleFile != "" {
+		data, err := os.ReadFile(*bundleFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		contents.TrustBundle = &tecp.TrustBundle{}
+		if err := json.Unmarshal(data, contents.TrustBundle); err != nil {
+			log.Fatalf("tecp-pack: invalid trust bundle %s: %v", *bundleFile, err)
+		}
+	}
+	if *logURL != "" {
+		client := &tecplog.Client{URL: *logURL}
+		if *logKey != "" {
+			client.PublicKey = decodeKey("log-key", *logKey)
+		}
+		for _, receipt := range contents.Receipts {
+			hash, err := tecp.ReceiptHash(receipt)
+			if err != nil {
+				log.Fatal(err)
+			}
+			response, err := client.Proof(hash)
+			if err != nil {
+				log.Printf("tecp-pack: no inclusion proof for %x: %v", hash, err)
+				continue
+			}
+			key, _ := store.Key(receipt)
+			contents.Proofs[key] = append(contents.Proofs[key], *response.InclusionProof(*logURL))
+		}
+		sth, err := client.TreeHead()
+		if err != nil {
+			log.Fatal(err)
+		}
+		contents.TreeHeads = append(contents.TreeHeads, *sth)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	manifest, err := pack.Pack(f, contents, signer)
+	if err != nil {
+		f.Close()
+		os.Remove(*out)
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("packed %d receipts in %d members into %s\n", manifest.Receipts, len(manifest.Files), *out)
+}
+
+// runUnpack stores a pack's receipts, with their detached proofs embedded
+func runUnpack(args []string) {
+	flags := flag.NewFlagSet("unpack", flag.ExitOnError)
+	var (
+		in        = flags.String("in", "", "pack file to read")
+		storeDir  = flags.String("store", "", "receipt archive directory the receipts are stored in")
+		packerKey = flags.String("packer", "", "base64 public key the pack must be signed with")
+		bundleOut = flags.String("bundle-out", "", "file the packed trust bundle is written to")
+	)
+	flags.Parse(args)
+
+	if *in == "" || *storeDir == "" {
+		log.Fatal("tecp-pack: -in and -store are required")
+	}
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	contents, manifest, err := pack.Unpack(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var packer []byte
+	if *packerKey != "" {
+		packer = decodePacker(*packerKey)
+	}
+	if err := manifest.VerifySignature(packer); err != nil {
+		log.Fatal(err)
+	}
+
+	archive, err := store.NewDirStore(*storeDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, receipt := range contents.Receipts {
+		key, err := store.Key(receipt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i := range contents.Proofs[key] {
+			if err := tecp.EmbedInclusionProof(receipt, &contents.Proofs[key][i]); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if _, err := archive.Put(receipt); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *bundleOut != "" && contents.TrustBundle != nil {
+		data, err := json.MarshalIndent(contents.TrustBundle, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*bundleOut, append(data, '\n'), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Printf("unpacked %d receipts into %s\n", len(contents.Receipts), *storeDir)
+}
+
+// runVerify verifies a pack and prints the report
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	var (
+		in        = flags.String("in", "", "pack file to read")
+		packerKey = flags.String("packer", "", "base64 public key the pack must be signed with")
+		bundleKey = flags.String("bundle-key", "", "base64 Ed25519 distribution key of the packed trust bundle")
+		logKID    = flags.String("log-kid", "", "key ID of a trusted log")
+		logKey    = flags.String("log-key", "", "base64 Ed25519 key of the trusted log")
+		profile   = flags.String("profile", string(tecp.ProfileV01), "verification profile")
+	)
+	flags.Parse(args)
+
+	if *in == "" {
+		log.Fatal("tecp-pack: -in is required")
+	}
+	options := pack.VerifyOptions{
+		Options: tecp.VerifyOptions{
+			Profile: tecp.Profile(*profile),
+			// Packed receipts are older than any freshness limit
+			DisabledChecks: []string{tecp.CheckTimestamp},
+		},
+	}
+	if *packerKey != "" {
+		options.PublicKey = decodePacker(*packerKey)
+	}
+	if *bundleKey != "" {
+		options.BundleKey = decodeKey("bundle-key", *bundleKey)
+	}
+	if *logKey != "" {
+		options.Options.Logs = []tecp.TrustedLog{{KeyID: *logKID, PublicKey: decodeKey("log-key", *logKey)}}
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := pack.VerifyPack(f, options)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	if !report.OK() {
+		os.Exit(3)
+	}
+}
+
+// decodeKey decodes a base64 Ed25519 public key flag
+func decodeKey(name, value string) ed25519.PublicKey {
+	publicKey, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		log.Fatalf("tecp-pack: invalid -%s", name)
+	}
+	return publicKey
+}
+
+// decodePacker decodes the base64 -packer key, as carried in receipts
+func decodePacker(value string) []byte {
+	publicKey, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(publicKey) == 0 {
+		log.Fatal("tecp-pack: invalid -packer")
+	}
+	return publicKey
+}
@@ -0,0 +1,160 @@
+// Command tecp-recover recovers an issuer from a signing key compromise.
+//
+//	tecp-recover compromise -key <b64> -since <time> -successor <b64> -authority auth.pem [-log URL]
+//	tecp-recover reattest -store <dir> -statement statement.json -successor-key new.pem
+//
+// compromise prints a key statement marking the key compromised since the
+// given RFC 3339 time, signed by the key authority and, with -log, logged.
+// Publish it to verifiers, who then refuse receipts the key signed from
+// that time on. reattest counter-signs, with the successor key, every
+// archived receipt the key signed before then that still verifies, and
+// prints the report as JSON; it exits with status 3 when receipts were
+// revoked or could not be re-attested.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/recovery"
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "compromise":
+		runCompromise(args)
+	case "reattest":
+		runReattest(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: tecp-recover compromise|reattest [flags]\n")
+}
+
+// runCompromise signs, optionally logs, and prints a compromise statement
+func runCompromise(args []string) {
+	flags := flag.NewFlagSet("compromise", flag.ExitOnError)
+	var (
+		keyB64        = flags.String("key", "", "base64 Ed25519 key that was compromised")
+		since         = flags.String("since", "", "RFC 3339 time the key was exposed from")
+		successorB64  = flags.String("successor", "", "base64 Ed25519 key replacing it")
+		authorityFile = flags.String("authority", "", "PEM Ed25519 key authority signing the statement")
+		reason        = flags.String("reason", "", "reason recorded in the statement")
+		logURL        = flags.String("log", "", "transparency log URL the statement is logged to")
+		logKey        = flags.String("log-key", "", "base64 Ed25519 key the log signs tree heads with")
+	)
+	flags.Parse(args)
+
+	if *keyB64 == "" || *since == "" || *successorB64 == "" || *authorityFile == "" {
+		log.Fatal("tecp-recover: -key, -since, -successor and -authority are required")
+	}
+	key := decodeKey("key", *keyB64)
+	successor := decodeKey("successor", *successorB64)
+	sinceTime, err := time.Parse(time.RFC3339, *since)
+	if err != nil {
+		log.Fatalf("tecp-recover: invalid -since: %v", err)
+	}
+	authority := loadKey(*authorityFile)
+
+	var logClient *tecplog.Client
+	if *logURL != "" {
+		logClient = &tecplog.Client{URL: *logURL}
+		if *logKey != "" {
+			logClient.PublicKey = decodeKey("log-key", *logKey)
+		}
+	}
+	statement, err := recovery.Compromise(key, successor, sinceTime, *reason, authority, logClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(statement); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runReattest re-attests an archive's genuine receipts and prints the report
+func runReattest(args []string) {
+	flags := flag.NewFlagSet("reattest", flag.ExitOnError)
+	var (
+		storeDir      = flags.String("store", "", "receipt archive directory")
+		statementFile = flags.String("statement", "", "compromise statement JSON")
+		successorFile = flags.String("successor-key", "", "PEM Ed25519 successor key counter-signing the receipts")
+		profile       = flags.String("profile", string(tecp.ProfileV01), "verification profile")
+	)
+	flags.Parse(args)
+
+	if *storeDir == "" || *statementFile == "" || *successorFile == "" {
+		log.Fatal("tecp-recover: -store, -statement and -successor-key are required")
+	}
+	archive, err := store.NewDirStore(*storeDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := os.ReadFile(*statementFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var statement tecp.KeyStatement
+	if err := json.Unmarshal(data, &statement); err != nil {
+		log.Fatalf("tecp-recover: invalid statement %s: %v", *statementFile, err)
+	}
+	successor := loadKey(*successorFile)
+
+	report, err := recovery.Reattest(archive, &statement, recovery.Options{
+		Successor: successor,
+		Verify:    tecp.VerifyOptions{Profile: tecp.Profile(*profile)},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	if len(report.Revoked) > 0 || len(report.Rejected) > 0 {
+		os.Exit(3)
+	}
+}
+
+// decodeKey decodes a base64 Ed25519 public key flag
+func decodeKey(name, value string) ed25519.PublicKey {
+	publicKey, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		log.Fatalf("tecp-recover: invalid -%s", name)
+	}
+	return publicKey
+}
+
+// loadKey reads a PEM private key, which must be Ed25519: key statements
+// and re-attestations are signed with Ed25519 keys
+func loadKey(path string) ed25519.PrivateKey {
+	key, err := tecp.LoadPrivateKey(path)
+	if err != nil {
+		log.Fatalf("tecp-recover: %v", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		log.Fatalf("tecp-recover: %s is not an Ed25519 key", path)
+	}
+	return edKey
+}
@@ -0,0 +1,136 @@
+// Command tecp-signerd holds a TECP issuer key and signs receipts' canonical
+// payloads for workers over mutual TLS, serving the protocol the
+// signer/remote package speaks:
+//
+//	tecp-signerd -key issuer.pem -cert server.pem -cert-key server-key.pem \
+//		-client-ca workers-ca.pem -allow spiffe://example.org/worker
+//
+// The issuer key is a PEM PKCS #8 Ed25519 or ECDSA key, or a base64
+// Ed25519 private key or seed. Every client must present a certificate
+// issued by -client-ca; -allow further restricts which identities may
+// sign. Each signature is logged with the client's identity.
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/signer/remote"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+func main() {
+	var (
+		listen   = flag.String("listen", ":8443", "address to serve on")
+		keyFile  = flag.String("key", "", "issuer key file")
+		certFile = flag.String("cert", "", "server certificate chain, PEM")
+		certKey  = flag.String("cert-key", "", "server certificate key, PEM")
+		clientCA = flag.String("client-ca", "", "CA certificates that issue client certificates, PEM")
+		allow    = flag.String("allow", "", "comma-separated client identities allowed to sign; any verified client when empty")
+	)
+	flag.Parse()
+
+	if *keyFile == "" || *certFile == "" || *certKey == "" || *clientCA == "" {
+		fmt.Fprintf(os.Stderr, "usage: tecp-signerd -key FILE -cert FILE -cert-key FILE -client-ca FILE [flags]\n\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	signer, err := loadSigner(*keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cert, err := tls.LoadX509KeyPair(*certFile, *certKey)
+	if err != nil {
+		log.Fatalf("tecp-signerd: failed to load server certificate: %v", err)
+	}
+	clientCAs, err := loadCertPool(*clientCA)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	handler := remote.NewServer(signer)
+	if *allow != "" {
+		handler.Clients = strings.Split(*allow, ",")
+	}
+	handler.OnSign = func(client string, size int) {
+		log.Printf("tecp-signerd: signed %d bytes for %s", size, client)
+	}
+
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           handler,
+		TLSConfig:         remote.ServerTLSConfig(cert, clientCAs),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	log.Printf("tecp-signerd: serving %s key %s on %s", signer.Algorithm(),
+		base64.StdEncoding.EncodeToString(signer.PublicKey()), *listen)
+	if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// loadSigner reads the issuer key: PEM, as tecp.ParsePrivateKey, or a base64 Ed25519
+// private key or seed
+func loadSigner(path string) (tecp.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tecp-signerd: failed to read key: %w", err)
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := tecp.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("tecp-signerd: invalid key: %w", err)
+		}
+		return tecp.NewSigner(key)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("tecp-signerd: key is neither PEM nor base64")
+	}
+	switch len(raw) {
+	case ed25519.PrivateKeySize:
+		return tecp.NewEd25519Signer(ed25519.PrivateKey(raw)), nil
+	case ed25519.SeedSize:
+		return tecp.NewEd25519Signer(ed25519.NewKeyFromSeed(raw)), nil
+	}
+	return nil, fmt.Errorf("tecp-signerd: base64 key must be a %d-byte Ed25519 key or %d-byte seed", ed25519.PrivateKeySize, ed25519.SeedSize)
+}
+
+// loadCertPool reads PEM CA certificates
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tecp-signerd: failed to read client CAs: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("tecp-signerd: no certificates in %s", path)
+	}
+	return pool, nil
+}
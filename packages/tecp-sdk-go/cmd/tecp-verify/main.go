@@ -0,0 +1,88 @@
+// Command tecp-verify verifies TECP receipt archives.
+//
+//	tecp-verify [flags] archive <dir>    check an archive against its signed manifest
+//
+// The archive's files must match the manifest's hashes, receipt counts and
+// Merkle root, and a random sample of its receipts is fully verified. The
+// report is printed as JSON; tecp-verify exits with status 3 when the
+// archive has problems. With -archiver-key the manifest must be signed
+// with that key; without it only the manifest's own key is checked, which
+// is reported on stderr.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/tecp-protocol/tecp-sdk-go/archive"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+func main() {
+	var (
+		archiverKey = flag.String("archiver-key", "", "base64 Ed25519 key the manifest must be signed with")
+		sample      = flag.Int("sample", archive.DefaultSample, "receipts to verify; negative verifies none")
+		profile     = flag.String("profile", string(tecp.ProfileV01), "verification profile")
+	)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	switch command, args := flag.Arg(0), flag.Args()[1:]; command {
+	case "archive":
+		if len(args) != 1 {
+			usage()
+			os.Exit(2)
+		}
+		options := archive.VerifyOptions{
+			Sample: *sample,
+			Options: tecp.VerifyOptions{
+				Profile: tecp.Profile(*profile),
+				// Archived receipts are older than any freshness limit
+				DisabledChecks: []string{tecp.CheckTimestamp},
+			},
+		}
+		if *archiverKey != "" {
+			publicKey, err := base64.StdEncoding.DecodeString(*archiverKey)
+			if err != nil || len(publicKey) != ed25519.PublicKeySize {
+				log.Fatal("tecp-verify: invalid -archiver-key")
+			}
+			options.PublicKey = publicKey
+		} else {
+			log.Printf("tecp-verify: warning: manifest checked against its own key; pin it with -archiver-key")
+		}
+		runArchive(args[0], options)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: tecp-verify [flags] archive <dir>\n\n")
+	flag.PrintDefaults()
+}
+
+// runArchive verifies an archive and prints the report
+func runArchive(dir string, options archive.VerifyOptions) {
+	report, err := archive.Verify(dir, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	if !report.OK() {
+		os.Exit(3)
+	}
+}
@@ -0,0 +1,204 @@
+package compliance
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Control is an audit control that receipts can evidence
+type Control struct {
+	Framework   string `json:"framework"`
+	ID          string `json:"id"`
+	Description string `json:"description"`
+
+	// Policies lists the policy IDs whose receipts evidence the control; a
+	// trailing "*" matches a prefix. When empty every receipt evidences it
+	Policies []string `json:"policies,omitempty"`
+}
+
+// matches reports whether a receipt evidences the control
+func (c Control) matches(receipt *tecp.Receipt) bool {
+	if len(c.Policies) == 0 {
+		return true
+	}
+	for _, pattern := range c.Policies {
+		for _, id := range receipt.PolicyIDs {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(id, prefix) || id == pattern {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SOC2Controls maps the SOC 2 common criteria to the receipts evidencing them
+var SOC2Controls = []Control{
+	{Framework: "SOC2", ID: "CC6.1", Description: "Logical access security and encryption key management", Policies: []string{"key_erasure"}},
+	{Framework: "SOC2", ID: "CC6.7", Description: "Restriction of information transmission", Policies: []string{"no_network", "no_export_pii", "eu_region", "us_region", "uk_region", "ch_region", "ca_region"}},
+	{Framework: "SOC2", ID: "CC7.2", Description: "Monitoring of system components for anomalies"},
+	{Framework: "SOC2", ID: "CC8.1", Description: "Authorized changes to software, evidenced by code references"},
+	{Framework: "SOC2", ID: "C1.2", Description: "Disposal of confidential information", Policies: []string{"no_retention", "ttl_*", "key_erasure"}},
+}
+
+// HIPAAControls maps the HIPAA technical safeguards of §164.312 to the
+// receipts evidencing them
+var HIPAAControls = []Control{
+	{Framework: "HIPAA", ID: "164.312(a)(2)(iv)", Description: "Encryption and decryption", Policies: []string{"key_erasure"}},
+	{Framework: "HIPAA", ID: "164.312(b)", Description: "Audit controls"},
+	{Framework: "HIPAA", ID: "164.312(c)(1)", Description: "Integrity of electronic PHI, evidenced by signed input and output hashes"},
+	{Framework: "HIPAA", ID: "164.312(e)(1)", Description: "Transmission security", Policies: []string{"no_network", "hipaa_safe"}},
+}
+
+// KeyRecord is an entry of the issuer's key rotation history
+type KeyRecord struct {
+	KeyID     string    `json:"kid"`
+	PublicKey string    `json:"pubkey"`
+	NotBefore time.Time `json:"not_before"`
+
+	// NotAfter is zero for keys still in use
+	NotAfter time.Time `json:"not_after"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// EvidenceOptions selects what an evidence pack contains besides receipts
+type EvidenceOptions struct {
+	Period Period
+
+	// Attestations are verification attestations; those for receipts in the
+	// pack are included
+	Attestations []*tecp.VerificationAttestation
+
+	// TreeHeads is the transparency log tree head history
+	TreeHeads []*tecp.SignedTreeHead
+
+	// Keys is the key rotation history of the issuers
+	Keys []KeyRecord
+}
+
+// PackManifest is the manifest.json of an evidence pack
+type PackManifest struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Period      Period            `json:"period"`
+	Receipts    int               `json:"receipts"`
+	Controls    []ControlEvidence `json:"controls"`
+	Files       map[string]string `json:"files"`
+}
+
+// ControlEvidence lists the receipts evidencing a control
+type ControlEvidence struct {
+	Control
+	Receipts []string `json:"receipts"`
+}
+
+// ExportEvidencePack writes a zip archive for auditors holding the receipts
+// issued during the period, the attestations verifying them, tree heads and
+// key history, with an index per control. manifest.json lists every file
+// with its SHA-256 hash
+func ExportEvidencePack(s store.ReceiptStore, controls []Control, w io.Writer, options EvidenceOptions) error {
+	archive := zip.NewWriter(w)
+	manifest := &PackManifest{
+		GeneratedAt: time.Now().UTC(),
+		Period:      options.Period,
+		Files:       make(map[string]string),
+	}
+	add := func(name string, value interface{}) error {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		f, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+		hash := sha256.Sum256(data)
+		manifest.Files[name] = hex.EncodeToString(hash[:])
+		return nil
+	}
+
+	evidence := make([]ControlEvidence, len(controls))
+	for i, control := range controls {
+		evidence[i] = ControlEvidence{Control: control, Receipts: []string{}}
+	}
+	included := make(map[string]bool)
+	err := s.Walk(func(key string, receipt *tecp.Receipt) error {
+		if !options.Period.contains(time.UnixMilli(receipt.Timestamp)) {
+			return nil
+		}
+		included[key] = true
+		manifest.Receipts++
+		for i, control := range controls {
+			if control.matches(receipt) {
+				evidence[i].Receipts = append(evidence[i].Receipts, key)
+			}
+		}
+		return add(path.Join("receipts", key+".json"), receipt)
+	})
+	if err != nil {
+		return fmt.Errorf("compliance: failed to export receipts: %w", err)
+	}
+
+	for i := range evidence {
+		sort.Strings(evidence[i].Receipts)
+		name := path.Join("controls", evidence[i].Framework, fileName(evidence[i].ID)+".json")
+		if err := add(name, evidence[i]); err != nil {
+			return fmt.Errorf("compliance: failed to export control %s: %w", evidence[i].ID, err)
+		}
+	}
+	manifest.Controls = evidence
+
+	n := 0
+	for _, attestation := range options.Attestations {
+		hash, err := base64.StdEncoding.DecodeString(attestation.ReceiptHash)
+		if err != nil || !included[hex.EncodeToString(hash)] {
+			continue
+		}
+		n++
+		if err := add(fmt.Sprintf("attestations/%s-%d.json", hex.EncodeToString(hash), n), attestation); err != nil {
+			return fmt.Errorf("compliance: failed to export attestation: %w", err)
+		}
+	}
+	if len(options.TreeHeads) > 0 {
+		if err := add("logs/tree-heads.json", options.TreeHeads); err != nil {
+			return fmt.Errorf("compliance: failed to export tree heads: %w", err)
+		}
+	}
+	if len(options.Keys) > 0 {
+		if err := add("keys/rotations.json", options.Keys); err != nil {
+			return fmt.Errorf("compliance: failed to export key history: %w", err)
+		}
+	}
+
+	// The manifest covers every other file, so it is written last
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := archive.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// fileName makes a control ID safe to use as a file name
+func fileName(id string) string {
+	return strings.NewReplacer("/", "_", "(", "_", ")", "", " ", "_").Replace(id)
+}
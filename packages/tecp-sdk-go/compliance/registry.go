@@ -0,0 +1,31 @@
+package compliance
+
+// registryPolicy is the part of a TECP policy registry entry used in reports
+type registryPolicy struct {
+	Description    string
+	ComplianceTags []string
+}
+
+// registry mirrors spec/policy-registry.json
+var registry = map[string]registryPolicy{
+	"no_retention":          {"Data is not stored after processing completes - ephemeral execution only", []string{"GDPR.Art17", "CCPA.1798.105", "HIPAA.164.530"}},
+	"eu_region":             {"Processing occurs within European Union jurisdiction", []string{"GDPR.Art44", "GDPR.Art45", "EU.DataGovernanceAct"}},
+	"no_export_pii":         {"Personally identifiable information is filtered from outputs", []string{"GDPR.Art4", "HIPAA.164.514", "CCPA.1798.140"}},
+	"hipaa_safe":            {"Processing meets HIPAA Safe Harbor requirements for PHI", []string{"HIPAA.164.514", "HIPAA.164.502"}},
+	"ttl_5s":                {"Processing environment destroyed within 5 seconds maximum", []string{"TECP.Ephemeral.UltraShort"}},
+	"ttl_60s":               {"Processing environment destroyed within 60 seconds maximum", []string{"TECP.Ephemeral.Short"}},
+	"ttl_300s":              {"Processing environment destroyed within 5 minutes maximum", []string{"TECP.Ephemeral.Standard"}},
+	"key_erasure":           {"Cryptographic keys irreversibly destroyed after use", []string{"FIPS.140.2", "CC.FCS.CKM", "NIST.SP800.57"}},
+	"no_model_training":     {"Input data is not used for machine learning model training", []string{"AI.Ethics", "GDPR.Art22", "EU.AI.Act"}},
+	"audit_trail":           {"Complete audit trail of data processing steps maintained", []string{"SOX.404", "GDPR.Art30", "HIPAA.164.312"}},
+	"no_front_running":      {"Financial data processing prevents front-running opportunities", []string{"MIFID.Art27", "SEC.Rule606", "CFTC.Part43"}},
+	"mifid_compliant":       {"Processing meets MiFID II transaction reporting requirements", []string{"MIFID.Art26", "MIFID.Art27", "ESMA.RTS22"}},
+	"fair_access":           {"Market data processing provides fair access without preferential treatment", []string{"SEC.RegNMS", "MIFID.Art18", "IOSCO.Principles"}},
+	"no_price_manipulation": {"Trading analysis prevents price manipulation opportunities", []string{"SEC.Rule10b.5", "CFTC.Part180", "MAR.Art15"}},
+	"test_env":              {"Processing occurs in test/development environment (not production)", []string{"TECP.Testing"}},
+	"pci_dss_compliant":     {"Payment Card Industry Data Security Standard compliance", []string{"PCI_DSS.Req3", "PCI_DSS.Req4", "PCI_DSS.Req7"}},
+	"sox_compliant":         {"Sarbanes-Oxley Act compliance for financial reporting", []string{"SOX.Section302", "SOX.Section404", "PCAOB.AS2201"}},
+	"iso27001_compliant":    {"ISO 27001 Information Security Management System compliance", []string{"ISO27001.A.10.1", "ISO27001.A.12.3", "ISO27001.A.18.1"}},
+	"gdpr_art6_lawful":      {"GDPR Article 6 lawful basis for processing established", []string{"GDPR.Art6.1a", "GDPR.Art6.1b", "GDPR.Art6.1f"}},
+	"ccpa_opt_out":          {"CCPA consumer right to opt-out of sale respected", []string{"CCPA.1798.120", "CCPA.1798.135"}},
+}
@@ -0,0 +1,311 @@
+// Package compliance maps TECP receipts onto regulatory reporting templates.
+//
+// GenerateRoPAReport aggregates archived receipts into a GDPR Article 30
+// record of processing activities: each activity lists the purposes,
+// personal data categories, recipients, third-country transfers, retention
+// and security measures evidenced by its receipts' policies.
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/render"
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Period bounds the receipts included in a report by timestamp. A zero
+// bound is open
+type Period struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// MarshalJSON encodes open bounds as null
+func (p Period) MarshalJSON() ([]byte, error) {
+	bound := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	return json.Marshal(struct {
+		From *time.Time `json:"from"`
+		To   *time.Time `json:"to"`
+	}{bound(p.From), bound(p.To)})
+}
+
+func (p Period) contains(t time.Time) bool {
+	return (p.From.IsZero() || !t.Before(p.From)) && (p.To.IsZero() || t.Before(p.To))
+}
+
+// Options configures a report
+type Options struct {
+	// Controller names the controller and its contact details
+	Controller string
+
+	// Activity, when set, names the processing activity of a receipt;
+	// defaults to its code reference
+	Activity func(receipt *tecp.Receipt) string
+
+	// Purposes overrides the purpose recorded for a policy ID. Registry
+	// policies default to their registry description
+	Purposes map[string]string
+
+	// Recipients, when set, lists the recipients of a receipt's output
+	Recipients func(receipt *tecp.Receipt) []string
+
+	// PolicyResolver, when set, describes custom policies
+	PolicyResolver tecp.PolicyResolver
+}
+
+// Activity is one record of processing activities (Article 30(1))
+type Activity struct {
+	Name             string    `json:"name"`
+	Purposes         []string  `json:"purposes"`
+	DataCategories   []string  `json:"data_categories"`
+	Recipients       []string  `json:"recipients"`
+	Transfers        []string  `json:"transfers"`
+	Retention        []string  `json:"retention"`
+	SecurityMeasures []string  `json:"security_measures"`
+	LegalReferences  []string  `json:"legal_references"`
+	PolicyIDs        []string  `json:"policy_ids"`
+	Receipts         int       `json:"receipts"`
+	First            time.Time `json:"first"`
+	Last             time.Time `json:"last"`
+}
+
+// Report is a GDPR Article 30 records-of-processing-activities report
+type Report struct {
+	Controller  string     `json:"controller"`
+	Period      Period     `json:"period"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Receipts    int        `json:"receipts"`
+	Activities  []Activity `json:"activities"`
+}
+
+// Data categories evidenced by registry policies
+var policyCategories = map[string]string{
+	"no_export_pii":     "Personal data, with identifiers filtered from outputs",
+	"hipaa_safe":        "Health data (PHI)",
+	"pci_dss_compliant": "Payment card data",
+	"gdpr_art6_lawful":  "Personal data processed under an Article 6 lawful basis",
+	"ccpa_opt_out":      "Consumer personal information (CCPA)",
+}
+
+// Policies whose claims are technical and organisational security measures
+var securityPolicies = map[string]bool{
+	tecp.KeyErasureExtension: true,
+	tecp.NoNetworkExtension:  true,
+	"no_export_pii":          true,
+	"hipaa_safe":             true,
+	"pci_dss_compliant":      true,
+	"iso27001_compliant":     true,
+	"audit_trail":            true,
+}
+
+// activity accumulates an Activity as receipts are added
+type activity struct {
+	Activity
+	sets map[string]map[string]bool
+}
+
+func (a *activity) add(field, value string) {
+	if a.sets[field] == nil {
+		a.sets[field] = make(map[string]bool)
+	}
+	a.sets[field][value] = true
+}
+
+func (a *activity) list(field string) []string {
+	values := make([]string, 0, len(a.sets[field]))
+	for value := range a.sets[field] {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// GenerateRoPAReport aggregates the receipts in s issued during period into
+// a records-of-processing-activities report
+func GenerateRoPAReport(s store.ReceiptStore, period Period, options Options) (*Report, error) {
+	report := &Report{Controller: options.Controller, Period: period, GeneratedAt: time.Now().UTC()}
+	activities := make(map[string]*activity)
+
+	err := s.Walk(func(key string, receipt *tecp.Receipt) error {
+		issued := time.UnixMilli(receipt.Timestamp).UTC()
+		if !period.contains(issued) {
+			return nil
+		}
+		name := receipt.CodeRef
+		if options.Activity != nil {
+			name = options.Activity(receipt)
+		}
+		if name == "" {
+			name = "unspecified"
+		}
+
+		a, ok := activities[name]
+		if !ok {
+			a = &activity{Activity: Activity{Name: name, First: issued, Last: issued}, sets: make(map[string]map[string]bool)}
+			activities[name] = a
+		}
+		a.Receipts++
+		if issued.Before(a.First) {
+			a.First = issued
+		}
+		if issued.After(a.Last) {
+			a.Last = issued
+		}
+		report.Receipts++
+
+		a.add("security", "Signed TECP execution receipts")
+		for _, id := range receipt.PolicyIDs {
+			addPolicy(a, id, options)
+		}
+		if options.Recipients != nil {
+			for _, recipient := range options.Recipients(receipt) {
+				a.add("recipients", recipient)
+			}
+		}
+		for _, extension := range []string{tecp.KeyErasureExtension, tecp.NoNetworkExtension, tecp.ResidencyExtension, tecp.SRTExtension} {
+			if _, ok := receipt.Extensions[extension]; ok {
+				a.add("evidence", extension)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("compliance: failed to walk store: %w", err)
+	}
+
+	for _, a := range activities {
+		a.Purposes = a.list("purposes")
+		a.DataCategories = a.list("categories")
+		a.Recipients = a.list("recipients")
+		a.Transfers = a.list("transfers")
+		a.Retention = a.list("retention")
+		a.SecurityMeasures = a.list("security")
+		for _, evidence := range a.list("evidence") {
+			a.SecurityMeasures = append(a.SecurityMeasures, "Signed "+evidence+" evidence attached to receipts")
+		}
+		a.LegalReferences = a.list("legal")
+		a.PolicyIDs = a.list("policies")
+		report.Activities = append(report.Activities, a.Activity)
+	}
+	sort.Slice(report.Activities, func(i, j int) bool { return report.Activities[i].Name < report.Activities[j].Name })
+	return report, nil
+}
+
+// addPolicy records what a claimed policy evidences for an activity
+func addPolicy(a *activity, id string, options Options) {
+	a.add("policies", id)
+
+	description := ""
+	if policy, ok := registry[id]; ok {
+		description = policy.Description
+		for _, tag := range policy.ComplianceTags {
+			if strings.HasPrefix(tag, "GDPR.") {
+				a.add("legal", tag)
+			}
+		}
+	} else if options.PolicyResolver != nil {
+		if parsed, err := tecp.ParsePolicyID(id); err == nil {
+			if descriptor, err := options.PolicyResolver.ResolvePolicy(parsed); err == nil {
+				description = descriptor.Description
+				for _, tag := range descriptor.ComplianceTags {
+					if strings.HasPrefix(tag, "GDPR.") {
+						a.add("legal", tag)
+					}
+				}
+			}
+		}
+	}
+	if purpose, ok := options.Purposes[id]; ok {
+		description = purpose
+	}
+
+	if category, ok := policyCategories[id]; ok {
+		a.add("categories", category)
+	}
+	if description == "" {
+		description = id
+	}
+
+	// Policies describing how data is handled fill the template's retention,
+	// transfer and security columns; the rest state purposes
+	_, ttl := tecp.PolicyTTL(id)
+	switch {
+	case id == "no_retention":
+		a.add("retention", "Not retained after processing")
+	case ttl:
+		a.add("retention", description)
+	case tecp.RegionPolicies[id] != "":
+		a.add("transfers", "Processing confined to "+tecp.RegionPolicies[id])
+	case securityPolicies[id]:
+		a.add("security", description)
+	default:
+		a.add("purposes", description)
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WritePDF writes the report as a PDF laid out like the GDPR Article 30
+// template: one section per processing activity
+func (r *Report) WritePDF(w io.Writer) error {
+	period := func(t time.Time, open string) string {
+		if t.IsZero() {
+			return open
+		}
+		return t.UTC().Format("2006-01-02")
+	}
+	sections := []render.Section{{
+		Heading: "Controller",
+		Fields: []render.Field{
+			{Label: "Controller", Value: orNotRecorded(r.Controller)},
+			{Label: "Period", Value: period(r.Period.From, "beginning") + " to " + period(r.Period.To, "present")},
+			{Label: "Generated", Value: r.GeneratedAt.Format(time.RFC3339)},
+			{Label: "Receipts", Value: fmt.Sprint(r.Receipts)},
+		},
+	}}
+	for _, a := range r.Activities {
+		sections = append(sections, render.Section{
+			Heading: a.Name,
+			Fields: []render.Field{
+				{Label: "Purposes", Value: joinOrNotRecorded(a.Purposes)},
+				{Label: "Data categories", Value: joinOrNotRecorded(a.DataCategories)},
+				{Label: "Recipients", Value: joinOrNotRecorded(a.Recipients)},
+				{Label: "Transfers", Value: joinOrNotRecorded(a.Transfers)},
+				{Label: "Retention", Value: joinOrNotRecorded(a.Retention)},
+				{Label: "Security", Value: joinOrNotRecorded(a.SecurityMeasures)},
+				{Label: "GDPR references", Value: joinOrNotRecorded(a.LegalReferences)},
+				{Label: "Policies", Value: joinOrNotRecorded(a.PolicyIDs)},
+				{Label: "Receipts", Value: fmt.Sprintf("%d, %s to %s", a.Receipts,
+					a.First.Format(time.RFC3339), a.Last.Format(time.RFC3339))},
+			},
+		})
+	}
+	return render.ReportPDF(w, "Records of Processing Activities (GDPR Art. 30)", sections)
+}
+
+func orNotRecorded(value string) string {
+	if value == "" {
+		return "Not recorded"
+	}
+	return value
+}
+
+func joinOrNotRecorded(values []string) string {
+	return orNotRecorded(strings.Join(values, "; "))
+}
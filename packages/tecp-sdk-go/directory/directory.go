@@ -0,0 +1,189 @@
+// Package directory maps issuer identifiers to their signing keys.
+//
+// An issuer publishes an Entry at /.well-known/tecp-issuer naming its JWKS,
+// the profiles it issues under and the transparency logs it submits to.
+// Directory services aggregate many issuers' entries behind the same path,
+// selected by the issuer query parameter. Receipts name their issuer in the
+// issuer extension, and verifiers add Check to their pipeline to resolve
+// unknown issuers and confirm the signing key is one the issuer publishes.
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// WellKnownPath is where issuers and directories publish entries
+const WellKnownPath = "/.well-known/tecp-issuer"
+
+// IssuerExtension names the issuer of a receipt. Issuers should sign it, so
+// the name cannot be swapped for another issuer's
+const IssuerExtension = "issuer"
+
+func init() {
+	tecp.RegisterExtension(IssuerExtension)
+}
+
+// Entry describes an issuer
+type Entry struct {
+	// Issuer identifies the issuer, typically its https origin
+	Issuer string `json:"issuer"`
+
+	// JWKSURI locates the issuer's receipt signing keys
+	JWKSURI string `json:"jwks_uri"`
+
+	// Profiles are the verification profiles the issuer's receipts target
+	Profiles []tecp.Profile `json:"profiles,omitempty"`
+
+	// Logs are the transparency logs the issuer submits receipts to
+	Logs []LogMembership `json:"logs,omitempty"`
+}
+
+// LogMembership names a transparency log and the key its tree heads are
+// signed with
+type LogMembership struct {
+	URL   string `json:"url"`
+	KeyID string `json:"kid,omitempty"`
+}
+
+// validate checks the required fields of an entry
+func (e *Entry) validate() error {
+	if e.Issuer == "" {
+		return fmt.Errorf("directory: entry has no issuer")
+	}
+	if !strings.HasPrefix(e.JWKSURI, "https://") {
+		return fmt.Errorf("directory: issuer %s: jwks_uri must be https", e.Issuer)
+	}
+	return nil
+}
+
+// SupportsProfile reports whether the issuer lists profile, or lists none
+func (e *Entry) SupportsProfile(profile tecp.Profile) bool {
+	if len(e.Profiles) == 0 {
+		return true
+	}
+	for _, p := range e.Profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// IssuerOf returns the issuer a receipt names, if any
+func IssuerOf(receipt *tecp.Receipt) (string, bool, error) {
+	issuer, found, err := tecp.GetExtension[string](receipt, IssuerExtension)
+	if err != nil {
+		return "", false, fmt.Errorf("directory: invalid issuer extension: %w", err)
+	}
+	return issuer, found && issuer != "", nil
+}
+
+// Directory serves issuer entries at WellKnownPath
+type Directory struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+// New returns a Directory serving entries
+func New(entries ...Entry) (*Directory, error) {
+	d := &Directory{entries: make(map[string]Entry)}
+	for _, entry := range entries {
+		if err := d.Add(entry); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Add adds or replaces an issuer's entry
+func (d *Directory) Add(entry Entry) error {
+	if err := entry.validate(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[entry.Issuer] = entry
+	return nil
+}
+
+// Remove removes an issuer's entry
+func (d *Directory) Remove(issuer string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.entries, issuer)
+}
+
+// Lookup returns an issuer's entry
+func (d *Directory) Lookup(issuer string) (*Entry, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	entry, ok := d.entries[issuer]
+	if !ok {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Entries returns every entry, sorted by issuer
+func (d *Directory) Entries() []Entry {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	entries := make([]Entry, 0, len(d.entries))
+	for _, entry := range d.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Issuer < entries[j].Issuer })
+	return entries
+}
+
+// ServeHTTP serves GET WellKnownPath. With an issuer query parameter it
+// returns that issuer's entry; without one it lists every entry, or returns
+// the only entry of a directory serving a single issuer
+func (d *Directory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != WellKnownPath {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if issuer := r.URL.Query().Get("issuer"); issuer != "" {
+		entry, ok := d.Lookup(issuer)
+		if !ok {
+			writeError(w, http.StatusNotFound, "unknown issuer")
+			return
+		}
+		writeJSON(w, entry)
+		return
+	}
+
+	entries := d.Entries()
+	if len(entries) == 1 {
+		writeJSON(w, entries[0])
+		return
+	}
+	writeJSON(w, struct {
+		Issuers []Entry `json:"issuers"`
+	}{entries})
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
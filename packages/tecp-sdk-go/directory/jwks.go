@@ -0,0 +1,257 @@
+package directory
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// JWKS cache defaults
+const (
+	DefaultMinKeysTTL   = time.Minute
+	DefaultMaxKeysTTL   = 24 * time.Hour
+	DefaultStaleIfError = 24 * time.Hour
+)
+
+// WarningCodeStaleKeys is reported by Check when an issuer's keys could not
+// be refreshed and a cached set was used
+const WarningCodeStaleKeys = tecp.WarningIssuerKeysStale
+
+// KeySet is a JWKS fetched by a JWKSCache
+type KeySet struct {
+	Keys map[string]ed25519.PublicKey
+
+	// FetchedAt is when the set was last fetched or revalidated
+	FetchedAt time.Time
+
+	// Expires is when the set must be revalidated
+	Expires time.Time
+
+	// RefreshError, when set, is why the set could not be refreshed after
+	// it expired; the set is served stale
+	RefreshError error
+}
+
+// JWKSCache fetches JSON Web Key Sets, caching them for as long as their
+// Cache-Control max-age or Expires header allows, clamped to MinTTL and
+// MaxTTL. Sets close to expiry are refreshed in the background, expired
+// sets are revalidated with their ETag, and when a refresh fails the last
+// set is served stale for up to StaleIfError. The zero value is ready to use
+type JWKSCache struct {
+	HTTPClient *http.Client
+
+	// MinTTL and MaxTTL bound how long a set is cached, whatever its
+	// headers say; they default to DefaultMinKeysTTL and DefaultMaxKeysTTL.
+	// Sets without caching headers are cached for MaxTTL or DefaultTTL,
+	// whichever is shorter
+	MinTTL time.Duration
+	MaxTTL time.Duration
+
+	// StaleIfError is how long past expiry a set is served when it cannot
+	// be refreshed, or the response's stale-if-error if longer. It
+	// defaults to DefaultStaleIfError
+	StaleIfError time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*jwksEntry
+}
+
+type jwksEntry struct {
+	set          KeySet
+	etag         string
+	staleIfError time.Duration
+	refreshing   bool
+}
+
+// UseHTTPClient fetches key sets with client when HTTPClient is unset
+func (c *JWKSCache) UseHTTPClient(client *http.Client) {
+	if c.HTTPClient == nil {
+		c.HTTPClient = client
+	}
+}
+
+// Get returns the key set at uri
+func (c *JWKSCache) Get(uri string) (*KeySet, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*jwksEntry)
+	}
+	entry, ok := c.entries[uri]
+	if ok && now.Before(entry.set.Expires) {
+		// Refresh in the background once a fifth of the lifetime remains
+		lifetime := entry.set.Expires.Sub(entry.set.FetchedAt)
+		if !entry.refreshing && entry.set.Expires.Sub(now) < lifetime/5 {
+			entry.refreshing = true
+			go c.refresh(uri)
+		}
+		set := entry.set
+		c.mu.Unlock()
+		return &set, nil
+	}
+	c.mu.Unlock()
+
+	if err := c.refresh(uri); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set := c.entries[uri].set
+	return &set, nil
+}
+
+// Forget drops a cached set, so the next Get refetches it
+func (c *JWKSCache) Forget(uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, uri)
+}
+
+// refresh fetches or revalidates the set at uri. When the fetch fails and a
+// set is still within its stale-if-error window, the set is kept and marked
+// with the error instead
+func (c *JWKSCache) refresh(uri string) error {
+	c.mu.Lock()
+	var etag string
+	if entry, ok := c.entries[uri]; ok {
+		etag = entry.etag
+	}
+	c.mu.Unlock()
+
+	fetched, notModified, err := c.fetch(uri, etag)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[uri]
+	if ok {
+		entry.refreshing = false
+	}
+	switch {
+	case err != nil:
+		if ok && now.Before(entry.set.Expires) {
+			// A failed background refresh; the set is still fresh
+			return nil
+		}
+		if ok && now.Before(entry.set.Expires.Add(entry.staleIfError)) {
+			entry.set.RefreshError = err
+			return nil
+		}
+		return err
+	case notModified && ok:
+		entry.set.FetchedAt, entry.set.Expires, entry.set.RefreshError = now, fetched.expires, nil
+		entry.staleIfError = fetched.staleIfError
+	default:
+		c.entries[uri] = &jwksEntry{
+			set:          KeySet{Keys: fetched.keys, FetchedAt: now, Expires: fetched.expires},
+			etag:         fetched.etag,
+			staleIfError: fetched.staleIfError,
+		}
+	}
+	return nil
+}
+
+type fetchedJWKS struct {
+	keys         map[string]ed25519.PublicKey
+	etag         string
+	expires      time.Time
+	staleIfError time.Duration
+}
+
+// fetch requests the set at uri, conditionally on etag
+func (c *JWKSCache) fetch(uri, etag string) (*fetchedJWKS, bool, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = tecp.DefaultHTTPClient()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	fetched := &fetchedJWKS{etag: resp.Header.Get("ETag")}
+	fetched.expires, fetched.staleIfError = c.freshness(resp.Header, time.Now())
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if etag == "" {
+			return nil, false, fmt.Errorf("%s returned status 304 to an unconditional request", uri)
+		}
+		return fetched, true, nil
+	case http.StatusOK:
+	default:
+		return nil, false, fmt.Errorf("%s returned status %d", uri, resp.StatusCode)
+	}
+
+	var jwks tecp.JWKS
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&jwks); err != nil {
+		return nil, false, fmt.Errorf("invalid response from %s: %w", uri, err)
+	}
+	if fetched.keys, err = jwks.PublicKeys(); err != nil {
+		return nil, false, err
+	}
+	return fetched, false, nil
+}
+
+// freshness returns when a response expires and how long it may be served
+// stale, from its Cache-Control and Expires headers
+func (c *JWKSCache) freshness(header http.Header, now time.Time) (time.Time, time.Duration) {
+	minTTL, maxTTL := c.MinTTL, c.MaxTTL
+	if minTTL <= 0 {
+		minTTL = DefaultMinKeysTTL
+	}
+	if maxTTL <= 0 {
+		maxTTL = DefaultMaxKeysTTL
+	}
+	staleIfError := c.StaleIfError
+	if staleIfError <= 0 {
+		staleIfError = DefaultStaleIfError
+	}
+
+	ttl, known := DefaultTTL, false
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store":
+			ttl, known = 0, true
+		case "max-age":
+			if err == nil && !known {
+				ttl, known = time.Duration(seconds)*time.Second, true
+			}
+		case "stale-if-error":
+			if err == nil && time.Duration(seconds)*time.Second > staleIfError {
+				staleIfError = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if !known {
+		if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+			ttl = expires.Sub(now)
+		}
+	}
+
+	if ttl < minTTL {
+		ttl = minTTL
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return now.Add(ttl), staleIfError
+}
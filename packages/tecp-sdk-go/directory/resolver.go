@@ -0,0 +1,257 @@
+package directory
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// DefaultTTL is how long a Resolver caches discovered issuers
+const DefaultTTL = time.Hour
+
+// CheckDirectory names the verification check added by Check
+const CheckDirectory = "directory"
+
+// Error codes reported by Check
+const (
+	ErrorCodeDiscoveryFailed  = "issuer_discovery_failed"
+	ErrorCodeUnknownIssuerKey = "unknown_issuer_key"
+)
+
+// Issuer is a discovered issuer: its entry and the keys its JWKS lists
+type Issuer struct {
+	Entry Entry
+	Keys  map[string]ed25519.PublicKey
+
+	// KeysRefreshError, when set, is why the issuer's JWKS could not be
+	// refreshed; Keys is the stale set cached by the Resolver's JWKS cache
+	KeysRefreshError error
+}
+
+// HasKey reports whether publicKey is one of the issuer's keys, returning
+// its key ID
+func (i *Issuer) HasKey(publicKey ed25519.PublicKey) (string, bool) {
+	for kid, key := range i.Keys {
+		if key.Equal(publicKey) {
+			return kid, true
+		}
+	}
+	return "", false
+}
+
+// Resolver discovers issuers. Issuers named by an https URL are looked up
+// at their own WellKnownPath; others are looked up in DirectoryURL.
+// Results, failures included, are cached for TTL
+type Resolver struct {
+	HTTPClient *http.Client
+
+	// DirectoryURL, when set, is consulted for every issuer
+	DirectoryURL string
+
+	// TTL defaults to DefaultTTL
+	TTL time.Duration
+
+	// JWKS, when set, fetches issuers' keys, honoring their caching headers
+	// and serving stale keys when a refresh fails. Keys are then looked up
+	// in it on every Resolve, while entries are still cached for TTL
+	JWKS *JWKSCache
+
+	mu    sync.Mutex
+	cache map[string]resolverCacheEntry
+}
+
+type resolverCacheEntry struct {
+	issuer  *Issuer
+	err     error
+	expires time.Time
+}
+
+// UseHTTPClient resolves issuers with client when HTTPClient is unset, and
+// shares it with the JWKS cache
+func (r *Resolver) UseHTTPClient(client *http.Client) {
+	if r.HTTPClient == nil {
+		r.HTTPClient = client
+	}
+	if r.JWKS != nil {
+		r.JWKS.UseHTTPClient(client)
+	}
+}
+
+// Resolve returns an issuer's entry and keys
+func (r *Resolver) Resolve(issuer string) (*Issuer, error) {
+	now := time.Now()
+	r.mu.Lock()
+	if cached, ok := r.cache[issuer]; ok && now.Before(cached.expires) {
+		r.mu.Unlock()
+		if cached.err != nil || r.JWKS == nil {
+			return cached.issuer, cached.err
+		}
+		return r.withKeys(cached.issuer.Entry)
+	}
+	r.mu.Unlock()
+
+	resolved, err := r.discover(issuer)
+
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]resolverCacheEntry)
+	}
+	r.cache[issuer] = resolverCacheEntry{issuer: resolved, err: err, expires: now.Add(ttl)}
+	r.mu.Unlock()
+	return resolved, err
+}
+
+// Forget drops an issuer from the cache, so the next Resolve refetches it
+func (r *Resolver) Forget(issuer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, issuer)
+}
+
+// discover fetches an issuer's entry and JWKS
+func (r *Resolver) discover(issuer string) (*Issuer, error) {
+	location, err := r.locate(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := r.get(location, &entry); err != nil {
+		return nil, fmt.Errorf("directory: issuer %s: %w", issuer, err)
+	}
+	if entry.Issuer != issuer {
+		return nil, fmt.Errorf("directory: issuer %s: entry is for %q", issuer, entry.Issuer)
+	}
+	if err := entry.validate(); err != nil {
+		return nil, err
+	}
+
+	if r.JWKS != nil {
+		return r.withKeys(entry)
+	}
+	var jwks tecp.JWKS
+	if err := r.get(entry.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("directory: issuer %s: JWKS: %w", issuer, err)
+	}
+	keys, err := jwks.PublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("directory: issuer %s: JWKS: %w", issuer, err)
+	}
+	return &Issuer{Entry: entry, Keys: keys}, nil
+}
+
+// withKeys returns an issuer with its keys from the JWKS cache
+func (r *Resolver) withKeys(entry Entry) (*Issuer, error) {
+	set, err := r.JWKS.Get(entry.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("directory: issuer %s: JWKS: %w", entry.Issuer, err)
+	}
+	return &Issuer{Entry: entry, Keys: set.Keys, KeysRefreshError: set.RefreshError}, nil
+}
+
+// locate returns the URL of an issuer's entry
+func (r *Resolver) locate(issuer string) (string, error) {
+	query := "?issuer=" + url.QueryEscape(issuer)
+	if r.DirectoryURL != "" {
+		if !strings.HasPrefix(r.DirectoryURL, "https://") {
+			return "", fmt.Errorf("directory: directory URL must be https")
+		}
+		return strings.TrimSuffix(r.DirectoryURL, "/") + WellKnownPath + query, nil
+	}
+	if !strings.HasPrefix(issuer, "https://") {
+		return "", fmt.Errorf("directory: issuer %s is not discoverable without a directory", issuer)
+	}
+	return strings.TrimSuffix(issuer, "/") + WellKnownPath + query, nil
+}
+
+// get fetches and decodes a JSON document
+func (r *Resolver) get(location string, out interface{}) error {
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = tecp.DefaultHTTPClient()
+	}
+
+	resp, err := httpClient.Get(location)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", location, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", location, err)
+	}
+	return nil
+}
+
+// Check returns a verification check that resolves the issuer a receipt
+// names and requires its signing key to be one the issuer publishes.
+// Receipts naming no issuer skip the check, which VerifyOptions.Deadline
+// bounds as it fetches the issuer's keys. Add it to a pipeline with
+//
+//	tecp.InsertCheck(tecp.DefaultChecks(), tecp.CheckIssuer, directory.Check(resolver))
+func Check(resolver *Resolver) tecp.Check {
+	return tecp.NetworkCheckFunc(CheckDirectory, func(v *tecp.Verification, result *tecp.CheckResult) {
+		name, ok, err := IssuerOf(v.Receipt)
+		if err != nil {
+			result.Fail(ErrorCodeDiscoveryFailed, err.Error())
+			return
+		}
+		if !ok {
+			result.Skip("receipt names no issuer")
+			return
+		}
+
+		issuer, err := resolver.Resolve(name)
+		if err != nil {
+			result.Fail(ErrorCodeDiscoveryFailed, fmt.Sprintf("issuer discovery failed: %v", err))
+			return
+		}
+
+		publicKey, err := v.Receipt.PublicKeyEd25519()
+		if err != nil {
+			result.Fail("", err.Error())
+			return
+		}
+		if _, ok := issuer.HasKey(publicKey); !ok {
+			result.Fail(ErrorCodeUnknownIssuerKey, fmt.Sprintf("signing key %s is not published by issuer %s", base64.StdEncoding.EncodeToString(publicKey), name))
+		}
+		if issuer.KeysRefreshError != nil {
+			result.Warn(WarningCodeStaleKeys, fmt.Sprintf("issuer %s keys could not be refreshed and are stale: %v", name, issuer.KeysRefreshError))
+		}
+		if !signed(v.Receipt, IssuerExtension) {
+			result.Warn(tecp.WarningUnsigned, "issuer extension is not signed")
+		}
+		if !issuer.Entry.SupportsProfile(v.Profile) {
+			result.Warn(tecp.WarningIssuerProfile, fmt.Sprintf("issuer %s does not list profile %s", name, v.Profile))
+		}
+	})
+}
+
+// signed reports whether the receipt signature covers an extension
+func signed(receipt *tecp.Receipt, name string) bool {
+	if receipt.SignedExt == nil {
+		return false
+	}
+	for _, n := range receipt.SignedExt.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
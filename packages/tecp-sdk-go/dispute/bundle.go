@@ -0,0 +1,56 @@
+package dispute
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// BundleVersion identifies the dispute bundle format
+const BundleVersion = "TECP-DISPUTE-BUNDLE-0.1"
+
+// Bundle is the exported form of a dispute: the receipt, its transitions and
+// the evidence content, as one JSON document
+type Bundle struct {
+	Version     string            `json:"version"`
+	Receipt     *tecp.Receipt     `json:"receipt"`
+	Transitions []Transition      `json:"transitions"`
+	Attachments map[string][]byte `json:"attachments,omitempty"`
+}
+
+// Bundle returns the dispute as a bundle
+func (d *Dispute) Bundle() *Bundle {
+	return &Bundle{
+		Version:     BundleVersion,
+		Receipt:     d.Receipt,
+		Transitions: d.Transitions,
+		Attachments: d.Attachments,
+	}
+}
+
+// Export writes the dispute bundle to w
+func (d *Dispute) Export(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d.Bundle())
+}
+
+// Import reads a dispute bundle and verifies the dispute, with resolvers as
+// for Verify. It does not verify the receipt itself
+func Import(r io.Reader, resolvers ...ed25519.PublicKey) (*Dispute, error) {
+	var bundle Bundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("dispute: invalid bundle: %w", err)
+	}
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("dispute: unsupported bundle version: %s", bundle.Version)
+	}
+	d := &Dispute{Receipt: bundle.Receipt, Transitions: bundle.Transitions, Attachments: bundle.Attachments}
+	if err := d.Verify(resolvers...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
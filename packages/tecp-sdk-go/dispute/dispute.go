@@ -0,0 +1,315 @@
+// Package dispute gives contested receipts a structured follow-up.
+//
+// A dispute is a chain of signed state transitions over one receipt: it is
+// opened, evidence is attached, and it is resolved. Every transition
+// references the receipt hash and the hash of the transition before it, so
+// the history cannot be reordered or truncated without detection. A dispute
+// and its evidence export as a self-contained bundle:
+//
+//	d, err := dispute.Open(receipt, customerKey, "output does not match the request")
+//	_, err = d.AttachEvidence(customerKey, dispute.Evidence{Type: "transcript"}, transcript)
+//	_, err = d.Resolve(arbiterKey, dispute.OutcomeUpheld, "receipt matches the archived output")
+//
+//	err = d.Export(w)
+package dispute
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Version identifies the transition format
+const Version = "TECP-DISPUTE-0.1"
+
+// TransitionType is the kind of a state transition
+type TransitionType string
+
+const (
+	TransitionOpen     TransitionType = "open"
+	TransitionEvidence TransitionType = "evidence"
+	TransitionResolve  TransitionType = "resolve"
+)
+
+// State is the state of a dispute
+type State string
+
+const (
+	StateOpen     State = "open"
+	StateResolved State = "resolved"
+)
+
+// Outcome is how a dispute was resolved
+type Outcome string
+
+const (
+	// OutcomeUpheld means the receipt stands
+	OutcomeUpheld Outcome = "upheld"
+
+	// OutcomeOverturned means the receipt was found not to reflect what
+	// happened
+	OutcomeOverturned Outcome = "overturned"
+
+	// OutcomeWithdrawn means the dispute was abandoned
+	OutcomeWithdrawn Outcome = "withdrawn"
+)
+
+// Evidence describes material attached to a dispute. The content itself is
+// referenced by digest and travels in the dispute bundle
+type Evidence struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+
+	// Digest is the base64 SHA-256 digest of the content
+	Digest string `json:"digest"`
+}
+
+// Transition is a signed state transition of a dispute
+type Transition struct {
+	Version     string         `json:"version"`
+	ReceiptHash string         `json:"receipt_hash"`
+	Type        TransitionType `json:"type"`
+
+	// Previous is the hash of the preceding transition; empty when opening
+	Previous string `json:"previous,omitempty"`
+
+	Statement string    `json:"statement,omitempty"`
+	Evidence  *Evidence `json:"evidence,omitempty"`
+	Outcome   Outcome   `json:"outcome,omitempty"`
+	Timestamp int64     `json:"ts"`
+
+	// Actor is the base64 Ed25519 key of the party making the transition
+	Actor     string `json:"actor"`
+	Signature string `json:"sig"`
+}
+
+// Dispute is a receipt and the transitions of its dispute, oldest first
+type Dispute struct {
+	Receipt     *tecp.Receipt
+	Transitions []Transition
+
+	// Attachments holds evidence content by digest
+	Attachments map[string][]byte
+}
+
+// Open opens a dispute over receipt, signed by the disputing party
+func Open(receipt *tecp.Receipt, key ed25519.PrivateKey, reason string) (*Dispute, error) {
+	d := &Dispute{Receipt: receipt}
+	if _, err := d.transition(key, Transition{Type: TransitionOpen, Statement: reason}); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// AttachEvidence records evidence in an open dispute. When content is given
+// the evidence digest is computed from it and the content is kept with the
+// dispute; otherwise evidence.Digest must be set
+func (d *Dispute) AttachEvidence(key ed25519.PrivateKey, evidence Evidence, content []byte) (*Transition, error) {
+	if evidence.Type == "" {
+		return nil, fmt.Errorf("dispute: evidence type required")
+	}
+	if content != nil {
+		digest := sha256.Sum256(content)
+		evidence.Digest = base64.StdEncoding.EncodeToString(digest[:])
+	}
+	if evidence.Digest == "" {
+		return nil, fmt.Errorf("dispute: evidence content or digest required")
+	}
+
+	transition, err := d.transition(key, Transition{Type: TransitionEvidence, Evidence: &evidence})
+	if err != nil {
+		return nil, err
+	}
+	if content != nil {
+		if d.Attachments == nil {
+			d.Attachments = make(map[string][]byte)
+		}
+		d.Attachments[evidence.Digest] = content
+	}
+	return transition, nil
+}
+
+// Resolve closes the dispute with an outcome
+func (d *Dispute) Resolve(key ed25519.PrivateKey, outcome Outcome, statement string) (*Transition, error) {
+	switch outcome {
+	case OutcomeUpheld, OutcomeOverturned, OutcomeWithdrawn:
+	default:
+		return nil, fmt.Errorf("dispute: unknown outcome %q", outcome)
+	}
+	return d.transition(key, Transition{Type: TransitionResolve, Outcome: outcome, Statement: statement})
+}
+
+// State returns the dispute's current state
+func (d *Dispute) State() State {
+	if n := len(d.Transitions); n > 0 && d.Transitions[n-1].Type == TransitionResolve {
+		return StateResolved
+	}
+	return StateOpen
+}
+
+// Outcome returns how the dispute was resolved, or "" while it is open
+func (d *Dispute) Outcome() Outcome {
+	if d.State() != StateResolved {
+		return ""
+	}
+	return d.Transitions[len(d.Transitions)-1].Outcome
+}
+
+// transition signs and appends a transition
+func (d *Dispute) transition(key ed25519.PrivateKey, t Transition) (*Transition, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("dispute: private key required")
+	}
+	if t.Type == TransitionOpen && len(d.Transitions) > 0 {
+		return nil, fmt.Errorf("dispute: already opened")
+	}
+	if d.State() == StateResolved {
+		return nil, fmt.Errorf("dispute: already resolved")
+	}
+
+	receiptHash, err := tecp.ReceiptHash(d.Receipt)
+	if err != nil {
+		return nil, err
+	}
+	t.Version = Version
+	t.ReceiptHash = base64.StdEncoding.EncodeToString(receiptHash)
+	t.Timestamp = time.Now().UnixMilli()
+	t.Actor = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	if n := len(d.Transitions); n > 0 {
+		t.Previous = base64.StdEncoding.EncodeToString(d.Transitions[n-1].Hash())
+		if t.Timestamp < d.Transitions[n-1].Timestamp {
+			t.Timestamp = d.Transitions[n-1].Timestamp
+		}
+	}
+	t.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, t.statement()))
+
+	d.Transitions = append(d.Transitions, t)
+	return &d.Transitions[len(d.Transitions)-1], nil
+}
+
+// Verify checks the dispute: every transition is signed by its actor,
+// references the receipt and the transition before it, and follows the
+// lifecycle, and every attachment matches its digest. When resolvers are
+// given, the dispute may only be resolved by one of them
+func (d *Dispute) Verify(resolvers ...ed25519.PublicKey) error {
+	if d.Receipt == nil {
+		return fmt.Errorf("dispute: receipt required")
+	}
+	if len(d.Transitions) == 0 {
+		return fmt.Errorf("dispute: no transitions")
+	}
+	receiptHash, err := tecp.ReceiptHash(d.Receipt)
+	if err != nil {
+		return err
+	}
+
+	var previous *Transition
+	for i := range d.Transitions {
+		t := &d.Transitions[i]
+		if err := t.verify(receiptHash, previous); err != nil {
+			return fmt.Errorf("dispute: transition %d: %w", i, err)
+		}
+		if t.Type == TransitionResolve && len(resolvers) > 0 && !containsKey(resolvers, t.Actor) {
+			return fmt.Errorf("dispute: transition %d: resolved by an unauthorized party", i)
+		}
+		previous = t
+	}
+
+	for digest, content := range d.Attachments {
+		sum := sha256.Sum256(content)
+		if base64.StdEncoding.EncodeToString(sum[:]) != digest {
+			return fmt.Errorf("dispute: attachment %s does not match its digest", digest)
+		}
+	}
+	return nil
+}
+
+// verify checks a transition against the receipt hash and its predecessor
+func (t *Transition) verify(receiptHash []byte, previous *Transition) error {
+	if t.Version != Version {
+		return fmt.Errorf("invalid version: %s", t.Version)
+	}
+	claimed, err := base64.StdEncoding.DecodeString(t.ReceiptHash)
+	if err != nil || !bytes.Equal(claimed, receiptHash) {
+		return fmt.Errorf("does not refer to this receipt")
+	}
+
+	switch {
+	case previous == nil && t.Type != TransitionOpen:
+		return fmt.Errorf("dispute must be opened first")
+	case previous != nil && t.Type == TransitionOpen:
+		return fmt.Errorf("dispute already opened")
+	case previous != nil && previous.Type == TransitionResolve:
+		return fmt.Errorf("dispute already resolved")
+	case t.Type == TransitionEvidence && (t.Evidence == nil || t.Evidence.Digest == ""):
+		return fmt.Errorf("evidence missing")
+	case t.Type == TransitionResolve && t.Outcome == "":
+		return fmt.Errorf("outcome missing")
+	case t.Type != TransitionOpen && t.Type != TransitionEvidence && t.Type != TransitionResolve:
+		return fmt.Errorf("unknown transition type %q", t.Type)
+	}
+	if previous != nil {
+		if t.Previous != base64.StdEncoding.EncodeToString(previous.Hash()) {
+			return fmt.Errorf("does not follow the preceding transition")
+		}
+		if t.Timestamp < previous.Timestamp {
+			return fmt.Errorf("timestamp precedes the preceding transition")
+		}
+	} else if t.Previous != "" {
+		return fmt.Errorf("opening transition has a predecessor")
+	}
+
+	actor, err := base64.StdEncoding.DecodeString(t.Actor)
+	if err != nil || len(actor) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid actor key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(t.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(actor, t.statement(), signature) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// Hash returns the SHA-256 digest identifying a transition, over its signed
+// fields and signature
+func (t *Transition) Hash() []byte {
+	hash := sha256.Sum256(append(t.statement(), t.Signature...))
+	return hash[:]
+}
+
+// statement is what the actor signs
+func (t *Transition) statement() []byte {
+	fields := map[string]interface{}{
+		"version":      t.Version,
+		"receipt_hash": t.ReceiptHash,
+		"type":         t.Type,
+		"previous":     t.Previous,
+		"statement":    t.Statement,
+		"outcome":      t.Outcome,
+		"ts":           t.Timestamp,
+		"actor":        t.Actor,
+	}
+	if t.Evidence != nil {
+		fields["evidence"] = t.Evidence
+	}
+	statement, _ := json.Marshal(fields)
+	return statement
+}
+
+func containsKey(keys []ed25519.PublicKey, encoded string) bool {
+	for _, key := range keys {
+		if base64.StdEncoding.EncodeToString(key) == encoded {
+			return true
+		}
+	}
+	return false
+}
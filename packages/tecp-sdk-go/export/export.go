@@ -0,0 +1,184 @@
+// Package export flattens receipts into tabular formats for audit reporting.
+//
+// Every export shares one schema: the signed receipt fields, the receipt hash
+// used as the archive key, the signed tenant and labels, and one column per
+// selected extension holding its JSON encoding. Policy IDs and key=value
+// labels are joined with commas. Timestamps are written as
+// RFC 3339 in CSV and as UTC millisecond timestamps in Parquet.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// ExtensionPrefix prefixes the column names of exported extensions
+const ExtensionPrefix = "ext_"
+
+// timestampLayout is RFC 3339 with fixed millisecond precision
+const timestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// cell is a single exported value
+type cell struct {
+	text   string
+	number int64
+	null   bool
+}
+
+// column describes one exported field
+type column struct {
+	name      string
+	timestamp bool
+	optional  bool
+	value     func(receipt *tecp.Receipt) (cell, error)
+}
+
+// columns returns the export schema for the selected extensions
+func columns(extensions []string) []column {
+	text := func(name string, get func(r *tecp.Receipt) string) column {
+		return column{name: name, value: func(r *tecp.Receipt) (cell, error) {
+			return cell{text: get(r)}, nil
+		}}
+	}
+
+	cols := []column{
+		{name: "receipt_hash", value: func(r *tecp.Receipt) (cell, error) {
+			key, err := store.Key(r)
+			return cell{text: key}, err
+		}},
+		text("version", func(r *tecp.Receipt) string { return r.Version }),
+		text("code_ref", func(r *tecp.Receipt) string { return r.CodeRef }),
+		{name: "ts", timestamp: true, value: func(r *tecp.Receipt) (cell, error) {
+			return cell{number: r.Timestamp}, nil
+		}},
+		text("nonce", func(r *tecp.Receipt) string { return r.Nonce }),
+		text("input_hash", func(r *tecp.Receipt) string { return r.InputHash }),
+		text("output_hash", func(r *tecp.Receipt) string { return r.OutputHash }),
+		text("policy_ids", func(r *tecp.Receipt) string { return strings.Join(r.PolicyIDs, ",") }),
+		text("pubkey", func(r *tecp.Receipt) string { return r.PublicKey }),
+		text("sig", func(r *tecp.Receipt) string { return r.Signature }),
+		{name: "tenant", optional: true, value: func(r *tecp.Receipt) (cell, error) {
+			tenant := r.Tenant()
+			return cell{text: tenant, null: tenant == ""}, nil
+		}},
+		{name: "labels", optional: true, value: func(r *tecp.Receipt) (cell, error) {
+			labels := r.Labels()
+			return cell{text: tecp.FormatLabels(labels), null: len(labels) == 0}, nil
+		}},
+	}
+
+	for _, name := range extensions {
+		name := name
+		cols = append(cols, column{name: ExtensionPrefix + name, optional: true, value: func(r *tecp.Receipt) (cell, error) {
+			value, ok := r.Extensions[name]
+			if !ok {
+				return cell{null: true}, nil
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return cell{}, fmt.Errorf("export: extension %s: %w", name, err)
+			}
+			return cell{text: string(encoded)}, nil
+		}})
+	}
+	return cols
+}
+
+// rows evaluates the schema over every receipt
+func rows(receipts []*tecp.Receipt, cols []column) ([][]cell, error) {
+	table := make([][]cell, len(receipts))
+	for i, receipt := range receipts {
+		table[i] = make([]cell, len(cols))
+		for j, col := range cols {
+			value, err := col.value(receipt)
+			if err != nil {
+				return nil, err
+			}
+			table[i][j] = value
+		}
+	}
+	return table, nil
+}
+
+// ToCSV writes receipts as CSV with a header row. Each name in extensions adds
+// an ext_<name> column; receipts without that extension leave it empty
+func ToCSV(receipts []*tecp.Receipt, w io.Writer, extensions ...string) error {
+	cols := columns(extensions)
+	table, err := rows(receipts, cols)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(cols))
+	for _, row := range table {
+		for i, col := range cols {
+			switch {
+			case row[i].null:
+				record[i] = ""
+			case col.timestamp:
+				record[i] = time.UnixMilli(row[i].number).UTC().Format(timestampLayout)
+			default:
+				record[i] = row[i].text
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Filter selects the receipts FromStore collects
+type Filter func(receipt *tecp.Receipt) bool
+
+// ForTenant selects receipts signed for tenant
+func ForTenant(tenant string) Filter {
+	return func(receipt *tecp.Receipt) bool { return receipt.Tenant() == tenant }
+}
+
+// WithLabels selects receipts signed with every label in selector
+func WithLabels(selector map[string]string) Filter {
+	return func(receipt *tecp.Receipt) bool { return receipt.HasLabels(selector) }
+}
+
+// FromStore collects the receipts in a store that pass every filter, oldest
+// first, for export
+func FromStore(s store.ReceiptStore, filters ...Filter) ([]*tecp.Receipt, error) {
+	var receipts []*tecp.Receipt
+	err := s.Walk(func(key string, receipt *tecp.Receipt) error {
+		for _, filter := range filters {
+			if !filter(receipt) {
+				return nil
+			}
+		}
+		receipts = append(receipts, receipt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(receipts, func(i, j int) bool {
+		return receipts[i].Timestamp < receipts[j].Timestamp
+	})
+	return receipts, nil
+}
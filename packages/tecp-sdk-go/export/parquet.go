@@ -0,0 +1,274 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Parquet format constants (parquet-format/src/main/thrift/parquet.thrift)
+const (
+	parquetMagic = "PAR1"
+
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+// ToParquet writes receipts as a single-row-group, uncompressed Parquet file
+// with the same columns as ToCSV. ts is an INT64 millisecond timestamp;
+// extension columns are optional and null when the extension is absent
+func ToParquet(receipts []*tecp.Receipt, w io.Writer, extensions ...string) error {
+	cols := columns(extensions)
+	table, err := rows(receipts, cols)
+	if err != nil {
+		return err
+	}
+
+	file := &bytes.Buffer{}
+	file.WriteString(parquetMagic)
+
+	// Write one data page per column and describe it in the footer
+	chunks := make([]*thrift, len(cols))
+	var totalSize int64
+	for i, col := range cols {
+		offset := int64(file.Len())
+		page, numValues := encodeColumn(table, i, col)
+		file.Write(page)
+		size := int64(len(page))
+		totalSize += size
+
+		meta := &thrift{}
+		meta.i32(1, physicalType(col))
+		meta.i32List(2, []int32{parquetPlain, parquetRLE})
+		meta.stringList(3, []string{col.name})
+		meta.i32(4, parquetUncompressed)
+		meta.i64(5, int64(numValues))
+		meta.i64(6, size)
+		meta.i64(7, size)
+		meta.i64(9, offset)
+		meta.end()
+
+		chunk := &thrift{}
+		chunk.i64(2, offset)
+		chunk.structField(3, meta)
+		chunk.end()
+		chunks[i] = chunk
+	}
+
+	// Schema: a root group followed by one leaf per column
+	schema := make([]*thrift, 0, len(cols)+1)
+	root := &thrift{}
+	root.binary(4, "receipt")
+	root.i32(5, int32(len(cols)))
+	root.end()
+	schema = append(schema, root)
+	for _, col := range cols {
+		leaf := &thrift{}
+		leaf.i32(1, physicalType(col))
+		if col.optional {
+			leaf.i32(3, parquetOptional)
+		} else {
+			leaf.i32(3, parquetRequired)
+		}
+		leaf.binary(4, col.name)
+		if col.timestamp {
+			leaf.i32(6, parquetTimestampMillis)
+		} else {
+			leaf.i32(6, parquetUTF8)
+		}
+		leaf.end()
+		schema = append(schema, leaf)
+	}
+
+	rowGroup := &thrift{}
+	rowGroup.structList(1, chunks)
+	rowGroup.i64(2, totalSize)
+	rowGroup.i64(3, int64(len(table)))
+	rowGroup.end()
+
+	footer := &thrift{}
+	footer.i32(1, 1)
+	footer.structList(2, schema)
+	footer.i64(3, int64(len(table)))
+	footer.structList(4, []*thrift{rowGroup})
+	footer.binary(6, "tecp-sdk-go")
+	footer.end()
+
+	file.Write(footer.Bytes())
+	binary.Write(file, binary.LittleEndian, uint32(footer.Len()))
+	file.WriteString(parquetMagic)
+
+	_, err = w.Write(file.Bytes())
+	return err
+}
+
+func physicalType(col column) int32 {
+	if col.timestamp {
+		return parquetInt64
+	}
+	return parquetByteArray
+}
+
+// encodeColumn renders column i as a PLAIN-encoded data page with its header
+func encodeColumn(table [][]cell, i int, col column) ([]byte, int) {
+	var body bytes.Buffer
+
+	// Optional columns carry definition levels: 1 when present, 0 when null
+	if col.optional {
+		levels := make([]bool, len(table))
+		for row := range table {
+			levels[row] = !table[row][i].null
+		}
+		encoded := bitPackedLevels(levels)
+		binary.Write(&body, binary.LittleEndian, uint32(len(encoded)))
+		body.Write(encoded)
+	}
+
+	for _, row := range table {
+		value := row[i]
+		switch {
+		case value.null:
+		case col.timestamp:
+			binary.Write(&body, binary.LittleEndian, value.number)
+		default:
+			binary.Write(&body, binary.LittleEndian, uint32(len(value.text)))
+			body.WriteString(value.text)
+		}
+	}
+
+	dataHeader := &thrift{}
+	dataHeader.i32(1, int32(len(table)))
+	dataHeader.i32(2, parquetPlain)
+	dataHeader.i32(3, parquetRLE)
+	dataHeader.i32(4, parquetRLE)
+	dataHeader.end()
+
+	header := &thrift{}
+	header.i32(1, parquetDataPage)
+	header.i32(2, int32(body.Len()))
+	header.i32(3, int32(body.Len()))
+	header.structField(5, dataHeader)
+	header.end()
+
+	return append(header.Bytes(), body.Bytes()...), len(table)
+}
+
+// bitPackedLevels encodes 1-bit levels as a single bit-packed run of the
+// RLE/bit-packing hybrid encoding
+func bitPackedLevels(levels []bool) []byte {
+	groups := (len(levels) + 7) / 8
+	out := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	for i, set := range levels {
+		if set {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(out, packed...)
+}
+
+// thrift is a minimal Thrift compact protocol struct encoder covering the
+// field types used by Parquet metadata
+type thrift struct {
+	bytes.Buffer
+	lastField int16
+}
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+func (t *thrift) field(id int16, kind byte) {
+	if delta := id - t.lastField; delta > 0 && delta <= 15 {
+		t.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.WriteByte(kind)
+		t.varint(int64(id))
+	}
+	t.lastField = id
+}
+
+func (t *thrift) varint(v int64) {
+	t.Write(binary.AppendUvarint(nil, uint64(v<<1)^uint64(v>>63)))
+}
+
+func (t *thrift) listHeader(size int, kind byte) {
+	if size < 15 {
+		t.WriteByte(byte(size)<<4 | kind)
+		return
+	}
+	t.WriteByte(0xf0 | kind)
+	t.Write(binary.AppendUvarint(nil, uint64(size)))
+}
+
+func (t *thrift) writeBinary(s string) {
+	t.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.WriteString(s)
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thrift) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.writeBinary(s)
+}
+
+func (t *thrift) i32List(id int16, values []int32) {
+	t.field(id, thriftList)
+	t.listHeader(len(values), thriftI32)
+	for _, v := range values {
+		t.varint(int64(v))
+	}
+}
+
+func (t *thrift) stringList(id int16, values []string) {
+	t.field(id, thriftList)
+	t.listHeader(len(values), thriftBinary)
+	for _, v := range values {
+		t.writeBinary(v)
+	}
+}
+
+func (t *thrift) structField(id int16, s *thrift) {
+	t.field(id, thriftStruct)
+	t.Write(s.Bytes())
+}
+
+func (t *thrift) structList(id int16, values []*thrift) {
+	t.field(id, thriftList)
+	t.listHeader(len(values), thriftStruct)
+	for _, v := range values {
+		t.Write(v.Bytes())
+	}
+}
+
+// end terminates the struct
+func (t *thrift) end() {
+	t.WriteByte(0)
+}
@@ -3,10 +3,14 @@ module github.com/tecp-protocol/tecp-sdk-go
 go 1.21
 
 require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0
 	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/klauspost/compress v1.17.9
 	golang.org/x/crypto v0.17.0
+	rsc.io/qr v0.2.0
 )
 
 require (
 	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.15.0 // indirect
 )
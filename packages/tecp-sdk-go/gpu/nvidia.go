@@ -0,0 +1,94 @@
+// Package gpu collects accelerator details for TECP receipts.
+//
+// NVIDIA reports the GPUs visible to the process, with their driver version
+// and confidential computing mode, as read from nvidia-smi. Attestation
+// evidence is obtained through a caller-supplied Attest function, typically
+// backed by NVIDIA's attestation SDK, for each GPU in confidential
+// computing mode:
+//
+//	collector := &gpu.NVIDIA{Attest: func(uuid string, nonce []byte) ([]byte, error) {
+//		return nvtrust.CollectEvidence(uuid, nonce)
+//	}}
+//	client := tecp.NewClient(tecp.WithSigner(privateKey), tecp.WithAcceleratorCollector(collector))
+package gpu
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// FormatNVIDIA identifies NVIDIA confidential computing attestation reports
+const FormatNVIDIA = "nvidia-cc"
+
+// NVIDIA is a tecp.AcceleratorCollector for NVIDIA GPUs
+type NVIDIA struct {
+	// SMIPath is the nvidia-smi binary; defaults to "nvidia-smi" on PATH
+	SMIPath string
+
+	// Attest, when set, returns the attestation report of the GPU with the
+	// given UUID, bound to nonce. It is called for GPUs in confidential
+	// computing mode
+	Attest func(uuid string, nonce []byte) ([]byte, error)
+}
+
+// CollectAccelerators lists the visible NVIDIA GPUs. A host without
+// nvidia-smi has none
+func (n *NVIDIA) CollectAccelerators(nonce []byte) ([]tecp.Accelerator, error) {
+	smi := n.SMIPath
+	if smi == "" {
+		smi = "nvidia-smi"
+	}
+	if _, err := exec.LookPath(smi); err != nil {
+		return nil, nil
+	}
+
+	out, err := exec.Command(smi, "--query-gpu=name,driver_version,uuid", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil, fmt.Errorf("gpu: nvidia-smi: %w", err)
+	}
+	cc := n.confidentialComputing(smi)
+
+	var accelerators []tecp.Accelerator
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			continue
+		}
+		accelerator := tecp.Accelerator{
+			Vendor:                "nvidia",
+			Model:                 strings.TrimSpace(fields[0]),
+			Driver:                strings.TrimSpace(fields[1]),
+			UUID:                  strings.TrimSpace(fields[2]),
+			ConfidentialComputing: cc,
+		}
+		if cc && n.Attest != nil {
+			evidence, err := n.Attest(accelerator.UUID, nonce)
+			if err != nil {
+				return nil, fmt.Errorf("gpu: attestation of %s failed: %w", accelerator.UUID, err)
+			}
+			accelerator.Attestation = &tecp.GPUAttestation{
+				Format:   FormatNVIDIA,
+				Nonce:    hex.EncodeToString(nonce),
+				Evidence: base64.StdEncoding.EncodeToString(evidence),
+			}
+		}
+		accelerators = append(accelerators, accelerator)
+	}
+	return accelerators, nil
+}
+
+// confidentialComputing reports whether the GPUs are in confidential
+// computing mode. Drivers without the conf-compute command are not
+func (n *NVIDIA) confidentialComputing(smi string) bool {
+	out, err := exec.Command(smi, "conf-compute", "-f").Output()
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(bytes.ToUpper(out), []byte("CC STATUS: ON"))
+}
@@ -0,0 +1,32 @@
+// Package tecpchi adapts tecphttp receipt issuance to Chi.
+//
+// Middleware issues receipts for the routes it is attached to, so policies
+// can differ per route or group:
+//
+//	r.Use(tecpchi.Middleware(tecphttp.Options{Client: client}))
+//	r.With(tecpchi.Middleware(strict)).Post("/v1/phi", handler)
+//
+// Outer middleware reads the issued receipt with
+// tecphttp.ReceiptFromContext after the handler returns.
+package tecpchi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tecp-protocol/tecp-sdk-go/tecphttp"
+)
+
+// Middleware returns Chi middleware issuing a receipt for every 2xx
+// response. The Chi route pattern is recorded unless options.Route is set
+func Middleware(options tecphttp.Options) func(http.Handler) http.Handler {
+	if options.Route == nil {
+		options.Route = func(r *http.Request) string {
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				return rctx.RoutePattern()
+			}
+			return ""
+		}
+	}
+	return tecphttp.Middleware(options)
+}
@@ -0,0 +1,18 @@
+module github.com/tecp-protocol/tecp-sdk-go/integrations/chi
+
+go 1.21
+
+replace github.com/tecp-protocol/tecp-sdk-go => ../..
+
+require (
+	github.com/go-chi/chi/v5 v5.1.0
+	github.com/tecp-protocol/tecp-sdk-go v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0 // indirect
+	github.com/fxamacker/cbor/v2 v2.5.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+)
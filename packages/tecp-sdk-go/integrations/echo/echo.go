@@ -0,0 +1,53 @@
+// Package tecpecho adapts tecphttp receipt issuance to Echo.
+//
+// Middleware issues receipts for the routes it is attached to, so policies
+// can differ per route or group:
+//
+//	e.Use(tecpecho.Middleware(tecphttp.Options{Client: client}))
+//	e.POST("/v1/phi", handler, tecpecho.Middleware(strict))
+//
+// The issued receipt is stored on the Echo context under ContextKey once the
+// handler returns, for middleware registered before it.
+package tecpecho
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tecp-protocol/tecp-sdk-go/tecphttp"
+)
+
+// ContextKey is the Echo context key holding the issued *tecp.Receipt
+const ContextKey = "tecp.receipt"
+
+// Middleware returns Echo middleware issuing a receipt for every 2xx
+// response. The Echo route path is recorded unless options.Route is set.
+// Handler errors are rendered by the error handler inside the middleware,
+// so error responses are sent without receipts
+func Middleware(options tecphttp.Options) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			opts := options
+			if opts.Route == nil {
+				opts.Route = func(*http.Request) string { return c.Path() }
+			}
+
+			response := c.Response()
+			writer := response.Writer
+			handler := tecphttp.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				response.Writer = w
+				if err := next(c); err != nil {
+					c.Error(err)
+				}
+			}), opts)
+			handler.ServeHTTP(writer, tecphttp.WithReceiptSlot(c.Request()))
+			response.Writer = writer
+
+			if receipt, ok := tecphttp.ReceiptFromContext(c.Request().Context()); ok {
+				c.Set(ContextKey, receipt)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Package tecpgin adapts tecphttp receipt issuance to Gin.
+//
+// Middleware issues receipts for the routes it is attached to, so policies
+// can differ per route or group:
+//
+//	router.Use(tecpgin.Middleware(tecphttp.Options{Client: client}))
+//	router.POST("/v1/phi", tecpgin.Middleware(strict), handler)
+//
+// The issued receipt is stored on the Gin context under ContextKey once the
+// handlers after the middleware return, for middleware registered before it.
+package tecpgin
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tecp-protocol/tecp-sdk-go/tecphttp"
+)
+
+// ContextKey is the Gin context key holding the issued *tecp.Receipt
+const ContextKey = "tecp.receipt"
+
+// Middleware returns Gin middleware issuing a receipt for every 2xx
+// response. The Gin route pattern is recorded unless options.Route is set
+func Middleware(options tecphttp.Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts := options
+		if opts.Route == nil {
+			opts.Route = func(*http.Request) string { return c.FullPath() }
+		}
+
+		writer := c.Writer
+		handler := tecphttp.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Request = r
+			c.Writer = &responseWriter{ResponseWriter: w, outer: writer, size: -1}
+			c.Next()
+		}), opts)
+		handler.ServeHTTP(writer, tecphttp.WithReceiptSlot(c.Request))
+		c.Writer = writer
+
+		if receipt, ok := tecphttp.ReceiptFromContext(c.Request.Context()); ok {
+			c.Set(ContextKey, receipt)
+		}
+	}
+}
+
+// responseWriter presents the buffering writer of tecphttp as a
+// gin.ResponseWriter, writing the status lazily as Gin's own writer does
+type responseWriter struct {
+	http.ResponseWriter
+	outer  gin.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.Status())
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != -1
+}
+
+// Hijack is refused: a hijacked connection bypasses the receipt
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("tecpgin: connections cannot be hijacked on receipted routes")
+}
+
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *responseWriter) CloseNotify() <-chan bool {
+	return w.outer.CloseNotify()
+}
+
+func (w *responseWriter) Pusher() http.Pusher {
+	return nil
+}
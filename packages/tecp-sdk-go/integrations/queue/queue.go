@@ -0,0 +1,300 @@
+// Package queue issues TECP receipts for messages processed by queue
+// consumers.
+//
+// A handler wrapped by an Issuer gets a receipt for every message it
+// processes successfully, or one receipt per micro-batch. The receipt's
+// input and output hashes cover the message value and the handler's result,
+// and the queue extension records where the messages came from.
+//
+// The package does not depend on any client library. Consumers convert each
+// message to a Message; with sarama, in a ConsumerGroupHandler:
+//
+//	for m := range claim.Messages() {
+//		msg := &queue.Message{System: queue.Kafka, Topic: m.Topic, Partition: m.Partition, Offset: m.Offset, Key: m.Key, Value: m.Value}
+//		if _, err := handle(session.Context(), msg); err != nil {
+//			return err
+//		}
+//		session.MarkMessage(m, "")
+//	}
+//
+// with segmentio/kafka-go:
+//
+//	m, err := reader.FetchMessage(ctx)
+//	msg := &queue.Message{System: queue.Kafka, Topic: m.Topic, Partition: int32(m.Partition), Offset: m.Offset, Key: m.Key, Value: m.Value}
+//	if _, err := handle(ctx, msg); err == nil {
+//		reader.CommitMessages(ctx, m)
+//	}
+//
+// and with NATS JetStream, using the stream sequence as the offset:
+//
+//	sub, err := js.Subscribe("orders.>", func(m *nats.Msg) {
+//		meta, _ := m.Metadata()
+//		msg := &queue.Message{System: queue.NATS, Topic: m.Subject, Offset: int64(meta.Sequence.Stream), Value: m.Data}
+//		if _, err := handle(context.Background(), msg); err == nil {
+//			m.Ack()
+//		}
+//	})
+//
+// where handle is issuer.Wrap(process).
+//
+// With batching, a successful call means only that the message joined the
+// pending batch; its receipt exists once OnReceipt reports the batch. Commit
+// offsets from OnReceipt rather than after each call, or a failed flush
+// leaves committed messages without a receipt.
+package queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Extension is the receipt extension recording the processed messages
+const Extension = "queue"
+
+func init() {
+	tecp.RegisterExtension(Extension)
+}
+
+// Queue systems recorded in the extension
+const (
+	Kafka = "kafka"
+	NATS  = "nats"
+)
+
+// Message is a consumed message, independent of the client library
+type Message struct {
+	// System names the queue, such as Kafka or NATS
+	System string
+
+	// Topic is the Kafka topic or NATS subject
+	Topic     string
+	Partition int32
+
+	// Offset is the Kafka offset or JetStream stream sequence
+	Offset int64
+	Key    []byte
+	Value  []byte
+}
+
+// Handler processes a message and returns its result
+type Handler func(ctx context.Context, msg *Message) ([]byte, error)
+
+// Options configures an Issuer
+type Options struct {
+	// Client signs the receipts
+	Client *tecp.Client
+
+	Policies []string
+	CodeRef  string
+
+	// Store, when set, persists every receipt
+	Store store.ReceiptStore
+
+	// OnReceipt, when set, is called with every receipt and the messages it
+	// covers
+	OnReceipt func(receipt *tecp.Receipt, messages []*Message)
+
+	// OnBatchError, when set, is called with a batch whose receipt could not
+	// be issued by a timed flush. Otherwise the failure is returned by the
+	// next Flush
+	OnBatchError func(err error, messages []*Message)
+
+	// BatchSize enables micro-batching: one receipt covers up to BatchSize
+	// messages. Values below 2 issue a receipt per message
+	BatchSize int
+
+	// BatchInterval, with BatchSize, issues a receipt for a partial batch
+	// once its first message is this old
+	BatchInterval time.Duration
+}
+
+// Range is a run of messages from one partition in the queue extension
+type Range struct {
+	Topic       string `json:"topic"`
+	Partition   int32  `json:"partition"`
+	FirstOffset int64  `json:"first_offset"`
+	LastOffset  int64  `json:"last_offset"`
+}
+
+// Record is the queue extension
+type Record struct {
+	System   string  `json:"system"`
+	Messages int     `json:"messages"`
+	Ranges   []Range `json:"ranges"`
+}
+
+// Issuer issues receipts for processed messages
+type Issuer struct {
+	opts Options
+
+	mu      sync.Mutex
+	batch   []*Message
+	inputs  [][]byte
+	outputs [][]byte
+	timer   *time.Timer
+	err     error
+}
+
+// New returns an Issuer
+func New(options Options) (*Issuer, error) {
+	if options.Client == nil {
+		return nil, fmt.Errorf("queue: client required")
+	}
+	return &Issuer{opts: options}, nil
+}
+
+// Wrap returns a handler that issues a receipt after handler processes a
+// message successfully. With batching, the call that fills a batch returns
+// the failure to issue its receipt; earlier calls only queue the message
+func (i *Issuer) Wrap(handler Handler) Handler {
+	return func(ctx context.Context, msg *Message) ([]byte, error) {
+		result, err := handler(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		if i.opts.BatchSize < 2 {
+			return result, i.issue([]*Message{msg}, msg.Value, result)
+		}
+		return result, i.add(msg, result)
+	}
+}
+
+// Flush issues a receipt for the pending micro-batch, if any, and returns
+// any failure of a timed flush since the last call not already reported to
+// OnBatchError
+func (i *Issuer) Flush() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.err; err != nil {
+		i.err = nil
+		return err
+	}
+	return i.flush()
+}
+
+// Close stops the batch timer and flushes the pending batch
+func (i *Issuer) Close() error {
+	return i.Flush()
+}
+
+// add appends a processed message to the batch
+func (i *Issuer) add(msg *Message, result []byte) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	inputHash := sha256.Sum256(msg.Value)
+	outputHash := sha256.Sum256(result)
+	i.batch = append(i.batch, msg)
+	i.inputs = append(i.inputs, inputHash[:])
+	i.outputs = append(i.outputs, outputHash[:])
+
+	if len(i.batch) >= i.opts.BatchSize {
+		return i.flush()
+	}
+	if len(i.batch) == 1 && i.opts.BatchInterval > 0 {
+		i.timer = time.AfterFunc(i.opts.BatchInterval, func() {
+			i.mu.Lock()
+			defer i.mu.Unlock()
+			batch := i.batch
+			if err := i.flush(); err != nil {
+				if i.opts.OnBatchError != nil {
+					i.opts.OnBatchError(err, batch)
+				} else if i.err == nil {
+					i.err = err
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// flush issues the batch receipt. A batch's input and output are the
+// concatenated SHA-256 hashes of its message values and results, in order.
+// Callers hold i.mu
+func (i *Issuer) flush() error {
+	if i.timer != nil {
+		i.timer.Stop()
+		i.timer = nil
+	}
+	if len(i.batch) == 0 {
+		return nil
+	}
+
+	batch, inputs, outputs := i.batch, i.inputs, i.outputs
+	i.batch, i.inputs, i.outputs = nil, nil, nil
+	return i.issue(batch, concat(inputs), concat(outputs))
+}
+
+// issue creates, stores and reports the receipt for messages
+func (i *Issuer) issue(messages []*Message, input, output []byte) error {
+	receipt, err := i.opts.Client.CreateReceipt(tecp.CreateReceiptOptions{
+		Input:      input,
+		Output:     output,
+		Policies:   i.opts.Policies,
+		CodeRef:    i.opts.CodeRef,
+		Extensions: map[string]interface{}{Extension: record(messages)},
+		// The offsets are what ties the receipt to the queue
+		SignedExtensions: []string{Extension},
+	})
+	if err != nil {
+		return fmt.Errorf("queue: failed to issue receipt: %w", err)
+	}
+
+	if i.opts.Store != nil {
+		if _, err := i.opts.Store.Put(receipt); err != nil {
+			return fmt.Errorf("queue: failed to store receipt: %w", err)
+		}
+	}
+	if i.opts.OnReceipt != nil {
+		i.opts.OnReceipt(receipt, messages)
+	}
+	return nil
+}
+
+// record summarizes messages as contiguous offset ranges per partition
+func record(messages []*Message) *Record {
+	sorted := append([]*Message(nil), messages...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		if sorted[a].Topic != sorted[b].Topic {
+			return sorted[a].Topic < sorted[b].Topic
+		}
+		if sorted[a].Partition != sorted[b].Partition {
+			return sorted[a].Partition < sorted[b].Partition
+		}
+		return sorted[a].Offset < sorted[b].Offset
+	})
+
+	rec := &Record{System: messages[0].System, Messages: len(messages)}
+	for _, msg := range sorted {
+		if n := len(rec.Ranges); n > 0 {
+			last := &rec.Ranges[n-1]
+			if last.Topic == msg.Topic && last.Partition == msg.Partition && last.LastOffset+1 >= msg.Offset {
+				last.LastOffset = msg.Offset
+				continue
+			}
+		}
+		rec.Ranges = append(rec.Ranges, Range{
+			Topic:       msg.Topic,
+			Partition:   msg.Partition,
+			FirstOffset: msg.Offset,
+			LastOffset:  msg.Offset,
+		})
+	}
+	return rec
+}
+
+func concat(hashes [][]byte) []byte {
+	out := make([]byte, 0, len(hashes)*sha256.Size)
+	for _, hash := range hashes {
+		out = append(out, hash...)
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package keys
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// madvDontDump excludes a mapping from core dumps, from linux/mman.h
+const madvDontDump = 16
+
+// memory is a mapping holding data between two guard pages
+type memory struct {
+	mapping []byte
+	data    []byte
+	locked  bool
+}
+
+// allocate maps size bytes of locked memory, placed against the trailing
+// guard page so overruns fault
+func allocate(size int) (*memory, error) {
+	page := os.Getpagesize()
+	inner := (size + page - 1) / page * page
+	mapping, err := syscall.Mmap(-1, 0, inner+2*page, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, fmt.Errorf("keys: failed to map sensitive memory: %w", err)
+	}
+	for _, guard := range [][]byte{mapping[:page], mapping[page+inner:]} {
+		if err := syscall.Mprotect(guard, syscall.PROT_NONE); err != nil {
+			syscall.Munmap(mapping)
+			return nil, fmt.Errorf("keys: failed to protect guard page: %w", err)
+		}
+	}
+
+	m := &memory{mapping: mapping}
+	region := mapping[page : page+inner]
+	m.locked = syscall.Mlock(region) == nil
+	syscall.Madvise(region, madvDontDump)
+	m.data = region[inner-size:]
+	return m, nil
+}
+
+// release unlocks and unmaps the memory
+func (m *memory) release() error {
+	if m.locked {
+		page := os.Getpagesize()
+		syscall.Munlock(m.mapping[page : len(m.mapping)-page])
+	}
+	if err := syscall.Munmap(m.mapping); err != nil {
+		return fmt.Errorf("keys: failed to unmap sensitive memory: %w", err)
+	}
+	return nil
+}
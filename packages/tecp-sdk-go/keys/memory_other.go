@@ -0,0 +1,17 @@
+//go:build !linux
+
+package keys
+
+// memory holds data on the heap; it cannot be locked
+type memory struct {
+	data   []byte
+	locked bool
+}
+
+func allocate(size int) (*memory, error) {
+	return &memory{data: make([]byte, size)}, nil
+}
+
+func (m *memory) release() error {
+	return nil
+}
@@ -0,0 +1,128 @@
+// Package keys holds private keys for deployments with strict key-hygiene
+// requirements.
+//
+// A Sensitive key lives outside the Go heap where the platform allows: on
+// Linux in its own mapping, locked into RAM so it is never swapped,
+// excluded from core dumps and fenced by inaccessible guard pages. Zeroize
+// wipes and releases it. Elsewhere the key is held on the heap and only
+// zeroizable.
+//
+// The Go runtime cannot sign from memory it does not manage, so each
+// signature is made from a transient heap copy of the key that is wiped as
+// soon as the signature is made.
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// ErrZeroized is returned when a zeroized key is used
+var ErrZeroized = errors.New("keys: key has been zeroized")
+
+// Sensitive is an Ed25519 private key in locked, zeroizable memory. It is a
+// crypto.Signer
+type Sensitive struct {
+	mu     sync.RWMutex
+	memory *memory
+	key    ed25519.PrivateKey
+	public ed25519.PublicKey
+}
+
+// NewSensitive moves key into sensitive memory, wiping the caller's copy
+func NewSensitive(key ed25519.PrivateKey) (*Sensitive, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("keys: invalid Ed25519 private key size")
+	}
+	m, err := allocate(ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sensitive{memory: m, key: ed25519.PrivateKey(m.data)}
+	copy(s.key, key)
+	s.public = append(ed25519.PublicKey(nil), s.key.Public().(ed25519.PublicKey)...)
+	Wipe(key)
+	return s, nil
+}
+
+// GenerateSensitive generates an Ed25519 key in sensitive memory
+func GenerateSensitive() (*Sensitive, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	defer Wipe(seed)
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, err
+	}
+	return NewSensitive(ed25519.NewKeyFromSeed(seed))
+}
+
+// Locked reports whether the key is locked into RAM. Locking fails when
+// the platform does not support it or RLIMIT_MEMLOCK is exhausted;
+// deployments that require it should refuse unlocked keys
+func (s *Sensitive) Locked() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.memory != nil && s.memory.locked
+}
+
+// Public returns the public key
+func (s *Sensitive) Public() crypto.PublicKey {
+	return s.public
+}
+
+// PublicKey returns the Ed25519 public key
+func (s *Sensitive) PublicKey() ed25519.PublicKey {
+	return s.public
+}
+
+// Sign signs message, which is not hashed; opts must be crypto.Hash(0)
+func (s *Sensitive) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts != nil && opts.HashFunc() != crypto.Hash(0) {
+		return nil, errors.New("keys: Ed25519 signs unhashed messages")
+	}
+	key, err := s.PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(key)
+	return ed25519.Sign(key, message), nil
+}
+
+// PrivateKey returns a heap copy of the key, for APIs such as
+// tecp.WithSigner that take an ed25519.PrivateKey. Wipe the copy as soon
+// as it is no longer needed
+func (s *Sensitive) PrivateKey() (ed25519.PrivateKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.memory == nil {
+		return nil, ErrZeroized
+	}
+	return append(ed25519.PrivateKey(nil), s.key...), nil
+}
+
+// Zeroize wipes the key and releases its memory. It is safe to call more
+// than once
+func (s *Sensitive) Zeroize() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.memory == nil {
+		return nil
+	}
+	Wipe(s.key)
+	err := s.memory.release()
+	s.memory, s.key = nil, nil
+	return err
+}
+
+// Wipe overwrites b with zeros, such as a private key or seed that is no
+// longer needed
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
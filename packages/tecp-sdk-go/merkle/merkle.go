@@ -0,0 +1,340 @@
+// Package merkle implements the RFC 6962 Merkle tree used by TECP
+// transparency logs: leaf and interior node hashes are domain separated with
+// 0x00 and 0x01 prefixes, and inclusion and consistency proofs follow the
+// algorithms of RFC 6962 section 2.1 (verification per RFC 9162). Range
+// proofs cover runs of consecutive leaves for incremental audits.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// HashSize is the size of every hash in the tree
+const HashSize = sha256.Size
+
+// Domain separation prefixes
+const (
+	LeafPrefix = 0x00
+	NodePrefix = 0x01
+)
+
+// ErrInvalidProof is returned when a proof does not verify
+var ErrInvalidProof = errors.New("merkle: invalid proof")
+
+// LeafHash returns the hash of a leaf's data
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{LeafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// NodeHash returns the hash of an interior node
+func NodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{NodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// EmptyRoot is the root hash of the empty tree
+func EmptyRoot() []byte {
+	sum := sha256.Sum256(nil)
+	return sum[:]
+}
+
+// Tree is an append-only Merkle tree. It keeps the hash of every complete
+// subtree, so appends, roots and proofs cost O(log n) hashes. Tree is not
+// safe for concurrent use
+type Tree struct {
+	// levels[k][i] is the hash of the complete subtree of 2^k leaves
+	// starting at leaf i<<k
+	levels [][][]byte
+}
+
+// Size returns the number of leaves
+func (t *Tree) Size() uint64 {
+	if len(t.levels) == 0 {
+		return 0
+	}
+	return uint64(len(t.levels[0]))
+}
+
+// Append adds a leaf by its leaf hash and returns its index
+func (t *Tree) Append(leafHash []byte) uint64 {
+	if len(t.levels) == 0 {
+		t.levels = append(t.levels, nil)
+	}
+	index := uint64(len(t.levels[0]))
+	t.levels[0] = append(t.levels[0], append([]byte(nil), leafHash...))
+
+	// Complete every subtree the new leaf closes
+	for k := 0; len(t.levels[k])%2 == 0; k++ {
+		n := len(t.levels[k])
+		parent := NodeHash(t.levels[k][n-2], t.levels[k][n-1])
+		if k+1 == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+		t.levels[k+1] = append(t.levels[k+1], parent)
+	}
+	return index
+}
+
+// LeafHash returns the leaf hash at index
+func (t *Tree) LeafHash(index uint64) ([]byte, error) {
+	if index >= t.Size() {
+		return nil, fmt.Errorf("merkle: leaf %d out of range (size %d)", index, t.Size())
+	}
+	return t.levels[0][index], nil
+}
+
+// Root returns the current root hash
+func (t *Tree) Root() []byte {
+	root, _ := t.RootAt(t.Size())
+	return root
+}
+
+// RootAt returns the root hash of the tree's first size leaves
+func (t *Tree) RootAt(size uint64) ([]byte, error) {
+	if size > t.Size() {
+		return nil, fmt.Errorf("merkle: size %d exceeds tree size %d", size, t.Size())
+	}
+	if size == 0 {
+		return EmptyRoot(), nil
+	}
+	return t.hash(0, size), nil
+}
+
+// InclusionProof returns the audit path for the leaf at index in the tree of
+// the first size leaves
+func (t *Tree) InclusionProof(index, size uint64) ([][]byte, error) {
+	if size > t.Size() {
+		return nil, fmt.Errorf("merkle: size %d exceeds tree size %d", size, t.Size())
+	}
+	if index >= size {
+		return nil, fmt.Errorf("merkle: leaf %d out of range (size %d)", index, size)
+	}
+	return t.path(index, 0, size), nil
+}
+
+// ConsistencyProof proves that the tree of size first is a prefix of the
+// tree of size second
+func (t *Tree) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	if second > t.Size() {
+		return nil, fmt.Errorf("merkle: size %d exceeds tree size %d", second, t.Size())
+	}
+	if first > second {
+		return nil, fmt.Errorf("merkle: size %d exceeds size %d", first, second)
+	}
+	if first == 0 || first == second {
+		return [][]byte{}, nil
+	}
+	return t.subproof(first, 0, second, true), nil
+}
+
+// RangeProof returns the subtree hashes needed to compute the root of the
+// tree of the first size leaves from the leaves in [begin, end), in
+// left-to-right order
+func (t *Tree) RangeProof(begin, end, size uint64) ([][]byte, error) {
+	if size > t.Size() {
+		return nil, fmt.Errorf("merkle: size %d exceeds tree size %d", size, t.Size())
+	}
+	if begin >= end || end > size {
+		return nil, fmt.Errorf("merkle: invalid range [%d, %d) (size %d)", begin, end, size)
+	}
+	return t.rangeProof(begin, end, 0, size), nil
+}
+
+// rangeProof collects the hashes of the maximal subtrees of D[lo:hi] outside
+// [begin, end)
+func (t *Tree) rangeProof(begin, end, lo, hi uint64) [][]byte {
+	if hi <= begin || lo >= end {
+		return [][]byte{t.hash(lo, hi)}
+	}
+	if begin <= lo && hi <= end {
+		return [][]byte{}
+	}
+	k := largestPowerOfTwoBelow(hi - lo)
+	return append(t.rangeProof(begin, end, lo, lo+k), t.rangeProof(begin, end, lo+k, hi)...)
+}
+
+// hash returns MTH(D[begin:end]). Every range reached by the RFC 6962
+// recursion splits into an aligned complete subtree and a remainder
+func (t *Tree) hash(begin, end uint64) []byte {
+	n := end - begin
+	if n&(n-1) == 0 && begin%n == 0 {
+		k := bits.TrailingZeros64(n)
+		return t.levels[k][begin>>k]
+	}
+	split := begin + largestPowerOfTwoBelow(n)
+	return NodeHash(t.hash(begin, split), t.hash(split, end))
+}
+
+// path implements PATH(m, D[begin:end]) from RFC 6962 section 2.1.1
+func (t *Tree) path(m, begin, end uint64) [][]byte {
+	n := end - begin
+	if n == 1 {
+		return [][]byte{}
+	}
+	k := largestPowerOfTwoBelow(n)
+	if m < k {
+		return append(t.path(m, begin, begin+k), t.hash(begin+k, end))
+	}
+	return append(t.path(m-k, begin+k, end), t.hash(begin, begin+k))
+}
+
+// subproof implements SUBPROOF(m, D[begin:end], b) from RFC 6962 section 2.1.2
+func (t *Tree) subproof(m, begin, end uint64, complete bool) [][]byte {
+	n := end - begin
+	if m == n {
+		if complete {
+			return [][]byte{}
+		}
+		return [][]byte{t.hash(begin, end)}
+	}
+	k := largestPowerOfTwoBelow(n)
+	if m <= k {
+		return append(t.subproof(m, begin, begin+k, complete), t.hash(begin+k, end))
+	}
+	return append(t.subproof(m-k, begin+k, end, false), t.hash(begin, begin+k))
+}
+
+// largestPowerOfTwoBelow returns the largest power of two strictly less than n (n > 1)
+func largestPowerOfTwoBelow(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+// VerifyInclusion checks that leafHash is the leaf at index in the tree of
+// the given size and root
+func VerifyInclusion(leafHash []byte, index, size uint64, proof [][]byte, root []byte) error {
+	if index >= size {
+		return fmt.Errorf("merkle: leaf %d out of range (size %d)", index, size)
+	}
+
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = NodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = NodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// VerifyRange checks that leafHashes are the consecutive leaves starting at
+// begin in the tree of the given size and root
+func VerifyRange(begin uint64, leafHashes [][]byte, size uint64, proof [][]byte, root []byte) error {
+	end := begin + uint64(len(leafHashes))
+	if len(leafHashes) == 0 || end > size || end < begin {
+		return fmt.Errorf("merkle: invalid range [%d, %d) (size %d)", begin, end, size)
+	}
+
+	var compute func(lo, hi uint64) ([]byte, error)
+	compute = func(lo, hi uint64) ([]byte, error) {
+		if hi <= begin || lo >= end {
+			if len(proof) == 0 {
+				return nil, ErrInvalidProof
+			}
+			node := proof[0]
+			proof = proof[1:]
+			return node, nil
+		}
+		if hi-lo == 1 {
+			return leafHashes[lo-begin], nil
+		}
+		k := largestPowerOfTwoBelow(hi - lo)
+		left, err := compute(lo, lo+k)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compute(lo+k, hi)
+		if err != nil {
+			return nil, err
+		}
+		return NodeHash(left, right), nil
+	}
+
+	computed, err := compute(0, size)
+	if err != nil {
+		return err
+	}
+	if len(proof) != 0 || !bytes.Equal(computed, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// VerifyConsistency checks that the tree of size first and root firstRoot is
+// a prefix of the tree of size second and root secondRoot
+func VerifyConsistency(first, second uint64, proof [][]byte, firstRoot, secondRoot []byte) error {
+	switch {
+	case first > second:
+		return fmt.Errorf("merkle: size %d exceeds size %d", first, second)
+	case first == second:
+		if len(proof) != 0 || !bytes.Equal(firstRoot, secondRoot) {
+			return ErrInvalidProof
+		}
+		return nil
+	case first == 0:
+		if len(proof) != 0 {
+			return ErrInvalidProof
+		}
+		return nil
+	case len(proof) == 0:
+		return ErrInvalidProof
+	}
+
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = NodeHash(c, fr)
+			sr = NodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = NodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, firstRoot) || !bytes.Equal(sr, secondRoot) {
+		return ErrInvalidProof
+	}
+	return nil
+}
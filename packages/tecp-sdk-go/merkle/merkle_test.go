@@ -0,0 +1,185 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// referenceRoot computes MTH(D[0:n]) directly from RFC 6962 section 2.1
+func referenceRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		return EmptyRoot()
+	case 1:
+		return leaves[0]
+	}
+	k := largestPowerOfTwoBelow(uint64(len(leaves)))
+	return NodeHash(referenceRoot(leaves[:k]), referenceRoot(leaves[k:]))
+}
+
+func buildTree(n int) (*Tree, [][]byte) {
+	var tree Tree
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = LeafHash([]byte(fmt.Sprintf("leaf %d", i)))
+		tree.Append(leaves[i])
+	}
+	return &tree, leaves
+}
+
+func TestRoots(t *testing.T) {
+	tree, leaves := buildTree(33)
+	for size := 0; size <= len(leaves); size++ {
+		root, err := tree.RootAt(uint64(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(root, referenceRoot(leaves[:size])) {
+			t.Fatalf("root of size %d differs from RFC 6962", size)
+		}
+	}
+	// The empty tree is the SHA-256 of the empty string
+	if hex.EncodeToString(EmptyRoot()) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatal("wrong empty root")
+	}
+}
+
+func TestInclusionProofs(t *testing.T) {
+	tree, leaves := buildTree(17)
+	for size := uint64(1); size <= tree.Size(); size++ {
+		root, _ := tree.RootAt(size)
+		for index := uint64(0); index < size; index++ {
+			proof, err := tree.InclusionProof(index, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyInclusion(leaves[index], index, size, proof, root); err != nil {
+				t.Fatalf("leaf %d of %d: %v", index, size, err)
+			}
+		}
+	}
+}
+
+func TestInclusionProofRejected(t *testing.T) {
+	tree, leaves := buildTree(11)
+	root := tree.Root()
+	proof, _ := tree.InclusionProof(6, 11)
+	tampered := append([][]byte(nil), proof...)
+	tampered[1] = LeafHash([]byte("forged"))
+
+	tests := []struct {
+		name  string
+		leaf  []byte
+		index uint64
+		size  uint64
+		proof [][]byte
+		root  []byte
+	}{
+		{"wrong leaf", leaves[5], 6, 11, proof, root},
+		{"wrong index", leaves[6], 7, 11, proof, root},
+		{"wrong size", leaves[6], 6, 7, proof, root},
+		{"index out of range", leaves[6], 11, 11, proof, root},
+		{"tampered path", leaves[6], 6, 11, tampered, root},
+		{"truncated path", leaves[6], 6, 11, proof[:len(proof)-1], root},
+		{"extended path", leaves[6], 6, 11, append(append([][]byte(nil), proof...), root), root},
+		{"wrong root", leaves[6], 6, 11, proof, EmptyRoot()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if VerifyInclusion(tt.leaf, tt.index, tt.size, tt.proof, tt.root) == nil {
+				t.Fatal("invalid inclusion proof accepted")
+			}
+		})
+	}
+}
+
+func TestConsistencyProofs(t *testing.T) {
+	tree, _ := buildTree(17)
+	for second := uint64(0); second <= tree.Size(); second++ {
+		secondRoot, _ := tree.RootAt(second)
+		for first := uint64(0); first <= second; first++ {
+			firstRoot, _ := tree.RootAt(first)
+			proof, err := tree.ConsistencyProof(first, second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyConsistency(first, second, proof, firstRoot, secondRoot); err != nil {
+				t.Fatalf("sizes %d and %d: %v", first, second, err)
+			}
+		}
+	}
+}
+
+func TestConsistencyProofRejected(t *testing.T) {
+	tree, _ := buildTree(13)
+	firstRoot, _ := tree.RootAt(6)
+	secondRoot := tree.Root()
+	proof, _ := tree.ConsistencyProof(6, 13)
+	tampered := append([][]byte(nil), proof...)
+	tampered[0] = LeafHash([]byte("forged"))
+
+	// A fork: the same size with a different leaf
+	var fork Tree
+	for i := 0; i < 13; i++ {
+		data := fmt.Sprintf("leaf %d", i)
+		if i == 3 {
+			data = "rewritten"
+		}
+		fork.Append(LeafHash([]byte(data)))
+	}
+	forkRoot, _ := fork.RootAt(6)
+
+	tests := []struct {
+		name                  string
+		first, second         uint64
+		proof                 [][]byte
+		firstRoot, secondRoot []byte
+	}{
+		{"rewritten history", 6, 13, proof, forkRoot, secondRoot},
+		{"wrong new root", 6, 13, proof, firstRoot, forkRoot},
+		{"tampered proof", 6, 13, tampered, firstRoot, secondRoot},
+		{"truncated proof", 6, 13, proof[:len(proof)-1], firstRoot, secondRoot},
+		{"empty proof", 6, 13, nil, firstRoot, secondRoot},
+		{"wrong sizes", 5, 13, proof, firstRoot, secondRoot},
+		{"shrinking", 13, 6, proof, secondRoot, firstRoot},
+		{"equal sizes, different roots", 6, 6, nil, firstRoot, forkRoot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if VerifyConsistency(tt.first, tt.second, tt.proof, tt.firstRoot, tt.secondRoot) == nil {
+				t.Fatal("invalid consistency proof accepted")
+			}
+		})
+	}
+}
+
+func TestRangeProofs(t *testing.T) {
+	tree, leaves := buildTree(12)
+	for size := uint64(1); size <= tree.Size(); size++ {
+		root, _ := tree.RootAt(size)
+		for begin := uint64(0); begin < size; begin++ {
+			for end := begin + 1; end <= size; end++ {
+				proof, err := tree.RangeProof(begin, end, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := VerifyRange(begin, leaves[begin:end], size, proof, root); err != nil {
+					t.Fatalf("range [%d, %d) of %d: %v", begin, end, size, err)
+				}
+			}
+		}
+	}
+
+	root := tree.Root()
+	proof, _ := tree.RangeProof(3, 7, 12)
+	if VerifyRange(4, leaves[3:7], 12, proof, root) == nil {
+		t.Fatal("shifted range accepted")
+	}
+	swapped := append([][]byte(nil), leaves[3:7]...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	if VerifyRange(3, swapped, 12, proof, root) == nil {
+		t.Fatal("reordered leaves accepted")
+	}
+}
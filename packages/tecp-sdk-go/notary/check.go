@@ -0,0 +1,50 @@
+package notary
+
+import (
+	"fmt"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// CheckNotary is the name of the pipeline check added by Check
+const CheckNotary = "notary"
+
+// ErrorCodeInsufficientNotarization is reported when a receipt carries fewer
+// valid attestations from trusted notaries than required
+const ErrorCodeInsufficientNotarization = "insufficient_notarization"
+
+// Check returns a pipeline check requiring receipts to carry valid
+// attestations from at least quorum distinct trusted notaries. Attestations
+// by other notaries are ignored, and an invalid attestation by a trusted
+// notary fails the check
+func Check(trusted Trusted, quorum int) tecp.Check {
+	return tecp.CheckFunc(CheckNotary, func(v *tecp.Verification, result *tecp.CheckResult) {
+		attestations, err := Attestations(v.Receipt)
+		if err != nil {
+			result.Fail("", err.Error())
+			return
+		}
+
+		notarized := make(map[string]bool)
+		for i := range attestations {
+			attestation := &attestations[i]
+			if _, ok := trusted[attestation.Notary]; !ok {
+				continue
+			}
+			at, err := Verify(v.Receipt, attestation, trusted)
+			if err != nil {
+				result.Fail("", err.Error())
+				return
+			}
+			// A receipt cannot be notarized before it was issued
+			if at.UnixMilli()+tecp.MaxClockSkewMS < v.Receipt.Timestamp {
+				result.Fail("", fmt.Sprintf("notary: %s attested to the receipt before its issuance", attestation.Notary))
+				return
+			}
+			notarized[attestation.Notary] = true
+		}
+		if len(notarized) < quorum {
+			result.Fail(ErrorCodeInsufficientNotarization, fmt.Sprintf("receipt notarized by %d trusted notaries, %d required", len(notarized), quorum))
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package notary
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// NotarizePath is the endpoint of the notarization protocol. Clients POST
+// {"receipt_hash": "<base64 SHA-256>"} and receive an Attestation
+const NotarizePath = "/v1/notarize"
+
+// Client is a Backend for a notary serving the notarization protocol over
+// HTTP, such as a Notary
+type Client struct {
+	// URL is the notary's base URL
+	URL string
+
+	// APIKey, when set, authenticates requests to notaries that require it
+	APIKey string
+
+	HTTPClient *http.Client
+}
+
+// UseHTTPClient sends notarization requests through client when no
+// HTTPClient is configured
+func (c *Client) UseHTTPClient(client *http.Client) {
+	if c.HTTPClient == nil {
+		c.HTTPClient = client
+	}
+}
+
+// Notarize submits a receipt hash and returns the notary's attestation
+func (c *Client) Notarize(receiptHash []byte) (*Attestation, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = tecp.DefaultHTTPClient()
+	}
+
+	body, err := json.Marshal(map[string]string{"receipt_hash": base64.StdEncoding.EncodeToString(receiptHash)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.URL, "/")+NotarizePath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&failure)
+		return nil, fmt.Errorf("notary: %s returned status %d: %s", c.URL, resp.StatusCode, failure.Error)
+	}
+
+	var attestation Attestation
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&attestation); err != nil {
+		return nil, fmt.Errorf("notary: invalid response: %w", err)
+	}
+	return &attestation, nil
+}
+
+// ServeHTTP serves the notarization protocol
+func (n *Notary) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != NotarizePath {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var request struct {
+		ReceiptHash string `json:"receipt_hash"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	receiptHash, err := base64.StdEncoding.DecodeString(request.ReceiptHash)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid receipt_hash encoding")
+		return
+	}
+	attestation, err := n.Notarize(receiptHash)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, attestation)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,200 @@
+// Package notary brings independent third parties into the receipt
+// lifecycle.
+//
+// A notary is handed a receipt's hash and returns a signed, timestamped
+// attestation that it saw the receipt. Attestations live in the receipt's
+// unsigned notary extension, so attaching them never invalidates the
+// receipt's own signature. Notaries are reached through pluggable
+// backends: Client speaks the HTTP protocol Notary serves, and any other
+// service can be adapted by implementing Backend:
+//
+//	attestations, err := notary.Notarize(receipt, &notary.Client{URL: "https://notary.example.com"})
+//
+//	pipeline := tecp.InsertCheck(tecp.DefaultChecks(), tecp.CheckSignature,
+//		notary.Check(notary.Trusted{"notary.example.com": notaryKey}, 1))
+package notary
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Extension holds the notary attestations attached to a receipt
+const Extension = "notary"
+
+// Version identifies the attestation format
+const Version = "TECP-NOTARY-0.1"
+
+func init() {
+	tecp.RegisterExtension(Extension)
+}
+
+// Attestation is a notary's signed statement that it saw a receipt at a
+// point in time
+type Attestation struct {
+	Version string `json:"version"`
+
+	// Notary identifies the notary, typically its host name
+	Notary string `json:"notary"`
+
+	ReceiptHash string `json:"receipt_hash"`
+	Timestamp   int64  `json:"ts"`
+
+	// PublicKey is the notary's base64 Ed25519 key. Verifiers trust it
+	// only when it matches the key they hold for Notary
+	PublicKey string `json:"pubkey"`
+	Signature string `json:"sig"`
+}
+
+// Backend obtains attestations from a notary service
+type Backend interface {
+	// Notarize returns the notary's attestation for a receipt hash
+	Notarize(receiptHash []byte) (*Attestation, error)
+}
+
+// Trusted maps notary identifiers to the keys their attestations must be
+// signed with
+type Trusted map[string]ed25519.PublicKey
+
+// Notary is a notary signing attestations with its own key. It is a
+// Backend, and an http.Handler serving the notarization protocol
+type Notary struct {
+	// ID identifies the notary in its attestations
+	ID string
+
+	key ed25519.PrivateKey
+}
+
+// NewNotary returns a notary named id that signs with key
+func NewNotary(id string, key ed25519.PrivateKey) *Notary {
+	return &Notary{ID: id, key: key}
+}
+
+// PublicKey returns the key verifiers should trust for the notary
+func (n *Notary) PublicKey() ed25519.PublicKey {
+	return n.key.Public().(ed25519.PublicKey)
+}
+
+// Notarize attests to a receipt hash at the current time
+func (n *Notary) Notarize(receiptHash []byte) (*Attestation, error) {
+	if len(receiptHash) != 32 {
+		return nil, fmt.Errorf("notary: invalid receipt hash size: %d", len(receiptHash))
+	}
+	attestation := &Attestation{
+		Version:     Version,
+		Notary:      n.ID,
+		ReceiptHash: base64.StdEncoding.EncodeToString(receiptHash),
+		Timestamp:   time.Now().UnixMilli(),
+		PublicKey:   base64.StdEncoding.EncodeToString(n.PublicKey()),
+	}
+	attestation.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(n.key, attestation.statement()))
+	return attestation, nil
+}
+
+// Notarize obtains an attestation for receipt from each backend and
+// attaches the ones that verify to the receipt. Backends that fail are
+// reported together in the error; the attestations obtained from the
+// others are still attached and returned
+func Notarize(receipt *tecp.Receipt, backends ...Backend) ([]Attestation, error) {
+	receiptHash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := Attestations(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	var obtained []Attestation
+	var failures []error
+	for i, backend := range backends {
+		attestation, err := backend.Notarize(receiptHash)
+		if err == nil {
+			err = attestation.verify(receiptHash, nil)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Errorf("backend %d: %w", i, err))
+			continue
+		}
+		obtained = append(obtained, *attestation)
+	}
+
+	if len(obtained) > 0 {
+		receipt.SetExtension(Extension, append(existing, obtained...))
+	}
+	return obtained, errors.Join(failures...)
+}
+
+// Attestations returns the attestations attached to a receipt, in order
+func Attestations(receipt *tecp.Receipt) ([]Attestation, error) {
+	attestations, _, err := tecp.GetExtension[[]Attestation](receipt, Extension)
+	return attestations, err
+}
+
+// Verify checks an attestation against receipt and returns its notarization
+// time. When trusted is set, the attestation must come from one of its
+// notaries and be signed with that notary's key
+func Verify(receipt *tecp.Receipt, attestation *Attestation, trusted Trusted) (time.Time, error) {
+	receiptHash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := attestation.verify(receiptHash, trusted); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(attestation.Timestamp), nil
+}
+
+// verify checks an attestation's format, receipt hash and signature, and,
+// when trusted is set, its notary key
+func (a *Attestation) verify(receiptHash []byte, trusted Trusted) error {
+	if a.Version != Version {
+		return fmt.Errorf("notary: invalid attestation version: %s", a.Version)
+	}
+	claimed, err := base64.StdEncoding.DecodeString(a.ReceiptHash)
+	if err != nil || !bytes.Equal(claimed, receiptHash) {
+		return fmt.Errorf("notary: attestation by %s does not refer to this receipt", a.Notary)
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(a.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("notary: invalid key in attestation by %s", a.Notary)
+	}
+	if trusted != nil {
+		key, ok := trusted[a.Notary]
+		if !ok {
+			return fmt.Errorf("notary: %s is not a trusted notary", a.Notary)
+		}
+		if !key.Equal(ed25519.PublicKey(publicKey)) {
+			return fmt.Errorf("notary: attestation by %s is not signed with its trusted key", a.Notary)
+		}
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(a.Signature)
+	if err != nil {
+		return fmt.Errorf("notary: invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, a.statement(), signature) {
+		return fmt.Errorf("notary: attestation by %s has an invalid signature", a.Notary)
+	}
+	return nil
+}
+
+// statement is what the notary signs
+func (a *Attestation) statement() []byte {
+	statement, _ := json.Marshal(map[string]interface{}{
+		"version":      a.Version,
+		"notary":       a.Notary,
+		"receipt_hash": a.ReceiptHash,
+		"ts":           a.Timestamp,
+		"pubkey":       a.PublicKey,
+	})
+	return statement
+}
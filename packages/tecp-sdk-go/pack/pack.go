@@ -0,0 +1,468 @@
+// Package pack bundles audit evidence into .tecp-pack containers, for
+// migrating it between systems.
+//
+// A pack is a zstd-compressed tar archive holding receipts, detached
+// inclusion proofs, signed tree heads and the trust bundle needed to
+// verify them offline. Its manifest, the first member, lists every other
+// member with its SHA-256 hash and commits to the receipts with the Merkle
+// root of their receipt hashes. The manifest is signed by the packer, so a
+// recipient holding the packer's key detects added, removed or altered
+// members. Packers sign with any receipt signature algorithm:
+//
+//	manifest, err := pack.Pack(w, &pack.Contents{Receipts: receipts, TrustBundle: bundle}, packerSigner)
+//
+//	contents, manifest, err := pack.Unpack(r)
+//	report, err := pack.VerifyPack(r, pack.VerifyOptions{PublicKey: packerPublicKey})
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Extension is the file name extension of packs
+const Extension = ".tecp-pack"
+
+// Version identifies the pack format
+const Version = "TECP-PACK-0.1"
+
+// Member names
+const (
+	ManifestName    = "MANIFEST.json"
+	TrustBundleName = "trust-bundle.json"
+	receiptsDir     = "receipts/"
+	proofsDir       = "proofs/"
+	treeHeadsDir    = "sths/"
+)
+
+// MaxMemberSize bounds the size of a single pack member
+const MaxMemberSize = 64 << 20
+
+// maxDecoderMemory bounds the memory the zstd decoder may use
+const maxDecoderMemory = 256 << 20
+
+// Contents is the evidence in a pack
+type Contents struct {
+	Receipts []*tecp.Receipt
+
+	// Proofs are detached inclusion proofs, by the hex receipt hash of the
+	// receipt they prove (store.Key)
+	Proofs map[string][]tecp.InclusionProof
+
+	// TreeHeads are signed tree heads of the logs the receipts are in
+	TreeHeads []tecp.SignedTreeHead
+
+	// TrustBundle, when set, is the bundle the receipts verify under
+	TrustBundle *tecp.TrustBundle
+}
+
+// File is a pack member in the manifest
+type File struct {
+	Path string `json:"path"`
+
+	// SHA256 is the hex SHA-256 hash of the member's content
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// Manifest describes a pack's content
+type Manifest struct {
+	Version string `json:"version"`
+	Created int64  `json:"created"`
+	Files   []File `json:"files"`
+
+	// Receipts is the number of receipts in the pack
+	Receipts int `json:"receipts"`
+
+	// MerkleRoot is the hex Merkle tree root over the receipts' hashes, in
+	// member order
+	MerkleRoot string `json:"merkle_root"`
+
+	// Algorithm is the packer's signature algorithm; empty for EdDSA
+	Algorithm tecp.Algorithm `json:"alg,omitempty"`
+
+	// PublicKey and Signature are the packer's base64 key, as carried in
+	// receipts, and its signature over the manifest
+	PublicKey string `json:"pubkey,omitempty"`
+	Signature string `json:"sig,omitempty"`
+}
+
+// member is a named pack member
+type member struct {
+	name string
+	data []byte
+}
+
+// Pack writes contents to w as a pack signed with the packer's key and
+// returns its manifest. Receipts are stored under their receipt hash, so
+// duplicates are packed once
+func Pack(w io.Writer, contents *Contents, signer tecp.Signer) (*Manifest, error) {
+	members, err := encodeMembers(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Version: Version, Created: time.Now().UnixMilli(), Files: []File{}}
+	for _, m := range members {
+		hash := sha256.Sum256(m.data)
+		manifest.Files = append(manifest.Files, File{Path: m.name, SHA256: hex.EncodeToString(hash[:]), Size: int64(len(m.data))})
+	}
+	manifest.MerkleRoot, manifest.Receipts = receiptsRoot(members)
+	if err := manifest.Sign(signer); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	compressed, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, fmt.Errorf("pack: %w", err)
+	}
+	archive := tar.NewWriter(compressed)
+	modified := time.UnixMilli(manifest.Created)
+	for _, m := range append([]member{{ManifestName, append(data, '\n')}}, members...) {
+		header := &tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.data)), ModTime: modified, Typeflag: tar.TypeReg}
+		if err := archive.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("pack: %w", err)
+		}
+		if _, err := archive.Write(m.data); err != nil {
+			return nil, fmt.Errorf("pack: %w", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("pack: %w", err)
+	}
+	if err := compressed.Close(); err != nil {
+		return nil, fmt.Errorf("pack: %w", err)
+	}
+	return manifest, nil
+}
+
+// encodeMembers encodes the contents as pack members, sorted by name
+func encodeMembers(contents *Contents) ([]member, error) {
+	var members []member
+	seen := make(map[string]bool)
+	for _, receipt := range contents.Receipts {
+		key, err := store.Key(receipt)
+		if err != nil {
+			return nil, fmt.Errorf("pack: %w", err)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		data, err := receipt.ToJSON()
+		if err != nil {
+			return nil, fmt.Errorf("pack: %w", err)
+		}
+		members = append(members, member{receiptsDir + key + ".json", data})
+	}
+	for key, proofs := range contents.Proofs {
+		if !seen[key] {
+			return nil, fmt.Errorf("pack: proofs for receipt %s, which is not in the pack", key)
+		}
+		data, err := json.Marshal(proofs)
+		if err != nil {
+			return nil, fmt.Errorf("pack: %w", err)
+		}
+		members = append(members, member{proofsDir + key + ".json", data})
+	}
+	for i := range contents.TreeHeads {
+		data, err := json.Marshal(&contents.TreeHeads[i])
+		if err != nil {
+			return nil, fmt.Errorf("pack: %w", err)
+		}
+		members = append(members, member{fmt.Sprintf("%s%06d.json", treeHeadsDir, i), data})
+	}
+	if contents.TrustBundle != nil {
+		data, err := json.Marshal(contents.TrustBundle)
+		if err != nil {
+			return nil, fmt.Errorf("pack: %w", err)
+		}
+		members = append(members, member{TrustBundleName, data})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].name < members[j].name })
+	return members, nil
+}
+
+// receiptMember returns the hex receipt hash a receipt member is stored
+// under
+func receiptMember(name string) (string, bool) {
+	key, ok := strings.CutPrefix(name, receiptsDir)
+	if !ok {
+		return "", false
+	}
+	key, ok = strings.CutSuffix(key, ".json")
+	if _, err := hex.DecodeString(key); !ok || err != nil || len(key) != 64 {
+		return "", false
+	}
+	return key, true
+}
+
+// Sign signs the manifest with the packer's key
+func (m *Manifest) Sign(signer tecp.Signer) error {
+	m.Algorithm = ""
+	if alg := signer.Algorithm(); alg != tecp.AlgEdDSA {
+		m.Algorithm = alg
+	}
+	m.PublicKey = base64.StdEncoding.EncodeToString(signer.PublicKey())
+	statement, err := m.statement()
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(statement)
+	if err != nil {
+		return fmt.Errorf("pack: failed to sign manifest: %w", err)
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// VerifySignature checks the manifest's signature. When publicKey is set
+// the manifest must be signed with it; otherwise the embedded key is used,
+// which only proves the manifest was not altered since it was signed
+func (m *Manifest) VerifySignature(publicKey []byte) error {
+	if m.Version != Version {
+		return fmt.Errorf("pack: unsupported manifest version: %s", m.Version)
+	}
+	if m.Signature == "" {
+		return fmt.Errorf("pack: manifest is not signed")
+	}
+	embedded, err := base64.StdEncoding.DecodeString(m.PublicKey)
+	if err != nil || len(embedded) == 0 {
+		return fmt.Errorf("pack: invalid manifest key")
+	}
+	if publicKey != nil && !bytes.Equal(publicKey, embedded) {
+		return fmt.Errorf("pack: manifest is not signed with the packer's key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("pack: invalid signature encoding: %w", err)
+	}
+	statement, err := m.statement()
+	if err != nil {
+		return err
+	}
+	alg := m.Algorithm
+	if alg == "" {
+		alg = tecp.AlgEdDSA
+	}
+	if err := tecp.VerifySignature(alg, embedded, statement, signature); err != nil {
+		return fmt.Errorf("pack: manifest has an invalid signature: %w", err)
+	}
+	return nil
+}
+
+// statement is what the packer signs. The algorithm is covered when set,
+// leaving Ed25519 statements as they were
+func (m *Manifest) statement() ([]byte, error) {
+	statement := map[string]interface{}{
+		"version":     m.Version,
+		"created":     m.Created,
+		"files":       m.Files,
+		"receipts":    m.Receipts,
+		"merkle_root": m.MerkleRoot,
+		"pubkey":      m.PublicKey,
+	}
+	if m.Algorithm != "" {
+		statement["alg"] = m.Algorithm
+	}
+	return json.Marshal(statement)
+}
+
+// Unpack reads a pack. Every member must match its manifest entry and none
+// may be unlisted, but the manifest's signature is not checked: use
+// VerifyPack, or Manifest.VerifySignature, before relying on the contents
+func Unpack(r io.Reader) (*Contents, *Manifest, error) {
+	manifest, members, err := readPack(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if problems := checkMembers(manifest, members); len(problems) > 0 {
+		return nil, nil, fmt.Errorf("pack: %s: %s", problems[0].File, problems[0].Detail)
+	}
+	contents, problems := decodeMembers(members)
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("pack: %s: %s", problems[0].File, problems[0].Detail)
+	}
+	return contents, manifest, nil
+}
+
+// readPack reads the manifest and members of a pack
+func readPack(r io.Reader) (*Manifest, []member, error) {
+	decompressed, err := zstd.NewReader(r, zstd.WithDecoderMaxMemory(maxDecoderMemory))
+	if err != nil {
+		return nil, nil, fmt.Errorf("pack: %w", err)
+	}
+	defer decompressed.Close()
+
+	archive := tar.NewReader(decompressed)
+	var manifest *Manifest
+	var members []member
+	seen := make(map[string]bool)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("pack: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("pack: member %q is not a regular file", header.Name)
+		}
+		if header.Name != path.Clean(header.Name) || path.IsAbs(header.Name) || strings.HasPrefix(header.Name, "../") {
+			return nil, nil, fmt.Errorf("pack: invalid member name %q", header.Name)
+		}
+		if seen[header.Name] {
+			return nil, nil, fmt.Errorf("pack: duplicate member %q", header.Name)
+		}
+		seen[header.Name] = true
+		if header.Size > MaxMemberSize {
+			return nil, nil, fmt.Errorf("pack: member %q exceeds %d bytes", header.Name, MaxMemberSize)
+		}
+		data, err := io.ReadAll(io.LimitReader(archive, MaxMemberSize))
+		if err != nil {
+			return nil, nil, fmt.Errorf("pack: %w", err)
+		}
+
+		if header.Name == ManifestName {
+			if manifest != nil || len(members) > 0 {
+				return nil, nil, errors.New("pack: manifest must be the first member")
+			}
+			manifest = &Manifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("pack: invalid manifest: %w", err)
+			}
+			continue
+		}
+		if manifest == nil {
+			return nil, nil, errors.New("pack: manifest must be the first member")
+		}
+		members = append(members, member{header.Name, data})
+	}
+	if manifest == nil {
+		return nil, nil, errors.New("pack: no manifest")
+	}
+	return manifest, members, nil
+}
+
+// checkMembers compares the members with the manifest
+func checkMembers(manifest *Manifest, members []member) []Problem {
+	var problems []Problem
+	listed := make(map[string]File, len(manifest.Files))
+	for _, file := range manifest.Files {
+		listed[file.Path] = file
+	}
+	present := make(map[string]bool, len(members))
+	for _, m := range members {
+		present[m.name] = true
+		file, ok := listed[m.name]
+		if !ok {
+			problems = append(problems, Problem{File: m.name, Kind: ProblemUnlisted, Detail: "member is not in the manifest"})
+			continue
+		}
+		hash := sha256.Sum256(m.data)
+		if file.SHA256 != hex.EncodeToString(hash[:]) || file.Size != int64(len(m.data)) {
+			problems = append(problems, Problem{File: m.name, Kind: ProblemHash, Detail: "content does not match the manifest"})
+		}
+	}
+	for _, file := range manifest.Files {
+		if !present[file.Path] {
+			problems = append(problems, Problem{File: file.Path, Kind: ProblemMissing, Detail: "member listed in the manifest is missing"})
+		}
+	}
+	return problems
+}
+
+// decodeMembers decodes the evidence in the members
+func decodeMembers(members []member) (*Contents, []Problem) {
+	contents := &Contents{Proofs: make(map[string][]tecp.InclusionProof)}
+	var problems []Problem
+	undecodable := func(name string, err error) {
+		problems = append(problems, Problem{File: name, Kind: ProblemUndecodable, Detail: err.Error()})
+	}
+	for _, m := range members {
+		switch {
+		case m.name == TrustBundleName:
+			var bundle tecp.TrustBundle
+			if err := json.Unmarshal(m.data, &bundle); err != nil {
+				undecodable(m.name, err)
+				continue
+			}
+			contents.TrustBundle = &bundle
+
+		case strings.HasPrefix(m.name, receiptsDir):
+			key, ok := receiptMember(m.name)
+			if !ok {
+				undecodable(m.name, errors.New("unexpected member"))
+				continue
+			}
+			receipt, err := tecp.FromJSON(m.data)
+			if err != nil {
+				undecodable(m.name, err)
+				continue
+			}
+			if hash, err := store.Key(receipt); err != nil || hash != key {
+				problems = append(problems, Problem{File: m.name, Kind: ProblemHash, Detail: "receipt does not match its member name"})
+				continue
+			}
+			contents.Receipts = append(contents.Receipts, receipt)
+
+		case strings.HasPrefix(m.name, proofsDir):
+			key := strings.TrimSuffix(strings.TrimPrefix(m.name, proofsDir), ".json")
+			var proofs []tecp.InclusionProof
+			if err := json.Unmarshal(m.data, &proofs); err != nil {
+				undecodable(m.name, err)
+				continue
+			}
+			contents.Proofs[key] = proofs
+
+		case strings.HasPrefix(m.name, treeHeadsDir):
+			var sth tecp.SignedTreeHead
+			if err := json.Unmarshal(m.data, &sth); err != nil {
+				undecodable(m.name, err)
+				continue
+			}
+			contents.TreeHeads = append(contents.TreeHeads, sth)
+
+		default:
+			undecodable(m.name, errors.New("unexpected member"))
+		}
+	}
+	return contents, problems
+}
+
+// receiptsRoot returns the hex Merkle root over the receipt members' hashes
+// in member order
+func receiptsRoot(members []member) (string, int) {
+	tree := &merkle.Tree{}
+	count := 0
+	for _, m := range members {
+		if key, ok := receiptMember(m.name); ok {
+			leaf, _ := hex.DecodeString(key)
+			tree.Append(merkle.LeafHash(leaf))
+			count++
+		}
+	}
+	return hex.EncodeToString(tree.Root()), count
+}
@@ -0,0 +1,232 @@
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+func testContents(t *testing.T) *Contents {
+	t.Helper()
+	priv, _, err := tecp.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := tecp.NewClient(tecp.WithSigner(priv))
+	contents := &Contents{}
+	for _, input := range []string{"a", "b", "c"} {
+		receipt, err := client.CreateReceipt(tecp.CreateReceiptOptions{Input: []byte(input), Output: []byte("out"), CodeRef: "git:abc"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents.Receipts = append(contents.Receipts, receipt)
+	}
+	return contents
+}
+
+func testSigner(t *testing.T) tecp.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tecp.NewEd25519Signer(key)
+}
+
+// writeMembers writes a pack from raw members, the first being the manifest
+func writeMembers(t *testing.T, members []member) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	compressed, _ := zstd.NewWriter(&buf)
+	archive := tar.NewWriter(compressed)
+	for _, m := range members {
+		if err := archive.WriteHeader(&tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		archive.Write(m.data)
+	}
+	archive.Close()
+	compressed.Close()
+	return buf.Bytes()
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p256Signer, err := tecp.NewECDSASigner(p256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1, _ := secp256k1.GeneratePrivateKey()
+
+	tests := []struct {
+		name   string
+		signer tecp.Signer
+		alg    tecp.Algorithm
+	}{
+		{"Ed25519", testSigner(t), ""},
+		{"P-256", p256Signer, tecp.AlgES256},
+		{"secp256k1", tecp.NewSecp256k1Signer(k1), tecp.AlgES256K},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contents := testContents(t)
+			contents.Receipts = append(contents.Receipts, contents.Receipts[0])
+
+			var buf bytes.Buffer
+			manifest, err := Pack(&buf, contents, tt.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest.Receipts != 3 {
+				t.Fatalf("manifest counts %d receipts, want the 3 distinct ones", manifest.Receipts)
+			}
+			if manifest.Algorithm != tt.alg {
+				t.Fatalf("manifest algorithm = %q, want %q", manifest.Algorithm, tt.alg)
+			}
+
+			unpacked, _, err := Unpack(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(unpacked.Receipts) != 3 {
+				t.Fatalf("unpacked %d receipts, want 3", len(unpacked.Receipts))
+			}
+
+			report, err := VerifyPack(bytes.NewReader(buf.Bytes()), VerifyOptions{PublicKey: tt.signer.PublicKey()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.OK() || report.Verified != 3 {
+				t.Fatalf("intact pack failed verification: %+v", report)
+			}
+		})
+	}
+}
+
+func TestVerifyPackTampering(t *testing.T) {
+	signer := testSigner(t)
+	var buf bytes.Buffer
+	if _, err := Pack(&buf, testContents(t), signer); err != nil {
+		t.Fatal(err)
+	}
+	manifest, members, err := readPack(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestData, _ := json.Marshal(manifest)
+
+	// tamper returns the pack with its members changed by fn
+	tamper := func(fn func(members []member) []member) []byte {
+		copied := append([]member(nil), members...)
+		return writeMembers(t, append([]member{{ManifestName, manifestData}}, fn(copied)...))
+	}
+	altered := tamper(func(m []member) []member {
+		m[0].data = bytes.Replace(m[0].data, []byte(`"git:abc"`), []byte(`"git:xyz"`), 1)
+		return m
+	})
+	removed := tamper(func(m []member) []member { return m[1:] })
+	added := tamper(func(m []member) []member { return append(m, member{"receipts/extra.json", []byte("{}")}) })
+	reordered := tamper(func(m []member) []member {
+		m[0].name, m[1].name = m[1].name, m[0].name
+		return m
+	})
+
+	tests := []struct {
+		name     string
+		pack     []byte
+		problems []string
+	}{
+		{"altered receipt", altered, []string{ProblemHash}},
+		{"removed receipt", removed, []string{ProblemMissing, ProblemMerkleRoot}},
+		{"unlisted member", added, []string{ProblemUnlisted}},
+		{"renamed receipts", reordered, []string{ProblemHash}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := VerifyPack(bytes.NewReader(tt.pack), VerifyOptions{PublicKey: signer.PublicKey(), SkipReceipts: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, kind := range tt.problems {
+				found := false
+				for _, problem := range report.Problems {
+					found = found || problem.Kind == kind
+				}
+				if !found {
+					t.Fatalf("no %s problem reported: %+v", kind, report.Problems)
+				}
+			}
+			if _, _, err := Unpack(bytes.NewReader(tt.pack)); err == nil {
+				t.Fatal("Unpack accepted a tampered pack")
+			}
+		})
+	}
+}
+
+func TestVerifyPackRejectsManifest(t *testing.T) {
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := tecp.NewECDSASigner(p256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := Pack(&buf, testContents(t), signer); err != nil {
+		t.Fatal(err)
+	}
+	manifest, members, _ := readPack(bytes.NewReader(buf.Bytes()))
+
+	withManifest := func(fn func(m Manifest) Manifest) []byte {
+		changed := fn(*manifest)
+		data, _ := json.Marshal(changed)
+		return writeMembers(t, append([]member{{ManifestName, data}}, members...))
+	}
+	resigned := withManifest(func(m Manifest) Manifest {
+		m.Sign(testSigner(t))
+		return m
+	})
+	relabeled := withManifest(func(m Manifest) Manifest {
+		m.Algorithm = tecp.AlgES384
+		return m
+	})
+	recounted := withManifest(func(m Manifest) Manifest {
+		m.Receipts = 2
+		return m
+	})
+	unsigned := withManifest(func(m Manifest) Manifest {
+		m.Signature = ""
+		return m
+	})
+	manifestData, _ := json.Marshal(manifest)
+
+	tests := []struct {
+		name string
+		pack []byte
+	}{
+		{"signed by another packer", resigned},
+		{"altered after signing", recounted},
+		{"algorithm altered after signing", relabeled},
+		{"unsigned", unsigned},
+		{"manifest not first", writeMembers(t, append(append([]member(nil), members...), member{ManifestName, manifestData}))},
+		{"no manifest", writeMembers(t, members)},
+		{"duplicate member", writeMembers(t, append([]member{{ManifestName, manifestData}, members[0]}, members...))},
+		{"path traversal", writeMembers(t, []member{{ManifestName, manifestData}, {"../receipts/x.json", []byte("{}")}})},
+		{"not a pack", []byte("not zstd")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyPack(bytes.NewReader(tt.pack), VerifyOptions{PublicKey: signer.PublicKey()}); err == nil {
+				t.Fatal("pack accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package pack
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Problem kinds
+const (
+	ProblemMissing     = "missing"
+	ProblemUnlisted    = "unlisted"
+	ProblemHash        = "hash_mismatch"
+	ProblemUndecodable = "undecodable"
+	ProblemMerkleRoot  = "merkle_root_mismatch"
+	ProblemBundle      = "invalid_trust_bundle"
+	ProblemProof       = "invalid_proof"
+	ProblemTreeHead    = "invalid_tree_head"
+	ProblemInvalid     = "invalid_receipt"
+)
+
+// Problem is a discrepancy between a pack and its manifest, or evidence in
+// it that failed verification
+type Problem struct {
+	// File is the member concerned; empty for the pack as a whole
+	File   string `json:"file,omitempty"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// Report is the outcome of VerifyPack
+type Report struct {
+	Files    int       `json:"files"`
+	Receipts int       `json:"receipts"`
+	Proofs   int       `json:"proofs"`
+	Verified int       `json:"verified"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// OK reports whether the pack matched its manifest and all its evidence
+// verified
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// VerifyOptions configures VerifyPack
+type VerifyOptions struct {
+	// PublicKey is the packer's key, as carried in receipts. Without it the
+	// manifest is checked against its embedded key only
+	PublicKey []byte
+
+	// BundleKey is the pinned distribution key of the packed trust bundle.
+	// Without it the bundle is not trusted and not used
+	BundleKey ed25519.PublicKey
+
+	// Verifier and Options verify the receipts, under the packed trust
+	// bundle when BundleKey is set. Verifier defaults to tecp.NewClient().
+	// Packed receipts are usually older than any freshness limit, so
+	// callers typically disable tecp.CheckTimestamp
+	Verifier *tecp.Client
+	Options  tecp.VerifyOptions
+
+	// SkipReceipts checks the pack's integrity and proofs without
+	// verifying the receipts themselves
+	SkipReceipts bool
+}
+
+// VerifyPack checks a pack against its signed manifest: every listed
+// member must be present with its hash, no member may be unlisted, and the
+// receipts must match the manifest's count and Merkle root. The trust
+// bundle is checked against BundleKey, tree heads and detached inclusion
+// proofs against the trusted logs, and every receipt is verified with its
+// proofs embedded. The error is reserved for an unreadable pack or a
+// missing, malformed or badly signed manifest
+func VerifyPack(r io.Reader, options VerifyOptions) (*Report, error) {
+	manifest, members, err := readPack(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.VerifySignature(options.PublicKey); err != nil {
+		return nil, err
+	}
+
+	report := &Report{Files: len(members)}
+	report.Problems = checkMembers(manifest, members)
+	root, count := receiptsRoot(members)
+	if root != manifest.MerkleRoot || count != manifest.Receipts {
+		report.Problems = append(report.Problems, Problem{Kind: ProblemMerkleRoot, Detail: fmt.Sprintf("%d receipts do not match the manifest's %d and Merkle root", count, manifest.Receipts)})
+	}
+	contents, problems := decodeMembers(members)
+	report.Problems = append(report.Problems, problems...)
+	report.Receipts = len(contents.Receipts)
+
+	verifyOptions := options.Options
+	if contents.TrustBundle != nil && options.BundleKey != nil {
+		if err := tecp.VerifyTrustBundle(contents.TrustBundle, options.BundleKey); err != nil {
+			report.Problems = append(report.Problems, Problem{File: TrustBundleName, Kind: ProblemBundle, Detail: err.Error()})
+		} else {
+			verifyOptions.TrustBundle = contents.TrustBundle
+		}
+	}
+	logs, err := trustedLogs(verifyOptions)
+	if err != nil {
+		report.Problems = append(report.Problems, Problem{File: TrustBundleName, Kind: ProblemBundle, Detail: err.Error()})
+	}
+
+	for i := range contents.TreeHeads {
+		sth := &contents.TreeHeads[i]
+		if err := verifyTreeHead(sth, logs); err != nil {
+			report.Problems = append(report.Problems, Problem{File: fmt.Sprintf("%s%06d.json", treeHeadsDir, i), Kind: ProblemTreeHead, Detail: err.Error()})
+		}
+	}
+	report.Problems = append(report.Problems, checkForks(contents.TreeHeads)...)
+
+	verifier := options.Verifier
+	if verifier == nil {
+		verifier = tecp.NewClient()
+	}
+	for _, receipt := range contents.Receipts {
+		key, err := store.Key(receipt)
+		if err != nil {
+			continue
+		}
+		name := receiptsDir + key + ".json"
+		hash, _ := tecp.ReceiptHash(receipt)
+		for _, proof := range contents.Proofs[key] {
+			report.Proofs++
+			publicKey, ok := logs[proof.STH.KeyID]
+			if !ok {
+				report.Problems = append(report.Problems, Problem{File: proofsDir + key + ".json", Kind: ProblemProof, Detail: fmt.Sprintf("inclusion proof from untrusted log %q", proof.STH.KeyID)})
+				continue
+			}
+			if err := proof.Verify(hash, publicKey); err != nil {
+				report.Problems = append(report.Problems, Problem{File: proofsDir + key + ".json", Kind: ProblemProof, Detail: err.Error()})
+				continue
+			}
+			proof := proof
+			if err := tecp.EmbedInclusionProof(receipt, &proof); err != nil {
+				report.Problems = append(report.Problems, Problem{File: proofsDir + key + ".json", Kind: ProblemProof, Detail: err.Error()})
+			}
+		}
+
+		if options.SkipReceipts {
+			continue
+		}
+		result, err := verifier.VerifyReceipt(receipt, verifyOptions)
+		switch {
+		case err != nil:
+			report.Problems = append(report.Problems, Problem{File: name, Kind: ProblemInvalid, Detail: err.Error()})
+		case !result.Valid:
+			report.Problems = append(report.Problems, Problem{File: name, Kind: ProblemInvalid, Detail: strings.Join(result.Errors, "; ")})
+		default:
+			report.Verified++
+		}
+	}
+	for key := range contents.Proofs {
+		if !hasReceipt(contents.Receipts, key) {
+			report.Problems = append(report.Problems, Problem{File: proofsDir + key + ".json", Kind: ProblemUnlisted, Detail: "proofs for a receipt that is not in the pack"})
+		}
+	}
+	return report, nil
+}
+
+// trustedLogs returns the keys of the logs trusted by options and their
+// trust bundle, by key ID
+func trustedLogs(options tecp.VerifyOptions) (map[string]ed25519.PublicKey, error) {
+	logs := make(map[string]ed25519.PublicKey)
+	for _, log := range options.Logs {
+		logs[log.KeyID] = log.PublicKey
+	}
+	if options.TrustBundle == nil {
+		return logs, nil
+	}
+	bundled, err := options.TrustBundle.Logs.PublicKeys()
+	for keyID, publicKey := range bundled {
+		logs[keyID] = publicKey
+	}
+	return logs, err
+}
+
+// verifyTreeHead checks a packed tree head's signature by a trusted log
+func verifyTreeHead(sth *tecp.SignedTreeHead, logs map[string]ed25519.PublicKey) error {
+	publicKey, ok := logs[sth.KeyID]
+	if !ok {
+		return fmt.Errorf("tree head from untrusted log %q", sth.KeyID)
+	}
+	return tecp.VerifyTreeHead(sth, publicKey)
+}
+
+// checkForks reports tree heads of one log with the same size but
+// different roots, proof the log forked
+func checkForks(heads []tecp.SignedTreeHead) []Problem {
+	var problems []Problem
+	roots := make(map[string]string)
+	for _, sth := range heads {
+		id := fmt.Sprintf("%s/%d", sth.KeyID, sth.Size)
+		if root, ok := roots[id]; ok && root != sth.Root {
+			problems = append(problems, Problem{Kind: ProblemTreeHead, Detail: fmt.Sprintf("log %q has two tree heads of size %d with different roots", sth.KeyID, sth.Size)})
+		}
+		roots[id] = sth.Root
+	}
+	return problems
+}
+
+// hasReceipt reports whether receipts include the one with key
+func hasReceipt(receipts []*tecp.Receipt, key string) bool {
+	for _, receipt := range receipts {
+		if k, err := store.Key(receipt); err == nil && k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Package recovery recovers an issuer from a signing key compromise.
+//
+// Recovery takes two steps. Compromise marks the key compromised as of the
+// time it was exposed, in a key statement signed by a key authority, naming
+// the successor key, and anchored in a transparency log. Verifiers given
+// the statement then refuse receipts the key signed from that time on
+// (key_compromised), and warn about earlier ones (W-KEY-005), which the
+// thief could have backdated. Reattest then counter-signs, with the
+// successor, every archived receipt that is still genuine, so verifiers
+// can accept those and refuse the rest.
+package recovery
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+	"github.com/tecp-protocol/tecp-sdk-go/tecplog"
+)
+
+// Compromise returns a statement marking key compromised since the given
+// time, naming successor as its replacement, signed by authority. When log
+// is set the statement is submitted to it and carries its inclusion proof
+func Compromise(key, successor ed25519.PublicKey, since time.Time, reason string, authority ed25519.PrivateKey, log *tecplog.Client) (*tecp.KeyStatement, error) {
+	statement := &tecp.KeyStatement{
+		Event:     tecp.KeyCompromised,
+		PublicKey: base64.StdEncoding.EncodeToString(key),
+		Effective: since.UnixMilli(),
+		Reason:    reason,
+		Successor: base64.StdEncoding.EncodeToString(successor),
+	}
+	tecp.SignKeyStatement(statement, authority)
+	if log != nil {
+		if err := log.SubmitKeyStatement(statement); err != nil {
+			return nil, fmt.Errorf("recovery: failed to log statement: %w", err)
+		}
+	}
+	return statement, nil
+}
+
+// Options configures Reattest
+type Options struct {
+	// Successor counter-signs the genuine receipts. It must be the
+	// statement's successor or a key authority
+	Successor ed25519.PrivateKey
+
+	// Verify verifies each receipt before it is re-attested. The timestamp
+	// check is always disabled, since archived receipts are old
+	Verify tecp.VerifyOptions
+
+	// Genuine, when set, tells receipts the issuer's own records show it
+	// issued from possible forgeries, such as by sequence number
+	Genuine func(receipt *tecp.Receipt) bool
+}
+
+// Report is the outcome of Reattest, listing receipts by store key
+type Report struct {
+	// Reattested receipts were counter-signed and stored back
+	Reattested []string `json:"reattested,omitempty"`
+
+	// Already counts receipts that were already re-attested
+	Already int `json:"already"`
+
+	// Revoked receipts were signed at or after the compromise
+	Revoked []string `json:"revoked,omitempty"`
+
+	// Rejected receipts failed verification or were not Genuine
+	Rejected []string `json:"rejected,omitempty"`
+}
+
+// Reattest counter-signs every receipt in st that the statement's key
+// signed before its compromise and that still verifies, storing it back
+// with a reattested annotation. Receipts from other keys are left alone
+func Reattest(st store.ReceiptStore, statement *tecp.KeyStatement, options Options) (*Report, error) {
+	if statement.Event != tecp.KeyCompromised {
+		return nil, fmt.Errorf("recovery: statement is not a compromise")
+	}
+	if err := tecp.VerifyKeyStatement(statement); err != nil {
+		return nil, fmt.Errorf("recovery: %w", err)
+	}
+	compromised, err := base64.StdEncoding.DecodeString(statement.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("recovery: invalid statement key: %w", err)
+	}
+	successor := tecp.NewClient(tecp.WithSigner(options.Successor))
+	verifyOptions := options.Verify
+	verifyOptions.DisabledChecks = append(append([]string(nil), verifyOptions.DisabledChecks...), tecp.CheckTimestamp)
+
+	// Receipts are stored back after the walk, which may hold the store
+	report := &Report{}
+	var reattested []*tecp.Receipt
+	err = st.Walk(func(key string, receipt *tecp.Receipt) error {
+		if publicKey, _, err := tecp.DecodeBinary(receipt.PublicKey, ed25519.PublicKeySize); err != nil || !bytes.Equal(publicKey, compromised) {
+			return nil
+		}
+		switch {
+		case receipt.Timestamp >= statement.Effective:
+			report.Revoked = append(report.Revoked, key)
+			return nil
+		case isReattested(receipt, options.Successor):
+			report.Already++
+			return nil
+		case options.Genuine != nil && !options.Genuine(receipt):
+			report.Rejected = append(report.Rejected, key)
+			return nil
+		}
+		result, err := successor.VerifyReceipt(receipt, verifyOptions)
+		if err != nil || !result.Valid {
+			report.Rejected = append(report.Rejected, key)
+			return nil
+		}
+		if _, err := successor.Annotate(receipt, tecp.AnnotationReattested, "re-attested after key compromise", nil); err != nil {
+			return err
+		}
+		report.Reattested = append(report.Reattested, key)
+		reattested = append(reattested, receipt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, receipt := range reattested {
+		if _, err := st.Put(receipt); err != nil {
+			return report, fmt.Errorf("recovery: failed to store %s: %w", report.Reattested[i], err)
+		}
+	}
+	return report, nil
+}
+
+// isReattested reports whether successor has already re-attested receipt
+func isReattested(receipt *tecp.Receipt, successor ed25519.PrivateKey) bool {
+	annotator := base64.StdEncoding.EncodeToString(successor.Public().(ed25519.PublicKey))
+	annotations, _ := tecp.Annotations(receipt)
+	for _, annotation := range annotations {
+		if annotation.Type == tecp.AnnotationReattested && annotation.Annotator == annotator {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+// Package rediscache provides a Redis-backed tecp.VerificationCache, so a
+// fleet of gateways can share verification outcomes.
+//
+// It speaks the Redis protocol directly and needs only GET and SET with an
+// expiry, so it works with Redis, Valkey, KeyDB and compatible services.
+package rediscache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Defaults applied by New
+const (
+	DefaultTTL         = 10 * time.Minute
+	DefaultPrefix      = "tecp:verify:"
+	DefaultPoolSize    = 4
+	DefaultDialTimeout = 2 * time.Second
+)
+
+// errNil is the Redis nil reply, returned for missing keys
+var errNil = errors.New("rediscache: nil reply")
+
+// Options configures a Redis verification cache
+type Options struct {
+	// Addr is the server's host:port
+	Addr     string
+	Password string
+	DB       int
+
+	// TTL is the lifetime of cached entries
+	TTL time.Duration
+
+	// Prefix is prepended to every cache key
+	Prefix string
+
+	// PoolSize bounds the number of idle connections kept open
+	PoolSize int
+
+	// DialTimeout bounds connecting and each request round trip
+	DialTimeout time.Duration
+
+	// OnError, when set, is called with errors that are otherwise reported
+	// to the verifier as cache misses
+	OnError func(error)
+}
+
+// Cache is a tecp.VerificationCache stored in Redis
+type Cache struct {
+	opts Options
+	idle chan *conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// New creates a cache; connections are opened on first use
+func New(opts Options) *Cache {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = DefaultPoolSize
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultDialTimeout
+	}
+	return &Cache{opts: opts, idle: make(chan *conn, opts.PoolSize)}
+}
+
+// Get returns the cached outcome for key
+func (c *Cache) Get(key string) (*tecp.CachedVerification, bool) {
+	reply, err := c.do("GET", c.opts.Prefix+key)
+	if err == errNil {
+		return nil, false
+	}
+	if err != nil {
+		c.report(err)
+		return nil, false
+	}
+
+	var entry tecp.CachedVerification
+	if err := json.Unmarshal(reply, &entry); err != nil {
+		c.report(fmt.Errorf("rediscache: invalid entry %s: %w", key, err))
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Put stores an outcome under key for the configured TTL
+func (c *Cache) Put(key string, entry *tecp.CachedVerification) {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		c.report(err)
+		return
+	}
+	ttl := strconv.FormatInt(c.opts.TTL.Milliseconds(), 10)
+	if _, err := c.do("SET", c.opts.Prefix+key, string(value), "PX", ttl); err != nil {
+		c.report(err)
+	}
+}
+
+// Close closes idle connections
+func (c *Cache) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Cache) report(err error) {
+	if c.opts.OnError != nil {
+		c.opts.OnError(err)
+	}
+}
+
+// do runs one command on a pooled connection
+func (c *Cache) do(args ...string) ([]byte, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.roundTrip(c.opts.DialTimeout, args...)
+	if err != nil && err != errNil {
+		// The connection state is unknown after a failure
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+func (c *Cache) get() (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", c.opts.Addr, c.opts.DialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("rediscache: %w", err)
+	}
+	cn := &conn{Conn: netConn, r: bufio.NewReader(netConn)}
+
+	if c.opts.Password != "" {
+		if _, err := cn.roundTrip(c.opts.DialTimeout, "AUTH", c.opts.Password); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := cn.roundTrip(c.opts.DialTimeout, "SELECT", strconv.Itoa(c.opts.DB)); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Cache) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// roundTrip sends a command and reads its reply
+func (cn *conn) roundTrip(timeout time.Duration, args ...string) ([]byte, error) {
+	cn.SetDeadline(time.Now().Add(timeout))
+
+	// Commands are arrays of bulk strings
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := cn.Write(buf); err != nil {
+		return nil, fmt.Errorf("rediscache: %w", err)
+	}
+	return cn.readReply()
+}
+
+// readReply reads a simple string, error, integer or bulk string reply
+func (cn *conn) readReply() ([]byte, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("rediscache: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("rediscache: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+', ':':
+		return []byte(payload), nil
+	case '-':
+		return nil, fmt.Errorf("rediscache: server error: %s", payload)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("rediscache: malformed bulk length %q", payload)
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, data); err != nil {
+			return nil, fmt.Errorf("rediscache: %w", err)
+		}
+		return data[:n], nil
+	default:
+		return nil, fmt.Errorf("rediscache: unexpected reply type %q", kind)
+	}
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"rsc.io/qr"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+var htmlTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2937; max-width: 760px; margin: 2em auto; }
+h1 { font-size: 1.4em; margin-bottom: 0.2em; }
+.status { font-weight: 600; }
+.valid { color: #047857; } .invalid { color: #b91c1c; } .unverified { color: #6b7280; }
+table { border-collapse: collapse; width: 100%; margin-top: 1em; }
+th { text-align: left; vertical-align: top; width: 9em; padding: 4px 8px 4px 0; color: #4b5563; font-weight: 500; }
+td { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 0.85em; word-break: break-all; padding: 4px 0; }
+.qr { float: right; margin-left: 1em; text-align: center; font-size: 0.75em; color: #6b7280; }
+ul { margin: 0.4em 0; }
+</style>
+</head>
+<body>
+{{if .QRCode}}<div class="qr"><a href="{{.Link}}">{{.QRCode}}</a><br>Scan to verify</div>{{end}}
+<h1>{{.Title}}</h1>
+<p class="status {{.StatusClass}}">{{.Status}}</p>
+{{if .Signed}}<p class="unverified">Summary signed by {{.Signed.SignedBy}}</p>{{end}}
+{{if .Errors}}<ul class="invalid">{{range .Errors}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Warnings}}<ul class="unverified">{{range .Warnings}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<table>
+{{range .Fields}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+<script type="application/json" id="tecp-receipt">{{.ReceiptJSON}}</script>
+{{if .Signed}}<script type="application/json" id="tecp-summary">{{.SummaryJSON}}</script>
+{{end}}</body>
+</html>
+`))
+
+// HTML writes a standalone HTML summary of a receipt. The signed receipt is
+// embedded as JSON in the script element with id "tecp-receipt", and any
+// signed summary in the one with id "tecp-summary"
+func HTML(receipt *tecp.Receipt, w io.Writer, opts Options) error {
+	s, err := summarize(receipt, opts)
+	if err != nil {
+		return err
+	}
+	return writeHTML(w, s)
+}
+
+// writeHTML renders a summary as HTML
+func writeHTML(w io.Writer, s *summary) error {
+	statusClass := "unverified"
+	if s.Verified && s.Valid {
+		statusClass = "valid"
+	} else if s.Verified {
+		statusClass = "invalid"
+	}
+
+	var qrCode template.HTML
+	if s.QR != nil {
+		qrCode = template.HTML(qrSVG(s.QR, 4))
+	}
+
+	return htmlTemplate.Execute(w, struct {
+		*summary
+		StatusClass string
+		QRCode      template.HTML
+		ReceiptJSON template.JS
+		SummaryJSON template.JS
+	}{
+		summary:     s,
+		StatusClass: statusClass,
+		QRCode:      qrCode,
+		// ToJSON escapes <, > and & so the receipt cannot close the script element
+		ReceiptJSON: template.JS(s.Receipt),
+		SummaryJSON: template.JS(s.SignedJSON),
+	})
+}
+
+// qrSVG renders a QR code as an SVG image with scale pixels per module and
+// the standard four-module quiet zone
+func qrSVG(code *qr.Code, scale int) string {
+	const quiet = 4
+	size := (code.Size + 2*quiet) * scale
+
+	var path strings.Builder
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+quiet, y+quiet)
+			}
+		}
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		size, size, code.Size+2*quiet, code.Size+2*quiet, path.String())
+}
@@ -0,0 +1,182 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// A4 page geometry in PDF points
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+	pdfValueX     = 150
+	pdfQRSize     = 120
+
+	// pdfValueWidth is the number of 8pt Courier characters that fit between
+	// pdfValueX and the right margin
+	pdfValueWidth = 80
+)
+
+// PDF writes a single-page PDF summary of a receipt. The signed receipt JSON
+// is stored, hex encoded, under the TECPReceipt key of the document
+// information dictionary, and any signed summary under TECPSummary
+func PDF(receipt *tecp.Receipt, w io.Writer, opts Options) error {
+	s, err := summarize(receipt, opts)
+	if err != nil {
+		return err
+	}
+	return writePDF(w, s)
+}
+
+// writePDF renders a summary as a PDF
+func writePDF(w io.Writer, s *summary) error {
+	var content bytes.Buffer
+	y := pdfPageHeight - pdfMargin - 20
+
+	text := func(font string, size, x, y int, value string) {
+		fmt.Fprintf(&content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, x, y, pdfString(value))
+	}
+
+	text("F2", 18, pdfMargin, y, s.Title)
+	y -= 24
+	text("F2", 11, pdfMargin, y, s.Status)
+	y -= 18
+	if s.Signed != nil {
+		text("F1", 8, pdfMargin, y, "Summary signed by "+s.Signed.SignedBy)
+		y -= 14
+	}
+
+	for _, msg := range s.Errors {
+		text("F1", 9, pdfMargin, y, "Error: "+msg)
+		y -= 12
+	}
+	for _, msg := range s.Warnings {
+		text("F1", 9, pdfMargin, y, "Warning: "+msg)
+		y -= 12
+	}
+
+	// Keep the field table clear of the QR code in the top-right corner
+	if s.QR != nil {
+		if top := pdfPageHeight - pdfMargin - pdfQRSize - 16; y > top {
+			y = top
+		}
+	}
+	y -= 8
+
+	for _, f := range s.Fields {
+		text("F1", 9, pdfMargin, y, f.Label)
+		for _, line := range wrap(f.Value, pdfValueWidth) {
+			text("F3", 8, pdfValueX, y, line)
+			y -= 11
+		}
+		y -= 4
+	}
+
+	if s.QR != nil {
+		// Draw dark modules as filled squares; PDF y grows upwards
+		module := float64(pdfQRSize) / float64(s.QR.Size)
+		left := float64(pdfPageWidth - pdfMargin - pdfQRSize)
+		top := float64(pdfPageHeight - pdfMargin)
+		content.WriteString("0 g\n")
+		for qy := 0; qy < s.QR.Size; qy++ {
+			for qx := 0; qx < s.QR.Size; qx++ {
+				if s.QR.Black(qx, qy) {
+					fmt.Fprintf(&content, "%.2f %.2f %.2f %.2f re\n",
+						left+float64(qx)*module, top-float64(qy+1)*module, module, module)
+				}
+			}
+		}
+		content.WriteString("f\n")
+		text("F1", 7, int(left)+30, int(top)-pdfQRSize-10, "Scan to verify")
+	}
+
+	page := fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents 4 0 R "+
+		"/Resources << /Font << /F1 5 0 R /F2 6 0 R /F3 7 0 R >> >>", pdfPageWidth, pdfPageHeight)
+	if s.QR != nil {
+		page += " /Annots [8 0 R]"
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		page + " >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	}
+	if s.QR != nil {
+		// Make the QR code area a clickable link to the verifier
+		left := pdfPageWidth - pdfMargin - pdfQRSize
+		top := pdfPageHeight - pdfMargin
+		objects = append(objects, fmt.Sprintf("<< /Type /Annot /Subtype /Link /Border [0 0 0] /Rect [%d %d %d %d] "+
+			"/A << /S /URI /URI (%s) >> >>", left, top-pdfQRSize, left+pdfQRSize, top, pdfString(s.Link)))
+	}
+	info := fmt.Sprintf("<< /Title (%s) /Producer (tecp-sdk-go) /TECPReceipt <%x>", pdfString(s.Title), s.Receipt)
+	if s.Signed != nil {
+		info += fmt.Sprintf(" /TECPSummary <%x>", s.SignedJSON)
+	}
+	objects = append(objects, info+" >>")
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, len(objects), xref)
+
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+// pdfString escapes a value for a PDF literal string. Characters outside
+// WinAnsi's Latin-1 range are replaced, as the standard fonts cannot show them
+func pdfString(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r <= 0xff:
+			fmt.Fprintf(&b, `\%03o`, r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// wrap splits value into lines of at most width characters
+func wrap(value string, width int) []string {
+	runes := []rune(value)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	for len(runes) > width {
+		lines = append(lines, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(lines, string(runes))
+}
@@ -0,0 +1,209 @@
+// Package render produces human-readable receipt summaries for customer-facing
+// compliance reports.
+//
+// Summaries are available as HTML and PDF. Both show the signed receipt fields,
+// the verification outcome when one is supplied, and a QR code linking to a
+// verification endpoint with the receipt attached (see tecp.Receipt.ToURL).
+// Both also embed the receipt itself, so the document can be re-verified
+// without access to the original archive. With Options.Signer the summary's
+// content is signed and embedded too, so VerifyDocument can check that what
+// the document shows is what the signer vouched for.
+package render
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"rsc.io/qr"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// DefaultTitle is used when Options.Title is empty
+const DefaultTitle = "TECP Receipt"
+
+// Options configures a rendered summary
+type Options struct {
+	Title string
+
+	// VerifierURL is the verification endpoint the QR code links to. When
+	// empty, no QR code is rendered
+	VerifierURL string
+
+	// Result is the outcome of verifying the receipt. When nil, the summary
+	// is marked as not verified
+	Result *tecp.VerificationResult
+
+	// Signer, when set, signs the summary's content, which is embedded as a
+	// SignedSummary
+	Signer ed25519.PrivateKey
+}
+
+// field is a labelled line of the summary
+type field struct {
+	Label string
+	Value string
+}
+
+// summary is the format-independent content of a rendered receipt
+type summary struct {
+	Title    string
+	Status   string
+	Valid    bool
+	Verified bool
+	Errors   []string
+	Warnings []string
+	Fields   []field
+	Link     string
+	QR       *qr.Code
+	Receipt  string
+	Hash     string
+
+	// Signed is the signed content, when Options.Signer is set, and
+	// SignedJSON its encoding
+	Signed     *SignedSummary
+	SignedJSON string
+}
+
+// VerificationLink returns a link to the verifier endpoint referencing the
+// receipt by hash, in the receipt query parameter, for verifiers with access
+// to the receipt archive
+func VerificationLink(receipt *tecp.Receipt, verifierURL string) (string, error) {
+	hash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(verifierURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid verifier URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("receipt", hex.EncodeToString(hash))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// QRCode returns a PNG QR code for a link carrying the whole receipt, so it
+// can be verified from a phone. Receipts too large for a QR code fall back to
+// a VerificationLink
+func QRCode(receipt *tecp.Receipt, verifierURL string) ([]byte, error) {
+	_, code, err := shareCode(receipt, verifierURL)
+	if err != nil {
+		return nil, err
+	}
+	return code.PNG(), nil
+}
+
+// shareCode returns the verification link for a receipt and its QR code
+func shareCode(receipt *tecp.Receipt, verifierURL string) (string, *qr.Code, error) {
+	link, err := receipt.ToURL(verifierURL)
+	if err != nil {
+		return "", nil, err
+	}
+	if code, err := qr.Encode(link, qr.M); err == nil {
+		return link, code, nil
+	}
+
+	// Receipts carrying certificate chains or large evidence can exceed QR
+	// capacity; link by hash instead
+	link, err = VerificationLink(receipt, verifierURL)
+	if err != nil {
+		return "", nil, err
+	}
+	code, err := qr.Encode(link, qr.M)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return link, code, nil
+}
+
+// summarize collects the content shared by every output format
+func summarize(receipt *tecp.Receipt, opts Options) (*summary, error) {
+	hash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := receipt.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &summary{
+		Title:   opts.Title,
+		Status:  "Not verified",
+		Receipt: string(encoded),
+		Hash:    hex.EncodeToString(hash),
+	}
+	if s.Title == "" {
+		s.Title = DefaultTitle
+	}
+
+	if opts.Result != nil {
+		s.Verified = true
+		s.Valid = opts.Result.Valid
+		s.Errors = opts.Result.Errors
+		s.Warnings = opts.Result.Warnings
+		if s.Valid {
+			s.Status = "Valid"
+		} else {
+			s.Status = "Invalid"
+		}
+		if opts.Result.Profile != "" {
+			s.Status += " (" + string(opts.Result.Profile) + " profile)"
+		}
+	}
+
+	policies := "none"
+	if len(receipt.PolicyIDs) > 0 {
+		policies = strings.Join(receipt.PolicyIDs, ", ")
+	}
+
+	s.Fields = []field{
+		{"Issued", time.UnixMilli(receipt.Timestamp).UTC().Format("2006-01-02 15:04:05.000 MST")},
+		{"Code", receipt.CodeRef},
+		{"Policies", policies},
+		{"Version", receipt.Version},
+		{"Input hash", receipt.InputHash},
+		{"Output hash", receipt.OutputHash},
+		{"Nonce", receipt.Nonce},
+		{"Receipt hash", s.Hash},
+		{"Signer key", receipt.PublicKey},
+		{"Signature", receipt.Signature},
+	}
+
+	if len(receipt.Extensions) > 0 {
+		names := make([]string, 0, len(receipt.Extensions))
+		for name := range receipt.Extensions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		s.Fields = append(s.Fields, field{"Extensions", strings.Join(names, ", ")})
+	}
+
+	if opts.VerifierURL != "" {
+		s.Link, s.QR, err = shareCode(receipt, opts.VerifierURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Signer != nil {
+		s.Signed = signSummary(s, opts.Signer)
+		// json.Marshal escapes <, > and & so the summary cannot close the
+		// script element of HTML summaries
+		signed, err := json.Marshal(s.Signed)
+		if err != nil {
+			return nil, err
+		}
+		s.SignedJSON = string(signed)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Field is a labelled value of a report section
+type Field struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Section is a headed group of fields in a report
+type Section struct {
+	Heading string
+	Fields  []Field
+}
+
+// ReportPDF writes a multi-page PDF report, such as a compliance report
+// aggregating many receipts, with the same layout as receipt summaries
+func ReportPDF(w io.Writer, title string, sections []Section) error {
+	var pages []*bytes.Buffer
+	var content *bytes.Buffer
+	y := 0
+
+	newPage := func() {
+		content = new(bytes.Buffer)
+		pages = append(pages, content)
+		y = pdfPageHeight - pdfMargin - 20
+	}
+	text := func(font string, size, x int, value string) {
+		fmt.Fprintf(content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, x, y, pdfString(value))
+	}
+	// need starts a new page unless height points remain above the margin
+	need := func(height int) {
+		if y-height < pdfMargin {
+			newPage()
+		}
+	}
+
+	newPage()
+	text("F2", 18, pdfMargin, title)
+	y -= 30
+
+	for _, section := range sections {
+		need(40)
+		text("F2", 12, pdfMargin, section.Heading)
+		y -= 18
+		for _, f := range section.Fields {
+			// Wrap on word boundaries where possible, as report values are prose
+			lines := wrapWords(f.Value, pdfValueWidth)
+			need(11*len(lines) + 4)
+			text("F1", 9, pdfMargin, f.Label)
+			for _, line := range lines {
+				need(11)
+				text("F3", 8, pdfValueX, line)
+				y -= 11
+			}
+			y -= 4
+		}
+		y -= 10
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title (%s) /Producer (tecp-sdk-go) >>", pdfString(title)),
+	}
+	kids := make([]string, len(pages))
+	for i, page := range pages {
+		pageID := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", pageID)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R "+
+				"/Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> >>", pdfPageWidth, pdfPageHeight, pageID+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, xref)
+
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+// wrapWords splits value into lines of at most width characters, breaking
+// between words and splitting only words longer than a line
+func wrapWords(value string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(value) {
+		for len([]rune(word)) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case len([]rune(line))+1+len([]rune(word)) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	return append(lines, line)
+}
@@ -0,0 +1,268 @@
+package render
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rsc.io/qr"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+const summaryVersion = "TECP-SUMMARY-0.1"
+
+// SignedSummary is the signed content of a rendered summary: everything it
+// shows, bound to the receipt by hash. It is embedded in HTML summaries in
+// the script element with id "tecp-summary", and in PDF summaries, hex
+// encoded, under the TECPSummary key of the document information
+// dictionary
+type SignedSummary struct {
+	Version     string   `json:"v"`
+	Title       string   `json:"title"`
+	Status      string   `json:"status"`
+	Verified    bool     `json:"verified"`
+	Valid       bool     `json:"valid"`
+	Errors      []string `json:"errors,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Fields      []Field  `json:"fields"`
+	ReceiptHash string   `json:"receipt_hash"`
+
+	// Link is the verification link the QR code encodes, if any
+	Link string `json:"link,omitempty"`
+
+	// SignedAt is when the summary was signed, in Unix milliseconds
+	SignedAt int64 `json:"signed_at"`
+
+	// SignedBy is the base64 Ed25519 key that signed the summary
+	SignedBy  string `json:"signed_by"`
+	Signature string `json:"sig,omitempty"`
+}
+
+// SigningPayload returns the bytes covered by the signature: the summary's
+// JSON encoding without the signature
+func (s *SignedSummary) SigningPayload() []byte {
+	unsigned := *s
+	unsigned.Signature = ""
+	payload, _ := json.Marshal(&unsigned)
+	return payload
+}
+
+// signSummary signs the content of a rendered summary
+func signSummary(s *summary, key ed25519.PrivateKey) *SignedSummary {
+	signed := &SignedSummary{
+		Version:     summaryVersion,
+		Title:       s.Title,
+		Status:      s.Status,
+		Verified:    s.Verified,
+		Valid:       s.Valid,
+		Errors:      s.Errors,
+		Warnings:    s.Warnings,
+		Fields:      exportFields(s.Fields),
+		ReceiptHash: s.Hash,
+		Link:        s.Link,
+		SignedAt:    time.Now().UnixMilli(),
+		SignedBy:    base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}
+	signed.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, signed.SigningPayload()))
+	return signed
+}
+
+func exportFields(fields []field) []Field {
+	out := make([]Field, len(fields))
+	for i, f := range fields {
+		out[i] = Field{Label: f.Label, Value: f.Value}
+	}
+	return out
+}
+
+// VerifySummary checks that a summary was signed by publicKey and that the
+// receipt fields it shows are those of receipt. The status, errors and
+// warnings are the signer's verification outcome and are covered by the
+// signature, not recomputed
+func VerifySummary(signed *SignedSummary, receipt *tecp.Receipt, publicKey ed25519.PublicKey) error {
+	if signed.Version != summaryVersion {
+		return fmt.Errorf("render: unsupported summary version: %s", signed.Version)
+	}
+	signer, err := base64.StdEncoding.DecodeString(signed.SignedBy)
+	if err != nil || len(signer) != ed25519.PublicKeySize {
+		return fmt.Errorf("render: invalid summary signer")
+	}
+	if !ed25519.PublicKey(signer).Equal(publicKey) {
+		return fmt.Errorf("render: summary is not signed by the expected key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		return fmt.Errorf("render: invalid summary signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, signed.SigningPayload(), signature) {
+		return fmt.Errorf("render: summary signature verification failed")
+	}
+
+	expected, err := summarize(receipt, Options{Title: signed.Title})
+	if err != nil {
+		return err
+	}
+	if signed.ReceiptHash != expected.Hash {
+		return fmt.Errorf("render: summary is for a different receipt")
+	}
+	fields := exportFields(expected.Fields)
+	if len(fields) != len(signed.Fields) {
+		return fmt.Errorf("render: summary fields do not match the receipt")
+	}
+	for i := range fields {
+		if fields[i] != signed.Fields[i] {
+			return fmt.Errorf("render: summary field %q does not match the receipt", signed.Fields[i].Label)
+		}
+	}
+	return nil
+}
+
+// embedded is the raw content embedded in a summary document
+type embedded struct {
+	pdf         bool
+	summaryJSON []byte
+	receiptJSON []byte
+}
+
+// extract finds the signed summary and receipt embedded in a document
+func extract(document []byte) (*embedded, error) {
+	e := &embedded{pdf: bytes.HasPrefix(document, []byte("%PDF-"))}
+	if e.pdf {
+		var err error
+		if e.summaryJSON, err = pdfInfoValue(document, "TECPSummary"); err != nil {
+			return nil, err
+		}
+		if e.receiptJSON, err = pdfInfoValue(document, "TECPReceipt"); err != nil {
+			return nil, err
+		}
+	} else {
+		e.summaryJSON = htmlScript(document, "tecp-summary")
+		e.receiptJSON = htmlScript(document, "tecp-receipt")
+	}
+	if e.summaryJSON == nil {
+		return nil, fmt.Errorf("render: document has no signed summary")
+	}
+	if e.receiptJSON == nil {
+		return nil, fmt.Errorf("render: document has no embedded receipt")
+	}
+	return e, nil
+}
+
+// Extract returns the signed summary and receipt embedded in an HTML or
+// PDF summary document
+func Extract(document []byte) (*SignedSummary, *tecp.Receipt, error) {
+	e, err := extract(document)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e.decode()
+}
+
+// decode parses the embedded summary and receipt
+func (e *embedded) decode() (*SignedSummary, *tecp.Receipt, error) {
+	var signed SignedSummary
+	if err := json.Unmarshal(e.summaryJSON, &signed); err != nil {
+		return nil, nil, fmt.Errorf("render: invalid signed summary: %w", err)
+	}
+	receipt, err := tecp.FromJSON(e.receiptJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("render: invalid embedded receipt: %w", err)
+	}
+	return &signed, receipt, nil
+}
+
+// VerifyDocument checks an HTML or PDF summary: that its signed summary
+// verifies with VerifySummary against the embedded receipt, and that the
+// document shows exactly the signed content, by rendering it again. The
+// receipt itself is not verified; pass it to tecp.Client.VerifyReceipt to
+// do so
+func VerifyDocument(document []byte, publicKey ed25519.PublicKey) (*SignedSummary, *tecp.Receipt, error) {
+	e, err := extract(document)
+	if err != nil {
+		return nil, nil, err
+	}
+	signed, receipt, err := e.decode()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := VerifySummary(signed, receipt, publicKey); err != nil {
+		return nil, nil, err
+	}
+
+	// Render the signed content again, so nothing outside it is shown
+	s := &summary{
+		Title:      signed.Title,
+		Status:     signed.Status,
+		Valid:      signed.Valid,
+		Verified:   signed.Verified,
+		Errors:     signed.Errors,
+		Warnings:   signed.Warnings,
+		Link:       signed.Link,
+		Receipt:    string(e.receiptJSON),
+		Hash:       signed.ReceiptHash,
+		Signed:     signed,
+		SignedJSON: string(e.summaryJSON),
+	}
+	for _, f := range signed.Fields {
+		s.Fields = append(s.Fields, field{f.Label, f.Value})
+	}
+	if signed.Link != "" {
+		if s.QR, err = qr.Encode(signed.Link, qr.M); err != nil {
+			return nil, nil, fmt.Errorf("render: failed to encode QR code: %w", err)
+		}
+	}
+
+	var rendered bytes.Buffer
+	if e.pdf {
+		err = writePDF(&rendered, s)
+	} else {
+		err = writeHTML(&rendered, s)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(rendered.Bytes(), document) {
+		return nil, nil, fmt.Errorf("render: document content does not match its signed summary")
+	}
+	return signed, receipt, nil
+}
+
+// htmlScript returns the content of the script element with id
+func htmlScript(document []byte, id string) []byte {
+	open := []byte(`<script type="application/json" id="` + id + `">`)
+	start := bytes.Index(document, open)
+	if start < 0 {
+		return nil
+	}
+	content := document[start+len(open):]
+	end := bytes.Index(content, []byte("</script>"))
+	if end < 0 {
+		return nil
+	}
+	return content[:end]
+}
+
+// pdfInfoValue returns the hex string stored under key in the document
+// information dictionary
+func pdfInfoValue(document []byte, key string) ([]byte, error) {
+	marker := []byte("/" + key + " <")
+	start := bytes.Index(document, marker)
+	if start < 0 {
+		return nil, nil
+	}
+	content := document[start+len(marker):]
+	end := bytes.IndexByte(content, '>')
+	if end < 0 {
+		return nil, fmt.Errorf("render: unterminated %s value", key)
+	}
+	value, err := hex.DecodeString(string(content[:end]))
+	if err != nil {
+		return nil, fmt.Errorf("render: invalid %s value: %w", key, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,287 @@
+// Package sampling verifies a random subset of a receipt stream.
+//
+// Platforms issuing receipts at high volume can rarely afford to verify
+// every one. A Sampler observes the stream, keeps a uniform reservoir sample
+// per stratum, by default per issuer and policy set, and verifies Rate of
+// each stratum's receipts per window. Reports estimate the failure rate of
+// each stratum and of the whole stream, with confidence intervals, so a
+// clean sample bounds how many bad receipts can have gone unnoticed.
+//
+// Samples are drawn with crypto/rand, so issuers cannot predict which of
+// their receipts will be checked.
+package sampling
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Defaults applied by New
+const (
+	DefaultReservoirSize = 1000
+	DefaultConfidence    = 0.95
+)
+
+// Options configures a Sampler
+type Options struct {
+	// Verifier verifies the sampled receipts; defaults to a client with
+	// the default profile
+	Verifier      *tecp.Client
+	VerifyOptions tecp.VerifyOptions
+
+	// Rate is the fraction of each stratum's receipts verified per window,
+	// in (0, 1]
+	Rate float64
+
+	// Stratum, when set, names the stratum of a receipt; defaults to
+	// ByIssuerPolicy
+	Stratum func(receipt *tecp.Receipt) string
+
+	// ReservoirSize bounds the receipts sampled per stratum and window;
+	// defaults to DefaultReservoirSize
+	ReservoirSize int
+
+	// Confidence is the level of reported intervals; defaults to 0.95
+	Confidence float64
+
+	// Interval is the window length of Run; defaults to a minute
+	Interval time.Duration
+
+	// OnReport is called by Run with the report of every window
+	OnReport func(report *Report)
+
+	// OnError, when set, is called with the failures of Run
+	OnError func(err error)
+}
+
+// ByIssuerPolicy stratifies receipts by signing key and policy set
+func ByIssuerPolicy(receipt *tecp.Receipt) string {
+	policies := append([]string(nil), receipt.PolicyIDs...)
+	sort.Strings(policies)
+	return receipt.PublicKey + "|" + strings.Join(policies, ",")
+}
+
+// Failure is a sampled receipt that failed verification
+type Failure struct {
+	Stratum string        `json:"stratum"`
+	Receipt *tecp.Receipt `json:"receipt"`
+	Errors  []string      `json:"errors"`
+}
+
+// Estimate is an estimated failure rate with its confidence interval
+type Estimate struct {
+	Seen        int64   `json:"seen"`
+	Verified    int     `json:"verified"`
+	Failed      int     `json:"failed"`
+	FailureRate float64 `json:"failure_rate"`
+	Lower       float64 `json:"lower"`
+	Upper       float64 `json:"upper"`
+}
+
+// StratumReport is the estimate for one stratum
+type StratumReport struct {
+	Stratum string `json:"stratum"`
+	Estimate
+}
+
+// Report is the outcome of one sampling window. Overall weights each
+// stratum's estimate by its share of the stream; its interval is
+// approximate
+type Report struct {
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	Confidence float64         `json:"confidence"`
+	Overall    Estimate        `json:"overall"`
+	Strata     []StratumReport `json:"strata"`
+	Failures   []Failure       `json:"failures,omitempty"`
+}
+
+// reservoir is a uniform sample of a stratum's receipts
+type reservoir struct {
+	seen    int64
+	samples []*tecp.Receipt
+}
+
+// Sampler samples and verifies a receipt stream
+type Sampler struct {
+	opts Options
+
+	mu     sync.Mutex
+	start  time.Time
+	strata map[string]*reservoir
+}
+
+// New returns a Sampler
+func New(options Options) (*Sampler, error) {
+	if options.Rate <= 0 || options.Rate > 1 {
+		return nil, fmt.Errorf("sampling: rate must be in (0, 1]: %g", options.Rate)
+	}
+	if options.Confidence == 0 {
+		options.Confidence = DefaultConfidence
+	}
+	if options.Confidence <= 0 || options.Confidence >= 1 {
+		return nil, fmt.Errorf("sampling: confidence must be in (0, 1): %g", options.Confidence)
+	}
+	if options.ReservoirSize <= 0 {
+		options.ReservoirSize = DefaultReservoirSize
+	}
+	if options.Stratum == nil {
+		options.Stratum = ByIssuerPolicy
+	}
+	if options.Verifier == nil {
+		options.Verifier = tecp.NewClient()
+	}
+	return &Sampler{opts: options, start: time.Now(), strata: make(map[string]*reservoir)}, nil
+}
+
+// Observe adds a receipt of the stream to the current window
+func (s *Sampler) Observe(receipt *tecp.Receipt) error {
+	stratum := s.opts.Stratum(receipt)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.strata[stratum]
+	if !ok {
+		r = &reservoir{}
+		s.strata[stratum] = r
+	}
+
+	// Algorithm R: the n-th receipt replaces a random sample with
+	// probability size/n
+	r.seen++
+	if len(r.samples) < s.opts.ReservoirSize {
+		r.samples = append(r.samples, receipt)
+		return nil
+	}
+	j, err := randomIndex(r.seen)
+	if err != nil {
+		return err
+	}
+	if j < int64(len(r.samples)) {
+		r.samples[j] = receipt
+	}
+	return nil
+}
+
+// Verify ends the current window, verifies its samples and reports the
+// estimated failure rates
+func (s *Sampler) Verify() (*Report, error) {
+	s.mu.Lock()
+	strata, start := s.strata, s.start
+	s.strata, s.start = make(map[string]*reservoir), time.Now()
+	s.mu.Unlock()
+
+	report := &Report{Start: start, End: time.Now(), Confidence: s.opts.Confidence}
+	z := math.Sqrt2 * math.Erfinv(s.opts.Confidence)
+
+	names := make([]string, 0, len(strata))
+	for name := range strata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var total int64
+	for _, name := range names {
+		total += strata[name].seen
+	}
+
+	var variance float64
+	for _, name := range names {
+		r := strata[name]
+		n := min(len(r.samples), int(math.Ceil(s.opts.Rate*float64(r.seen))))
+
+		// Any subset of a uniform reservoir is itself uniform
+		estimate := Estimate{Seen: r.seen, Verified: n}
+		for _, receipt := range r.samples[:n] {
+			result, err := s.opts.Verifier.VerifyReceipt(receipt, s.opts.VerifyOptions)
+			if err != nil {
+				return nil, fmt.Errorf("sampling: %w", err)
+			}
+			if !result.Valid {
+				estimate.Failed++
+				report.Failures = append(report.Failures, Failure{Stratum: name, Receipt: receipt, Errors: result.Errors})
+			}
+		}
+		estimate.FailureRate, estimate.Lower, estimate.Upper = wilson(estimate.Failed, n, r.seen, z)
+		report.Strata = append(report.Strata, StratumReport{Stratum: name, Estimate: estimate})
+
+		weight := float64(r.seen) / float64(total)
+		report.Overall.Seen += r.seen
+		report.Overall.Verified += n
+		report.Overall.Failed += estimate.Failed
+		report.Overall.FailureRate += weight * estimate.FailureRate
+		half := (estimate.Upper - estimate.Lower) / 2
+		variance += weight * weight * half * half
+	}
+	half := math.Sqrt(variance)
+	report.Overall.Lower = math.Max(0, report.Overall.FailureRate-half)
+	report.Overall.Upper = math.Min(1, report.Overall.FailureRate+half)
+	return report, nil
+}
+
+// Run verifies a window every Interval until ctx is done
+func (s *Sampler) Run(ctx context.Context) error {
+	interval := s.opts.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		report, err := s.Verify()
+		if err != nil {
+			if s.opts.OnError != nil {
+				s.opts.OnError(err)
+			}
+			continue
+		}
+		if s.opts.OnReport != nil {
+			s.opts.OnReport(report)
+		}
+	}
+}
+
+// wilson returns the observed failure rate of a sample and its Wilson
+// score interval. A sample covering the whole stratum is exact
+func wilson(failed, n int, seen int64, z float64) (rate, lower, upper float64) {
+	if n == 0 {
+		return 0, 0, 1
+	}
+	p := float64(failed) / float64(n)
+	if int64(n) == seen {
+		return p, p, p
+	}
+	z2 := z * z / float64(n)
+	center := (p + z2/2) / (1 + z2)
+	half := z * math.Sqrt(p*(1-p)/float64(n)+z2/(4*float64(n))) / (1 + z2)
+	return p, math.Max(0, center-half), math.Min(1, center+half)
+}
+
+// randomIndex returns a uniform integer in [0, n)
+func randomIndex(n int64) (int64, error) {
+	var buf [8]byte
+	limit := math.MaxUint64 - math.MaxUint64%uint64(n)
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, fmt.Errorf("sampling: failed to sample: %w", err)
+		}
+		if v := binary.BigEndian.Uint64(buf[:]); v < limit {
+			return int64(v % uint64(n)), nil
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Package sandbox runs computations under enforced restrictions and records
+// evidence of those restrictions for inclusion in TECP receipts.
+//
+// Sandboxed functions execute in a re-executed child process, so they must be
+// registered at init time and the program must call Init at the start of main:
+//
+//	var resize = sandbox.Register("resize", func(input []byte) ([]byte, error) {
+//		return doResize(input)
+//	})
+//
+//	func main() {
+//		sandbox.Init()
+//
+//		result, err := sandbox.RunNoNetwork(resize, input)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//
+//		receipt, err := client.CreateReceipt(tecp.CreateReceiptOptions{
+//			Input:      input,
+//			Output:     result.Output,
+//			Policies:   []string{"no_network"},
+//			Extensions: map[string]interface{}{sandbox.Extension: result.Evidence},
+//		})
+//	}
+//
+// CreateReceipt always signs the evidence; verifiers reject no_network
+// claims backed by unsigned evidence.
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// Extension is the receipt extension carrying no_network evidence
+const Extension = "no_network"
+
+// childEnv names the registered function a re-executed child should run
+const childEnv = "TECP_SANDBOX_FUNC"
+
+// ErrUnsupported is returned on platforms without sandbox support
+var ErrUnsupported = errors.New("sandbox: not supported on this platform")
+
+// Func is a computation that can run inside the sandbox
+type Func func(input []byte) ([]byte, error)
+
+// Evidence records the restrictions a computation ran under
+type Evidence struct {
+	Restrictions      []string          `json:"restrictions"`
+	DeniedSyscalls    map[string]uint64 `json:"denied_syscalls"`
+	CountersAvailable bool              `json:"counters_available"`
+	StartedAt         int64             `json:"started_at"`
+	FinishedAt        int64             `json:"finished_at"`
+}
+
+// Result is the output of a sandboxed computation and its evidence
+type Result struct {
+	Output   []byte
+	Evidence *Evidence
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Func)
+	names      = make(map[uintptr]string)
+)
+
+// Register makes fn available to sandboxed child processes under name and
+// returns it. It must be called during package initialization so the child
+// process registers the same functions
+func Register(name string, fn Func) Func {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("sandbox: function %q registered twice", name))
+	}
+	registry[name] = fn
+	names[reflect.ValueOf(fn).Pointer()] = name
+	return fn
+}
+
+// Init runs the requested function and exits when the process is a sandbox
+// child; otherwise it returns immediately. Call it first thing in main
+func Init() {
+	name := os.Getenv(childEnv)
+	if name == "" {
+		return
+	}
+
+	registryMu.RLock()
+	fn, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		fmt.Fprintf(os.Stderr, "sandbox: function %q not registered\n", name)
+		os.Exit(2)
+	}
+	os.Exit(runChild(fn))
+}
+
+// registeredName returns the name fn was registered under
+func registeredName(fn Func) (string, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	name, ok := names[reflect.ValueOf(fn).Pointer()]
+	if !ok {
+		return "", fmt.Errorf("sandbox: function not registered")
+	}
+	return name, nil
+}
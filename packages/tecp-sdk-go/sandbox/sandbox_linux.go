@@ -0,0 +1,356 @@
+//go:build linux && (amd64 || arm64)
+
+package sandbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// seccomp and BPF constants from linux/seccomp.h and linux/filter.h
+const (
+	seccompSetModeFilter = 1
+
+	seccompFilterFlagTSync      = 1 << 0
+	seccompFilterFlagNewListen  = 1 << 3
+	seccompFilterFlagTSyncESRCH = 1 << 4
+
+	seccompRetAllow      = 0x7fff0000
+	seccompRetUserNotify = 0x7fc00000
+	seccompRetErrno      = 0x00050000
+
+	seccompIoctlNotifRecv = 0xc0502100
+	seccompIoctlNotifSend = 0xc0182101
+
+	bpfLdWAbs = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfJeqK   = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJgeK   = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfRetK   = 0x06 // BPF_RET | BPF_K
+
+	prSetNoNewPrivs = 38
+	x32SyscallBit   = 0x40000000
+	sysIoUringSetup = 425
+	afPacket        = 17
+
+	// Child descriptors inherited through ExtraFiles
+	resultFD   = 3
+	listenerFD = 4
+)
+
+// Restriction labels recorded in evidence
+const (
+	restrictionNetNS       = "netns:isolated"
+	restrictionNoNewPrivs  = "no_new_privs"
+	restrictionSeccompNet  = "seccomp:socket(AF_INET,AF_INET6,AF_PACKET)=EPERM"
+	restrictionSeccompUrng = "seccomp:io_uring_setup=EPERM"
+)
+
+type seccompData struct {
+	Nr                 int32
+	Arch               uint32
+	InstructionPointer uint64
+	Args               [6]uint64
+}
+
+type seccompNotif struct {
+	ID    uint64
+	Pid   uint32
+	Flags uint32
+	Data  seccompData
+}
+
+type seccompNotifResp struct {
+	ID    uint64
+	Val   int64
+	Error int32
+	Flags uint32
+}
+
+// childResult is reported by the child over resultFD
+type childResult struct {
+	Output       []byte   `json:"output"`
+	Error        string   `json:"error,omitempty"`
+	Restrictions []string `json:"restrictions"`
+}
+
+// RunNoNetwork runs fn on input in a child process placed in an empty network
+// namespace, with socket creation for network address families denied by
+// seccomp. The parent answers the child's denied syscalls itself, so the
+// denial counters in the evidence cannot be altered by the computation
+func RunNoNetwork(fn Func, input []byte) (*Result, error) {
+	name, err := registeredName(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: cannot locate executable: %w", err)
+	}
+
+	resultReader, resultWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer resultReader.Close()
+
+	// The child hands its seccomp listener back over a unix socket
+	pair, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		resultWriter.Close()
+		return nil, err
+	}
+	listenerConn := os.NewFile(uintptr(pair[0]), "sandbox-listener")
+	childConn := os.NewFile(uintptr(pair[1]), "sandbox-listener-child")
+	defer listenerConn.Close()
+
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), childEnv+"="+name)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{resultWriter, childConn}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNET,
+		Pdeathsig:  syscall.SIGKILL,
+	}
+
+	// Unprivileged callers need a user namespace to create a network namespace
+	if uid, gid := os.Getuid(), os.Getgid(); uid != 0 {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+	}
+
+	startedAt := time.Now().UnixMilli()
+	err = cmd.Start()
+	resultWriter.Close()
+	childConn.Close()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: failed to start child: %w", err)
+	}
+
+	counter := &denialCounter{counts: make(map[string]uint64), done: make(chan struct{})}
+	var supervised sync.WaitGroup
+	if listener, ok := receiveListener(listenerConn); ok {
+		counter.available = true
+		supervised.Add(1)
+		go func() {
+			defer supervised.Done()
+			defer syscall.Close(listener)
+			counter.serve(listener)
+		}()
+	}
+
+	data, readErr := io.ReadAll(resultReader)
+	waitErr := cmd.Wait()
+	finishedAt := time.Now().UnixMilli()
+	close(counter.done)
+	supervised.Wait()
+
+	if readErr != nil {
+		return nil, fmt.Errorf("sandbox: failed to read child result: %w", readErr)
+	}
+	var result childResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		if waitErr != nil {
+			return nil, fmt.Errorf("sandbox: child failed: %w", waitErr)
+		}
+		return nil, fmt.Errorf("sandbox: invalid child result: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("sandbox: %s", result.Error)
+	}
+	if waitErr != nil {
+		return nil, fmt.Errorf("sandbox: child failed: %w", waitErr)
+	}
+
+	evidence := &Evidence{
+		Restrictions:      append([]string{restrictionNetNS}, result.Restrictions...),
+		DeniedSyscalls:    counter.counts,
+		CountersAvailable: counter.available,
+		StartedAt:         startedAt,
+		FinishedAt:        finishedAt,
+	}
+	return &Result{Output: result.Output, Evidence: evidence}, nil
+}
+
+// receiveListener reads the child's handshake, which carries the seccomp
+// listener descriptor when the kernel supports user notifications
+func receiveListener(conn *os.File) (int, bool) {
+	buf := make([]byte, 1)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := syscall.Recvmsg(int(conn.Fd()), buf, oob, 0)
+	if err != nil || oobn == 0 {
+		return -1, false
+	}
+
+	messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(messages) == 0 {
+		return -1, false
+	}
+	fds, err := syscall.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) == 0 {
+		return -1, false
+	}
+	return fds[0], true
+}
+
+// runChild installs the seccomp filter, runs fn and reports over resultFD
+func runChild(fn Func) int {
+	out := os.NewFile(resultFD, "sandbox-result")
+	report := func(result *childResult) int {
+		if err := json.NewEncoder(out).Encode(result); err != nil {
+			return 2
+		}
+		if result.Error != "" {
+			return 1
+		}
+		return 0
+	}
+
+	if err := installFilter(listenerFD); err != nil {
+		return report(&childResult{Error: fmt.Sprintf("failed to install seccomp filter: %v", err)})
+	}
+
+	result := &childResult{
+		Restrictions: []string{restrictionNoNewPrivs, restrictionSeccompNet, restrictionSeccompUrng},
+	}
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to read input: %v", err)
+		return report(result)
+	}
+
+	output, err := fn(input)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Output = output
+	return report(result)
+}
+
+// installFilter applies the network seccomp filter to every thread and
+// completes the handshake on conn. It prefers user notifications, passing the
+// listener to the parent so denials can be counted, and falls back to a plain
+// EPERM filter on kernels without listener support
+func installFilter(conn int) error {
+	defer syscall.Close(conn)
+
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+
+	flags := uintptr(seccompFilterFlagTSync | seccompFilterFlagTSyncESRCH | seccompFilterFlagNewListen)
+	listener, err := loadFilter(seccompRetUserNotify, flags)
+	if err == nil {
+		defer syscall.Close(listener)
+		return syscall.Sendmsg(conn, []byte{1}, syscall.UnixRights(listener), nil, 0)
+	}
+
+	if _, err := loadFilter(seccompRetErrno|uint32(syscall.EPERM), seccompFilterFlagTSync); err != nil {
+		return err
+	}
+	_, err = syscall.Write(conn, []byte{0})
+	return err
+}
+
+// loadFilter installs the filter program with the given action for denied calls
+func loadFilter(deny uint32, flags uintptr) (int, error) {
+	eperm := uint32(seccompRetErrno | uint32(syscall.EPERM))
+	filter := []syscall.SockFilter{
+		{Code: bpfLdWAbs, K: 4},                            // 0: load arch
+		{Code: bpfJeqK, Jt: 0, Jf: 10, K: auditArch},       // 1: foreign arch -> 12
+		{Code: bpfLdWAbs, K: 0},                            // 2: load syscall nr
+		{Code: bpfJgeK, Jt: 8, Jf: 0, K: x32SyscallBit},    // 3: x32 ABI -> 12
+		{Code: bpfJeqK, Jt: 6, Jf: 0, K: sysIoUringSetup},  // 4: io_uring_setup -> 11
+		{Code: bpfJeqK, Jt: 0, Jf: 4, K: sysSocket},        // 5: not socket -> 10
+		{Code: bpfLdWAbs, K: 16},                           // 6: load socket family
+		{Code: bpfJeqK, Jt: 3, Jf: 0, K: syscall.AF_INET},  // 7: -> 11
+		{Code: bpfJeqK, Jt: 2, Jf: 0, K: syscall.AF_INET6}, // 8: -> 11
+		{Code: bpfJeqK, Jt: 1, Jf: 0, K: afPacket},         // 9: -> 11
+		{Code: bpfRetK, K: seccompRetAllow},                // 10
+		{Code: bpfRetK, K: deny},                           // 11
+		{Code: bpfRetK, K: eperm},                          // 12
+	}
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+
+	r, _, errno := syscall.RawSyscall(sysSeccomp, seccompSetModeFilter, flags, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(r), nil
+}
+
+// denialCounter answers a child's seccomp notifications with EPERM and
+// counts them per syscall
+type denialCounter struct {
+	counts    map[string]uint64
+	available bool
+	done      chan struct{}
+}
+
+// serve handles notifications until the child has exited
+func (d *denialCounter) serve(listener int) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return
+	}
+	defer syscall.Close(epfd)
+
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(listener)}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, listener, &event); err != nil {
+		return
+	}
+
+	events := make([]syscall.EpollEvent, 1)
+	for {
+		n, err := syscall.EpollWait(epfd, events, 50)
+		if err != nil && err != syscall.EINTR {
+			return
+		}
+		if n <= 0 {
+			select {
+			case <-d.done:
+				return
+			default:
+				continue
+			}
+		}
+		if events[0].Events&syscall.EPOLLIN == 0 {
+			// EPOLLHUP: no task uses the filter any more
+			return
+		}
+
+		var notif seccompNotif
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(listener), seccompIoctlNotifRecv, uintptr(unsafe.Pointer(&notif))); errno != 0 {
+			continue
+		}
+		d.counts[syscallName(notif.Data.Nr)]++
+
+		resp := seccompNotifResp{ID: notif.ID, Error: -int32(syscall.EPERM)}
+		syscall.Syscall(syscall.SYS_IOCTL, uintptr(listener), seccompIoctlNotifSend, uintptr(unsafe.Pointer(&resp)))
+	}
+}
+
+// syscallName labels a denied syscall number in evidence
+func syscallName(nr int32) string {
+	switch nr {
+	case sysSocket:
+		return "socket"
+	case sysIoUringSetup:
+		return "io_uring_setup"
+	default:
+		return fmt.Sprintf("syscall_%d", nr)
+	}
+}
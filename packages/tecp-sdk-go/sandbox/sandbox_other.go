@@ -0,0 +1,12 @@
+//go:build !linux || !(amd64 || arm64)
+
+package sandbox
+
+// RunNoNetwork is not supported on this platform
+func RunNoNetwork(fn Func, input []byte) (*Result, error) {
+	return nil, ErrUnsupported
+}
+
+func runChild(fn Func) int {
+	return 2
+}
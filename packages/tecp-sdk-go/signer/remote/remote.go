@@ -0,0 +1,148 @@
+// Package remote signs TECP receipts with a key held by a remote signing
+// service, such as tecp-signerd.
+//
+// The service holds the issuer key and signs receipts' canonical payloads
+// for clients that authenticate with a TLS client certificate, so fleets
+// of stateless workers can issue receipts without holding key material.
+// Signer is a tecp.Signer backed by the service:
+//
+//	tlsConfig := remote.ClientTLSConfig(workerCert, serviceRoots)
+//	signer, err := remote.Dial("https://signer.internal:8443", &http.Client{
+//		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+//	})
+//	client := tecp.NewClient(tecp.WithReceiptSigner(signer))
+package remote
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Endpoints of the remote signing protocol. Clients GET KeyPath for the
+// signing key and POST {"payload": "<base64>"} to SignPath to receive
+// {"sig": "<base64>"}
+const (
+	KeyPath  = "/v1/key"
+	SignPath = "/v1/sign"
+)
+
+// MaxPayloadSize bounds the payloads the service signs. Canonical receipt
+// payloads are far smaller
+const MaxPayloadSize = 64 * 1024
+
+// Key is the service's signing key
+type Key struct {
+	Algorithm tecp.Algorithm `json:"alg"`
+
+	// PublicKey is the base64 public key as carried in receipts
+	PublicKey string `json:"pubkey"`
+}
+
+// Signer is a tecp.Signer that signs through a remote signing service
+type Signer struct {
+	url        string
+	httpClient *http.Client
+	algorithm  tecp.Algorithm
+	publicKey  []byte
+}
+
+// Dial fetches the signing key of the service at url and returns a Signer
+// for it. httpClient should present the worker's client certificate; it
+// defaults to tecp.DefaultHTTPClient
+func Dial(url string, httpClient *http.Client) (*Signer, error) {
+	if httpClient == nil {
+		httpClient = tecp.DefaultHTTPClient()
+	}
+	s := &Signer{url: strings.TrimSuffix(url, "/"), httpClient: httpClient}
+
+	var key Key
+	if err := s.do(http.MethodGet, KeyPath, nil, &key); err != nil {
+		return nil, err
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil || len(publicKey) == 0 {
+		return nil, fmt.Errorf("remote: %s served an invalid public key", s.url)
+	}
+	s.algorithm, s.publicKey = key.Algorithm, publicKey
+	return s, nil
+}
+
+// Algorithm is the service key's signature algorithm
+func (s *Signer) Algorithm() tecp.Algorithm { return s.algorithm }
+
+// PublicKey is the service's public key
+func (s *Signer) PublicKey() []byte { return s.publicKey }
+
+// Sign has the service sign a receipt's canonical payload
+func (s *Signer) Sign(payload []byte) ([]byte, error) {
+	if len(payload) > MaxPayloadSize {
+		return nil, fmt.Errorf("remote: payload is %d bytes, more than %d", len(payload), MaxPayloadSize)
+	}
+	var response struct {
+		Signature string `json:"sig"`
+	}
+	request := map[string]string{"payload": base64.StdEncoding.EncodeToString(payload)}
+	if err := s.do(http.MethodPost, SignPath, request, &response); err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(response.Signature)
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("remote: %s returned an invalid signature", s.url)
+	}
+	return signature, nil
+}
+
+// do makes a request to the service and decodes its JSON response
+func (s *Signer) do(method, path string, request, response interface{}) error {
+	var body io.Reader
+	if request != nil {
+		encoded, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, s.url+path, body)
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&failure)
+		return fmt.Errorf("remote: %s returned status %d: %s", s.url, resp.StatusCode, failure.Error)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(response); err != nil {
+		return fmt.Errorf("remote: invalid response: %w", err)
+	}
+	return nil
+}
+
+// ClientTLSConfig returns a TLS configuration that presents cert to the
+// signing service and verifies the service against roots
+func ClientTLSConfig(cert tls.Certificate, roots *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
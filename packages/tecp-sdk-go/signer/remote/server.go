@@ -0,0 +1,140 @@
+package remote
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Server serves the remote signing protocol for a key. It must be served
+// with a TLS configuration that verifies client certificates, such as
+// ServerTLSConfig; requests without a verified certificate are refused
+type Server struct {
+	signer tecp.Signer
+
+	// Clients, when set, lists the client certificate identities allowed
+	// to sign: a subject common name, DNS name or URI such as a SPIFFE ID.
+	// Otherwise any verified client may sign
+	Clients []string
+
+	// OnSign, when set, is called after each signature with the client's
+	// identity and the signed payload's size, for auditing
+	OnSign func(client string, size int)
+}
+
+// NewServer returns a server for signer
+func NewServer(signer tecp.Signer) *Server {
+	return &Server{signer: signer}
+}
+
+// ServeHTTP serves the remote signing protocol
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != KeyPath && r.URL.Path != SignPath {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	client, ok := s.authorize(r)
+	if !ok {
+		writeError(w, http.StatusForbidden, "client is not authorized to sign")
+		return
+	}
+
+	if r.URL.Path == KeyPath {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, Key{
+			Algorithm: s.signer.Algorithm(),
+			PublicKey: base64.StdEncoding.EncodeToString(s.signer.PublicKey()),
+		})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var request struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 2*MaxPayloadSize)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	payload, err := base64.StdEncoding.DecodeString(request.Payload)
+	if err != nil || len(payload) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid payload encoding")
+		return
+	}
+	if len(payload) > MaxPayloadSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "payload too large")
+		return
+	}
+	signature, err := s.signer.Sign(payload)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "signing failed")
+		return
+	}
+	if s.OnSign != nil {
+		s.OnSign(client, len(payload))
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"sig": base64.StdEncoding.EncodeToString(signature)})
+}
+
+// authorize returns the identity of the request's verified client
+// certificate, if it is allowed to sign
+func (s *Server) authorize(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", false
+	}
+	identities := certificateIdentities(r.TLS.VerifiedChains[0][0])
+	if len(s.Clients) == 0 {
+		return identities[0], true
+	}
+	for _, identity := range identities {
+		for _, allowed := range s.Clients {
+			if identity == allowed {
+				return identity, true
+			}
+		}
+	}
+	return "", false
+}
+
+// certificateIdentities returns a certificate's URIs, DNS names and subject
+// common name, most specific first. The list is never empty
+func certificateIdentities(cert *x509.Certificate) []string {
+	var identities []string
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, cert.DNSNames...)
+	return append(identities, cert.Subject.CommonName)
+}
+
+// ServerTLSConfig returns a TLS configuration that serves cert and requires
+// client certificates issued by clientCAs
+func ServerTLSConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,95 @@
+package spiffe
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// CheckSPIFFE is the name of the pipeline check added by Check
+const CheckSPIFFE = "spiffe"
+
+// Check returns a pipeline check requiring receipts to be signed by a key
+// bound to an X509-SVID that chains to bundles at issuance and whose
+// SPIFFE ID matcher accepts, such as spiffeid.MatchMemberOf
+func Check(bundles x509bundle.Source, matcher spiffeid.Matcher) tecp.Check {
+	return tecp.CheckFunc(CheckSPIFFE, func(v *tecp.Verification, result *tecp.CheckResult) {
+		id, err := VerifyReceipt(v.Receipt, bundles)
+		if err != nil {
+			result.Fail("", err.Error())
+			return
+		}
+		if matcher != nil {
+			if err := matcher(id); err != nil {
+				result.Fail("", fmt.Sprintf("workload identity %s not accepted: %v", id, err))
+			}
+		}
+	})
+}
+
+// VerifyReceipt verifies a receipt's spiffe extension and returns the
+// SPIFFE ID it binds the signing key to. The SVID must chain to bundles at
+// the receipt's issuance time, and the extension must be signed
+func VerifyReceipt(receipt *tecp.Receipt, bundles x509bundle.Source) (spiffeid.ID, error) {
+	binding, found, err := tecp.GetExtension[Binding](receipt, Extension)
+	if err != nil {
+		return spiffeid.ID{}, err
+	}
+	if !found {
+		return spiffeid.ID{}, fmt.Errorf("spiffe: receipt has no %s extension", Extension)
+	}
+	if receipt.SignedExt == nil || !contains(receipt.SignedExt.Names, Extension) {
+		return spiffeid.ID{}, fmt.Errorf("spiffe: %s extension is not covered by the signature", Extension)
+	}
+
+	chain := make([]*x509.Certificate, len(binding.X5C))
+	for i, encoded := range binding.X5C {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return spiffeid.ID{}, fmt.Errorf("spiffe: invalid SVID certificate %d: %w", i, err)
+		}
+		if chain[i], err = x509.ParseCertificate(der); err != nil {
+			return spiffeid.ID{}, fmt.Errorf("spiffe: invalid SVID certificate %d: %w", i, err)
+		}
+	}
+	if len(chain) == 0 {
+		return spiffeid.ID{}, fmt.Errorf("spiffe: %s extension has no SVID", Extension)
+	}
+
+	// Validity is judged at issuance so receipts outlive short-lived SVIDs
+	id, _, err := x509svid.Verify(chain, bundles, x509svid.WithTime(time.UnixMilli(receipt.Timestamp)))
+	if err != nil {
+		return spiffeid.ID{}, fmt.Errorf("spiffe: SVID verification failed: %w", err)
+	}
+	if id.String() != binding.ID {
+		return spiffeid.ID{}, fmt.Errorf("spiffe: SVID identifies %s, not %s", id, binding.ID)
+	}
+
+	publicKey, err := receipt.PublicKeyEd25519()
+	if err != nil {
+		return spiffeid.ID{}, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(binding.Signature)
+	if err != nil {
+		return spiffeid.ID{}, fmt.Errorf("spiffe: invalid key binding signature encoding: %w", err)
+	}
+	if err := verifyBindingSignature(chain[0], bindingStatement(binding.ID, publicKey), signature); err != nil {
+		return spiffeid.ID{}, err
+	}
+	return id, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+module github.com/tecp-protocol/tecp-sdk-go/signer/spiffe
+
+go 1.22.11
+
+replace github.com/tecp-protocol/tecp-sdk-go => ../..
+
+require (
+	github.com/spiffe/go-spiffe/v2 v2.5.0
+	github.com/tecp-protocol/tecp-sdk-go v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0 // indirect
+	github.com/fxamacker/cbor/v2 v2.5.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	github.com/zeebo/errs v1.4.0 // indirect
+)
@@ -0,0 +1,171 @@
+// Package spiffe signs TECP receipts under a SPIFFE workload identity.
+//
+// The workload's X509-SVID is obtained from the SPIFFE Workload API. SVID
+// keys are ECDSA or RSA, so receipts are signed with an Ed25519 key bound to
+// the SVID: the SVID key signs a statement binding its SPIFFE ID to the
+// Ed25519 public key, and the statement and SVID chain are embedded in each
+// receipt as the signed spiffe extension. The binding key is replaced
+// whenever the SVID rotates. Verifiers authorize issuers by SPIFFE ID with
+// Check instead of pinning raw keys:
+//
+//	source, err := workloadapi.NewX509Source(ctx)
+//	signer := spiffe.NewSigner(source)
+//	receipt, err := signer.CreateReceipt(client, tecp.CreateReceiptOptions{Input: input, Output: output})
+//
+//	pipeline := tecp.InsertCheck(tecp.DefaultChecks(), tecp.CheckIssuer,
+//		spiffe.Check(bundles, spiffeid.MatchMemberOf(trustDomain)))
+package spiffe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Extension carries the SPIFFE ID, SVID chain and key binding
+const Extension = "spiffe"
+
+const bindingVersion = "TECP-SPIFFE-0.1"
+
+func init() {
+	tecp.RegisterExtension(Extension)
+}
+
+// Binding is the spiffe extension: the SVID key's signature binding a
+// SPIFFE ID to the receipt's Ed25519 signing key
+type Binding struct {
+	ID string `json:"spiffe_id"`
+
+	// X5C is the X509-SVID chain, leaf first, as base64 DER
+	X5C []string `json:"x5c"`
+
+	// Signature is the SVID key's base64 signature over the binding
+	// statement
+	Signature string `json:"sig"`
+}
+
+// SVIDSource supplies the workload's current X509-SVID, as a
+// workloadapi.X509Source does
+type SVIDSource interface {
+	GetX509SVID() (*x509svid.SVID, error)
+}
+
+// Signer signs receipts with a key bound to the workload's SVID
+type Signer struct {
+	source SVIDSource
+
+	mu      sync.Mutex
+	leaf    []byte
+	key     ed25519.PrivateKey
+	binding *Binding
+}
+
+// NewSigner returns a signer for the SVIDs source supplies
+func NewSigner(source SVIDSource) *Signer {
+	return &Signer{source: source}
+}
+
+// CreateReceipt creates a receipt with client, signed by the key bound to
+// the current SVID and carrying the signed binding
+func (s *Signer) CreateReceipt(client *tecp.Client, options tecp.CreateReceiptOptions) (*tecp.Receipt, error) {
+	key, binding, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+
+	extensions := make(map[string]interface{}, len(options.Extensions)+1)
+	for name, value := range options.Extensions {
+		extensions[name] = value
+	}
+	extensions[Extension] = binding
+	options.Extensions = extensions
+	options.SignedExtensions = append(append([]string(nil), options.SignedExtensions...), Extension)
+	return client.CreateReceipt(options, tecp.WithSigner(key))
+}
+
+// current returns the signing key and binding for the current SVID,
+// binding a new key when the SVID has rotated
+func (s *Signer) current() (ed25519.PrivateKey, *Binding, error) {
+	svid, err := s.source.GetX509SVID()
+	if err != nil {
+		return nil, nil, fmt.Errorf("spiffe: failed to fetch X509-SVID: %w", err)
+	}
+	if len(svid.Certificates) == 0 {
+		return nil, nil, fmt.Errorf("spiffe: X509-SVID has no certificates")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.binding != nil && bytes.Equal(s.leaf, svid.Certificates[0].Raw) {
+		return s.key, s.binding, nil
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("spiffe: failed to generate signing key: %w", err)
+	}
+	id := svid.ID.String()
+	statement := bindingStatement(id, publicKey)
+	message, opts := statement, crypto.SignerOpts(crypto.Hash(0))
+	if _, ok := svid.PrivateKey.Public().(ed25519.PublicKey); !ok {
+		digest := sha256.Sum256(statement)
+		message, opts = digest[:], crypto.SHA256
+	}
+	signature, err := svid.PrivateKey.Sign(rand.Reader, message, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("spiffe: failed to sign key binding: %w", err)
+	}
+
+	chain := make([]string, len(svid.Certificates))
+	for i, cert := range svid.Certificates {
+		chain[i] = base64.StdEncoding.EncodeToString(cert.Raw)
+	}
+	s.leaf = svid.Certificates[0].Raw
+	s.key = privateKey
+	s.binding = &Binding{ID: id, X5C: chain, Signature: base64.StdEncoding.EncodeToString(signature)}
+	return s.key, s.binding, nil
+}
+
+// bindingStatement is the statement the SVID key signs
+func bindingStatement(id string, publicKey ed25519.PublicKey) []byte {
+	statement, _ := json.Marshal(map[string]string{
+		"version":   bindingVersion,
+		"spiffe_id": id,
+		"pubkey":    base64.StdEncoding.EncodeToString(publicKey),
+	})
+	return statement
+}
+
+// verifyBindingSignature checks the SVID leaf's signature over a statement
+func verifyBindingSignature(leaf *x509.Certificate, statement, signature []byte) error {
+	digest := sha256.Sum256(statement)
+	switch key := leaf.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, statement, signature) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("spiffe: unsupported SVID key type %T", leaf.PublicKey)
+	}
+	return fmt.Errorf("spiffe: key binding signature invalid")
+}
@@ -0,0 +1,296 @@
+// Package cdc publishes a change-data-capture feed of a receipt store.
+//
+// A FeedStore wraps a ReceiptStore and appends an Event to a Feed for every
+// receipt stored, updated or deleted. Events carry increasing sequence
+// numbers, so a consumer resumes from the last sequence it processed:
+//
+//	feed, err := cdc.NewFeed(cdc.Options{Path: "/var/lib/tecp/receipts.cdc"})
+//	archive := cdc.NewFeedStore(dirStore, feed)
+//
+//	cursor := loadCheckpoint()
+//	for {
+//		events, err := feed.Next(ctx, cursor, 100)
+//		if err != nil {
+//			return err
+//		}
+//		for _, event := range events {
+//			forward(event)
+//			cursor = event.Seq
+//		}
+//		saveCheckpoint(cursor)
+//	}
+//
+// A Relay runs that loop for an Emitter; NATSEmitter and KafkaEmitter
+// adapt the publish functions of any NATS or Kafka client, so the package
+// does not depend on one.
+package cdc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Event operations
+const (
+	OpPut    = "put"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// DefaultRetain is the number of events a Feed keeps readable by default
+const DefaultRetain = 100000
+
+// ErrCursorExpired is returned when events after a cursor are no longer
+// retained; the consumer must resynchronize from the store with Walk
+var ErrCursorExpired = errors.New("cdc: cursor expired")
+
+// Event is one change to the store
+type Event struct {
+	// Seq numbers events from 1 in the order they were appended
+	Seq  uint64    `json:"seq"`
+	Op   string    `json:"op"`
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+
+	// Receipt is the receipt JSON as stored, absent for deletions
+	Receipt json.RawMessage `json:"receipt,omitempty"`
+}
+
+// Decode returns the event's receipt, or nil for deletions
+func (e *Event) Decode() (*tecp.Receipt, error) {
+	if len(e.Receipt) == 0 {
+		return nil, nil
+	}
+	return tecp.FromJSON(e.Receipt)
+}
+
+// Options configures a Feed
+type Options struct {
+	// Path, when set, is an append-only journal the feed is persisted to
+	// and restored from, so cursors survive restarts
+	Path string
+
+	// Retain is the number of most recent events kept readable; defaults
+	// to DefaultRetain
+	Retain int
+
+	// Now returns the event time; defaults to time.Now
+	Now func() time.Time
+}
+
+// Feed is an ordered, resumable log of store changes, safe for concurrent
+// use
+type Feed struct {
+	mu      sync.Mutex
+	events  []Event
+	last    uint64
+	journal *os.File
+	retain  int
+	now     func() time.Time
+
+	// appended is closed and replaced whenever an event is appended
+	appended chan struct{}
+}
+
+// NewFeed creates a feed, restoring it from the journal at options.Path
+// when one exists
+func NewFeed(options Options) (*Feed, error) {
+	f := &Feed{retain: options.Retain, now: options.Now, appended: make(chan struct{})}
+	if f.retain <= 0 {
+		f.retain = DefaultRetain
+	}
+	if f.now == nil {
+		f.now = time.Now
+	}
+	if options.Path == "" {
+		return f, nil
+	}
+
+	journal, err := os.OpenFile(options.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("cdc: %w", err)
+	}
+	scanner := bufio.NewScanner(journal)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			journal.Close()
+			return nil, fmt.Errorf("cdc: corrupt journal %s after event %d: %w", options.Path, f.last, err)
+		}
+		f.keep(event)
+	}
+	if err := scanner.Err(); err != nil {
+		journal.Close()
+		return nil, fmt.Errorf("cdc: %w", err)
+	}
+	f.journal = journal
+	return f, nil
+}
+
+// keep adds an event to the retained window
+func (f *Feed) keep(event Event) {
+	f.events = append(f.events, event)
+	if len(f.events) > f.retain {
+		f.events = append(f.events[:0:0], f.events[len(f.events)-f.retain:]...)
+	}
+	f.last = event.Seq
+}
+
+// Append records a change, journaling it before it becomes readable
+func (f *Feed) Append(op, key string, receipt json.RawMessage) (Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	event := Event{Seq: f.last + 1, Op: op, Key: key, Time: f.now().UTC(), Receipt: receipt}
+	if f.journal != nil {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return Event{}, fmt.Errorf("cdc: %w", err)
+		}
+		if _, err := f.journal.Write(append(line, '\n')); err != nil {
+			return Event{}, fmt.Errorf("cdc: %w", err)
+		}
+	}
+	f.keep(event)
+	close(f.appended)
+	f.appended = make(chan struct{})
+	return event, nil
+}
+
+// Read returns up to limit events after the cursor, the sequence number
+// of the last event the consumer processed or 0 to start from the
+// beginning. It returns ErrCursorExpired when the next event is no longer
+// retained
+func (f *Feed) Read(cursor uint64, limit int) ([]Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.read(cursor, limit)
+}
+
+func (f *Feed) read(cursor uint64, limit int) ([]Event, error) {
+	if cursor > f.last {
+		return nil, fmt.Errorf("cdc: cursor %d is ahead of the feed at %d", cursor, f.last)
+	}
+	if cursor == f.last {
+		return nil, nil
+	}
+	first := f.events[0].Seq
+	if cursor+1 < first {
+		return nil, fmt.Errorf("%w: %d precedes the oldest retained event %d", ErrCursorExpired, cursor, first)
+	}
+	events := f.events[cursor+1-first:]
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return append([]Event(nil), events...), nil
+}
+
+// Next returns up to limit events after the cursor, waiting for one to be
+// appended when the consumer is caught up, or until ctx is done
+func (f *Feed) Next(ctx context.Context, cursor uint64, limit int) ([]Event, error) {
+	for {
+		f.mu.Lock()
+		events, err := f.read(cursor, limit)
+		appended := f.appended
+		f.mu.Unlock()
+		if err != nil || len(events) > 0 {
+			return events, err
+		}
+		select {
+		case <-appended:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Last returns the sequence number of the newest event, 0 when empty
+func (f *Feed) Last() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last
+}
+
+// Close closes the journal
+func (f *Feed) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.journal == nil {
+		return nil
+	}
+	err := f.journal.Close()
+	f.journal = nil
+	return err
+}
+
+// FeedStore wraps a ReceiptStore and appends every change to a feed. A
+// change is appended after the store accepts it; when the append fails the
+// change is stored but the error is returned
+type FeedStore struct {
+	store.ReceiptStore
+	Feed *Feed
+
+	// mu serializes writes so each event reflects the store's order
+	mu sync.Mutex
+}
+
+// NewFeedStore returns a store that records changes to s in feed
+func NewFeedStore(s store.ReceiptStore, feed *Feed) *FeedStore {
+	return &FeedStore{ReceiptStore: s, Feed: feed}
+}
+
+// Put stores a receipt and appends a put event for a new receipt or an
+// update event for a changed one. Storing an identical copy appends nothing
+func (s *FeedStore) Put(receipt *tecp.Receipt) (string, error) {
+	data, err := receipt.ToJSON()
+	if err != nil {
+		return "", err
+	}
+	key, err := store.Key(receipt)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := OpPut
+	existing, err := s.ReceiptStore.Get(key)
+	switch {
+	case err == nil:
+		op = OpUpdate
+		if stored, err := existing.ToJSON(); err == nil && bytes.Equal(stored, data) {
+			return s.ReceiptStore.Put(receipt)
+		}
+	case !errors.Is(err, store.ErrNotFound):
+		return "", err
+	}
+	if key, err = s.ReceiptStore.Put(receipt); err != nil {
+		return "", err
+	}
+	if _, err := s.Feed.Append(op, key, data); err != nil {
+		return key, err
+	}
+	return key, nil
+}
+
+// Delete removes a receipt and appends a delete event
+func (s *FeedStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ReceiptStore.Delete(key); err != nil {
+		return err
+	}
+	_, err := s.Feed.Append(OpDelete, key, nil)
+	return err
+}
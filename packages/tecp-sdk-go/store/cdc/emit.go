@@ -0,0 +1,108 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultSubject prefixes the NATS subjects events are published on
+const DefaultSubject = "tecp.receipts"
+
+// Emitter forwards events to a downstream system
+type Emitter interface {
+	Emit(ctx context.Context, event Event) error
+}
+
+// NATSEmitter publishes each event as JSON on Subject.<op>, such as
+// tecp.receipts.put. With nats.go and JetStream, which deduplicates on the
+// message ID:
+//
+//	cdc.NATSEmitter{Publish: func(subject string, id string, data []byte) error {
+//		_, err := js.Publish(subject, data, nats.MsgId(id))
+//		return err
+//	}}
+type NATSEmitter struct {
+	// Publish sends data on subject; id is unique per event
+	Publish func(subject, id string, data []byte) error
+
+	// Subject defaults to DefaultSubject
+	Subject string
+}
+
+// Emit publishes the event
+func (e NATSEmitter) Emit(_ context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cdc: %w", err)
+	}
+	subject := e.Subject
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	return e.Publish(subject+"."+event.Op, fmt.Sprintf("%s-%d", event.Key, event.Seq), data)
+}
+
+// KafkaEmitter produces each event as JSON to Topic, keyed by receipt key so
+// the changes to one receipt stay ordered within a partition. With
+// segmentio/kafka-go:
+//
+//	cdc.KafkaEmitter{Topic: "tecp-receipts", Produce: func(ctx context.Context, topic string, key, value []byte) error {
+//		return writer.WriteMessages(ctx, kafka.Message{Topic: topic, Key: key, Value: value})
+//	}}
+type KafkaEmitter struct {
+	Produce func(ctx context.Context, topic string, key, value []byte) error
+	Topic   string
+}
+
+// Emit produces the event
+func (e KafkaEmitter) Emit(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cdc: %w", err)
+	}
+	return e.Produce(ctx, e.Topic, []byte(event.Key), data)
+}
+
+// Relay forwards a feed's events to an emitter from a cursor, at least
+// once: after a restart from the last checkpoint, events emitted since may
+// be emitted again
+type Relay struct {
+	Feed    *Feed
+	Emitter Emitter
+
+	// Cursor is the sequence number of the last event emitted; Run
+	// advances it
+	Cursor uint64
+
+	// Checkpoint, when set, persists the cursor after each batch
+	Checkpoint func(cursor uint64) error
+
+	// BatchSize caps the events read at a time; defaults to 100
+	BatchSize int
+}
+
+// Run relays events until ctx is done or emitting fails
+func (r *Relay) Run(ctx context.Context) error {
+	batch := r.BatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+	for {
+		events, err := r.Feed.Next(ctx, r.Cursor, batch)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := r.Emitter.Emit(ctx, event); err != nil {
+				return fmt.Errorf("cdc: emitting event %d: %w", event.Seq, err)
+			}
+			r.Cursor = event.Seq
+		}
+		if r.Checkpoint != nil {
+			if err := r.Checkpoint(r.Cursor); err != nil {
+				return err
+			}
+		}
+	}
+}
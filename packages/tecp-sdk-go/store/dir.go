@@ -0,0 +1,183 @@
+package store
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// DirStore archives receipts as JSON files named by key in a directory
+type DirStore struct {
+	dir        string
+	encryption *Encryption
+
+	// mu serializes puts so an existing copy is checked before it is replaced
+	mu sync.Mutex
+}
+
+// NewDirStore creates a store rooted at dir, creating the directory if needed
+func NewDirStore(dir string, opts ...Option) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	return &DirStore{dir: dir, encryption: applyOptions(opts).encryption}, nil
+}
+
+// Put writes a receipt atomically and returns its key. Storing a receipt
+// again is a no-op; a copy with different signed content fails with
+// ErrConflict
+func (s *DirStore) Put(receipt *tecp.Receipt) (string, error) {
+	key, err := Key(receipt)
+	if err != nil {
+		return "", err
+	}
+	data, err := receipt.ToJSON()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, err := os.ReadFile(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("store: %w", err)
+	}
+	if existing != nil {
+		if existing, err = s.encryption.open(key, existing); err != nil {
+			return "", err
+		}
+	}
+	if write, err := reconcile(key, existing, data, receipt); err != nil || !write {
+		return key, err
+	}
+	if data, err = s.encryption.seal(key, data); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return "", fmt.Errorf("store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		return "", fmt.Errorf("store: %w", err)
+	}
+	return key, nil
+}
+
+// Get reads the receipt stored under key
+func (s *DirStore) Get(key string) (*tecp.Receipt, error) {
+	if !validKey(key) {
+		return nil, ErrNotFound
+	}
+	data, err := s.read(key)
+	if err != nil {
+		return nil, err
+	}
+	return tecp.FromJSON(data)
+}
+
+// Delete removes the receipt stored under key
+func (s *DirStore) Delete(key string) error {
+	if !validKey(key) {
+		return ErrNotFound
+	}
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Walk calls fn for every archived receipt in key order
+func (s *DirStore) Walk(fn func(key string, receipt *tecp.Receipt) error) error {
+	keys, err := s.keys()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		receipt, err := s.Get(key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("store: %s: %w", key, err)
+		}
+		if err := fn(key, receipt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyIntegrity re-verifies every archived receipt with verifier, which
+// defaults to a client with the default profile, and reports files that
+// are unreadable, do not match their key or fail verification
+func (s *DirStore) VerifyIntegrity(verifier *tecp.Client, options tecp.VerifyOptions) (*IntegrityReport, error) {
+	keys, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	return verifyIntegrity(keys, s.read, verifier, options)
+}
+
+// read returns the decrypted receipt JSON stored under key
+func (s *DirStore) read(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	return s.encryption.open(key, data)
+}
+
+// keys lists the archived keys in order
+func (s *DirStore) keys() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		key, ok := strings.CutSuffix(entry.Name(), ".json")
+		if ok && !entry.IsDir() && validKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *DirStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// validKey reports whether key is a hex SHA-256 digest, keeping lookups
+// confined to the store directory
+func validKey(key string) bool {
+	if len(key) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Encryption algorithms for stored receipts
+const (
+	AlgorithmAESGCM    = "A256GCM"
+	AlgorithmXChaCha20 = "XC20P"
+)
+
+// ErrDecrypt is returned when a stored receipt cannot be decrypted
+var ErrDecrypt = errors.New("store: receipt cannot be decrypted")
+
+const (
+	encryptionVersion = "TECP-ENC-0.1"
+
+	// maxDataKeyUses bounds the receipts sealed under one data key, well
+	// within the random-nonce limits of both algorithms
+	maxDataKeyUses = 1 << 24
+)
+
+// KeyWrapper wraps data keys under a key-encryption key, typically held in
+// a KMS. Adapters for cloud KMS encrypt and decrypt calls implement it
+type KeyWrapper interface {
+	// WrapKey encrypts a data key, returning the ID of the key-encryption
+	// key used
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+
+	// UnwrapKey decrypts a data key wrapped under keyID
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyWrapper wraps data keys with AES-256-GCM under a local key, for
+// deployments without a KMS
+type LocalKeyWrapper struct {
+	KeyID string
+
+	// Key is a 32-byte key-encryption key
+	Key []byte
+}
+
+// WrapKey encrypts a data key under the local key
+func (w *LocalKeyWrapper) WrapKey(dataKey []byte) (string, []byte, error) {
+	aead, err := newAEAD(AlgorithmAESGCM, w.Key)
+	if err != nil {
+		return "", nil, err
+	}
+	wrapped, err := seal(aead, dataKey, []byte(w.KeyID))
+	return w.KeyID, wrapped, err
+}
+
+// UnwrapKey decrypts a data key wrapped under the local key
+func (w *LocalKeyWrapper) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != w.KeyID {
+		return nil, fmt.Errorf("store: unknown key-encryption key %q", keyID)
+	}
+	aead, err := newAEAD(AlgorithmAESGCM, w.Key)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// Encryption seals stored receipts with envelope encryption: receipts are
+// encrypted under a data key, which is stored alongside them wrapped by a
+// KeyWrapper. A data key is reused for many receipts, so the KMS is called
+// once per data key rather than once per receipt. Each ciphertext is bound
+// to its store key, so encrypted files cannot be swapped
+type Encryption struct {
+	algorithm string
+	keys      KeyWrapper
+
+	mu        sync.Mutex
+	dataKey   []byte
+	keyID     string
+	wrapped   []byte
+	uses      int
+	unwrapped map[string][]byte
+}
+
+// NewEncryption returns an Encryption using algorithm, AlgorithmAESGCM by
+// default, with data keys wrapped by keys
+func NewEncryption(keys KeyWrapper, algorithm string) (*Encryption, error) {
+	if keys == nil {
+		return nil, fmt.Errorf("store: key wrapper required")
+	}
+	if algorithm == "" {
+		algorithm = AlgorithmAESGCM
+	}
+	if algorithm != AlgorithmAESGCM && algorithm != AlgorithmXChaCha20 {
+		return nil, fmt.Errorf("store: unsupported encryption algorithm %q", algorithm)
+	}
+	return &Encryption{algorithm: algorithm, keys: keys, unwrapped: make(map[string][]byte)}, nil
+}
+
+// envelope is the stored form of an encrypted receipt
+type envelope struct {
+	Version    string `json:"tecp_enc"`
+	Algorithm  string `json:"alg"`
+	KeyID      string `json:"kid"`
+	WrappedKey string `json:"wrapped_key"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// WithEncryption encrypts the receipts a store writes with e. Receipts
+// stored before encryption was enabled remain readable
+func WithEncryption(e *Encryption) Option {
+	return func(o *options) { o.encryption = e }
+}
+
+// seal encrypts a receipt's JSON stored under key. A nil Encryption stores
+// it as it is
+func (e *Encryption) seal(key string, data []byte) ([]byte, error) {
+	if e == nil {
+		return data, nil
+	}
+	dataKey, keyID, wrapped, err := e.currentKey()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(e.algorithm, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(aead, data, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{
+		Version:    encryptionVersion,
+		Algorithm:  e.algorithm,
+		KeyID:      keyID,
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	})
+}
+
+// open decrypts the data stored under key. Receipts stored before
+// encryption was enabled are returned as they are
+func (e *Encryption) open(key string, data []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Version != encryptionVersion {
+		return data, nil
+	}
+	if e == nil {
+		return nil, fmt.Errorf("%w: store has no encryption configured", ErrDecrypt)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid wrapped key", ErrDecrypt)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ciphertext", ErrDecrypt)
+	}
+
+	dataKey, err := e.unwrap(env.KeyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	aead, err := newAEAD(env.Algorithm, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	plaintext, err := open(aead, ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
+
+// currentKey returns the data key for the next receipt, generating and
+// wrapping a new one when none exists or the current one is used up
+func (e *Encryption) currentKey() (dataKey []byte, keyID string, wrapped []byte, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dataKey == nil || e.uses >= maxDataKeyUses {
+		dataKey := make([]byte, 32)
+		if _, err := rand.Read(dataKey); err != nil {
+			return nil, "", nil, fmt.Errorf("store: failed to generate data key: %w", err)
+		}
+		keyID, wrapped, err := e.keys.WrapKey(dataKey)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("store: failed to wrap data key: %w", err)
+		}
+		e.dataKey, e.keyID, e.wrapped, e.uses = dataKey, keyID, wrapped, 0
+		e.unwrapped[keyID+"/"+string(wrapped)] = dataKey
+	}
+	e.uses++
+	return e.dataKey, e.keyID, e.wrapped, nil
+}
+
+// unwrap returns a wrapped data key, caching unwrapped keys
+func (e *Encryption) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	cacheKey := keyID + "/" + string(wrapped)
+	e.mu.Lock()
+	dataKey, ok := e.unwrapped[cacheKey]
+	e.mu.Unlock()
+	if ok {
+		return dataKey, nil
+	}
+
+	dataKey, err := e.keys.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.unwrapped[cacheKey] = dataKey
+	e.mu.Unlock()
+	return dataKey, nil
+}
+
+func newAEAD(algorithm string, key []byte) (cipher.AEAD, error) {
+	switch algorithm {
+	case AlgorithmAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("store: %w", err)
+		}
+		return cipher.NewGCM(block)
+	case AlgorithmXChaCha20:
+		return chacha20poly1305.NewX(key)
+	}
+	return nil, fmt.Errorf("store: unsupported encryption algorithm %q", algorithm)
+}
+
+// seal encrypts with a random nonce, prepended to the ciphertext
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("store: failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open decrypts the output of seal
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
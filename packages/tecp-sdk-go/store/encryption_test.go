@@ -0,0 +1,200 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+func testReceipt(t *testing.T, input string) *tecp.Receipt {
+	t.Helper()
+	priv, _, err := tecp.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := tecp.NewClient(tecp.WithSigner(priv)).CreateReceipt(tecp.CreateReceiptOptions{
+		Input:   []byte(input),
+		Output:  []byte("out"),
+		CodeRef: "git:abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return receipt
+}
+
+func testEncryption(t *testing.T, algorithm string) *Encryption {
+	t.Helper()
+	e, err := NewEncryption(&LocalKeyWrapper{KeyID: "kek-1", Key: bytes.Repeat([]byte{7}, 32)}, algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmAESGCM, AlgorithmXChaCha20} {
+		stores := map[string]func(e *Encryption) (ReceiptStore, func(key string) []byte){
+			"memory": func(e *Encryption) (ReceiptStore, func(string) []byte) {
+				s := NewMemoryStore(WithEncryption(e))
+				return s, func(key string) []byte { return s.receipts[key] }
+			},
+			"dir": func(e *Encryption) (ReceiptStore, func(string) []byte) {
+				s, err := NewDirStore(t.TempDir(), WithEncryption(e))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s, func(key string) []byte {
+					data, _ := os.ReadFile(s.path(key))
+					return data
+				}
+			},
+		}
+		for kind, open := range stores {
+			t.Run(algorithm+"/"+kind, func(t *testing.T) {
+				s, raw := open(testEncryption(t, algorithm))
+				receipt := testReceipt(t, "in")
+				key, err := s.Put(receipt)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				stored := raw(key)
+				var env envelope
+				if err := json.Unmarshal(stored, &env); err != nil || env.Version != encryptionVersion || env.Algorithm != algorithm {
+					t.Fatalf("receipt not stored in an envelope: %s", stored)
+				}
+				if bytes.Contains(stored, []byte(receipt.Signature)) {
+					t.Fatal("stored envelope contains the plaintext signature")
+				}
+
+				got, err := s.Get(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := receipt.ToJSON()
+				have, _ := got.ToJSON()
+				if !bytes.Equal(want, have) {
+					t.Fatal("decrypted receipt differs from the stored one")
+				}
+				if _, err := s.Put(receipt); err != nil {
+					t.Fatalf("storing the receipt again failed: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestEncryptionRejects(t *testing.T) {
+	e := testEncryption(t, AlgorithmAESGCM)
+	plaintext := []byte(`{"receipt":true}`)
+	sealed, err := e.seal("key-a", plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := e.seal("key-b", []byte(`{"receipt":"other"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modify := func(fn func(env *envelope)) []byte {
+		var env envelope
+		json.Unmarshal(sealed, &env)
+		fn(&env)
+		data, _ := json.Marshal(env)
+		return data
+	}
+	flipped := modify(func(env *envelope) {
+		ciphertext, _ := base64.StdEncoding.DecodeString(env.Ciphertext)
+		ciphertext[len(ciphertext)-1] ^= 1
+		env.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	})
+	otherKey := testEncryption(t, AlgorithmAESGCM)
+	otherKey.keys = &LocalKeyWrapper{KeyID: "kek-1", Key: bytes.Repeat([]byte{8}, 32)}
+
+	tests := []struct {
+		name string
+		e    *Encryption
+		key  string
+		data []byte
+	}{
+		{"swapped into another key", e, "key-b", sealed},
+		{"other receipt's envelope", e, "key-a", other},
+		{"flipped ciphertext bit", e, "key-a", flipped},
+		{"truncated ciphertext", e, "key-a", modify(func(env *envelope) { env.Ciphertext = env.Ciphertext[:8] })},
+		{"invalid base64", e, "key-a", modify(func(env *envelope) { env.Ciphertext = "!" })},
+		{"unknown key-encryption key", e, "key-a", modify(func(env *envelope) { env.KeyID = "kek-2" })},
+		{"different key-encryption key", otherKey, "key-a", sealed},
+		{"algorithm downgrade", e, "key-a", modify(func(env *envelope) { env.Algorithm = AlgorithmXChaCha20 })},
+		{"no encryption configured", nil, "key-a", sealed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.e.open(tt.key, tt.data); !errors.Is(err, ErrDecrypt) {
+				t.Fatalf("open = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+
+	opened, err := e.open("key-a", sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("open of the untouched envelope failed: %v", err)
+	}
+}
+
+func TestEncryptionReadsPlaintextReceipts(t *testing.T) {
+	dir := t.TempDir()
+	plain, err := NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := plain.Put(testReceipt(t, "before"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := NewDirStore(dir, WithEncryption(testEncryption(t, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encrypted.Get(key); err != nil {
+		t.Fatalf("receipt stored before encryption unreadable: %v", err)
+	}
+	sealedKey, err := encrypted.Put(testReceipt(t, "after"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.Get(sealedKey); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("store without encryption read an encrypted receipt: %v", err)
+	}
+}
+
+func TestEncryptionRotatesDataKeys(t *testing.T) {
+	e := testEncryption(t, AlgorithmAESGCM)
+	first, err := e.seal("key-a", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.uses = maxDataKeyUses
+	second, err := e.seal("key-b", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var a, b envelope
+	json.Unmarshal(first, &a)
+	json.Unmarshal(second, &b)
+	if a.WrappedKey == b.WrappedKey {
+		t.Fatal("data key not rotated after its use limit")
+	}
+	for key, data := range map[string][]byte{"key-a": first, "key-b": second} {
+		if _, err := e.open(key, data); err != nil {
+			t.Fatalf("receipt under %s unreadable after rotation: %v", key, err)
+		}
+	}
+}
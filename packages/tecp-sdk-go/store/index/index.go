@@ -0,0 +1,389 @@
+// Package index maintains an embedded search index over archived receipts.
+//
+// The index supports structured filters (policy IDs, code_ref, tenant,
+// labels, issuance time, extension presence) and full-text terms drawn from code refs, extension
+// values and annotation statements, without an external search cluster.
+// It can be rebuilt from a store or snapshotted to disk with Save and Load.
+package index
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// maxIndexedValue bounds extension strings that are tokenized; longer values
+// are almost always encoded certificates, proofs or signatures
+const maxIndexedValue = 256
+
+// Query selects receipts. Zero-valued fields do not filter
+type Query struct {
+	// Policies must all be claimed by the receipt
+	Policies []string
+
+	// CodeRef matches exactly, or as a prefix when it ends in "*"
+	CodeRef string
+
+	// Tenant matches the receipt's signed tenant exactly
+	Tenant string
+
+	// Labels must all be signed into the receipt with these values
+	Labels map[string]string
+
+	// Text terms must all occur in the receipt's indexed text
+	Text string
+
+	// Extensions must all be present on the receipt
+	Extensions []string
+
+	// From and To bound the issuance time (inclusive, exclusive)
+	From time.Time
+	To   time.Time
+
+	// Limit caps the number of results; 0 means no limit
+	Limit int
+}
+
+// Hit is a matching receipt key with its issuance time
+type Hit struct {
+	Key       string
+	Timestamp int64
+}
+
+// document is the indexed form of a receipt
+type document struct {
+	Timestamp  int64
+	CodeRef    string
+	Tenant     string
+	Labels     []string
+	Policies   []string
+	Extensions []string
+	Terms      []string
+}
+
+// Index is an in-memory inverted index safe for concurrent use
+type Index struct {
+	mu         sync.RWMutex
+	docs       map[string]*document
+	policies   map[string]map[string]struct{}
+	codeRefs   map[string]map[string]struct{}
+	tenants    map[string]map[string]struct{}
+	labels     map[string]map[string]struct{}
+	extensions map[string]map[string]struct{}
+	terms      map[string]map[string]struct{}
+}
+
+// New creates an empty index
+func New() *Index {
+	return &Index{
+		docs:       make(map[string]*document),
+		policies:   make(map[string]map[string]struct{}),
+		codeRefs:   make(map[string]map[string]struct{}),
+		tenants:    make(map[string]map[string]struct{}),
+		labels:     make(map[string]map[string]struct{}),
+		extensions: make(map[string]map[string]struct{}),
+		terms:      make(map[string]map[string]struct{}),
+	}
+}
+
+// Build indexes every receipt in a store
+func Build(s store.ReceiptStore) (*Index, error) {
+	ix := New()
+	err := s.Walk(func(key string, receipt *tecp.Receipt) error {
+		ix.Add(key, receipt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// Add indexes a receipt under key, replacing any previous entry
+func (ix *Index) Add(key string, receipt *tecp.Receipt) {
+	doc := analyze(receipt)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+	ix.insert(key, doc)
+}
+
+// Remove drops key from the index
+func (ix *Index) Remove(key string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+}
+
+// Len returns the number of indexed receipts
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Search returns matching receipts, newest first
+func (ix *Index) Search(q Query) []Hit {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Intersect posting lists for the exact-match filters first
+	var candidates map[string]struct{}
+	narrow := func(postings map[string]struct{}) {
+		if candidates == nil {
+			candidates = make(map[string]struct{}, len(postings))
+			for key := range postings {
+				candidates[key] = struct{}{}
+			}
+			return
+		}
+		for key := range candidates {
+			if _, ok := postings[key]; !ok {
+				delete(candidates, key)
+			}
+		}
+	}
+
+	for _, policy := range q.Policies {
+		narrow(ix.policies[policy])
+	}
+	for _, name := range q.Extensions {
+		narrow(ix.extensions[name])
+	}
+	for _, term := range tokenize(q.Text) {
+		narrow(ix.terms[term])
+	}
+	if q.CodeRef != "" && !strings.HasSuffix(q.CodeRef, "*") {
+		narrow(ix.codeRefs[q.CodeRef])
+	}
+	if q.Tenant != "" {
+		narrow(ix.tenants[q.Tenant])
+	}
+	for key, value := range q.Labels {
+		narrow(ix.labels[key+"="+value])
+	}
+
+	var from, to int64
+	if !q.From.IsZero() {
+		from = q.From.UnixMilli()
+	}
+	if !q.To.IsZero() {
+		to = q.To.UnixMilli()
+	}
+	prefix, prefixed := strings.CutSuffix(q.CodeRef, "*")
+
+	var hits []Hit
+	match := func(key string, doc *document) {
+		if from != 0 && doc.Timestamp < from {
+			return
+		}
+		if to != 0 && doc.Timestamp >= to {
+			return
+		}
+		if prefixed && !strings.HasPrefix(doc.CodeRef, prefix) {
+			return
+		}
+		hits = append(hits, Hit{Key: key, Timestamp: doc.Timestamp})
+	}
+
+	if candidates == nil {
+		for key, doc := range ix.docs {
+			match(key, doc)
+		}
+	} else {
+		for key := range candidates {
+			match(key, ix.docs[key])
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Timestamp != hits[j].Timestamp {
+			return hits[i].Timestamp > hits[j].Timestamp
+		}
+		return hits[i].Key < hits[j].Key
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits
+}
+
+// Save writes a snapshot of the index
+func (ix *Index) Save(w io.Writer) error {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return gob.NewEncoder(w).Encode(ix.docs)
+}
+
+// Load reads a snapshot written by Save
+func Load(r io.Reader) (*Index, error) {
+	var docs map[string]*document
+	if err := gob.NewDecoder(r).Decode(&docs); err != nil {
+		return nil, fmt.Errorf("index: invalid snapshot: %w", err)
+	}
+
+	ix := New()
+	for key, doc := range docs {
+		ix.insert(key, doc)
+	}
+	return ix, nil
+}
+
+func (ix *Index) insert(key string, doc *document) {
+	ix.docs[key] = doc
+	post(ix.codeRefs, doc.CodeRef, key)
+	if doc.Tenant != "" {
+		post(ix.tenants, doc.Tenant, key)
+	}
+	for _, label := range doc.Labels {
+		post(ix.labels, label, key)
+	}
+	for _, policy := range doc.Policies {
+		post(ix.policies, policy, key)
+	}
+	for _, name := range doc.Extensions {
+		post(ix.extensions, name, key)
+	}
+	for _, term := range doc.Terms {
+		post(ix.terms, term, key)
+	}
+}
+
+func (ix *Index) remove(key string) {
+	doc, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	delete(ix.docs, key)
+	unpost(ix.codeRefs, doc.CodeRef, key)
+	if doc.Tenant != "" {
+		unpost(ix.tenants, doc.Tenant, key)
+	}
+	for _, label := range doc.Labels {
+		unpost(ix.labels, label, key)
+	}
+	for _, policy := range doc.Policies {
+		unpost(ix.policies, policy, key)
+	}
+	for _, name := range doc.Extensions {
+		unpost(ix.extensions, name, key)
+	}
+	for _, term := range doc.Terms {
+		unpost(ix.terms, term, key)
+	}
+}
+
+func post(postings map[string]map[string]struct{}, value, key string) {
+	keys, ok := postings[value]
+	if !ok {
+		keys = make(map[string]struct{})
+		postings[value] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+func unpost(postings map[string]map[string]struct{}, value, key string) {
+	keys := postings[value]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(postings, value)
+	}
+}
+
+// analyze extracts the indexed fields of a receipt
+func analyze(receipt *tecp.Receipt) *document {
+	doc := &document{
+		Timestamp: receipt.Timestamp,
+		CodeRef:   receipt.CodeRef,
+		Tenant:    receipt.Tenant(),
+		Policies:  append([]string(nil), receipt.PolicyIDs...),
+	}
+	for key, value := range receipt.Labels() {
+		doc.Labels = append(doc.Labels, key+"="+value)
+	}
+	sort.Strings(doc.Labels)
+
+	terms := make(map[string]struct{})
+	addText := func(text string) {
+		for _, term := range tokenize(text) {
+			terms[term] = struct{}{}
+		}
+	}
+	addText(receipt.CodeRef)
+	for _, policy := range receipt.PolicyIDs {
+		addText(policy)
+	}
+
+	for name, value := range receipt.Extensions {
+		doc.Extensions = append(doc.Extensions, name)
+		addText(name)
+		walkStrings(generic(value), func(s string) {
+			if len(s) <= maxIndexedValue {
+				addText(s)
+			}
+		})
+	}
+	sort.Strings(doc.Extensions)
+
+	for term := range terms {
+		doc.Terms = append(doc.Terms, term)
+	}
+	sort.Strings(doc.Terms)
+	return doc
+}
+
+// generic converts a typed extension value to its decoded JSON form so fresh
+// and archived receipts are indexed identically
+func generic(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// walkStrings visits string values and object keys nested in a decoded value
+func walkStrings(value interface{}, fn func(string)) {
+	switch v := value.(type) {
+	case string:
+		fn(v)
+	case []interface{}:
+		for _, item := range v {
+			walkStrings(item, fn)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			fn(key)
+			walkStrings(item, fn)
+		}
+	}
+}
+
+// tokenize lowercases text and splits it into alphanumeric terms
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := fields[:0]
+	for _, field := range fields {
+		if len(field) >= 2 {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,57 @@
+package index
+
+import (
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// IndexedStore wraps a ReceiptStore and keeps an index in sync with it
+type IndexedStore struct {
+	store.ReceiptStore
+	Index *Index
+}
+
+// NewIndexedStore indexes the existing contents of s and returns a store
+// that updates the index on every write
+func NewIndexedStore(s store.ReceiptStore) (*IndexedStore, error) {
+	ix, err := Build(s)
+	if err != nil {
+		return nil, err
+	}
+	return &IndexedStore{ReceiptStore: s, Index: ix}, nil
+}
+
+// Put stores and indexes a receipt
+func (s *IndexedStore) Put(receipt *tecp.Receipt) (string, error) {
+	key, err := s.ReceiptStore.Put(receipt)
+	if err != nil {
+		return "", err
+	}
+	s.Index.Add(key, receipt)
+	return key, nil
+}
+
+// Delete removes a receipt from the store and the index
+func (s *IndexedStore) Delete(key string) error {
+	if err := s.ReceiptStore.Delete(key); err != nil {
+		return err
+	}
+	s.Index.Remove(key)
+	return nil
+}
+
+// Query returns the stored receipts matching q, newest first
+func (s *IndexedStore) Query(q Query) ([]*tecp.Receipt, error) {
+	var receipts []*tecp.Receipt
+	for _, hit := range s.Index.Search(q) {
+		receipt, err := s.Get(hit.Key)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
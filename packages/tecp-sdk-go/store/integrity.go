@@ -0,0 +1,134 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// ErrConflict is returned by Put when a receipt's key is already stored with
+// different signed content, which means one of the copies was tampered with
+var ErrConflict = errors.New("store: conflicting receipt for key")
+
+// Integrity problem kinds
+const (
+	ProblemUnreadable  = "unreadable"
+	ProblemKeyMismatch = "key_mismatch"
+	ProblemInvalid     = "invalid"
+)
+
+// IntegrityProblem is a stored receipt that failed an integrity check
+type IntegrityProblem struct {
+	Key    string   `json:"key"`
+	Kind   string   `json:"kind"`
+	Errors []string `json:"errors"`
+}
+
+// IntegrityReport is the outcome of VerifyIntegrity
+type IntegrityReport struct {
+	Checked  int                `json:"checked"`
+	Problems []IntegrityProblem `json:"problems,omitempty"`
+}
+
+// OK reports whether every stored receipt passed
+func (r *IntegrityReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// reconcile decides how Put treats a receipt whose key may already be
+// stored. Identical copies are not rewritten. Copies differing only in
+// unsigned extensions, such as newly attached proofs, are replaced. Copies
+// whose signed extensions differ conflict
+func reconcile(key string, existing, data []byte, receipt *tecp.Receipt) (bool, error) {
+	if existing == nil {
+		return true, nil
+	}
+	if bytes.Equal(existing, data) {
+		return false, nil
+	}
+	stored, err := tecp.FromJSON(existing)
+	if err != nil {
+		// An unreadable copy is repaired by the new one
+		return true, nil
+	}
+
+	var names []string
+	for _, r := range []*tecp.Receipt{stored, receipt} {
+		if r.SignedExt != nil {
+			names = append(names, r.SignedExt.Names...)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a, errA := normalizedJSON(stored.Extensions[name])
+		b, errB := normalizedJSON(receipt.Extensions[name])
+		if errA != nil || errB != nil || !bytes.Equal(a, b) {
+			return false, fmt.Errorf("%w %s: signed extension %s differs", ErrConflict, key, name)
+		}
+	}
+	return true, nil
+}
+
+// normalizedJSON encodes a value with sorted keys, so typed values and the
+// generic maps of decoded receipts compare alike
+func normalizedJSON(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// verifyIntegrity reads every key with read and checks that the receipt
+// decodes, matches its key and verifies. Freshness is not checked, since
+// archived receipts outlive any age limit
+func verifyIntegrity(keys []string, read func(key string) ([]byte, error), verifier *tecp.Client, options tecp.VerifyOptions) (*IntegrityReport, error) {
+	if verifier == nil {
+		verifier = tecp.NewClient()
+	}
+	options.DisabledChecks = append(append([]string(nil), options.DisabledChecks...), tecp.CheckTimestamp)
+	sort.Strings(keys)
+
+	report := &IntegrityReport{}
+	for _, key := range keys {
+		data, err := read(key)
+		if err == ErrNotFound {
+			continue
+		}
+		if errors.Is(err, ErrDecrypt) {
+			report.Checked++
+			report.Problems = append(report.Problems, IntegrityProblem{Key: key, Kind: ProblemUnreadable, Errors: []string{err.Error()}})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Checked++
+
+		receipt, err := tecp.FromJSON(data)
+		if err != nil {
+			report.Problems = append(report.Problems, IntegrityProblem{Key: key, Kind: ProblemUnreadable, Errors: []string{err.Error()}})
+			continue
+		}
+		if actual, err := Key(receipt); err != nil || actual != key {
+			report.Problems = append(report.Problems, IntegrityProblem{Key: key, Kind: ProblemKeyMismatch, Errors: []string{"stored receipt does not match its key"}})
+			continue
+		}
+		result, err := verifier.VerifyReceipt(receipt, options)
+		if err != nil {
+			return nil, err
+		}
+		if !result.Valid {
+			report.Problems = append(report.Problems, IntegrityProblem{Key: key, Kind: ProblemInvalid, Errors: result.Errors})
+		}
+	}
+	return report, nil
+}
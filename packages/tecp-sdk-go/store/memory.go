@@ -0,0 +1,125 @@
+package store
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// MemoryStore is an in-memory ReceiptStore for tests and short-lived services
+type MemoryStore struct {
+	mu         sync.RWMutex
+	receipts   map[string][]byte
+	encryption *Encryption
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	return &MemoryStore{receipts: make(map[string][]byte), encryption: applyOptions(opts).encryption}
+}
+
+// Put stores a receipt and returns its key. Storing a receipt again is a
+// no-op; a copy with different signed content fails with ErrConflict
+func (s *MemoryStore) Put(receipt *tecp.Receipt) (string, error) {
+	key, err := Key(receipt)
+	if err != nil {
+		return "", err
+	}
+
+	// Store the encoded form so callers cannot mutate stored receipts
+	data, err := receipt.ToJSON()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing := s.receipts[key]
+	if existing != nil {
+		if existing, err = s.encryption.open(key, existing); err != nil {
+			return "", err
+		}
+	}
+	write, err := reconcile(key, existing, data, receipt)
+	if err != nil {
+		return "", err
+	}
+	if write {
+		if data, err = s.encryption.seal(key, data); err != nil {
+			return "", err
+		}
+		s.receipts[key] = data
+	}
+	return key, nil
+}
+
+// Get returns the receipt stored under key
+func (s *MemoryStore) Get(key string) (*tecp.Receipt, error) {
+	data, err := s.read(key)
+	if err != nil {
+		return nil, err
+	}
+	return tecp.FromJSON(data)
+}
+
+// Delete removes the receipt stored under key
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.receipts[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.receipts, key)
+	return nil
+}
+
+// VerifyIntegrity re-verifies every stored receipt with verifier, which
+// defaults to a client with the default profile, and reports corruption
+func (s *MemoryStore) VerifyIntegrity(verifier *tecp.Client, options tecp.VerifyOptions) (*IntegrityReport, error) {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.receipts))
+	for key := range s.receipts {
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+
+	return verifyIntegrity(keys, s.read, verifier, options)
+}
+
+// read returns the decrypted receipt JSON stored under key
+func (s *MemoryStore) read(key string) ([]byte, error) {
+	s.mu.RLock()
+	data, ok := s.receipts[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.encryption.open(key, data)
+}
+
+// Walk calls fn for every stored receipt in key order
+func (s *MemoryStore) Walk(fn func(key string, receipt *tecp.Receipt) error) error {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.receipts))
+	for key := range s.receipts {
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		receipt, err := s.Get(key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(key, receipt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Package retention expires stored receipts according to per-policy
+// retention periods.
+//
+// A receipt is kept for the longest retention of the policies it claims, or
+// the default retention when it claims none with a rule, counted from its
+// timestamp. Each sweep that deletes receipts first records a signed
+// deletion manifest, so removals from the archive stay accountable.
+package retention
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+const manifestVersion = "TECP-DELETION-0.1"
+
+// Forever marks a retention period that never expires
+const Forever time.Duration = -1
+
+// Options configures a Manager
+type Options struct {
+	// Policies maps policy IDs to how long receipts claiming them are kept,
+	// such as 7 years for gdpr_compliant
+	Policies map[string]time.Duration
+
+	// Default is the retention of receipts claiming no policy in Policies.
+	// Zero or Forever keeps them forever
+	Default time.Duration
+
+	// KeyID and PrivateKey sign deletion manifests
+	KeyID      string
+	PrivateKey ed25519.PrivateKey
+
+	// OnManifest records a signed manifest before its receipts are deleted.
+	// When it fails the sweep deletes nothing
+	OnManifest func(manifest *Manifest) error
+
+	// Interval is the time between background sweeps; defaults to an hour
+	Interval time.Duration
+
+	// OnError, when set, is called with the failures of background sweeps
+	OnError func(err error)
+}
+
+// Deletion records one receipt removed by a sweep
+type Deletion struct {
+	Key       string   `json:"key"`
+	Timestamp int64    `json:"ts"`
+	PolicyIDs []string `json:"policy_ids"`
+	ExpiredAt int64    `json:"expired_at"`
+}
+
+// Manifest is the signed record of the receipts deleted by a sweep
+type Manifest struct {
+	Version   string     `json:"v"`
+	Timestamp int64      `json:"timestamp"`
+	Deletions []Deletion `json:"deletions"`
+	KeyID     string     `json:"kid"`
+	Signature string     `json:"sig,omitempty"`
+}
+
+// SigningPayload returns the bytes covered by the manifest signature: its
+// JSON encoding without the signature
+func (m *Manifest) SigningPayload() []byte {
+	unsigned := *m
+	unsigned.Signature = ""
+	payload, _ := json.Marshal(&unsigned)
+	return payload
+}
+
+// VerifyManifest checks a manifest's signature
+func VerifyManifest(manifest *Manifest, publicKey ed25519.PublicKey) error {
+	if manifest.Version != manifestVersion {
+		return fmt.Errorf("retention: unsupported manifest version: %s", manifest.Version)
+	}
+	signature, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil {
+		return fmt.Errorf("retention: invalid manifest signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, manifest.SigningPayload(), signature) {
+		return fmt.Errorf("retention: manifest signature verification failed")
+	}
+	return nil
+}
+
+// Manager applies retention to a store
+type Manager struct {
+	store store.ReceiptStore
+	opts  Options
+}
+
+// New returns a Manager for s
+func New(s store.ReceiptStore, options Options) (*Manager, error) {
+	if options.PrivateKey == nil {
+		return nil, fmt.Errorf("retention: manifest signing key required")
+	}
+	if options.OnManifest == nil {
+		return nil, fmt.Errorf("retention: OnManifest required")
+	}
+	return &Manager{store: s, opts: options}, nil
+}
+
+// Expiry returns when a receipt's retention ends. It returns false for
+// receipts kept forever
+func (m *Manager) Expiry(receipt *tecp.Receipt) (time.Time, bool) {
+	retain, matched := time.Duration(0), false
+	for _, id := range receipt.PolicyIDs {
+		period, ok := m.opts.Policies[id]
+		if !ok {
+			continue
+		}
+		if period == Forever {
+			return time.Time{}, false
+		}
+		if !matched || period > retain {
+			retain, matched = period, true
+		}
+	}
+	if !matched {
+		if m.opts.Default <= 0 {
+			return time.Time{}, false
+		}
+		retain = m.opts.Default
+	}
+	return time.UnixMilli(receipt.Timestamp).Add(retain), true
+}
+
+// Sweep deletes the receipts expired at now. It returns the signed manifest
+// of the deletions, or nil when nothing expired. Receipts that fail to
+// delete are reported and listed again by the next sweep
+func (m *Manager) Sweep(now time.Time) (*Manifest, error) {
+	var deletions []Deletion
+	err := m.store.Walk(func(key string, receipt *tecp.Receipt) error {
+		expiry, ok := m.Expiry(receipt)
+		if ok && !now.Before(expiry) {
+			deletions = append(deletions, Deletion{
+				Key:       key,
+				Timestamp: receipt.Timestamp,
+				PolicyIDs: receipt.PolicyIDs,
+				ExpiredAt: expiry.UnixMilli(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("retention: failed to walk store: %w", err)
+	}
+	if len(deletions) == 0 {
+		return nil, nil
+	}
+	sort.Slice(deletions, func(a, b int) bool { return deletions[a].Key < deletions[b].Key })
+
+	manifest := &Manifest{
+		Version:   manifestVersion,
+		Timestamp: now.UnixMilli(),
+		Deletions: deletions,
+		KeyID:     m.opts.KeyID,
+	}
+	manifest.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(m.opts.PrivateKey, manifest.SigningPayload()))
+	if err := m.opts.OnManifest(manifest); err != nil {
+		return nil, fmt.Errorf("retention: failed to record manifest: %w", err)
+	}
+
+	var failed []error
+	for _, deletion := range deletions {
+		if err := m.store.Delete(deletion.Key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			failed = append(failed, fmt.Errorf("%s: %w", deletion.Key, err))
+		}
+	}
+	if len(failed) > 0 {
+		return manifest, fmt.Errorf("retention: failed to delete %d receipts: %w", len(failed), errors.Join(failed...))
+	}
+	return manifest, nil
+}
+
+// Run sweeps every Interval until ctx is done
+func (m *Manager) Run(ctx context.Context) error {
+	interval := m.opts.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Sweep(time.Now()); err != nil && m.opts.OnError != nil {
+			m.opts.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package store persists issued TECP receipts.
+//
+// Receipts are keyed by the hex encoding of tecp.ReceiptHash, which covers the
+// signed fields and signature but not the mutable extensions, so re-storing a
+// receipt after attaching proofs or annotations updates it in place. Stores
+// reject copies whose signed extensions differ with ErrConflict.
+//
+// DirStore and MemoryStore encrypt receipts at rest when created with
+// WithEncryption, using envelope encryption with data keys wrapped by a
+// KeyWrapper such as a KMS.
+package store
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// ErrNotFound is returned when no receipt is stored under a key
+var ErrNotFound = errors.New("store: receipt not found")
+
+// ReceiptStore persists receipts by key
+type ReceiptStore interface {
+	// Put stores a receipt and returns its key
+	Put(receipt *tecp.Receipt) (string, error)
+
+	// Get returns the receipt stored under key, or ErrNotFound
+	Get(key string) (*tecp.Receipt, error)
+
+	// Delete removes the receipt stored under key, or returns ErrNotFound
+	Delete(key string) error
+
+	// Walk calls fn for every stored receipt until fn returns an error
+	Walk(fn func(key string, receipt *tecp.Receipt) error) error
+}
+
+// Option configures a store
+type Option func(*options)
+
+type options struct {
+	encryption *Encryption
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Key returns the store key for a receipt
+func Key(receipt *tecp.Receipt) (string, error) {
+	hash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash), nil
+}
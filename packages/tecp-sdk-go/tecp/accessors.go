@@ -0,0 +1,111 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// InputHashBytes returns the decoded SHA-256 input hash
+func (r *Receipt) InputHashBytes() ([]byte, error) {
+	return decodeDigest("input hash", r.InputHash)
+}
+
+// OutputHashBytes returns the decoded SHA-256 output hash
+func (r *Receipt) OutputHashBytes() ([]byte, error) {
+	return decodeDigest("output hash", r.OutputHash)
+}
+
+// NonceBytes returns the decoded nonce
+func (r *Receipt) NonceBytes() ([]byte, error) {
+	return decodeBinaryField("nonce", r.Nonce, NonceSize)
+}
+
+// SignatureBytes returns the decoded signature
+func (r *Receipt) SignatureBytes() ([]byte, error) {
+	_, size := r.keySizes()
+	return decodeBinaryField("signature", r.Signature, size)
+}
+
+// PublicKeyEd25519 returns the decoded issuer public key
+func (r *Receipt) PublicKeyEd25519() (ed25519.PublicKey, error) {
+	return receiptPublicKey(r)
+}
+
+// Time returns the issuance time
+func (r *Receipt) Time() time.Time {
+	return time.UnixMilli(r.Timestamp)
+}
+
+// SetInputHash sets the input hash from a SHA-256 digest
+func (r *Receipt) SetInputHash(hash []byte) error {
+	if len(hash) != sha256.Size {
+		return fmt.Errorf("input hash must be a SHA-256 digest, got %d bytes", len(hash))
+	}
+	r.InputHash = base64.StdEncoding.EncodeToString(hash)
+	return nil
+}
+
+// SetOutputHash sets the output hash from a SHA-256 digest
+func (r *Receipt) SetOutputHash(hash []byte) error {
+	if len(hash) != sha256.Size {
+		return fmt.Errorf("output hash must be a SHA-256 digest, got %d bytes", len(hash))
+	}
+	r.OutputHash = base64.StdEncoding.EncodeToString(hash)
+	return nil
+}
+
+// SetNonce sets the nonce
+func (r *Receipt) SetNonce(nonce []byte) error {
+	if len(nonce) != NonceSize {
+		return fmt.Errorf("nonce must be %d bytes, got %d", NonceSize, len(nonce))
+	}
+	r.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	return nil
+}
+
+// SetPublicKey sets the issuer public key
+func (r *Receipt) SetPublicKey(publicKey ed25519.PublicKey) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key size: %d", len(publicKey))
+	}
+	r.PublicKey = base64.StdEncoding.EncodeToString(publicKey)
+	return nil
+}
+
+// SetTime sets the issuance time, at millisecond precision
+func (r *Receipt) SetTime(t time.Time) {
+	r.Timestamp = t.UnixMilli()
+}
+
+// SetExtension sets an extension. Extensions are not covered by the
+// signature, so setting one does not invalidate the receipt
+func (r *Receipt) SetExtension(name string, value interface{}) {
+	if r.Extensions == nil {
+		r.Extensions = make(map[string]interface{})
+	}
+	r.Extensions[name] = value
+}
+
+// GetExtension decodes the named extension into a T. It reports whether the
+// extension exists; typed values and the generic maps of decoded receipts
+// are both accepted
+func GetExtension[T any](r *Receipt, name string) (T, bool, error) {
+	var value T
+	found, err := decodeExtension(r, name, &value)
+	return value, found, err
+}
+
+// decodeDigest decodes a base64 SHA-256 digest
+func decodeDigest(field, encoded string) ([]byte, error) {
+	digest, err := decodeBinaryField(field, encoded, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("invalid %s size: %d", field, len(digest))
+	}
+	return digest, nil
+}
@@ -0,0 +1,178 @@
+package tecp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// AIExtension fingerprints the model and data behind an AI workload
+const AIExtension = "ai"
+
+// CheckModel is the pipeline check verifying AI fingerprints
+const CheckModel = "model"
+
+// digestPrefix prefixes the SHA-256 digests of fingerprints
+const digestPrefix = "sha256:"
+
+// AIFingerprint identifies the model and data an AI workload ran with.
+// Digests are "sha256:<hex>", or registry references ending in one, such
+// as "registry.example.com/acme/summarizer@sha256:<hex>"
+type AIFingerprint struct {
+	// ModelDigest is the digest of the model weights or their registry
+	// manifest
+	ModelDigest string `json:"model_digest"`
+
+	// WeightsVersion is the model's version label, such as "2025.06.1"
+	WeightsVersion string `json:"weights_version,omitempty"`
+
+	// TokenizerDigest is the digest of the tokenizer files
+	TokenizerDigest string `json:"tokenizer_digest,omitempty"`
+
+	// Datasets are the digests of the datasets in the model's lineage
+	Datasets []string `json:"datasets,omitempty"`
+}
+
+// AllowedModel is a model a verifier accepts. An empty TokenizerDigest
+// or WeightsVersion accepts any
+type AllowedModel struct {
+	ModelDigest     string
+	TokenizerDigest string
+	WeightsVersion  string
+}
+
+// Digest returns the "sha256:<hex>" digest of data
+func Digest(data []byte) string {
+	hash := sha256.Sum256(data)
+	return digestPrefix + hex.EncodeToString(hash[:])
+}
+
+// DigestReader returns the "sha256:<hex>" digest of everything read from r
+func DigestReader(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return digestPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// DigestFile returns the "sha256:<hex>" digest of a file, such as model
+// weights, without loading it into memory
+func DigestFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return DigestReader(f)
+}
+
+// Validate checks that every digest is well formed
+func (f *AIFingerprint) Validate() error {
+	if f.ModelDigest == "" {
+		return fmt.Errorf("ai fingerprint has no model_digest")
+	}
+	if _, err := parseDigest(f.ModelDigest); err != nil {
+		return fmt.Errorf("invalid model_digest: %w", err)
+	}
+	if f.TokenizerDigest != "" {
+		if _, err := parseDigest(f.TokenizerDigest); err != nil {
+			return fmt.Errorf("invalid tokenizer_digest: %w", err)
+		}
+	}
+	for _, dataset := range f.Datasets {
+		if _, err := parseDigest(dataset); err != nil {
+			return fmt.Errorf("invalid dataset digest: %w", err)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether the fingerprint matches an allowed model.
+// Registry references match by their digest
+func (m AllowedModel) Allows(f *AIFingerprint) bool {
+	if !sameDigest(m.ModelDigest, f.ModelDigest) {
+		return false
+	}
+	if m.TokenizerDigest != "" && !sameDigest(m.TokenizerDigest, f.TokenizerDigest) {
+		return false
+	}
+	return m.WeightsVersion == "" || m.WeightsVersion == f.WeightsVersion
+}
+
+// AIFingerprint returns the receipt's AI fingerprint. Only a fingerprint
+// covered by the signature is returned; the signature check verifies it
+func (r *Receipt) AIFingerprint() (*AIFingerprint, bool) {
+	if !r.signsExtension(AIExtension) {
+		return nil, false
+	}
+	var fingerprint AIFingerprint
+	if found, err := decodeExtension(r, AIExtension, &fingerprint); err != nil || !found {
+		return nil, false
+	}
+	return &fingerprint, true
+}
+
+// checkModel verifies the AI fingerprint and matches it against
+// VerifyOptions.AllowedModels
+func checkModel(v *Verification, result *CheckResult) {
+	receipt, allowed := v.Receipt, v.Options.AllowedModels
+	var fingerprint AIFingerprint
+	found, err := decodeExtension(receipt, AIExtension, &fingerprint)
+	if err != nil {
+		result.Fail("", err.Error())
+		return
+	}
+	if !found {
+		if len(allowed) > 0 {
+			result.Fail("", "receipt has no ai fingerprint to match against allowed models")
+		} else {
+			result.Skip("no ai fingerprint")
+		}
+		return
+	}
+
+	if err := fingerprint.Validate(); err != nil {
+		result.Fail("", err.Error())
+		return
+	}
+	if !receipt.signsExtension(AIExtension) {
+		if len(allowed) > 0 {
+			result.Fail("", "ai fingerprint is not covered by the signature")
+		} else {
+			result.Warn(WarningUnsigned, "ai fingerprint is not covered by the signature")
+		}
+		return
+	}
+	if len(allowed) == 0 {
+		return
+	}
+	for _, model := range allowed {
+		if model.Allows(&fingerprint) {
+			return
+		}
+	}
+	result.Fail("", fmt.Sprintf("model %s is not allowed", fingerprint.ModelDigest))
+}
+
+// parseDigest returns the hex SHA-256 of a digest or registry reference
+func parseDigest(digest string) (string, error) {
+	i := strings.LastIndex(digest, digestPrefix)
+	if i < 0 || (i > 0 && digest[i-1] != '@') {
+		return "", fmt.Errorf("%q is not a sha256 digest", digest)
+	}
+	hash := digest[i+len(digestPrefix):]
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("%q is not a sha256 digest", digest)
+	}
+	return strings.ToLower(hash), nil
+}
+
+func sameDigest(a, b string) bool {
+	ha, errA := parseDigest(a)
+	hb, errB := parseDigest(b)
+	return errA == nil && errB == nil && ha == hb
+}
@@ -0,0 +1,114 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+)
+
+// AIActPolicy claims EU AI Act processing transparency, backed by the
+// ai_act extension
+const AIActPolicy = "ai_act_transparency"
+
+// AIActExtension carries the AI Act transparency record
+const AIActExtension = "ai_act"
+
+const aiActSigningVersion = "TECP-AI-ACT-0.1"
+
+// AIActTransparency identifies the AI system behind a computation, as the
+// EU AI Act transparency obligations require
+type AIActTransparency struct {
+	// ModelID identifies the model, such as "acme/summarizer-7b"
+	ModelID string `json:"model_id"`
+
+	// SystemVersion is the version of the deployed AI system
+	SystemVersion string `json:"system_version"`
+
+	// HumanOversight records whether a human oversaw the output
+	HumanOversight bool `json:"human_oversight"`
+
+	// PurposeCode is the intended purpose the system was used for
+	PurposeCode string `json:"purpose_code"`
+
+	Signature string `json:"sig,omitempty"`
+}
+
+// aiActRecord decodes the extension, detecting an absent oversight flag
+type aiActRecord struct {
+	ModelID        string `json:"model_id"`
+	SystemVersion  string `json:"system_version"`
+	HumanOversight *bool  `json:"human_oversight"`
+	PurposeCode    string `json:"purpose_code"`
+	Signature      string `json:"sig"`
+}
+
+// attachAIActTransparency binds the record to the receipt nonce with the
+// issuer key, since extensions are not covered by the receipt signature
+func attachAIActTransparency(receipt *Receipt, record *AIActTransparency, privateKey ed25519.PrivateKey) error {
+	signed := *record
+	payload, err := canonicalCBOR(aiActSigningPayload(signed.ModelID, signed.SystemVersion, signed.HumanOversight, signed.PurposeCode, receipt.Nonce))
+	if err != nil {
+		return fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	signed.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+
+	receipt.Extensions[AIActExtension] = &signed
+	if !containsPolicy(receipt.PolicyIDs, AIActPolicy) {
+		receipt.PolicyIDs = append(append([]string(nil), receipt.PolicyIDs...), AIActPolicy)
+	}
+	return nil
+}
+
+// verifyAIActTransparency checks that the ai_act extension carries every
+// required field and was signed by the receipt's issuer
+func verifyAIActTransparency(receipt *Receipt) error {
+	publicKey, err := receiptPublicKey(receipt)
+	if err != nil {
+		return err
+	}
+
+	var record aiActRecord
+	found, err := decodeExtension(receipt, AIActExtension, &record)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%s policy claimed without transparency record", AIActPolicy)
+	}
+
+	switch {
+	case record.ModelID == "":
+		return fmt.Errorf("transparency record has no model_id")
+	case record.SystemVersion == "":
+		return fmt.Errorf("transparency record has no system_version")
+	case record.HumanOversight == nil:
+		return fmt.Errorf("transparency record has no human_oversight flag")
+	case record.PurposeCode == "":
+		return fmt.Errorf("transparency record has no purpose_code")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(record.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid transparency record signature encoding: %w", err)
+	}
+	payload, err := canonicalCBOR(aiActSigningPayload(record.ModelID, record.SystemVersion, *record.HumanOversight, record.PurposeCode, receipt.Nonce))
+	if err != nil {
+		return fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	if !ed25519.Verify(publicKey, payload, signature) {
+		return fmt.Errorf("transparency record signature invalid")
+	}
+	return nil
+}
+
+// aiActSigningPayload binds a transparency record to a single receipt
+func aiActSigningPayload(modelID, systemVersion string, humanOversight bool, purposeCode, nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"version":         aiActSigningVersion,
+		"model_id":        modelID,
+		"system_version":  systemVersion,
+		"human_oversight": humanOversight,
+		"purpose_code":    purposeCode,
+		"nonce":           nonce,
+	}
+}
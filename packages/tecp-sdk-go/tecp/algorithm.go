@@ -0,0 +1,291 @@
+package tecp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Algorithm identifies a receipt signature algorithm by its JOSE name
+type Algorithm string
+
+const (
+	// AlgEdDSA is Ed25519, the default. Receipts without an alg field are
+	// signed with it
+	AlgEdDSA Algorithm = "EdDSA"
+
+	// AlgES256 is ECDSA over P-256 with SHA-256
+	AlgES256 Algorithm = "ES256"
+
+	// AlgES384 is ECDSA over P-384 with SHA-384
+	AlgES384 Algorithm = "ES384"
+
+	// AlgES256K is ECDSA over secp256k1 with SHA-256
+	AlgES256K Algorithm = "ES256K"
+)
+
+// FIPSAlgorithms are the algorithms receipts may be signed and verified
+// with in FIPS mode
+var FIPSAlgorithms = []Algorithm{AlgES256, AlgES384}
+
+var (
+	profileAlgorithmsMu sync.RWMutex
+
+	// profileAlgorithms restricts the algorithms of profiles; profiles not
+	// listed accept every supported algorithm
+	profileAlgorithms = map[Profile][]Algorithm{
+		ProfileStrict: {AlgEdDSA, AlgES256, AlgES384},
+	}
+)
+
+// SetProfileAlgorithms sets the signature algorithms receipts verified
+// under profile may use. No algorithms lifts the restriction
+func SetProfileAlgorithms(profile Profile, algorithms ...Algorithm) {
+	profileAlgorithmsMu.Lock()
+	defer profileAlgorithmsMu.Unlock()
+	if len(algorithms) == 0 {
+		delete(profileAlgorithms, profile)
+		return
+	}
+	profileAlgorithms[profile] = append([]Algorithm(nil), algorithms...)
+}
+
+// ProfileAlgorithms returns the signature algorithms profile accepts, or
+// nil when it accepts every supported algorithm. By default ProfileStrict
+// excludes ES256K
+func ProfileAlgorithms(profile Profile) []Algorithm {
+	profileAlgorithmsMu.RLock()
+	defer profileAlgorithmsMu.RUnlock()
+	return append([]Algorithm(nil), profileAlgorithms[profile]...)
+}
+
+var fipsMode atomic.Bool
+
+// EnableFIPSMode restricts signing and verification to FIPSAlgorithms for
+// the rest of the process. Building with the tecp_fips tag enables it from
+// the start
+func EnableFIPSMode() {
+	fipsMode.Store(true)
+}
+
+// FIPSMode reports whether FIPS mode is enabled
+func FIPSMode() bool {
+	return fipsBuild || fipsMode.Load()
+}
+
+// Signer signs receipts, for keys held in an HSM or KMS or under an
+// algorithm other than Ed25519
+type Signer interface {
+	// Algorithm is the signature algorithm
+	Algorithm() Algorithm
+
+	// PublicKey is the public key as carried in receipts: the raw key for
+	// Ed25519, the uncompressed SEC1 point for ECDSA
+	PublicKey() []byte
+
+	// Sign signs a receipt's canonical payload. ECDSA signatures are the
+	// fixed-size concatenation r||s
+	Sign(payload []byte) ([]byte, error)
+}
+
+// NewEd25519Signer returns a Signer for an Ed25519 key
+func NewEd25519Signer(key ed25519.PrivateKey) Signer {
+	return ed25519Signer{key: key}
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func (s ed25519Signer) Algorithm() Algorithm { return AlgEdDSA }
+
+func (s ed25519Signer) PublicKey() []byte { return s.key.Public().(ed25519.PublicKey) }
+
+func (s ed25519Signer) Sign(payload []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, payload), nil
+}
+
+// NewECDSASigner returns a Signer for an ECDSA key: an *ecdsa.PrivateKey,
+// or any crypto.Signer with an ECDSA public key, such as an HSM key. The
+// algorithm follows the curve: ES256 for P-256, ES384 for P-384 and ES256K
+// for secp256k1, as secp256k1.S256
+func NewECDSASigner(key crypto.Signer) (Signer, error) {
+	publicKey, ok := key.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ECDSA key: %T", key.Public())
+	}
+	for _, alg := range []Algorithm{AlgES256, AlgES384, AlgES256K} {
+		if curve, _ := ecdsaParams(alg); curve == publicKey.Curve {
+			return &ecdsaSigner{key: key, publicKey: publicKey, alg: alg}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported ECDSA curve %s", publicKey.Curve.Params().Name)
+}
+
+type ecdsaSigner struct {
+	key       crypto.Signer
+	publicKey *ecdsa.PublicKey
+	alg       Algorithm
+}
+
+func (s *ecdsaSigner) Algorithm() Algorithm { return s.alg }
+
+func (s *ecdsaSigner) PublicKey() []byte {
+	return elliptic.Marshal(s.publicKey.Curve, s.publicKey.X, s.publicKey.Y)
+}
+
+func (s *ecdsaSigner) Sign(payload []byte) ([]byte, error) {
+	curve, hash := ecdsaParams(s.alg)
+	h := hash.New()
+	h.Write(payload)
+	der, err := s.key.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return nil, err
+	}
+	var signature struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &signature); err != nil {
+		return nil, fmt.Errorf("invalid ECDSA signature: %w", err)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	signature.R.FillBytes(raw[:size])
+	signature.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+// NewSecp256k1Signer returns an ES256K Signer for a secp256k1 key, signing
+// deterministically per RFC 6979
+func NewSecp256k1Signer(key *secp256k1.PrivateKey) Signer {
+	return secp256k1Signer{key: key}
+}
+
+type secp256k1Signer struct {
+	key *secp256k1.PrivateKey
+}
+
+func (s secp256k1Signer) Algorithm() Algorithm { return AlgES256K }
+
+func (s secp256k1Signer) PublicKey() []byte { return s.key.PubKey().SerializeUncompressed() }
+
+func (s secp256k1Signer) Sign(payload []byte) ([]byte, error) {
+	digest := sha256.Sum256(payload)
+	signature := secp256k1ecdsa.Sign(s.key, digest[:])
+	r, sv := signature.R(), signature.S()
+	raw := make([]byte, 64)
+	r.PutBytesUnchecked(raw[:32])
+	sv.PutBytesUnchecked(raw[32:])
+	return raw, nil
+}
+
+// ecdsaParams returns the curve and hash of an ECDSA algorithm
+func ecdsaParams(alg Algorithm) (elliptic.Curve, crypto.Hash) {
+	switch alg {
+	case AlgES256:
+		return elliptic.P256(), crypto.SHA256
+	case AlgES384:
+		return elliptic.P384(), crypto.SHA384
+	case AlgES256K:
+		return secp256k1.S256(), crypto.SHA256
+	}
+	return nil, 0
+}
+
+// SignatureAlgorithm returns the algorithm the receipt is signed with
+func (r *Receipt) SignatureAlgorithm() Algorithm {
+	if r.Algorithm == "" {
+		return AlgEdDSA
+	}
+	return r.Algorithm
+}
+
+// verifyAlgorithmSignature verifies a signature over payload under alg
+func verifyAlgorithmSignature(alg Algorithm, publicKey, payload, signature []byte) error {
+	if alg == AlgEdDSA {
+		if len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key size: %d", len(publicKey))
+		}
+		if !ed25519.Verify(publicKey, payload, signature) {
+			return fmt.Errorf("signature verification failed")
+		}
+		return nil
+	}
+	if alg == AlgES256K {
+		return verifySecp256k1(publicKey, payload, signature)
+	}
+
+	curve, hash := ecdsaParams(alg)
+	if curve == nil {
+		return fmt.Errorf("unsupported signature algorithm %q", alg)
+	}
+	x, y := elliptic.Unmarshal(curve, publicKey)
+	if x == nil {
+		return fmt.Errorf("invalid %s public key", alg)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(signature) != 2*size {
+		return fmt.Errorf("invalid %s signature size: %d", alg, len(signature))
+	}
+	h := hash.New()
+	h.Write(payload)
+	r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+	if !ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, h.Sum(nil), r, s) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// verifySecp256k1 verifies an ES256K signature
+func verifySecp256k1(publicKey, payload, signature []byte) error {
+	key, err := secp256k1.ParsePubKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid %s public key: %w", AlgES256K, err)
+	}
+	if len(signature) != 64 {
+		return fmt.Errorf("invalid %s signature size: %d", AlgES256K, len(signature))
+	}
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:]) {
+		return fmt.Errorf("signature verification failed")
+	}
+	digest := sha256.Sum256(payload)
+	if !secp256k1ecdsa.NewSignature(&r, &s).Verify(digest[:], key) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// checkAlgorithm rejects receipts signed with an algorithm outside allowed,
+// when set, outside the profile's algorithms, or outside FIPSAlgorithms in
+// FIPS mode
+func checkAlgorithm(alg Algorithm, allowed []Algorithm, profile Profile) error {
+	if len(allowed) > 0 && !containsAlgorithm(allowed, alg) {
+		return fmt.Errorf("signature algorithm %s is not allowed", alg)
+	}
+	if algorithms := ProfileAlgorithms(profile); len(algorithms) > 0 && !containsAlgorithm(algorithms, alg) {
+		return fmt.Errorf("signature algorithm %s is not allowed under profile %s", alg, profile)
+	}
+	if FIPSMode() && !containsAlgorithm(FIPSAlgorithms, alg) {
+		return fmt.Errorf("signature algorithm %s is not permitted in FIPS mode", alg)
+	}
+	return nil
+}
+
+func containsAlgorithm(algorithms []Algorithm, alg Algorithm) bool {
+	for _, a := range algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
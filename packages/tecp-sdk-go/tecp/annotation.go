@@ -0,0 +1,150 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// AnnotationsExtension holds signed addenda attached to a receipt after issuance
+const AnnotationsExtension = "annotations"
+
+// AnnotationVersion identifies the annotation format
+const AnnotationVersion = "TECP-ANN-0.1"
+
+// Annotation is a signed, timestamped statement about an issued receipt, such
+// as "exported to customer X" or "dispute opened". Annotations reference the
+// receipt by hash and live in an unsigned extension, so attaching them never
+// invalidates the receipt's own signature
+type Annotation struct {
+	Version     string            `json:"version"`
+	ReceiptHash string            `json:"receipt_hash"`
+	Type        string            `json:"type"`
+	Statement   string            `json:"statement"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Timestamp   int64             `json:"ts"`
+	Annotator   string            `json:"annotator"`
+	Signature   string            `json:"sig"`
+}
+
+// Annotate signs a statement about receipt with the client's key and appends
+// it to the receipt's annotations
+func (c *Client) Annotate(receipt *Receipt, annotationType, statement string, attributes map[string]string) (*Annotation, error) {
+	if c.privateKey == nil {
+		return nil, fmt.Errorf("private key required for annotations")
+	}
+	if annotationType == "" {
+		return nil, fmt.Errorf("annotation type required")
+	}
+
+	existing, err := Annotations(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	receiptHash, err := ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	annotation := Annotation{
+		Version:     AnnotationVersion,
+		ReceiptHash: base64.StdEncoding.EncodeToString(receiptHash),
+		Type:        annotationType,
+		Statement:   statement,
+		Attributes:  attributes,
+		Timestamp:   time.Now().UnixMilli(),
+		Annotator:   base64.StdEncoding.EncodeToString(c.privateKey.Public().(ed25519.PublicKey)),
+	}
+
+	payload, err := canonicalCBOR(annotation.signingPayload())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	annotation.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(c.privateKey, payload))
+
+	if receipt.Extensions == nil {
+		receipt.Extensions = make(map[string]interface{})
+	}
+	receipt.Extensions[AnnotationsExtension] = append(existing, annotation)
+
+	return &annotation, nil
+}
+
+// Annotations returns the annotations attached to a receipt, in order
+func Annotations(receipt *Receipt) ([]Annotation, error) {
+	var annotations []Annotation
+	if _, err := decodeExtension(receipt, AnnotationsExtension, &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// VerifyAnnotation checks that an annotation is correctly signed and refers
+// to receipt
+func VerifyAnnotation(receipt *Receipt, annotation *Annotation) error {
+	if annotation.Version != AnnotationVersion {
+		return fmt.Errorf("invalid annotation version: %s", annotation.Version)
+	}
+
+	receiptHash, err := ReceiptHash(receipt)
+	if err != nil {
+		return err
+	}
+	claimed, err := base64.StdEncoding.DecodeString(annotation.ReceiptHash)
+	if err != nil || !bytes.Equal(claimed, receiptHash) {
+		return fmt.Errorf("annotation does not refer to this receipt")
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(annotation.Annotator)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid annotator key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(annotation.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	payload, err := canonicalCBOR(annotation.signingPayload())
+	if err != nil {
+		return fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(publicKey), payload, signature) {
+		return fmt.Errorf("annotation signature verification failed")
+	}
+	return nil
+}
+
+// VerifyAnnotations verifies every annotation attached to a receipt and
+// returns them; the first invalid annotation is reported with its index
+func VerifyAnnotations(receipt *Receipt) ([]Annotation, error) {
+	annotations, err := Annotations(receipt)
+	if err != nil {
+		return nil, err
+	}
+	for i := range annotations {
+		if err := VerifyAnnotation(receipt, &annotations[i]); err != nil {
+			return nil, fmt.Errorf("annotation %d: %w", i, err)
+		}
+	}
+	return annotations, nil
+}
+
+// signingPayload returns the annotation fields covered by the signature
+func (a *Annotation) signingPayload() map[string]interface{} {
+	attributes := make(map[string]interface{}, len(a.Attributes))
+	for k, v := range a.Attributes {
+		attributes[k] = v
+	}
+	return map[string]interface{}{
+		"version":      a.Version,
+		"receipt_hash": a.ReceiptHash,
+		"type":         a.Type,
+		"statement":    a.Statement,
+		"attributes":   attributes,
+		"ts":           a.Timestamp,
+		"annotator":    a.Annotator,
+	}
+}
@@ -0,0 +1,133 @@
+package tecp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AnonymizationExtension carries the anonymization proof of a pseudonymized
+// receipt
+const AnonymizationExtension = "anonymization"
+
+const anonymizationVersion = "TECP-ANON-0.1"
+
+// AnonymizationBucket is the granularity anonymized timestamps are rounded to
+const AnonymizationBucket = time.Hour
+
+// AnonymizationProof lets the data holder, who keeps the salt, show that an
+// anonymized receipt was derived from a given original receipt
+type AnonymizationProof struct {
+	Version string `json:"version"`
+
+	// Commitment is the hex HMAC-SHA256 of the original receipt hash under
+	// the salt
+	Commitment string `json:"commitment"`
+}
+
+// Anonymize returns a pseudonymized copy of the receipt for sharing receipt
+// datasets. Identifiers are replaced by HMAC-SHA256 pseudonyms under salt,
+// which stay stable across a dataset so receipts can still be grouped by
+// issuer or input: the public key, nonce and input and output hashes. The
+// code reference keeps only its scheme, the timestamp is rounded down to
+// AnonymizationBucket, and the signature and every extension, including
+// request identifiers, are removed. The copy carries an anonymization proof
+// that VerifyAnonymization checks against the original and salt
+func (r *Receipt) Anonymize(salt []byte) (*Receipt, error) {
+	if len(salt) < 16 {
+		return nil, fmt.Errorf("anonymization salt must be at least 16 bytes")
+	}
+	hash, err := ReceiptHash(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Receipt{
+		Version:            r.Version,
+		CodeRef:            bucketCodeRef(r.CodeRef),
+		Timestamp:          r.Timestamp - r.Timestamp%AnonymizationBucket.Milliseconds(),
+		Nonce:              pseudonym(salt, "nonce", r.Nonce),
+		InputHash:          pseudonym(salt, "input", r.InputHash),
+		OutputHash:         pseudonym(salt, "output", r.OutputHash),
+		PolicyIDs:          append([]string(nil), r.PolicyIDs...),
+		PublicKey:          pseudonym(salt, "issuer", r.PublicKey),
+		Algorithm:          r.Algorithm,
+		Canonicalization:   r.Canonicalization,
+		TimestampPrecision: r.TimestampPrecision,
+		Extensions: map[string]interface{}{
+			AnonymizationExtension: &AnonymizationProof{
+				Version:    anonymizationVersion,
+				Commitment: hex.EncodeToString(keyedHash(salt, "receipt", hash)),
+			},
+		},
+	}, nil
+}
+
+// VerifyAnonymization checks that anonymized was derived from original with
+// salt, and that original is the receipt its proof commits to
+func VerifyAnonymization(anonymized, original *Receipt, salt []byte) error {
+	var proof AnonymizationProof
+	found, err := decodeExtension(anonymized, AnonymizationExtension, &proof)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("receipt carries no anonymization proof")
+	}
+	if proof.Version != anonymizationVersion {
+		return fmt.Errorf("unsupported anonymization proof version: %s", proof.Version)
+	}
+
+	expected, err := original.Anonymize(salt)
+	if err != nil {
+		return err
+	}
+	var commitment AnonymizationProof
+	if _, err := decodeExtension(expected, AnonymizationExtension, &commitment); err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(proof.Commitment), []byte(commitment.Commitment)) {
+		return fmt.Errorf("anonymization proof does not commit to the original receipt")
+	}
+
+	switch {
+	case anonymized.Version != expected.Version,
+		anonymized.CodeRef != expected.CodeRef,
+		anonymized.Timestamp != expected.Timestamp,
+		anonymized.Nonce != expected.Nonce,
+		anonymized.InputHash != expected.InputHash,
+		anonymized.OutputHash != expected.OutputHash,
+		anonymized.PublicKey != expected.PublicKey,
+		strings.Join(anonymized.PolicyIDs, "\x00") != strings.Join(expected.PolicyIDs, "\x00"):
+		return fmt.Errorf("anonymized fields do not match the original receipt")
+	}
+	return nil
+}
+
+// pseudonym replaces an identifier with a salted, domain-separated HMAC
+func pseudonym(salt []byte, domain, value string) string {
+	return "anon:" + base64.RawURLEncoding.EncodeToString(keyedHash(salt, domain, []byte(value))[:16])
+}
+
+func keyedHash(salt []byte, domain string, value []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(anonymizationVersion))
+	mac.Write([]byte{0})
+	mac.Write([]byte(domain))
+	mac.Write([]byte{0})
+	mac.Write(value)
+	return mac.Sum(nil)
+}
+
+// bucketCodeRef keeps only the scheme of a code reference, so "git:3f2a..."
+// becomes "git:*"
+func bucketCodeRef(codeRef string) string {
+	if scheme, _, ok := strings.Cut(codeRef, ":"); ok {
+		return scheme + ":*"
+	}
+	return "*"
+}
@@ -0,0 +1,61 @@
+package tecp
+
+import (
+	"fmt"
+	"time"
+)
+
+// NetworkCheckFunc returns a check running fn that makes network requests,
+// such as fetching issuer keys, so VerifyOptions.Deadline and CheckBudgets
+// bound it
+func NetworkCheckFunc(name string, fn func(v *Verification, result *CheckResult)) Check {
+	return checkFunc{name: name, run: fn, network: true}
+}
+
+// budgeted reports whether a verification bounds its network checks
+func (v *Verification) budgeted() bool {
+	return !v.Deadline.IsZero() || len(v.Options.CheckBudgets) > 0
+}
+
+// checkBudget returns how long a network check may run: its
+// VerifyOptions.CheckBudgets entry, cut short by the deadline. It reports
+// false for unbounded checks
+func (v *Verification) checkBudget(name string) (time.Duration, bool) {
+	budget, bounded := v.Options.CheckBudgets[name]
+	if !v.Deadline.IsZero() {
+		if remaining := time.Until(v.Deadline); !bounded || remaining < budget {
+			budget, bounded = remaining, true
+		}
+	}
+	return budget, bounded
+}
+
+// runBudgeted runs a network check within budget. A check that overruns
+// is abandoned, its outcome replaced by a WarningCheckTimedOut warning;
+// it finishes in the background, its result discarded. Checks left no
+// budget by the deadline are not run
+func (v *Verification) runBudgeted(check Check, outcome *CheckResult, budget time.Duration) {
+	if budget > 0 {
+		done := make(chan CheckResult, 1)
+		go func(result CheckResult) {
+			check.Run(v, &result)
+			done <- result
+		}(*outcome)
+
+		timer := time.NewTimer(budget)
+		defer timer.Stop()
+		select {
+		case result := <-done:
+			*outcome = result
+			return
+		case <-timer.C:
+		}
+	}
+	outcome.TimedOut = true
+	outcome.Skip("time budget exceeded")
+	if budget <= 0 {
+		outcome.Warn(WarningCheckTimedOut, fmt.Sprintf("%s check not run: verification deadline passed", outcome.Name))
+		return
+	}
+	outcome.Warn(WarningCheckTimedOut, fmt.Sprintf("%s check abandoned after exceeding its %s time budget", outcome.Name, budget.Round(time.Millisecond)))
+}
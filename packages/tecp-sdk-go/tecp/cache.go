@@ -0,0 +1,121 @@
+package tecp
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CachedVerification is the cached outcome of a receipt's time-independent
+// checks, such as its signature and evidence. Age, clock skew and log
+// promises are always checked afresh
+type CachedVerification struct {
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// VerificationCache stores verification outcomes by key. Implementations
+// must be safe for concurrent use; failures should be reported as misses
+type VerificationCache interface {
+	Get(key string) (*CachedVerification, bool)
+	Put(key string, entry *CachedVerification)
+}
+
+// verificationCacheKey identifies a receipt, including its unsigned
+// extensions, under a verification profile and policy version
+func verificationCacheKey(receipt *Receipt, profile Profile, policyVersion string) (string, error) {
+	receiptHash, err := ReceiptHash(receipt)
+	if err != nil {
+		return "", err
+	}
+	extensions, err := json.Marshal(receipt.Extensions)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode extensions: %w", err)
+	}
+
+	h := sha256.New()
+	h.Write(receiptHash)
+	h.Write(extensions)
+	return fmt.Sprintf("%s:%s:%s", hex.EncodeToString(h.Sum(nil)), profile, policyVersion), nil
+}
+
+// LRUVerificationCache is an in-memory VerificationCache holding a bounded
+// number of entries for a fixed TTL
+type LRUVerificationCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   *CachedVerification
+	expires time.Time
+}
+
+// NewLRUVerificationCache creates a cache evicting the least recently used
+// entry beyond size entries. A zero ttl keeps entries until evicted
+func NewLRUVerificationCache(size int, ttl time.Duration) *LRUVerificationCache {
+	return &LRUVerificationCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry for key if present and not expired
+func (c *LRUVerificationCache) Get(key string) (*CachedVerification, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Put stores an entry, evicting the least recently used one when full
+func (c *LRUVerificationCache) Put(key string, value *CachedVerification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = &lruEntry{key: key, value: value, expires: expires}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of cached entries
+func (c *LRUVerificationCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
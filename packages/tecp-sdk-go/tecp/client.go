@@ -14,10 +14,10 @@
 //	}
 //
 //	// Create a client
-//	client := tecp.NewClient(tecp.ClientOptions{
-//		PrivateKey: privateKey,
-//		Profile:    tecp.ProfileV01,
-//	})
+//	client := tecp.NewClient(
+//		tecp.WithSigner(privateKey),
+//		tecp.WithProfile(tecp.ProfileV01),
+//	)
 //
 //	// Create a receipt
 //	receipt, err := client.CreateReceipt(tecp.CreateReceiptOptions{
@@ -43,13 +43,16 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"sort"
 	"time"
 
 	"github.com/fxamacker/cbor/v2"
+	"github.com/tecp-protocol/tecp-sdk-go/keys"
 )
 
 // Profile represents a TECP profile level
@@ -59,6 +62,9 @@ const (
 	ProfileLite   Profile = "tecp-lite"
 	ProfileV01    Profile = "tecp-v0.1"
 	ProfileStrict Profile = "tecp-strict"
+
+	// ProfileAIAct requires an EU AI Act transparency record on every receipt
+	ProfileAIAct Profile = "tecp-ai-act"
 )
 
 // Client provides methods for creating and verifying TECP receipts
@@ -69,11 +75,88 @@ type Client struct {
 	options    ClientOptions
 }
 
-// ClientOptions configures a TECP client
+// ClientOptions configures a TECP client.
+//
+// Deprecated: pass functional options such as WithSigner and WithProfile to
+// NewClient. A ClientOptions value is still accepted as an Option
 type ClientOptions struct {
 	PrivateKey ed25519.PrivateKey
 	Profile    Profile
 	LogURL     string
+
+	// CertificateChain certifies PrivateKey's public key (leaf first) and is
+	// embedded in every receipt as the x5c extension
+	CertificateChain []*x509.Certificate
+
+	// Keyless enables OIDC-bound keyless signing when PrivateKey is nil
+	Keyless *KeylessOptions
+
+	// Log, when set, submits every receipt to a transparency log and embeds
+	// the log's promise in the srt extension
+	Log LogPromiser
+
+	// Logs are further independent logs every receipt is submitted to,
+	// each adding its promise to the srt extension
+	Logs []LogPromiser
+
+	// DegradationPolicy decides what CreateReceipt does when a log cannot
+	// be reached; by default it fails
+	DegradationPolicy DegradationPolicy
+
+	// AcceleratorCollector, when set, records the GPUs and other
+	// accelerators in the signed environment extension
+	AcceleratorCollector AcceleratorCollector
+
+	// Sequencer, when set, numbers each receipt in its signing key's
+	// sequence under the signature, so collected receipts can be checked
+	// for gaps with CheckSequences
+	Sequencer Sequencer
+
+	// Signer, when set, signs receipts in place of PrivateKey, such as with
+	// an HSM key or under ECDSA. Evidence that must be signed with the
+	// receipt's Ed25519 key, such as erasure evidence, is unavailable
+	Signer Signer
+
+	// PolicySnapshots, when set, resolves the namespaced and URI policies
+	// each receipt claims and embeds hash-pinned snapshots of their
+	// descriptors under the signature
+	PolicySnapshots PolicyResolver
+
+	// KeyUsage, when set, accounts the receipts each key signs and
+	// enforces its signing quota
+	KeyUsage *KeyUsage
+
+	// StrictCreation makes CreateReceipt reject options that fail
+	// CreateReceiptOptions.Validate instead of issuing the receipt
+	StrictCreation bool
+
+	// Canonicalization is how receipts' signed payloads are encoded;
+	// CanonicalizationCBOR by default
+	Canonicalization Canonicalization
+
+	// TimestampPrecision, when set, rounds issued timestamps down to this
+	// granularity, recorded in the signed ts_precision field, so receipts
+	// do not reveal request timing. Verifiers widen age limits to match
+	TimestampPrecision time.Duration
+
+	// HTTPClient, when set, makes every outbound request, and is passed to
+	// logs and certificate authorities that implement HTTPClientUser and
+	// have no client of their own. By default DefaultHTTPClient is used
+	HTTPClient *http.Client
+
+	// Retry, when set, retries the outbound requests made through
+	// HTTPClient, or the default client, under the policy
+	Retry *RetryPolicy
+
+	// KeyRegistration, when set, is the logged introduction statement of
+	// the signing key, embedded in every receipt it signs
+	KeyRegistration *KeyStatement
+
+	// HashingKeys, when set, hashes each receipt's payloads with
+	// HMAC-SHA256 under its tenant's key, so identical payloads of
+	// different tenants do not produce linkable hashes in a shared log.
+	// Receipts must then name a tenant
+	HashingKeys HashingKeys
 }
 
 // Receipt represents a TECP receipt
@@ -87,6 +170,21 @@ type Receipt struct {
 	PolicyIDs  []string          `json:"policy_ids" cbor:"policy_ids"`
 	Signature  string            `json:"sig" cbor:"sig"`
 	PublicKey  string            `json:"pubkey" cbor:"pubkey"`
+
+	// Algorithm is the signature algorithm; empty means AlgEdDSA
+	Algorithm Algorithm `json:"alg,omitempty" cbor:"alg,omitempty"`
+
+	// Canonicalization is how the signed payload is encoded; empty means
+	// CanonicalizationCBOR
+	Canonicalization Canonicalization `json:"canon,omitempty" cbor:"canon,omitempty"`
+
+	// TimestampPrecision, when set, is the granularity in milliseconds the
+	// timestamp was rounded down to; the receipt was issued within that
+	// many milliseconds after Timestamp
+	TimestampPrecision int64 `json:"ts_precision,omitempty" cbor:"ts_precision,omitempty"`
+
+	// SignedExt, when set, extends the signature to the named extensions
+	SignedExt  *SignedExtensions      `json:"signed_ext,omitempty" cbor:"signed_ext,omitempty"`
 	Extensions map[string]interface{} `json:",inline" cbor:",inline"`
 }
 
@@ -97,6 +195,58 @@ type CreateReceiptOptions struct {
 	Policies   []string
 	CodeRef    string
 	Extensions map[string]interface{}
+
+	// ErasureProver, when set, supplies signed key erasure evidence
+	ErasureProver ErasureProver
+
+	// RegionAsserter, when set, supplies signed data-residency evidence
+	RegionAsserter RegionAsserter
+
+	// AIAct, when set, attaches a signed AI Act transparency record and
+	// claims the ai_act_transparency policy
+	AIAct *AIActTransparency
+
+	// SignedExtensions names extensions to cover by the signature, so they
+	// cannot be stripped or altered. Only extensions present at signing can
+	// be covered; log promises and annotations are added later
+	SignedExtensions []string
+
+	// CriticalExtensions names extensions verifiers must understand to
+	// accept the receipt. They are signed along with SignedExtensions
+	CriticalExtensions []string
+
+	// IdempotencyKey, when set, derives the nonce from the signing key and
+	// this key, so every receipt for retries of one logical request carries
+	// the same nonce
+	IdempotencyKey string
+
+	// Tenant, when set, names the customer the receipt is issued for
+	Tenant string
+
+	// Labels are key=value metadata, such as a project or environment.
+	// The tenant and labels are always covered by the signature
+	Labels map[string]string
+
+	// Determinism, when set, declares under the signature whether
+	// replaying the input reproduces the output hash
+	Determinism Determinism
+
+	// AI, when set, fingerprints the model and datasets under the signature
+	AI *AIFingerprint
+
+	// Metering, when set, binds the receipt to its metering record under
+	// the signature
+	Metering *Metering
+
+	// Transform, when set, normalizes Input before it is hashed, and is
+	// recorded under the signature so verifiers apply it too
+	Transform Transform
+
+	// Salt, when set, makes the input and output hashes salted commitments,
+	// SHA-256(salt || data), so low-entropy payloads cannot be recovered by
+	// dictionary attack. The salt is not recorded; deliver it out of band to
+	// the data owner, who opens the commitments with OpenInput and OpenOutput
+	Salt []byte
 }
 
 // VerificationResult contains the result of receipt verification
@@ -106,6 +256,30 @@ type VerificationResult struct {
 	Warnings   []string `json:"warnings,omitempty"`
 	Profile    Profile  `json:"profile,omitempty"`
 	ErrorCodes []string `json:"error_codes,omitempty"`
+
+	// WarningCodes are the codes of the warnings, such as
+	// WarningLogUnreachable, for alerting without matching messages
+	WarningCodes []string `json:"warning_codes,omitempty"`
+
+	// Checks are the results of the pipeline's checks, in order
+	Checks []CheckResult `json:"checks,omitempty"`
+
+	// VerifiedAt is when the receipt was verified, in Unix milliseconds
+	VerifiedAt int64 `json:"verified_at,omitempty"`
+
+	// AsOf, set by VerifyAt, is the past instant time-dependent checks were
+	// evaluated at, in Unix milliseconds
+	AsOf int64 `json:"as_of,omitempty"`
+
+	// Elapsed, when VerifyOptions.Deadline or CheckBudgets is set, is the
+	// time the verification consumed. TimedOut names the network checks
+	// abandoned for exceeding their budget
+	Elapsed  time.Duration `json:"elapsed,omitempty"`
+	TimedOut []string      `json:"timed_out,omitempty"`
+
+	// Partial, set by VerifyLite, marks a result covering only the
+	// receipt's structure and signature. Valid then means no more than that
+	Partial bool `json:"partial,omitempty"`
 }
 
 // VerifyOptions configures receipt verification
@@ -113,6 +287,135 @@ type VerifyOptions struct {
 	RequireLog bool
 	Profile    Profile
 	LogURL     string
+
+	// Logs are the transparency logs whose promises and inclusion proofs
+	// are accepted. RequireLog requires a valid promise or proof from one
+	// of them
+	Logs []TrustedLog
+
+	// MinLogs, when set, requires valid promises or proofs from at least
+	// this many distinct trusted logs, so a single compromised log cannot
+	// vouch for a receipt alone
+	MinLogs int
+
+	// MaxSTHAge, when set, only accepts embedded inclusion proofs whose tree
+	// head is at most this old, or whose log's TreeHeads supplies a tree
+	// head this recent that provably extends it
+	MaxSTHAge time.Duration
+
+	// Tenant, when set, requires the receipt to carry this signed tenant
+	Tenant string
+
+	// AllowedModels, when set, requires a signed AI fingerprint matching
+	// one of these models
+	AllowedModels []AllowedModel
+
+	// Algorithms, when set, are the signature algorithms accepted. In FIPS
+	// mode only FIPSAlgorithms are accepted in any case
+	Algorithms []Algorithm
+
+	// Roots, when set, requires the receipt to carry an x5c certificate
+	// chain for its signing key that chains to one of these roots.
+	// Validity is judged at the receipt's timestamp, which the signer
+	// asserts: anyone holding a certified key, even after its certificate
+	// expired or the key leaked, can backdate receipts into the validity
+	// window. Roots establishes who signed, not when; only a trusted log's
+	// promise or inclusion proof shows when a receipt existed
+	Roots *x509.CertPool
+
+	// Identities, when set, requires the leaf certificate to carry an OIDC
+	// identity matching one of these entries (requires Roots)
+	Identities []CertificateIdentity
+
+	// MaxAge and MaxSkew override the profile's receipt age and clock skew limits
+	MaxAge  time.Duration
+	MaxSkew time.Duration
+
+	// IgnorePolicyTTL stops the shortest ttl_* policy the receipt claims
+	// from tightening MaxAge, for verifiers predating TTL enforcement
+	IgnorePolicyTTL bool
+
+	// PolicyResolver, when set, resolves namespaced and URI policy IDs;
+	// policies that cannot be resolved produce warnings. Resolved
+	// descriptors that differ from the receipt's policy snapshots are
+	// reported with WarningPolicyChanged warnings
+	PolicyResolver PolicyResolver
+
+	// PolicyPins maps policy IDs to the descriptor hashes they must have,
+	// as returned by PinPolicies. A claimed policy whose snapshot, or
+	// resolved descriptor without one, has another hash fails
+	PolicyPins map[string]string
+
+	// GPUAttestationVerifier, when set, appraises the accelerator
+	// attestation evidence backing the confidential_gpu policy; without it
+	// evidence is only checked to be bound to the receipt, with a warning
+	GPUAttestationVerifier GPUAttestationVerifier
+
+	// ResidencyAuthorities are the provider keys trusted to sign residency
+	// evidence; when empty any valid signature is accepted with a warning
+	ResidencyAuthorities []ed25519.PublicKey
+
+	// Cache, when set, reuses the outcome of signature and evidence checks
+	// for receipts seen before. Use one cache per verification configuration
+	Cache VerificationCache
+
+	// PolicyVersion identifies the verifier's policy and trust configuration;
+	// changing it invalidates cached results
+	PolicyVersion string
+
+	// TrustBundle, when set, restricts issuers to the bundle's keys, trusts
+	// its logs and resolves policies from its registry snapshot. Receipts
+	// are rejected outside the bundle's validity window. The bundle must
+	// have been verified, as by LoadTrustBundle
+	TrustBundle *TrustBundle
+
+	// KnownExtensions are extensions the caller understands in addition to
+	// those registered with RegisterExtension; receipts marking any other
+	// extension critical are rejected
+	KnownExtensions []string
+
+	// Pipeline, when set, replaces DefaultChecks. Use InsertCheck to add
+	// custom checks to the default pipeline
+	Pipeline []Check
+
+	// DisabledChecks names checks to skip
+	DisabledChecks []string
+
+	// FailOn names checks and warning codes whose warnings are errors
+	FailOn []string
+
+	// KeyStatements are logged key lifecycle statements. Receipts signed by
+	// a key after its retirement or compromise fail. Statements count when
+	// anchored by one of Logs and signed by the key itself or one of
+	// KeyAuthorities
+	KeyStatements  []*KeyStatement
+	KeyAuthorities []ed25519.PublicKey
+
+	// RequireKeyTransparency fails receipts, with ErrorCodeKeyNotLogged,
+	// unless their signing key was introduced before them by a statement
+	// with an inclusion proof from one of Logs, from KeyStatements or
+	// embedded in the receipt
+	RequireKeyTransparency bool
+
+	// ExpiryWarning, when set, warns with WarningKeyNearExpiry and
+	// WarningTrustBundleNearExpiry when the signing certificate or trust
+	// bundle expires within this long
+	ExpiryWarning time.Duration
+
+	// RequiredChecks names checks that must run and find something to
+	// verify, such as "log" to make a trusted log promise mandatory
+	RequiredChecks []string
+
+	// Deadline, when set, bounds the network checks (attestation, policy,
+	// log and those built with NetworkCheckFunc) so verification returns
+	// by then: a network check still running at the deadline is abandoned
+	// with WarningCheckTimedOut instead of failing. Offline checks, such
+	// as the signature check, always run to completion
+	Deadline time.Time
+
+	// CheckBudgets bounds individual network checks, by name, within any
+	// Deadline
+	CheckBudgets map[string]time.Duration
 }
 
 // Constants
@@ -124,8 +427,14 @@ const (
 	NonceSize          = 16
 )
 
-// NewClient creates a new TECP client
-func NewClient(options ClientOptions) *Client {
+// NewClient creates a new TECP client configured by opts, applied in order
+func NewClient(opts ...Option) *Client {
+	var options ClientOptions
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+	shareHTTPClient(&options)
+
 	profile := options.Profile
 	if profile == "" {
 		profile = ProfileV01
@@ -139,22 +448,60 @@ func NewClient(options ClientOptions) *Client {
 	}
 }
 
-// CreateReceipt creates a new TECP receipt for ephemeral computation
-func (c *Client) CreateReceipt(options CreateReceiptOptions) (*Receipt, error) {
+// CreateReceipt creates a new TECP receipt for ephemeral computation.
+// Overrides, such as WithSigner, apply to this call only
+func (c *Client) CreateReceipt(options CreateReceiptOptions, overrides ...Option) (*Receipt, error) {
+	c = c.with(overrides)
+	input := options.Input
+	if options.Transform != "" {
+		var err error
+		if input, err = options.Transform.Apply(input); err != nil {
+			return nil, err
+		}
+	}
+	inputHash, outputHash, commitment, err := c.commitmentHashes(options, input)
+	if err != nil {
+		return nil, err
+	}
+	return c.createReceipt(options, inputHash, outputHash, commitment, false)
+}
+
+// createReceipt creates a receipt for the input and output hashes, which
+// commit to the payloads under the commitment scheme, if any. The hashes
+// are marked as supplied externally when external is set
+func (c *Client) createReceipt(options CreateReceiptOptions, inputHash, outputHash [sha256.Size]byte, commitment string, external bool) (*Receipt, error) {
+	if c.options.StrictCreation {
+		if err := options.validate(external); err != nil {
+			return nil, err
+		}
+	}
+	signer, privateKey, chain, err := c.receiptSigner()
+	if err != nil {
+		return nil, err
+	}
 	if c.privateKey == nil {
-		return nil, fmt.Errorf("private key required for receipt creation")
+		// Keyless keys sign one receipt and are discarded
+		defer keys.Wipe(privateKey)
+	}
+	if FIPSMode() && !containsAlgorithm(FIPSAlgorithms, signer.Algorithm()) {
+		return nil, fmt.Errorf("signature algorithm %s is not permitted in FIPS mode", signer.Algorithm())
 	}
 
 	// Generate receipt fields
-	timestamp := time.Now().UnixMilli()
+	timestamp, precision, err := roundTimestamp(time.Now().UnixMilli(), c.options.TimestampPrecision)
+	if err != nil {
+		return nil, err
+	}
 	nonce := make([]byte, NonceSize)
-	if _, err := rand.Read(nonce); err != nil {
+	if options.IdempotencyKey != "" {
+		if privateKey == nil {
+			return nil, fmt.Errorf("idempotency keys require an Ed25519 signing key")
+		}
+		nonce = idempotentNonce(privateKey, options.IdempotencyKey)
+	} else if _, err := rand.Read(nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	inputHash := sha256.Sum256(options.Input)
-	outputHash := sha256.Sum256(options.Output)
-
 	// Create core receipt data
 	codeRef := options.CodeRef
 	if codeRef == "" {
@@ -165,8 +512,9 @@ func (c *Client) CreateReceipt(options CreateReceiptOptions) (*Receipt, error) {
 	if policies == nil {
 		policies = []string{"no_retention"}
 	}
-
-	publicKey := c.privateKey.Public().(ed25519.PublicKey)
+	if err := ValidatePolicySet(policies); err != nil {
+		return nil, err
+	}
 
 	receipt := &Receipt{
 		Version:    TECPVersion,
@@ -176,9 +524,16 @@ func (c *Client) CreateReceipt(options CreateReceiptOptions) (*Receipt, error) {
 		InputHash:  base64.StdEncoding.EncodeToString(inputHash[:]),
 		OutputHash: base64.StdEncoding.EncodeToString(outputHash[:]),
 		PolicyIDs:  policies,
-		PublicKey:  base64.StdEncoding.EncodeToString(publicKey),
+		PublicKey:  base64.StdEncoding.EncodeToString(signer.PublicKey()),
 		Extensions: make(map[string]interface{}),
 	}
+	if alg := signer.Algorithm(); alg != AlgEdDSA {
+		receipt.Algorithm = alg
+	}
+	if mode := c.options.Canonicalization; mode != "" && mode != CanonicalizationCBOR {
+		receipt.Canonicalization = mode
+	}
+	receipt.TimestampPrecision = precision
 
 	// Add extensions
 	if options.Extensions != nil {
@@ -187,146 +542,300 @@ func (c *Client) CreateReceipt(options CreateReceiptOptions) (*Receipt, error) {
 		}
 	}
 
-	// Add environment metadata
-	receipt.Extensions["environment"] = map[string]interface{}{
-		"provider": "tecp-sdk-go",
-		"version":  "0.1.0",
+	// Bind the signing key to its certificate chain
+	if len(chain) > 0 {
+		receipt.Extensions[X5CExtension] = encodeCertificateChain(chain)
 	}
 
-	// Sign the receipt
-	signingData := map[string]interface{}{
-		"version":     receipt.Version,
-		"code_ref":    receipt.CodeRef,
-		"ts":          receipt.Timestamp,
-		"nonce":       receipt.Nonce,
-		"input_hash":  receipt.InputHash,
-		"output_hash": receipt.OutputHash,
-		"policy_ids":  receipt.PolicyIDs,
-		"pubkey":      receipt.PublicKey,
+	// Attach key erasure evidence
+	if options.ErasureProver != nil {
+		if privateKey == nil {
+			return nil, fmt.Errorf("erasure evidence requires an Ed25519 signing key")
+		}
+		if err := attachErasureEvidence(receipt, options.ErasureProver, privateKey); err != nil {
+			return nil, err
+		}
 	}
 
-	canonicalCBOR, err := c.canonicalCBOR(signingData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create canonical CBOR: %w", err)
+	// Attach data-residency evidence
+	if options.RegionAsserter != nil {
+		evidence, err := options.RegionAsserter.AssertRegion(receipt.Nonce)
+		if err != nil {
+			return nil, fmt.Errorf("failed to assert region: %w", err)
+		}
+		receipt.Extensions[ResidencyExtension] = evidence
 	}
 
-	signature := ed25519.Sign(c.privateKey, canonicalCBOR)
-	receipt.Signature = base64.StdEncoding.EncodeToString(signature)
+	// Attach the AI Act transparency record
+	if options.AIAct != nil {
+		if privateKey == nil {
+			return nil, fmt.Errorf("AI Act transparency records require an Ed25519 signing key")
+		}
+		if err := attachAIActTransparency(receipt, options.AIAct, privateKey); err != nil {
+			return nil, err
+		}
+	}
 
-	return receipt, nil
-}
+	// Add environment metadata
+	environment, err := collectEnvironment(receipt, c.options.AcceleratorCollector)
+	if err != nil {
+		return nil, err
+	}
+	receipt.Extensions[EnvironmentExtension] = environment
+	attachKeyRegistration(receipt, c.options.KeyRegistration, signer.PublicKey())
+
+	// The tenant, labels, determinism, transform, hash source, commitment,
+	// AI fingerprint, metering record, no_network evidence, policy
+	// snapshots, sequence number and collected accelerators are always
+	// signed
+	implicit, err := attachTenancy(receipt, options.Tenant, options.Labels)
+	if err != nil {
+		return nil, err
+	}
+	if options.Determinism != "" {
+		if err := options.Determinism.Validate(); err != nil {
+			return nil, err
+		}
+		receipt.Extensions[DeterminismExtension] = options.Determinism
+		implicit = append(implicit, DeterminismExtension)
+	}
+	if options.Transform != "" {
+		receipt.Extensions[TransformExtension] = options.Transform
+		implicit = append(implicit, TransformExtension)
+	}
+	if external {
+		receipt.Extensions[HashSourceExtension] = HashSourceExternal
+		implicit = append(implicit, HashSourceExtension)
+	}
+	if commitment != "" {
+		receipt.Extensions[CommitmentExtension] = commitment
+		implicit = append(implicit, CommitmentExtension)
+	}
+	if options.AI != nil {
+		if err := options.AI.Validate(); err != nil {
+			return nil, err
+		}
+		fingerprint := *options.AI
+		receipt.Extensions[AIExtension] = &fingerprint
+		implicit = append(implicit, AIExtension)
+	}
+	if options.Metering != nil {
+		if err := options.Metering.Validate(); err != nil {
+			return nil, err
+		}
+		metering := *options.Metering
+		receipt.Extensions[MeteringExtension] = &metering
+		implicit = append(implicit, MeteringExtension)
+	}
+	if len(environment.Accelerators) > 0 {
+		implicit = append(implicit, EnvironmentExtension)
+	}
+	if _, ok := receipt.Extensions[NoNetworkExtension]; ok {
+		implicit = append(implicit, NoNetworkExtension)
+	}
+	if c.options.PolicySnapshots != nil {
+		snapshotted, err := snapshotPolicies(receipt, c.options.PolicySnapshots)
+		if err != nil {
+			return nil, err
+		}
+		if snapshotted {
+			implicit = append(implicit, PolicySnapshotsExtension)
+		}
+	}
+	if c.options.Sequencer != nil {
+		seq, err := c.options.Sequencer.Next(receipt.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign sequence number: %w", err)
+		}
+		receipt.Extensions[SequenceExtension] = seq
+		implicit = append(implicit, SequenceExtension)
+	}
 
-// VerifyReceipt verifies a TECP receipt's cryptographic integrity
-func (c *Client) VerifyReceipt(receipt *Receipt, options VerifyOptions) (*VerificationResult, error) {
-	var errors []string
-	var warnings []string
+	// Commit the signature to the selected extensions
+	signed := options.SignedExtensions
+	for _, name := range implicit {
+		if !containsPolicy(signed, name) {
+			signed = append(append([]string(nil), signed...), name)
+		}
+	}
+	for _, name := range options.CriticalExtensions {
+		if !containsPolicy(signed, name) {
+			signed = append(append([]string(nil), signed...), name)
+		}
+	}
+	if len(signed) > 0 {
+		if receipt.SignedExt, err = signExtensions(receipt, signed); err != nil {
+			return nil, err
+		}
+	}
+	if len(options.CriticalExtensions) > 0 {
+		if err := markCritical(receipt.SignedExt, options.CriticalExtensions); err != nil {
+			return nil, err
+		}
+	}
 
-	// Validate basic structure
-	if receipt.Version != TECPVersion {
-		errors = append(errors, fmt.Sprintf("invalid version: %s", receipt.Version))
+	// Sign the receipt, within the key's quota
+	if c.options.KeyUsage != nil {
+		if err := c.options.KeyUsage.reserve(receipt.PublicKey, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if err := signReceipt(receipt, signer); err != nil {
+		return nil, err
 	}
 
-	// Validate timestamp
-	now := time.Now().UnixMilli()
-	age := now - receipt.Timestamp
-	skew := receipt.Timestamp - now
+	// Obtain each log's inclusion promise for the signed receipt
+	if err := c.obtainPromises(receipt, signer, signed); err != nil {
+		return nil, err
+	}
 
-	maxAge := int64(MaxReceiptAgeMS)
-	maxSkew := int64(MaxClockSkewMS)
+	return receipt, nil
+}
 
-	// Adjust limits based on profile
-	profile := options.Profile
-	if profile == "" {
-		profile = c.profile
+// receiptSigner returns the signer and certificate chain for the next
+// receipt, and the Ed25519 key when signing with one
+func (c *Client) receiptSigner() (Signer, ed25519.PrivateKey, []*x509.Certificate, error) {
+	if c.options.Signer != nil {
+		return c.options.Signer, nil, c.options.CertificateChain, nil
 	}
+	privateKey, chain, err := c.signingKey()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return NewEd25519Signer(privateKey), privateKey, chain, nil
+}
 
-	switch profile {
-	case ProfileLite:
-		maxAge = 7 * 24 * 60 * 60 * 1000 // 7 days
-		maxSkew = 15 * 60 * 1000          // 15 minutes
-	case ProfileStrict:
-		maxAge = 60 * 60 * 1000 // 1 hour
-		maxSkew = 60 * 1000     // 1 minute
+// signingKey returns the key and certificate chain used to sign the next receipt
+func (c *Client) signingKey() (ed25519.PrivateKey, []*x509.Certificate, error) {
+	if c.privateKey != nil {
+		return c.privateKey, c.options.CertificateChain, nil
+	}
+	if c.options.Keyless != nil {
+		return c.options.Keyless.ephemeralKey()
 	}
+	return nil, nil, fmt.Errorf("private key required for receipt creation")
+}
 
-	if age > maxAge {
-		errors = append(errors, fmt.Sprintf("receipt too old: %dms > %dms", age, maxAge))
-	} else if skew > maxSkew {
-		errors = append(errors, fmt.Sprintf("receipt timestamp in future: %dms > %dms", skew, maxSkew))
+// VerifyReceipt verifies a TECP receipt's cryptographic integrity by
+// running the check pipeline, DefaultChecks unless VerifyOptions.Pipeline
+// is set. Overrides, such as WithProfile, apply to this call only
+func (c *Client) VerifyReceipt(receipt *Receipt, options VerifyOptions, overrides ...Option) (*VerificationResult, error) {
+	c = c.with(overrides)
+	pipeline := options.Pipeline
+	if pipeline == nil {
+		pipeline = DefaultChecks()
 	}
+	return c.newVerification(receipt, options, time.Now()).run(pipeline), nil
+}
 
-	// Verify signature
-	if err := c.verifySignature(receipt); err != nil {
-		errors = append(errors, fmt.Sprintf("signature verification failed: %v", err))
+// VerifyAt verifies a receipt as of a past instant, answering whether it
+// was valid when it was relied upon rather than whether it is valid now.
+// Time-dependent checks judge asOf instead of the current time: the
+// receipt's age and clock skew, the trust bundle's validity window, log
+// merge delays and tree head freshness, and any custom check reading
+// Verification.Now. Evidence logged after asOf was not available then and
+// is disregarded: key statements, log promises and inclusion proofs, and
+// tree heads fetched to refresh stale proofs. Verification caching is
+// unaffected, as cached checks do not depend on the time or on key
+// statements
+func (c *Client) VerifyAt(receipt *Receipt, asOf time.Time, options VerifyOptions, overrides ...Option) (*VerificationResult, error) {
+	c = c.with(overrides)
+	pipeline := options.Pipeline
+	if pipeline == nil {
+		pipeline = DefaultChecks()
 	}
+	v := c.newVerification(receipt, options, asOf)
+	v.historical = true
+	result := v.run(pipeline)
+	result.VerifiedAt = time.Now().UnixMilli()
+	result.AsOf = asOf.UnixMilli()
+	return result, nil
+}
 
-	// Validate policies (profile-dependent)
-	if profile == ProfileStrict && len(receipt.PolicyIDs) == 0 {
-		errors = append(errors, "TECP-STRICT requires at least one policy")
+// verifySignature verifies the signature on a receipt
+func (c *Client) verifySignature(receipt *Receipt) error {
+	// Decode public key and signature, in any accepted encoding
+	publicKeySize, signatureSize := receipt.keySizes()
+	publicKey, err := decodeBinaryField("public key", receipt.PublicKey, publicKeySize)
+	if err != nil {
+		return err
+	}
+	signature, err := decodeBinaryField("signature", receipt.Signature, signatureSize)
+	if err != nil {
+		return err
 	}
 
-	// TODO: Transparency log verification
-	if options.RequireLog {
-		warnings = append(warnings, "transparency log verification not yet implemented")
+	// Reconstruct signing data
+	payload, err := canonicalPayload(receipt, signingPayload(receipt))
+	if err != nil {
+		return err
 	}
 
-	return &VerificationResult{
-		Valid:    len(errors) == 0,
-		Errors:   errors,
-		Warnings: warnings,
-		Profile:  profile,
-	}, nil
+	// Verify signature
+	return verifyAlgorithmSignature(receipt.SignatureAlgorithm(), publicKey, payload, signature)
 }
 
-// verifySignature verifies the Ed25519 signature on a receipt
-func (c *Client) verifySignature(receipt *Receipt) error {
-	// Decode public key
-	publicKeyBytes, err := base64.StdEncoding.DecodeString(receipt.PublicKey)
+// receiptPublicKey decodes the receipt's Ed25519 public key
+func receiptPublicKey(receipt *Receipt) (ed25519.PublicKey, error) {
+	if alg := receipt.SignatureAlgorithm(); alg != AlgEdDSA {
+		return nil, fmt.Errorf("receipt is signed with %s, not Ed25519", alg)
+	}
+	publicKeyBytes, err := decodeBinaryField("public key", receipt.PublicKey, ed25519.PublicKeySize)
 	if err != nil {
-		return fmt.Errorf("invalid public key encoding: %w", err)
+		return nil, err
 	}
 
 	if len(publicKeyBytes) != ed25519.PublicKeySize {
-		return fmt.Errorf("invalid public key size: %d", len(publicKeyBytes))
+		return nil, fmt.Errorf("invalid public key size: %d", len(publicKeyBytes))
 	}
 
-	publicKey := ed25519.PublicKey(publicKeyBytes)
+	return ed25519.PublicKey(publicKeyBytes), nil
+}
 
-	// Decode signature
-	signature, err := base64.StdEncoding.DecodeString(receipt.Signature)
-	if err != nil {
-		return fmt.Errorf("invalid signature encoding: %w", err)
+// containsPolicy reports whether policyIDs includes id
+func containsPolicy(policyIDs []string, id string) bool {
+	for _, p := range policyIDs {
+		if p == id {
+			return true
+		}
 	}
+	return false
+}
 
-	// Reconstruct signing data
-	signingData := map[string]interface{}{
+// signingPayload returns the receipt fields covered by the signature
+func signingPayload(receipt *Receipt) map[string]interface{} {
+	// Binary fields are signed in canonical base64, whatever their transport
+	// encoding
+	binary := receipt.canonicalBinaryFields()
+	payload := map[string]interface{}{
 		"version":     receipt.Version,
 		"code_ref":    receipt.CodeRef,
 		"ts":          receipt.Timestamp,
-		"nonce":       receipt.Nonce,
-		"input_hash":  receipt.InputHash,
-		"output_hash": receipt.OutputHash,
+		"nonce":       binary["nonce"],
+		"input_hash":  binary["input_hash"],
+		"output_hash": binary["output_hash"],
 		"policy_ids":  receipt.PolicyIDs,
-		"pubkey":      receipt.PublicKey,
+		"pubkey":      binary["pubkey"],
 	}
-
-	canonicalCBOR, err := c.canonicalCBOR(signingData)
-	if err != nil {
-		return fmt.Errorf("failed to create canonical CBOR: %w", err)
+	if receipt.Algorithm != "" {
+		payload["alg"] = string(receipt.Algorithm)
 	}
-
-	// Verify signature
-	if !ed25519.Verify(publicKey, canonicalCBOR, signature) {
-		return fmt.Errorf("signature verification failed")
+	if receipt.Canonicalization != "" {
+		payload["canon"] = string(receipt.Canonicalization)
 	}
-
-	return nil
+	if receipt.TimestampPrecision != 0 {
+		payload["ts_precision"] = receipt.TimestampPrecision
+	}
+	if receipt.SignedExt != nil {
+		payload["signed_ext"] = receipt.SignedExt.signingMap()
+	}
+	return payload
 }
 
 // canonicalCBOR creates canonical CBOR encoding with sorted keys
-func (c *Client) canonicalCBOR(data interface{}) ([]byte, error) {
+func canonicalCBOR(data interface{}) ([]byte, error) {
 	// Sort keys recursively
-	sorted := c.sortKeys(data)
+	sorted := sortKeys(data)
 
 	// Create CBOR encoder with canonical options
 	em, err := cbor.CanonicalEncOptions().EncMode()
@@ -338,7 +847,7 @@ func (c *Client) canonicalCBOR(data interface{}) ([]byte, error) {
 }
 
 // sortKeys recursively sorts map keys for deterministic encoding
-func (c *Client) sortKeys(data interface{}) interface{} {
+func sortKeys(data interface{}) interface{} {
 	switch v := data.(type) {
 	case map[string]interface{}:
 		keys := make([]string, 0, len(v))
@@ -349,14 +858,14 @@ func (c *Client) sortKeys(data interface{}) interface{} {
 
 		result := make(map[string]interface{})
 		for _, k := range keys {
-			result[k] = c.sortKeys(v[k])
+			result[k] = sortKeys(v[k])
 		}
 		return result
 
 	case []interface{}:
 		result := make([]interface{}, len(v))
 		for i, item := range v {
-			result[i] = c.sortKeys(item)
+			result[i] = sortKeys(item)
 		}
 		return result
 
@@ -365,9 +874,14 @@ func (c *Client) sortKeys(data interface{}) interface{} {
 	}
 }
 
-// ToJSON converts a receipt to JSON
+// ToJSON converts a receipt to JSON, with its binary fields in canonical
+// base64
 func (r *Receipt) ToJSON() ([]byte, error) {
-	return json.Marshal(r)
+	canonical := *r
+	binary := r.canonicalBinaryFields()
+	canonical.Nonce, canonical.InputHash, canonical.OutputHash = binary["nonce"], binary["input_hash"], binary["output_hash"]
+	canonical.PublicKey, canonical.Signature = binary["pubkey"], binary["sig"]
+	return json.Marshal(&canonical)
 }
 
 // FromJSON creates a receipt from JSON
@@ -388,6 +902,22 @@ func GenerateKeyPair() (ed25519.PrivateKey, ed25519.PublicKey, error) {
 	return privateKey, publicKey, nil
 }
 
+// ReceiptHash returns the SHA-256 digest identifying a receipt: the canonical
+// encoding of its signed fields and signature, under the receipt's
+// canonicalization. Unsigned extensions are excluded so the hash is stable as
+// proofs and annotations are attached
+func ReceiptHash(receipt *Receipt) ([]byte, error) {
+	data := signingPayload(receipt)
+	data["sig"] = receipt.canonicalBinaryFields()["sig"]
+
+	encoded, err := canonicalPayload(receipt, data)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(encoded)
+	return hash[:], nil
+}
+
 // CalculateReceiptSize calculates the size of a receipt in bytes
 func CalculateReceiptSize(receipt *Receipt) (int, error) {
 	data, err := receipt.ToJSON()
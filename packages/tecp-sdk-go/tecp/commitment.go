@@ -0,0 +1,183 @@
+package tecp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// CommitmentExtension records, under the signature, how the receipt's
+// input and output hashes commit to the payloads
+const CommitmentExtension = "commitment"
+
+// Commitment schemes. CommitmentSaltedSHA256 marks hashes computed as
+// SHA-256(salt || data), hiding low-entropy payloads from dictionary
+// attacks. CommitmentHMACSHA256 marks hashes computed as HMAC-SHA256 under
+// the tenant's hashing key, so identical payloads of different tenants do
+// not produce linkable hashes
+const (
+	CommitmentSaltedSHA256 = "salted-sha256"
+	CommitmentHMACSHA256   = "hmac-sha256"
+)
+
+// SaltSize is the size of salts generated by NewSalt, and MinSaltSize the
+// smallest salt or hashing key CreateReceipt accepts
+const (
+	SaltSize    = 32
+	MinSaltSize = 16
+)
+
+// HashingKeys looks up the key a tenant's payloads are hashed under
+type HashingKeys interface {
+	HashingKey(tenant string) ([]byte, error)
+}
+
+// StaticHashingKeys maps tenants to their hashing keys
+type StaticHashingKeys map[string][]byte
+
+// HashingKey returns the tenant's key, failing for unknown tenants
+func (k StaticHashingKeys) HashingKey(tenant string) ([]byte, error) {
+	key, ok := k[tenant]
+	if !ok {
+		return nil, fmt.Errorf("no hashing key for tenant %q", tenant)
+	}
+	return key, nil
+}
+
+// NewSalt returns a random salt for CreateReceiptOptions.Salt
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// saltedHash returns SHA-256(salt || data)
+func saltedHash(salt, data []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(data)
+	var hash [sha256.Size]byte
+	h.Sum(hash[:0])
+	return hash
+}
+
+// tenantHash returns HMAC-SHA256(key, data)
+func tenantHash(key, data []byte) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	var hash [sha256.Size]byte
+	mac.Sum(hash[:0])
+	return hash
+}
+
+// commitmentHashes returns the receipt input and output hashes for the
+// options: salted when a salt is set, keyed under the tenant's hashing key
+// when the client has hashing keys, and plain SHA-256 otherwise
+func (c *Client) commitmentHashes(options CreateReceiptOptions, input []byte) (inputHash, outputHash [sha256.Size]byte, scheme string, err error) {
+	switch {
+	case options.Salt != nil && c.options.HashingKeys != nil:
+		return inputHash, outputHash, "", fmt.Errorf("salted and keyed hashing cannot be combined")
+	case options.Salt != nil:
+		if len(options.Salt) < MinSaltSize {
+			return inputHash, outputHash, "", fmt.Errorf("salt must be at least %d bytes, got %d", MinSaltSize, len(options.Salt))
+		}
+		return saltedHash(options.Salt, input), saltedHash(options.Salt, options.Output), CommitmentSaltedSHA256, nil
+	case c.options.HashingKeys != nil:
+		if options.Tenant == "" {
+			return inputHash, outputHash, "", fmt.Errorf("keyed hashing requires a tenant")
+		}
+		key, err := c.options.HashingKeys.HashingKey(options.Tenant)
+		if err != nil {
+			return inputHash, outputHash, "", err
+		}
+		if len(key) < MinSaltSize {
+			return inputHash, outputHash, "", fmt.Errorf("hashing key for tenant %q must be at least %d bytes", options.Tenant, MinSaltSize)
+		}
+		return tenantHash(key, input), tenantHash(key, options.Output), CommitmentHMACSHA256, nil
+	}
+	return sha256.Sum256(input), sha256.Sum256(options.Output), "", nil
+}
+
+// commitment returns the receipt's signed commitment scheme, or "" for
+// plain SHA-256 hashes
+func (r *Receipt) commitment() string {
+	if !r.signsExtension(CommitmentExtension) {
+		return ""
+	}
+	var commitment string
+	if _, err := decodeExtension(r, CommitmentExtension, &commitment); err != nil {
+		return ""
+	}
+	return commitment
+}
+
+// commit hashes data under the receipt's commitment scheme
+func (r *Receipt) commit(secret, data []byte) ([sha256.Size]byte, error) {
+	switch r.commitment() {
+	case CommitmentSaltedSHA256:
+		return saltedHash(secret, data), nil
+	case CommitmentHMACSHA256:
+		return tenantHash(secret, data), nil
+	}
+	return [sha256.Size]byte{}, fmt.Errorf("receipt hashes are not salted or keyed commitments")
+}
+
+// Salted reports whether the receipt's hashes are salted commitments,
+// which only the salt's holder can open. Only a signed marking counts
+func (r *Receipt) Salted() bool {
+	return r.commitment() == CommitmentSaltedSHA256
+}
+
+// Keyed reports whether the receipt's hashes are keyed under its tenant's
+// hashing key. Only a signed marking counts
+func (r *Receipt) Keyed() bool {
+	return r.commitment() == CommitmentHMACSHA256
+}
+
+// OpenInput reports whether secret and input open the receipt's input
+// commitment, normalizing input with the receipt's signed transform first.
+// The secret is the salt of a salted receipt, or the tenant's hashing key
+// of a keyed one
+func (r *Receipt) OpenInput(secret, input []byte) (bool, error) {
+	if transform, ok := r.Transform(); ok {
+		var err error
+		if input, err = transform.Apply(input); err != nil {
+			return false, err
+		}
+	}
+	hash, err := r.commit(secret, input)
+	if err != nil {
+		return false, err
+	}
+	return r.canonicalBinaryFields()["input_hash"] == base64.StdEncoding.EncodeToString(hash[:]), nil
+}
+
+// OpenOutput reports whether secret and output open the receipt's output
+// commitment
+func (r *Receipt) OpenOutput(secret, output []byte) (bool, error) {
+	hash, err := r.commit(secret, output)
+	if err != nil {
+		return false, err
+	}
+	return r.canonicalBinaryFields()["output_hash"] == base64.StdEncoding.EncodeToString(hash[:]), nil
+}
+
+// verifyCommitment checks that any commitment marking is known and signed
+func verifyCommitment(receipt *Receipt) (warnings []string, err error) {
+	var commitment string
+	found, err := decodeExtension(receipt, CommitmentExtension, &commitment)
+	if err != nil || !found {
+		return nil, err
+	}
+	if commitment != CommitmentSaltedSHA256 && commitment != CommitmentHMACSHA256 {
+		return nil, fmt.Errorf("unknown commitment scheme %q", commitment)
+	}
+	if !receipt.signsExtension(CommitmentExtension) {
+		warnings = append(warnings, "commitment marking is not covered by the signature and is ignored")
+	}
+	return warnings, nil
+}
@@ -0,0 +1,102 @@
+package tecp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// compatAliases maps canonical receipt fields to the names other SDKs and
+// their older releases have written them under
+var compatAliases = map[string][]string{
+	"version":     {"Version"},
+	"code_ref":    {"codeRef", "code_reference"},
+	"ts":          {"timestamp", "timestamp_ms", "tsMs"},
+	"nonce":       {"Nonce"},
+	"input_hash":  {"inputHash"},
+	"output_hash": {"outputHash"},
+	"policy_ids":  {"policyIds", "policies"},
+	"sig":         {"signature"},
+	"pubkey":      {"public_key", "publicKey"},
+}
+
+// DecodeCompat reads a receipt written by another TECP SDK, tolerating
+// known historical differences: alias field names such as "timestamp" for
+// "ts", timestamps as decimal strings, and binary fields in hex or
+// base64url. The receipt is normalized to the canonical layout and
+// encoding, and each normalization is returned as a warning. Signatures
+// cover the canonical values, so normalized receipts still verify.
+// Receipts that need no normalization decode as with Decode
+func DecodeCompat(data []byte) (*Receipt, []string, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		receipt, err := DecodeCompact(string(data))
+		return receipt, nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, nil, fmt.Errorf("invalid receipt: %w", err)
+	}
+	if _, ok := fields["created_at"]; ok && bytes.HasPrefix(bytes.TrimSpace(fields["sig"]), []byte("{")) {
+		return nil, nil, fmt.Errorf("receipt is a JavaScript SDK wrap envelope, which is signed over its own JSON layout and cannot be converted")
+	}
+
+	var warnings []string
+	canonical := make([]string, 0, len(compatAliases))
+	for name := range compatAliases {
+		canonical = append(canonical, name)
+	}
+	sort.Strings(canonical)
+	for _, name := range canonical {
+		for _, alias := range compatAliases[name] {
+			value, ok := fields[alias]
+			if !ok {
+				continue
+			}
+			if existing, ok := fields[name]; ok && !bytes.Equal(bytes.TrimSpace(existing), bytes.TrimSpace(value)) {
+				return nil, nil, fmt.Errorf("invalid receipt: %s and %s disagree", name, alias)
+			}
+			fields[name] = value
+			delete(fields, alias)
+			warnings = append(warnings, fmt.Sprintf("field %s renamed to %s", alias, name))
+		}
+	}
+
+	// Timestamps serialized by languages without 64-bit integers
+	var ts string
+	if raw, ok := fields["ts"]; ok && json.Unmarshal(raw, &ts) == nil {
+		ms, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid receipt: ts %q is not a millisecond timestamp", ts)
+		}
+		fields["ts"] = json.RawMessage(strconv.FormatInt(ms, 10))
+		warnings = append(warnings, "ts converted from string")
+	}
+
+	normalized, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid receipt: %w", err)
+	}
+	receipt, err := Decode(normalized)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, field := range receipt.binaryFields() {
+		if *field.value == "" {
+			continue
+		}
+		decoded, encoding, err := DecodeBinary(*field.value, field.size)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s encoding: %w", field.name, err)
+		}
+		if encoding != EncodingBase64 {
+			*field.value = base64.StdEncoding.EncodeToString(decoded)
+			warnings = append(warnings, fmt.Sprintf("%s converted from %s to %s", field.name, encoding, EncodingBase64))
+		}
+	}
+	return receipt, warnings, nil
+}
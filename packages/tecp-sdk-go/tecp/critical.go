@@ -0,0 +1,87 @@
+package tecp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	extensionsMu sync.RWMutex
+
+	// knownExtensions are the extensions this SDK understands
+	knownExtensions = map[string]bool{
+		AIExtension:              true,
+		AIActExtension:           true,
+		AnnotationsExtension:     true,
+		AnonymizationExtension:   true,
+		CommitmentExtension:      true,
+		DegradedExtension:        true,
+		DeterminismExtension:     true,
+		EnvironmentExtension:     true,
+		HashSourceExtension:      true,
+		KeyErasureExtension:      true,
+		KeyRegistrationExtension: true,
+		LabelsExtension:          true,
+		MeteringExtension:        true,
+		NoNetworkExtension:       true,
+		PolicySnapshotsExtension: true,
+		InclusionExtension:       true,
+		ResidencyExtension:       true,
+		SequenceExtension:        true,
+		SRTExtension:             true,
+		TenantExtension:          true,
+		TransformExtension:       true,
+		X5CExtension:             true,
+	}
+)
+
+// RegisterExtension declares that this process understands an extension,
+// so receipts marking it critical pass verification. Packages defining
+// extensions register them from init
+func RegisterExtension(name string) {
+	extensionsMu.Lock()
+	defer extensionsMu.Unlock()
+	knownExtensions[name] = true
+}
+
+// KnownExtension reports whether an extension has been registered
+func KnownExtension(name string) bool {
+	extensionsMu.RLock()
+	defer extensionsMu.RUnlock()
+	return knownExtensions[name]
+}
+
+// markCritical records the critical extensions, which must be signed
+func markCritical(signed *SignedExtensions, names []string) error {
+	critical := append([]string(nil), names...)
+	sort.Strings(critical)
+	for i, name := range critical {
+		if i > 0 && critical[i-1] == name {
+			return fmt.Errorf("extension %s marked critical twice", name)
+		}
+		if !containsPolicy(signed.Names, name) {
+			return fmt.Errorf("critical extension %s is not signed", name)
+		}
+	}
+	signed.Critical = critical
+	return nil
+}
+
+// verifyCriticalExtensions fails, as X.509 verifiers do, when the receipt
+// marks an extension critical that neither the registry nor the verifier's
+// own list knows. It returns the error code along with the error
+func verifyCriticalExtensions(receipt *Receipt, known []string) (string, error) {
+	if receipt.SignedExt == nil {
+		return "", nil
+	}
+	for _, name := range receipt.SignedExt.Critical {
+		if !containsPolicy(receipt.SignedExt.Names, name) {
+			return ErrorCodeUnsignedCriticalExtension, fmt.Errorf("critical extension %s is not signed", name)
+		}
+		if !KnownExtension(name) && !containsPolicy(known, name) {
+			return ErrorCodeUnknownCriticalExtension, fmt.Errorf("unknown critical extension %s", name)
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,281 @@
+package tecp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ArchiveFormat is the encoding of a receipt archive stream
+type ArchiveFormat string
+
+const (
+	// ArchiveJSONL is one JSON receipt per line
+	ArchiveJSONL ArchiveFormat = "jsonl"
+
+	// ArchiveCBORSequence is an RFC 8742 CBOR sequence of receipts in their
+	// compact CBOR form, as shared by EncodeCompact before compression
+	ArchiveCBORSequence ArchiveFormat = "cbor-seq"
+
+	// ArchiveLengthPrefixed is receipts in their compact CBOR form, each
+	// preceded by its length as a 4-byte big-endian integer
+	ArchiveLengthPrefixed ArchiveFormat = "length-prefixed"
+)
+
+// MaxArchiveItemSize bounds a single receipt in an archive stream
+const MaxArchiveItemSize = 1 << 20
+
+// DecodeError is an archive item that could not be decoded. The Decoder
+// has skipped it, and decoding can continue with the next item
+type DecodeError struct {
+	// Item is the item's index in the stream, from zero
+	Item int
+
+	// Offset is the item's byte offset in the stream
+	Offset int64
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("archive item %d at offset %d: %v", e.Item, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Decoder streams receipts from an archive without reading it into
+// memory, for replaying archives larger than memory. The format is
+// detected from the first byte. A malformed receipt is reported as a
+// *DecodeError and skipped; any other error ends the stream
+type Decoder struct {
+	r      *bufio.Reader
+	format ArchiveFormat
+	cbor   *cbor.Decoder
+	item   int
+	offset int64
+	err    error
+}
+
+// NewDecoder returns a decoder reading an archive from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Format returns the archive's format, detecting it if no receipt has been
+// decoded yet
+func (d *Decoder) Format() (ArchiveFormat, error) {
+	if d.format != "" {
+		return d.format, nil
+	}
+	for {
+		b, err := d.r.Peek(1)
+		if err != nil {
+			return "", err
+		}
+		switch c := b[0]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			d.r.ReadByte()
+			d.offset++
+			continue
+		case c == '{':
+			d.format = ArchiveJSONL
+		case c >= 0xa0 && c <= 0xbf:
+			// A CBOR map
+			d.format = ArchiveCBORSequence
+			dm, err := cbor.DecOptions{MaxNestedLevels: 64}.DecMode()
+			if err != nil {
+				return "", err
+			}
+			d.cbor = dm.NewDecoder(d.r)
+		case c == 0:
+			// The high byte of a length below MaxArchiveItemSize
+			d.format = ArchiveLengthPrefixed
+		default:
+			return "", fmt.Errorf("unrecognized archive format: first byte 0x%02x", c)
+		}
+		return d.format, nil
+	}
+}
+
+// Decode returns the next receipt, or io.EOF at the end of the archive
+func (d *Decoder) Decode() (*Receipt, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	format, err := d.Format()
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+
+	start := d.offset
+	var receipt *Receipt
+	switch format {
+	case ArchiveJSONL:
+		receipt, err = d.decodeLine()
+	case ArchiveCBORSequence:
+		receipt, err = d.decodeCBOR()
+	case ArchiveLengthPrefixed:
+		receipt, err = d.decodeRecord()
+	}
+	var itemErr *DecodeError
+	switch {
+	case errors.As(err, &itemErr):
+		itemErr.Item, itemErr.Offset = d.item, start
+		d.item++
+		return nil, itemErr
+	case err != nil:
+		d.err = err
+		return nil, err
+	}
+	d.item++
+	return receipt, nil
+}
+
+// decodeLine decodes the next non-empty line of a JSONL archive
+func (d *Decoder) decodeLine() (*Receipt, error) {
+	for {
+		line, tooLong, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if tooLong {
+			return nil, &DecodeError{Err: fmt.Errorf("line exceeds %d bytes", MaxArchiveItemSize)}
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		receipt, err := FromJSON(line)
+		if err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+		return receipt, nil
+	}
+}
+
+// readLine reads a line, discarding the rest of lines longer than
+// MaxArchiveItemSize. A final line without a newline is returned whole
+func (d *Decoder) readLine() ([]byte, bool, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := d.r.ReadSlice('\n')
+		d.offset += int64(len(chunk))
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(line) > MaxArchiveItemSize {
+				line, tooLong = nil, true
+			}
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && (len(line) > 0 || tooLong):
+			return line, tooLong, nil
+		case err != nil:
+			return nil, false, err
+		}
+		return line, tooLong, nil
+	}
+}
+
+// decodeCBOR decodes the next item of a CBOR sequence. Well-formed items
+// that are not receipts are skipped; malformed CBOR cannot be resynchronized
+func (d *Decoder) decodeCBOR() (*Receipt, error) {
+	var raw cbor.RawMessage
+	before := d.cbor.NumBytesRead()
+	err := d.cbor.Decode(&raw)
+	d.offset += int64(d.cbor.NumBytesRead() - before)
+	switch {
+	case err == io.EOF:
+		return nil, io.EOF
+	case err != nil:
+		return nil, fmt.Errorf("malformed CBOR sequence at offset %d: %w", d.offset, err)
+	case len(raw) > MaxArchiveItemSize:
+		return nil, &DecodeError{Err: fmt.Errorf("item exceeds %d bytes", MaxArchiveItemSize)}
+	}
+	receipt, err := unmarshalCompact(raw)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return receipt, nil
+}
+
+// decodeRecord decodes the next record of a length-prefixed archive
+func (d *Decoder) decodeRecord() (*Receipt, error) {
+	var prefix [4]byte
+	n, err := io.ReadFull(d.r, prefix[:])
+	d.offset += int64(n)
+	switch {
+	case err == io.EOF:
+		return nil, io.EOF
+	case err != nil:
+		return nil, fmt.Errorf("truncated record length at offset %d: %w", d.offset, io.ErrUnexpectedEOF)
+	}
+
+	length := int64(binary.BigEndian.Uint32(prefix[:]))
+	if length > MaxArchiveItemSize {
+		skipped, err := io.CopyN(io.Discard, d.r, length)
+		d.offset += skipped
+		if err != nil {
+			return nil, fmt.Errorf("truncated record at offset %d: %w", d.offset, io.ErrUnexpectedEOF)
+		}
+		return nil, &DecodeError{Err: fmt.Errorf("record of %d bytes exceeds %d", length, MaxArchiveItemSize)}
+	}
+	record := make([]byte, length)
+	n, err = io.ReadFull(d.r, record)
+	d.offset += int64(n)
+	if err != nil {
+		return nil, fmt.Errorf("truncated record at offset %d: %w", d.offset, io.ErrUnexpectedEOF)
+	}
+	receipt, err := unmarshalCompact(record)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return receipt, nil
+}
+
+// Encoder writes receipts to an archive stream in one of the formats
+// Decoder reads
+type Encoder struct {
+	w      io.Writer
+	format ArchiveFormat
+}
+
+// NewEncoder returns an encoder writing an archive to w
+func NewEncoder(w io.Writer, format ArchiveFormat) *Encoder {
+	return &Encoder{w: w, format: format}
+}
+
+// Encode writes a receipt to the archive
+func (e *Encoder) Encode(receipt *Receipt) error {
+	var item []byte
+	var err error
+	switch e.format {
+	case ArchiveJSONL:
+		if item, err = receipt.ToJSON(); err == nil {
+			item = append(item, '\n')
+		}
+	case ArchiveCBORSequence:
+		item, err = marshalCompact(receipt)
+	case ArchiveLengthPrefixed:
+		var record []byte
+		if record, err = marshalCompact(receipt); err == nil {
+			item = binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(record)), uint32(len(record)))
+			item = append(item, record...)
+		}
+	default:
+		return fmt.Errorf("unsupported archive format %q", e.format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(item)
+	return err
+}
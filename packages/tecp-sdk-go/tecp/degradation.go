@@ -0,0 +1,164 @@
+package tecp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// DegradedExtension records, under the receipt signature, that the receipt
+// was issued while a transparency log could not be reached
+const DegradedExtension = "degraded"
+
+// DegradationMode is what CreateReceipt does when a log cannot be reached
+type DegradationMode string
+
+// Degradation modes
+const (
+	// DegradeFail fails receipt creation; this is the default
+	DegradeFail DegradationMode = "fail"
+
+	// DegradePending issues the receipt without the missing promises,
+	// marked as pending log submission
+	DegradePending DegradationMode = "pending"
+
+	// DegradeSpool issues the receipt like DegradePending and hands it to
+	// the policy's Spool for later submission
+	DegradeSpool DegradationMode = "spool"
+)
+
+// DegradationPolicy configures CreateReceipt for unreachable logs
+type DegradationPolicy struct {
+	Mode DegradationMode
+
+	// Spool receives the receipts issued under DegradeSpool
+	Spool Spool
+}
+
+// Spool holds receipts whose log submission failed, for later submission
+type Spool interface {
+	Enqueue(receipt *Receipt) error
+}
+
+// Degradation is the degraded extension
+type Degradation struct {
+	Mode DegradationMode `json:"mode"`
+
+	// Reason is the log submission failure
+	Reason string `json:"reason"`
+}
+
+// ReceiptDegradation returns the degradation recorded in a receipt, if any
+func ReceiptDegradation(receipt *Receipt) (*Degradation, bool, error) {
+	var degradation Degradation
+	found, err := decodeExtension(receipt, DegradedExtension, &degradation)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	return &degradation, true, nil
+}
+
+// signReceipt signs a receipt's canonical payload
+func signReceipt(receipt *Receipt, signer Signer) error {
+	payload, err := canonicalPayload(receipt, signingPayload(receipt))
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign receipt: %w", err)
+	}
+	receipt.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// obtainPromises embeds the promises of the client's logs in a signed
+// receipt. When a log fails and the degradation policy allows, the receipt
+// is re-signed with the degradation recorded under its signature, which
+// voids the promises already obtained, and resubmitted to the logs that
+// answered
+func (c *Client) obtainPromises(receipt *Receipt, signer Signer, signed []string) error {
+	logs := c.options.Logs
+	if c.options.Log != nil {
+		logs = append([]LogPromiser{c.options.Log}, logs...)
+	}
+	if len(logs) == 0 {
+		return nil
+	}
+
+	hash, err := ReceiptHash(receipt)
+	if err != nil {
+		return err
+	}
+	srts, reachable, err := promiseAll(receipt, hash, logs)
+	if err == nil {
+		receipt.Extensions[SRTExtension] = srts
+		return nil
+	}
+
+	policy := c.options.DegradationPolicy
+	switch policy.Mode {
+	case "", DegradeFail:
+		return fmt.Errorf("failed to submit receipt to log: %w", err)
+	case DegradePending:
+	case DegradeSpool:
+		if policy.Spool == nil {
+			return fmt.Errorf("failed to submit receipt to log: %w (no spool configured)", err)
+		}
+	default:
+		return fmt.Errorf("unknown degradation mode %q", policy.Mode)
+	}
+
+	// Record the degradation under a new signature
+	receipt.Extensions[DegradedExtension] = &Degradation{Mode: policy.Mode, Reason: err.Error()}
+	var critical []string
+	if receipt.SignedExt != nil {
+		critical = receipt.SignedExt.Critical
+	}
+	if receipt.SignedExt, err = signExtensions(receipt, append(append([]string(nil), signed...), DegradedExtension)); err != nil {
+		return err
+	}
+	receipt.SignedExt.Critical = critical
+	if err := signReceipt(receipt, signer); err != nil {
+		return err
+	}
+
+	// Logs failing now are left to the spool too
+	if hash, err = ReceiptHash(receipt); err != nil {
+		return err
+	}
+	if srts, _, _ := promiseAll(receipt, hash, reachable); len(srts) > 0 {
+		receipt.Extensions[SRTExtension] = srts
+	}
+	if policy.Mode == DegradeSpool {
+		if err := policy.Spool.Enqueue(receipt); err != nil {
+			return fmt.Errorf("failed to spool receipt: %w", err)
+		}
+	}
+	return nil
+}
+
+// promiseAll submits a receipt, or its hash to logs that take only hashes,
+// to every log, returning the promises and the logs that gave them, and the
+// failures of the others
+func promiseAll(receipt *Receipt, hash []byte, logs []LogPromiser) ([]SignedReceiptTimestamp, []LogPromiser, error) {
+	var srts []SignedReceiptTimestamp
+	var answered []LogPromiser
+	var failures []error
+	for _, log := range logs {
+		var srt *SignedReceiptTimestamp
+		var err error
+		if promiser, ok := log.(ReceiptPromiser); ok {
+			srt, err = promiser.PromiseReceipt(receipt)
+		} else {
+			srt, err = log.Promise(hash)
+		}
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		srts = append(srts, *srt)
+		answered = append(answered, log)
+	}
+	return srts, answered, errors.Join(failures...)
+}
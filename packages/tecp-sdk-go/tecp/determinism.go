@@ -0,0 +1,94 @@
+package tecp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DeterminismExtension declares whether replaying the input reproduces the
+// output hash
+const DeterminismExtension = "determinism"
+
+// Determinism is a determinism declaration
+type Determinism string
+
+// Determinism declarations. A seeded computation is declared with
+// SeededDeterminism
+const (
+	Deterministic    Determinism = "deterministic"
+	Nondeterministic Determinism = "nondeterministic"
+)
+
+const seededPrefix = "seeded:"
+
+// SeededDeterminism declares a computation that is reproducible given its
+// seed, such as sampling with a fixed random seed. Only the seed's SHA-256
+// hash is disclosed
+func SeededDeterminism(seed []byte) Determinism {
+	hash := sha256.Sum256(seed)
+	return Determinism(seededPrefix + hex.EncodeToString(hash[:]))
+}
+
+// Reproducible reports whether replaying the input, with the seed for a
+// seeded computation, reproduces the output hash
+func (d Determinism) Reproducible() bool {
+	return d == Deterministic || d.Seeded()
+}
+
+// Seeded reports whether the declaration is seeded
+func (d Determinism) Seeded() bool {
+	return strings.HasPrefix(string(d), seededPrefix)
+}
+
+// MatchesSeed reports whether a seeded declaration was made for seed
+func (d Determinism) MatchesSeed(seed []byte) bool {
+	return d.Seeded() && d == SeededDeterminism(seed)
+}
+
+// Validate checks the declaration's form
+func (d Determinism) Validate() error {
+	switch {
+	case d == Deterministic, d == Nondeterministic:
+		return nil
+	case d.Seeded():
+		hash, err := hex.DecodeString(strings.TrimPrefix(string(d), seededPrefix))
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("invalid determinism %q: seed hash must be a hex SHA-256 digest", d)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid determinism %q", d)
+}
+
+// Determinism returns the receipt's determinism declaration. Only a
+// declaration covered by the signature is returned; the signature check
+// verifies it
+func (r *Receipt) Determinism() (Determinism, bool) {
+	if !r.signsExtension(DeterminismExtension) {
+		return "", false
+	}
+	var determinism Determinism
+	if found, err := decodeExtension(r, DeterminismExtension, &determinism); err != nil || !found {
+		return "", false
+	}
+	return determinism, true
+}
+
+// verifyDeterminism checks that any determinism declaration is well formed
+// and signed
+func verifyDeterminism(receipt *Receipt) (warnings []string, err error) {
+	var determinism Determinism
+	found, err := decodeExtension(receipt, DeterminismExtension, &determinism)
+	if err != nil || !found {
+		return nil, err
+	}
+	if err := determinism.Validate(); err != nil {
+		return nil, err
+	}
+	if !receipt.signsExtension(DeterminismExtension) {
+		warnings = append(warnings, "determinism declaration is not covered by the signature and is ignored")
+	}
+	return warnings, nil
+}
@@ -0,0 +1,198 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// BinaryEncoding is the text encoding of a binary receipt field: the nonce,
+// hashes, public key or signature
+type BinaryEncoding string
+
+const (
+	// EncodingBase64 is padded standard base64, the canonical encoding
+	// receipts are issued with
+	EncodingBase64 BinaryEncoding = "base64"
+
+	// EncodingBase64Unpadded is standard base64 without padding
+	EncodingBase64Unpadded BinaryEncoding = "base64-unpadded"
+
+	// EncodingBase64URL is URL-safe base64, padded or not
+	EncodingBase64URL BinaryEncoding = "base64url"
+
+	// EncodingHex is hexadecimal, in either case
+	EncodingHex BinaryEncoding = "hex"
+)
+
+// Encoding error codes. Outside ProfileStrict, which accepts only
+// EncodingBase64, non-canonical encodings are WarningNonCanonicalEncoding
+// warnings instead
+const (
+	ErrorCodeInvalidEncoding      = "invalid_encoding"
+	ErrorCodeNonCanonicalEncoding = "non_canonical_encoding"
+)
+
+// DecodeBinary decodes a binary field in any accepted encoding and reports
+// which it was. size is the expected decoded length, which tells hex apart
+// from base64 drawn from the hex alphabet; hex is not recognized without it.
+// Padding and alphabet violations are reported explicitly
+func DecodeBinary(value string, size int) ([]byte, BinaryEncoding, error) {
+	if size > 0 && len(value) == 2*size && isHex(value) {
+		decoded, err := hex.DecodeString(value)
+		return decoded, EncodingHex, err
+	}
+
+	// Classify the alphabet and check the padding
+	standard, urlSafe := false, false
+	padding := strings.IndexByte(value, '=')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '+' || c == '/':
+			standard = true
+		case c == '-' || c == '_':
+			urlSafe = true
+		case c == '=':
+			if strings.TrimRight(value[i:], "=") != "" {
+				return nil, "", fmt.Errorf("padding at offset %d is followed by data", padding)
+			}
+		default:
+			return nil, "", fmt.Errorf("invalid character %q at offset %d", c, i)
+		}
+	}
+	if standard && urlSafe {
+		return nil, "", fmt.Errorf("mixes base64 and base64url alphabets")
+	}
+	unpadded := value
+	if padding >= 0 {
+		unpadded = value[:padding]
+		if len(value)%4 != 0 || len(value)-padding > 2 {
+			return nil, "", fmt.Errorf("invalid padding: %d characters of padding on %d characters of data", len(value)-padding, padding)
+		}
+	}
+	if len(unpadded)%4 == 1 {
+		return nil, "", fmt.Errorf("invalid length: %d characters", len(unpadded))
+	}
+
+	// Data that needs no padding is canonical without it
+	encoding, name := base64.RawStdEncoding, EncodingBase64
+	switch {
+	case urlSafe:
+		encoding, name = base64.RawURLEncoding, EncodingBase64URL
+	case padding < 0 && len(unpadded)%4 != 0:
+		name = EncodingBase64Unpadded
+	}
+	decoded, err := encoding.Strict().DecodeString(unpadded)
+	if err != nil {
+		if _, lenient := encoding.DecodeString(unpadded); lenient == nil {
+			return nil, "", fmt.Errorf("invalid %s: non-zero trailing bits", name)
+		}
+		return nil, "", fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return decoded, name, nil
+}
+
+// NormalizeEncodings rewrites the receipt's binary fields in canonical
+// padded standard base64, as issued. Signatures and receipt hashes are
+// computed over the canonical forms, so normalizing never invalidates a
+// receipt. Empty fields are left alone
+func (r *Receipt) NormalizeEncodings() error {
+	for _, field := range r.binaryFields() {
+		if *field.value == "" {
+			continue
+		}
+		decoded, _, err := DecodeBinary(*field.value, field.size)
+		if err != nil {
+			return fmt.Errorf("invalid %s encoding: %w", field.name, err)
+		}
+		*field.value = base64.StdEncoding.EncodeToString(decoded)
+	}
+	return nil
+}
+
+// binaryField is a binary receipt field and its expected decoded size
+type binaryField struct {
+	name  string
+	value *string
+	size  int
+}
+
+// binaryFields returns the receipt's binary fields
+func (r *Receipt) binaryFields() []binaryField {
+	publicKeySize, signatureSize := r.keySizes()
+	return []binaryField{
+		{"nonce", &r.Nonce, NonceSize},
+		{"input_hash", &r.InputHash, 32},
+		{"output_hash", &r.OutputHash, 32},
+		{"pubkey", &r.PublicKey, publicKeySize},
+		{"sig", &r.Signature, signatureSize},
+	}
+}
+
+// keySizes returns the public key and signature sizes of the receipt's
+// signature algorithm
+func (r *Receipt) keySizes() (publicKey, signature int) {
+	switch r.SignatureAlgorithm() {
+	case AlgES256, AlgES256K:
+		return 65, 64
+	case AlgES384:
+		return 97, 96
+	}
+	return ed25519.PublicKeySize, ed25519.SignatureSize
+}
+
+// canonicalBinaryFields returns the receipt's binary fields by name in
+// canonical base64. Fields that cannot be decoded are returned unchanged
+func (r *Receipt) canonicalBinaryFields() map[string]string {
+	canonical := make(map[string]string, 5)
+	for _, field := range r.binaryFields() {
+		canonical[field.name] = *field.value
+		if decoded, encoding, err := DecodeBinary(*field.value, field.size); err == nil && encoding != EncodingBase64 {
+			canonical[field.name] = base64.StdEncoding.EncodeToString(decoded)
+		}
+	}
+	return canonical
+}
+
+// decodeBinaryField decodes a binary receipt field in any accepted encoding
+func decodeBinaryField(name, value string, size int) ([]byte, error) {
+	decoded, _, err := DecodeBinary(value, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s encoding: %w", name, err)
+	}
+	return decoded, nil
+}
+
+// checkEncodings reports binary fields that cannot be decoded, and fields
+// not in canonical base64: errors under ProfileStrict, warnings otherwise
+func checkEncodings(v *Verification, result *CheckResult) {
+	for _, field := range v.Receipt.binaryFields() {
+		if *field.value == "" {
+			continue
+		}
+		_, encoding, err := DecodeBinary(*field.value, field.size)
+		switch {
+		case err != nil:
+			result.Fail(ErrorCodeInvalidEncoding, fmt.Sprintf("invalid %s encoding: %v", field.name, err))
+		case encoding == EncodingBase64:
+		case v.Profile == ProfileStrict:
+			result.Fail(ErrorCodeNonCanonicalEncoding, fmt.Sprintf("%s is %s encoded; %s requires %s", field.name, encoding, v.Profile, EncodingBase64))
+		default:
+			result.Warn(WarningNonCanonicalEncoding, fmt.Sprintf("%s is %s encoded", field.name, encoding))
+		}
+	}
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package tecp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// EnvironmentExtension describes the environment a receipt was issued in
+const EnvironmentExtension = "environment"
+
+// ConfidentialGPUPolicy claims execution on accelerators in confidential
+// computing mode, backed by attestation evidence in the environment
+const ConfidentialGPUPolicy = "confidential_gpu"
+
+const gpuAttestationVersion = "TECP-GPU-0.1"
+
+// Environment is the environment extension
+type Environment struct {
+	Provider string `json:"provider"`
+	Version  string `json:"version"`
+
+	// Accelerators are the GPUs and other accelerators available to the
+	// computation
+	Accelerators []Accelerator `json:"accelerators,omitempty"`
+}
+
+// Accelerator describes one GPU or other accelerator
+type Accelerator struct {
+	Vendor string `json:"vendor"`
+	Model  string `json:"model"`
+	Driver string `json:"driver,omitempty"`
+	UUID   string `json:"uuid,omitempty"`
+
+	// ConfidentialComputing records that the device ran in confidential
+	// computing mode
+	ConfidentialComputing bool `json:"cc_mode,omitempty"`
+
+	// Attestation is the device's attestation evidence, where available
+	Attestation *GPUAttestation `json:"attestation,omitempty"`
+}
+
+// GPUAttestation is device attestation evidence, such as an NVIDIA
+// confidential computing attestation report
+type GPUAttestation struct {
+	// Format identifies the evidence format, such as "nvidia-cc"
+	Format string `json:"format"`
+
+	// Nonce is the hex attestation nonce, derived from the receipt nonce
+	// by GPUAttestationNonce
+	Nonce string `json:"nonce"`
+
+	// Evidence is the base64 attestation report
+	Evidence string `json:"evidence"`
+}
+
+// AcceleratorCollector describes the accelerators available to
+// computations. nonce is the attestation nonce evidence must be bound to
+type AcceleratorCollector interface {
+	CollectAccelerators(nonce []byte) ([]Accelerator, error)
+}
+
+// GPUAttestationVerifier appraises attestation evidence, such as by
+// checking an NVIDIA report's certificate chain and measurements
+type GPUAttestationVerifier interface {
+	VerifyGPUAttestation(accelerator *Accelerator, nonce []byte) error
+}
+
+// GPUAttestationNonce derives the 32-byte attestation nonce for a receipt
+// nonce, binding device evidence to a single receipt
+func GPUAttestationNonce(receiptNonce string) []byte {
+	hash := sha256.Sum256([]byte(gpuAttestationVersion + ":" + receiptNonce))
+	return hash[:]
+}
+
+// ReceiptEnvironment returns the environment recorded in a receipt
+func ReceiptEnvironment(receipt *Receipt) (*Environment, bool, error) {
+	var environment Environment
+	found, err := decodeExtension(receipt, EnvironmentExtension, &environment)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	return &environment, true, nil
+}
+
+// collectEnvironment returns the environment extension, with accelerators
+// when a collector is configured
+func collectEnvironment(receipt *Receipt, collector AcceleratorCollector) (*Environment, error) {
+	environment := &Environment{Provider: "tecp-sdk-go", Version: "0.1.0"}
+	if collector == nil {
+		return environment, nil
+	}
+	accelerators, err := collector.CollectAccelerators(GPUAttestationNonce(receipt.Nonce))
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect accelerators: %w", err)
+	}
+	environment.Accelerators = accelerators
+	return environment, nil
+}
+
+// verifyConfidentialGPU checks that every accelerator ran in confidential
+// computing mode with attestation evidence bound to the receipt. Evidence
+// is appraised by verifier; without one it is only checked for binding
+func verifyConfidentialGPU(receipt *Receipt, verifier GPUAttestationVerifier) error {
+	environment, found, err := ReceiptEnvironment(receipt)
+	if err != nil {
+		return err
+	}
+	if !found || len(environment.Accelerators) == 0 {
+		return fmt.Errorf("%s policy claimed without accelerator evidence", ConfidentialGPUPolicy)
+	}
+	if !receipt.signsExtension(EnvironmentExtension) {
+		return fmt.Errorf("environment is not covered by the signature")
+	}
+
+	nonce := GPUAttestationNonce(receipt.Nonce)
+	for i := range environment.Accelerators {
+		accelerator := &environment.Accelerators[i]
+		name := accelerator.UUID
+		if name == "" {
+			name = accelerator.Model
+		}
+		attestation := accelerator.Attestation
+		switch {
+		case !accelerator.ConfidentialComputing:
+			return fmt.Errorf("accelerator %s did not run in confidential computing mode", name)
+		case attestation == nil:
+			return fmt.Errorf("accelerator %s has no attestation evidence", name)
+		case attestation.Nonce != hex.EncodeToString(nonce):
+			return fmt.Errorf("accelerator %s attestation is not bound to this receipt", name)
+		}
+		if _, err := base64.StdEncoding.DecodeString(attestation.Evidence); err != nil || attestation.Evidence == "" {
+			return fmt.Errorf("accelerator %s has invalid attestation evidence", name)
+		}
+		if verifier != nil {
+			if err := verifier.VerifyGPUAttestation(accelerator, nonce); err != nil {
+				return fmt.Errorf("accelerator %s attestation invalid: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// KeyErasureExtension carries evidence for the key_erasure policy
+const KeyErasureExtension = "key_erasure"
+
+// Key erasure evidence schemes
+const (
+	ErasureSchemeTEE      = "counter+seal@tee"
+	ErasureSchemeSoftware = "sw-sim"
+)
+
+const (
+	erasureSigningVersion = "TECP-ERASURE-0.1"
+	maxErasureDelayMS     = 5 * 60 * 1000 // erasure must follow issuance within 5 minutes
+)
+
+// ErasureEvidence attests that key material used for a computation was
+// destroyed. ProofHash is the SHA-256 of the scheme-specific proof (a sealed
+// monotonic counter reading, an accumulator witness, ...)
+type ErasureEvidence struct {
+	Scheme    string `json:"scheme"`
+	Timestamp int64  `json:"ts"`
+	ProofHash string `json:"proof_hash"`
+	Signature string `json:"sig,omitempty"`
+}
+
+// ErasureProver produces erasure evidence for the computation being receipted
+type ErasureProver interface {
+	ProveErasure() (*ErasureEvidence, error)
+}
+
+// attachErasureEvidence obtains evidence from prover, binds it to the receipt
+// nonce with the issuer key and stores it in the key_erasure extension
+func attachErasureEvidence(receipt *Receipt, prover ErasureProver, privateKey ed25519.PrivateKey) error {
+	evidence, err := prover.ProveErasure()
+	if err != nil {
+		return fmt.Errorf("failed to prove key erasure: %w", err)
+	}
+	if evidence == nil {
+		return fmt.Errorf("erasure prover returned no evidence")
+	}
+
+	signed := *evidence
+	if signed.Timestamp == 0 {
+		signed.Timestamp = time.Now().UnixMilli()
+	}
+
+	payload, err := canonicalCBOR(erasureSigningPayload(&signed, receipt.Nonce))
+	if err != nil {
+		return fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	signed.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+
+	receipt.Extensions[KeyErasureExtension] = &signed
+	return nil
+}
+
+// verifyErasureEvidence checks the shape, freshness and signature of the
+// key_erasure extension
+func verifyErasureEvidence(receipt *Receipt) error {
+	publicKey, err := receiptPublicKey(receipt)
+	if err != nil {
+		return err
+	}
+
+	var evidence ErasureEvidence
+	found, err := decodeExtension(receipt, KeyErasureExtension, &evidence)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("key_erasure policy claimed without erasure evidence")
+	}
+
+	switch evidence.Scheme {
+	case ErasureSchemeTEE, ErasureSchemeSoftware:
+	default:
+		return fmt.Errorf("unknown erasure scheme: %q", evidence.Scheme)
+	}
+
+	proofHash, err := base64.StdEncoding.DecodeString(evidence.ProofHash)
+	if err != nil || len(proofHash) != 32 {
+		return fmt.Errorf("erasure proof hash must be a base64 SHA-256 digest")
+	}
+
+	delay := evidence.Timestamp - receipt.Timestamp
+	if delay < -MaxClockSkewMS || delay > maxErasureDelayMS {
+		return fmt.Errorf("erasure evidence not fresh: %dms from issuance", delay)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(evidence.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid erasure signature encoding: %w", err)
+	}
+	payload, err := canonicalCBOR(erasureSigningPayload(&evidence, receipt.Nonce))
+	if err != nil {
+		return fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	if !ed25519.Verify(publicKey, payload, signature) {
+		return fmt.Errorf("erasure evidence signature invalid")
+	}
+	return nil
+}
+
+// erasureSigningPayload binds evidence to a single receipt through its nonce
+func erasureSigningPayload(evidence *ErasureEvidence, nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"version":    erasureSigningVersion,
+		"scheme":     evidence.Scheme,
+		"ts":         evidence.Timestamp,
+		"proof_hash": evidence.ProofHash,
+		"nonce":      nonce,
+	}
+}
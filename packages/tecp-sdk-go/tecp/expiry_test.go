@@ -0,0 +1,124 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// certifiedReceipt issues a receipt whose key is certified by a fresh root
+// for the validity window [notBefore, notAfter]
+func certifiedReceipt(t *testing.T, notBefore, notAfter time.Time) (*Receipt, *x509.CertPool) {
+	t.Helper()
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             notBefore.Add(-time.Hour),
+		NotAfter:              notAfter.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, rootPub, rootPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := x509.ParseCertificate(rootDER)
+
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "issuer"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, root, pub, rootPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDER)
+
+	client := NewClient(WithSigner(priv), WithCertificateChain(leaf, root))
+	receipt, err := client.CreateReceipt(CreateReceiptOptions{Input: []byte("in"), Output: []byte("out"), CodeRef: "git:abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return receipt, roots
+}
+
+func hasWarning(result *VerificationResult, code string) bool {
+	for _, c := range result.WarningCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestKeyExpiryWarningWithCache(t *testing.T) {
+	now := time.Now()
+	receipt, roots := certifiedReceipt(t, now.Add(-50*24*time.Hour), now.Add(10*24*time.Hour))
+	cache := NewLRUVerificationCache(10, time.Hour)
+	client := NewClient()
+
+	first, err := client.VerifyReceipt(receipt, VerifyOptions{Roots: roots, Cache: cache})
+	if err != nil || !first.Valid {
+		t.Fatalf("first verification failed: %v %v", err, first.Errors)
+	}
+	if hasWarning(first, WarningKeyNearExpiry) {
+		t.Fatal("warned without an expiry window")
+	}
+
+	second, err := client.VerifyReceipt(receipt, VerifyOptions{Roots: roots, Cache: cache, ExpiryWarning: 30 * 24 * time.Hour})
+	if err != nil || !second.Valid {
+		t.Fatalf("second verification failed: %v %v", err, second.Errors)
+	}
+	if !hasWarning(second, WarningKeyNearExpiry) {
+		t.Fatalf("cached verification lost the expiry warning: %v", second.WarningCodes)
+	}
+	for _, check := range second.Checks {
+		if check.Name == CheckIssuer && !check.Cached {
+			t.Fatal("issuer check was not served from the cache")
+		}
+	}
+}
+
+func TestKeyExpiryWarningShortLivedCertificate(t *testing.T) {
+	now := time.Now()
+	receipt, roots := certifiedReceipt(t, now.Add(-time.Hour), now.Add(10*24*time.Hour))
+
+	result, err := NewClient().VerifyReceipt(receipt, VerifyOptions{Roots: roots, ExpiryWarning: 30 * 24 * time.Hour})
+	if err != nil || !result.Valid {
+		t.Fatalf("verification failed: %v %v", err, result.Errors)
+	}
+	if !hasWarning(result, WarningKeyNearExpiry) {
+		t.Fatalf("certificate shorter than the window was not warned about: %v", result.WarningCodes)
+	}
+}
+
+func TestKeyExpiryKeylessCertificateExempt(t *testing.T) {
+	now := time.Now()
+	receipt, roots := certifiedReceipt(t, now.Add(-time.Minute), now.Add(9*time.Minute))
+
+	result, err := NewClient().VerifyReceipt(receipt, VerifyOptions{Roots: roots, ExpiryWarning: 30 * 24 * time.Hour})
+	if err != nil || !result.Valid {
+		t.Fatalf("verification failed: %v %v", err, result.Errors)
+	}
+	if hasWarning(result, WarningKeyNearExpiry) {
+		t.Fatal("keyless certificate was warned about")
+	}
+}
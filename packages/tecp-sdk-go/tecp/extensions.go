@@ -0,0 +1,25 @@
+package tecp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// decodeExtension decodes the named extension into v. Extensions may hold
+// typed values on freshly created receipts or generic maps after decoding,
+// so both are normalized through JSON. It reports whether the extension exists
+func decodeExtension(receipt *Receipt, name string, v interface{}) (bool, error) {
+	raw, ok := receipt.Extensions[name]
+	if !ok || raw == nil {
+		return false, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return true, fmt.Errorf("invalid %s extension: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return true, fmt.Errorf("invalid %s extension: %w", name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,5 @@
+//go:build !tecp_fips
+
+package tecp
+
+const fipsBuild = false
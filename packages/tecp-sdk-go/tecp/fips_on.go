@@ -0,0 +1,6 @@
+//go:build tecp_fips
+
+package tecp
+
+// fipsBuild enables FIPS mode for builds with the tecp_fips tag
+const fipsBuild = true
@@ -0,0 +1,63 @@
+package tecp
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// HashSourceExtension records, under the signature, that the receipt's
+// input and output hashes were supplied to the issuer rather than computed
+// by it from the payloads
+const HashSourceExtension = "hash_source"
+
+// HashSourceExternal marks hashes supplied by the caller of
+// CreateReceiptFromHashes
+const HashSourceExternal = "external"
+
+// CreateReceiptFromHashes creates a receipt from SHA-256 hashes of the
+// input and output, for control planes that never see the payloads. The
+// receipt records under the signature that the hashes were supplied
+// externally: the issuer vouches for the computation's policies, not for
+// having hashed the payloads itself. Options.Input and Options.Output must
+// be empty. A Transform, when set, must already have been applied to the
+// input that was hashed
+func (c *Client) CreateReceiptFromHashes(inputHash, outputHash []byte, options CreateReceiptOptions, overrides ...Option) (*Receipt, error) {
+	if len(inputHash) != sha256.Size || len(outputHash) != sha256.Size {
+		return nil, fmt.Errorf("input and output hashes must be %d-byte SHA-256 hashes", sha256.Size)
+	}
+	if options.Input != nil || options.Output != nil || options.Salt != nil {
+		return nil, fmt.Errorf("input, output and salt must not be set when creating a receipt from hashes")
+	}
+	var hashes [2][sha256.Size]byte
+	copy(hashes[0][:], inputHash)
+	copy(hashes[1][:], outputHash)
+	return c.with(overrides).createReceipt(options, hashes[0], hashes[1], "", true)
+}
+
+// ExternalHashes reports whether the receipt's input and output hashes
+// were supplied to the issuer, as by CreateReceiptFromHashes. Only a
+// signed marking counts
+func (r *Receipt) ExternalHashes() bool {
+	if !r.signsExtension(HashSourceExtension) {
+		return false
+	}
+	var source string
+	found, err := decodeExtension(r, HashSourceExtension, &source)
+	return err == nil && found && source == HashSourceExternal
+}
+
+// verifyHashSource checks that any hash source marking is known and signed
+func verifyHashSource(receipt *Receipt) (warnings []string, err error) {
+	var source string
+	found, err := decodeExtension(receipt, HashSourceExtension, &source)
+	if err != nil || !found {
+		return nil, err
+	}
+	if source != HashSourceExternal {
+		return nil, fmt.Errorf("unknown hash source %q", source)
+	}
+	if !receipt.signsExtension(HashSourceExtension) {
+		warnings = append(warnings, "hash source marking is not covered by the signature and is ignored")
+	}
+	return warnings, nil
+}
@@ -0,0 +1,25 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+
+	"github.com/tecp-protocol/tecp-sdk-go/keys"
+)
+
+const idempotencyLabel = "TECP-IDEMPOTENCY-0.1"
+
+// idempotentNonce derives a receipt nonce from the signing key and an
+// idempotency key, so retries of one logical request share a nonce. The
+// derivation is keyed by the private key, so nonces stay unpredictable to
+// anyone who only knows the idempotency key
+func idempotentNonce(privateKey ed25519.PrivateKey, idempotencyKey string) []byte {
+	seed := privateKey.Seed()
+	defer keys.Wipe(seed)
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(idempotencyLabel))
+	mac.Write([]byte{0})
+	mac.Write([]byte(idempotencyKey))
+	return mac.Sum(nil)[:NonceSize]
+}
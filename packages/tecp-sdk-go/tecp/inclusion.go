@@ -0,0 +1,87 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+)
+
+// InclusionExtension carries transparency log inclusion proofs embedded in
+// a receipt, at most one per log
+const InclusionExtension = "inclusion"
+
+// InclusionProof proves a receipt hash is a leaf of a log's tree at the
+// size of a signed tree head
+type InclusionProof struct {
+	LeafIndex uint64 `json:"leaf_index"`
+
+	// Proof is the RFC 6962 audit path, hex encoded
+	Proof []string       `json:"proof"`
+	STH   SignedTreeHead `json:"sth"`
+
+	// Log is the URL of the log. It is not signed and only tells verifiers
+	// where to refresh the proof
+	Log string `json:"log,omitempty"`
+}
+
+// Verify checks the proof against an entry hash and the log's key
+func (p *InclusionProof) Verify(entry []byte, publicKey ed25519.PublicKey) error {
+	if err := VerifyTreeHead(&p.STH, publicKey); err != nil {
+		return err
+	}
+	root, err := hex.DecodeString(p.STH.Root)
+	if err != nil {
+		return fmt.Errorf("invalid tree head root encoding: %w", err)
+	}
+	path := make([][]byte, len(p.Proof))
+	for i, node := range p.Proof {
+		if path[i], err = hex.DecodeString(node); err != nil {
+			return fmt.Errorf("invalid inclusion proof encoding: %w", err)
+		}
+	}
+	if err := merkle.VerifyInclusion(merkle.LeafHash(entry), p.LeafIndex, p.STH.Size, path, root); err != nil {
+		return fmt.Errorf("inclusion proof verification failed: %w", err)
+	}
+	return nil
+}
+
+// EmbedInclusionProof adds an inclusion proof to a receipt, replacing any
+// earlier proof from the same log. Extensions are not covered by the
+// receipt signature, so proofs can be added after signing
+func EmbedInclusionProof(receipt *Receipt, proof *InclusionProof) error {
+	proofs, err := receiptInclusionProofs(receipt)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range proofs {
+		if proofs[i].STH.KeyID == proof.STH.KeyID {
+			proofs[i] = *proof
+			replaced = true
+		}
+	}
+	if !replaced {
+		proofs = append(proofs, *proof)
+	}
+	if receipt.Extensions == nil {
+		receipt.Extensions = make(map[string]interface{})
+	}
+	receipt.Extensions[InclusionExtension] = proofs
+	return nil
+}
+
+// InclusionProofs returns the inclusion proofs embedded in a receipt
+func (r *Receipt) InclusionProofs() ([]InclusionProof, error) {
+	return receiptInclusionProofs(r)
+}
+
+// receiptInclusionProofs returns the inclusion proofs embedded in a receipt
+func receiptInclusionProofs(receipt *Receipt) ([]InclusionProof, error) {
+	var proofs []InclusionProof
+	if _, err := decodeExtension(receipt, InclusionExtension, &proofs); err != nil {
+		return nil, err
+	}
+	return proofs, nil
+}
@@ -0,0 +1,214 @@
+package tecp
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Canonicalization is how a receipt's signed payload is encoded
+type Canonicalization string
+
+const (
+	// CanonicalizationCBOR is canonical CBOR, the default. Receipts without
+	// a canon field use it
+	CanonicalizationCBOR Canonicalization = "cbor"
+
+	// CanonicalizationJCS is the JSON Canonicalization Scheme of RFC 8785,
+	// for ecosystems without a CBOR implementation
+	CanonicalizationJCS Canonicalization = "jcs"
+)
+
+// canonicalization returns the receipt's canonicalization
+func (r *Receipt) canonicalization() Canonicalization {
+	if r.Canonicalization == "" {
+		return CanonicalizationCBOR
+	}
+	return r.Canonicalization
+}
+
+// canonicalPayload encodes a receipt's signed payload, or a payload derived
+// from it, under the receipt's canonicalization
+func canonicalPayload(receipt *Receipt, payload map[string]interface{}) ([]byte, error) {
+	switch mode := receipt.canonicalization(); mode {
+	case CanonicalizationCBOR:
+		encoded, err := canonicalCBOR(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create canonical CBOR: %w", err)
+		}
+		return encoded, nil
+	case CanonicalizationJCS:
+		encoded, err := canonicalJSON(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create canonical JSON: %w", err)
+		}
+		return encoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported canonicalization %q", mode)
+	}
+}
+
+// canonicalJSON encodes data per RFC 8785: object members sorted by their
+// UTF-16 code units, minimal string escaping and ECMAScript number formatting
+func canonicalJSON(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, data interface{}) error {
+	switch v := data.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		return writeJCSString(buf, v)
+	case int:
+		return writeJCSInteger(buf, int64(v))
+	case int64:
+		return writeJCSInteger(buf, v)
+	case uint64:
+		if v > 1<<53 {
+			return fmt.Errorf("integer %d cannot be represented exactly", v)
+		}
+		return writeJCSInteger(buf, int64(v))
+	case float64:
+		return writeJCSNumber(buf, v)
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return writeCanonicalJSON(buf, items)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJCSString(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported type %T", data)
+	}
+	return nil
+}
+
+// writeJCSInteger writes an integer, which must be exactly representable as
+// an IEEE 754 double
+func writeJCSInteger(buf *bytes.Buffer, v int64) error {
+	if v > 1<<53 || v < -(1<<53) {
+		return fmt.Errorf("integer %d cannot be represented exactly", v)
+	}
+	buf.WriteString(strconv.FormatInt(v, 10))
+	return nil
+}
+
+// writeJCSNumber writes a number as ECMAScript's Number.prototype.toString
+// would
+func writeJCSNumber(buf *bytes.Buffer, v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("number %v is not valid JSON", v)
+	}
+	if v == 0 {
+		buf.WriteByte('0')
+		return nil
+	}
+	format := byte('f')
+	if abs := math.Abs(v); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(v, format, -1, 64)
+	if format == 'e' {
+		// 1e-07 is written 1e-7
+		if n := len(s); n >= 4 && s[n-4] == 'e' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	buf.WriteString(s)
+	return nil
+}
+
+// writeJCSString writes a string with only the escapes RFC 8785 requires
+func writeJCSString(buf *bytes.Buffer, s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("string is not valid UTF-8")
+	}
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return nil
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, as RFC 8785 sorts
+// object members
+func lessUTF16(a, b string) bool {
+	if isASCII(a) && isASCII(b) {
+		return a < b
+	}
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+func isASCII(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r >= utf8.RuneSelf }) < 0
+}
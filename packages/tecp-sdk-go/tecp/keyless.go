@@ -0,0 +1,251 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/keys"
+)
+
+// Fulcio certificate extensions carrying the OIDC issuer of the identity token
+var (
+	oidFulcioIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// CertificateAuthority certifies ephemeral signing keys against an OIDC identity
+type CertificateAuthority interface {
+	// CertifyKey returns a short-lived certificate chain (leaf first) binding
+	// publicKey to the identity in idToken. proof is the key's signature over
+	// the token subject, demonstrating possession of the private key
+	CertifyKey(publicKey ed25519.PublicKey, proof []byte, idToken string) ([]*x509.Certificate, error)
+}
+
+// KeylessOptions configures OIDC-bound keyless signing. Each receipt is signed
+// by a fresh key that is certified by CA and discarded after use
+type KeylessOptions struct {
+	CA CertificateAuthority
+
+	// IdentityToken returns a current OIDC identity token for the workload
+	IdentityToken func() (string, error)
+}
+
+// CertificateIdentity is an OIDC identity accepted for keyless receipts.
+// Empty fields match any value
+type CertificateIdentity struct {
+	Issuer                 string
+	SubjectAlternativeName string
+}
+
+// ephemeralKey generates a signing key and certifies it with the configured CA
+func (k *KeylessOptions) ephemeralKey() (ed25519.PrivateKey, []*x509.Certificate, error) {
+	if k.CA == nil || k.IdentityToken == nil {
+		return nil, nil, fmt.Errorf("keyless signing requires a certificate authority and identity token source")
+	}
+
+	token, err := k.IdentityToken()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to obtain identity token: %w", err)
+	}
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	proof := ed25519.Sign(privateKey, []byte(subject))
+	chain, err := k.CA.CertifyKey(publicKey, proof, token)
+	if err != nil {
+		keys.Wipe(privateKey)
+		return nil, nil, fmt.Errorf("failed to certify ephemeral key: %w", err)
+	}
+	if len(chain) == 0 {
+		keys.Wipe(privateKey)
+		return nil, nil, fmt.Errorf("certificate authority returned an empty chain")
+	}
+	return privateKey, chain, nil
+}
+
+// tokenSubject extracts the claim a CA expects the proof of possession to
+// sign: the email claim when present, otherwise sub. The token is not
+// verified here; that is the CA's job
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("identity token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid identity token payload: %w", err)
+	}
+
+	var claims struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("invalid identity token claims: %w", err)
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("identity token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// FulcioCA certifies keys using a Sigstore Fulcio instance (v2 API)
+type FulcioCA struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// UseHTTPClient requests certificates from Fulcio with client, unless the
+// CA has its own HTTPClient
+func (f *FulcioCA) UseHTTPClient(client *http.Client) {
+	if f.HTTPClient == nil {
+		f.HTTPClient = client
+	}
+}
+
+// CertifyKey requests a signing certificate from Fulcio
+func (f *FulcioCA) CertifyKey(publicKey ed25519.PublicKey, proof []byte, idToken string) ([]*x509.Certificate, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	request := map[string]interface{}{
+		"credentials": map[string]string{
+			"oidcIdentityToken": idToken,
+		},
+		"publicKeyRequest": map[string]interface{}{
+			"publicKey": map[string]string{
+				"algorithm": "ED25519",
+				"content":   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			},
+			"proofOfPossession": base64.StdEncoding.EncodeToString(proof),
+		},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := f.HTTPClient
+	if httpClient == nil {
+		// Certificate issuance is slower than a lookup
+		httpClient = &http.Client{Transport: defaultTransport, Timeout: 30 * time.Second}
+	}
+
+	resp, err := httpClient.Post(strings.TrimSuffix(f.URL, "/")+"/api/v2/signingCert", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("fulcio returned status %d", resp.StatusCode)
+	}
+
+	type certificateChain struct {
+		Chain struct {
+			Certificates []string `json:"certificates"`
+		} `json:"chain"`
+	}
+	var response struct {
+		Embedded *certificateChain `json:"signedCertificateEmbeddedSct"`
+		Detached *certificateChain `json:"signedCertificateDetachedSct"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid fulcio response: %w", err)
+	}
+
+	signed := response.Embedded
+	if signed == nil {
+		signed = response.Detached
+	}
+	if signed == nil {
+		return nil, fmt.Errorf("fulcio response contains no certificate chain")
+	}
+
+	var chain []*x509.Certificate
+	for _, encoded := range signed.Chain.Certificates {
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil {
+			return nil, fmt.Errorf("invalid PEM certificate in fulcio response")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	return chain, nil
+}
+
+// verifyIdentity checks the leaf certificate's OIDC issuer and subject
+// alternative names against the accepted identities
+func verifyIdentity(receipt *Receipt, identities []CertificateIdentity) error {
+	chain, err := CertificateChain(receipt)
+	if err != nil {
+		return err
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("receipt has no %s certificate chain", X5CExtension)
+	}
+
+	issuer, sans := certificateIdentity(chain[0])
+	for _, identity := range identities {
+		if identity.Issuer != "" && identity.Issuer != issuer {
+			continue
+		}
+		if identity.SubjectAlternativeName == "" {
+			return nil
+		}
+		for _, san := range sans {
+			if san == identity.SubjectAlternativeName {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("certificate identity (issuer %q, SANs %v) not accepted", issuer, sans)
+}
+
+// certificateIdentity returns the OIDC issuer and subject alternative names of
+// a keyless signing certificate
+func certificateIdentity(cert *x509.Certificate) (string, []string) {
+	var issuer string
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			var value string
+			if _, err := asn1.Unmarshal(ext.Value, &value); err == nil {
+				issuer = value
+			}
+		case ext.Id.Equal(oidFulcioIssuerV1) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+
+	sans := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return issuer, sans
+}
@@ -0,0 +1,46 @@
+package tecp
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+// TestKeyStateNotCached checks that a compromise statement supplied after a
+// receipt was verified, and cached, as valid fails the next verification
+func TestKeyStateNotCached(t *testing.T) {
+	priv, _, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := NewClient(WithSigner(priv)).CreateReceipt(CreateReceiptOptions{Input: []byte("in"), Output: []byte("out"), CodeRef: "git:abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPriv, logPub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := []TrustedLog{{KeyID: "log-1", PublicKey: logPub}}
+	cache := NewLRUVerificationCache(10, 0)
+	client := NewClient()
+
+	first, err := client.VerifyReceipt(receipt, VerifyOptions{Logs: logs, Cache: cache})
+	if err != nil || !first.Valid {
+		t.Fatalf("first verification failed: %v %v", err, first.Errors)
+	}
+
+	statement := &KeyStatement{Event: KeyCompromised, PublicKey: receipt.PublicKey, Effective: receipt.Timestamp - 1000}
+	SignKeyStatement(statement, priv)
+	srt := SignedReceiptTimestamp{Entry: hex.EncodeToString(statement.EntryHash()), Timestamp: time.Now().UnixMilli(), MMD: 60000, KeyID: "log-1"}
+	SignReceiptTimestamp(&srt, logPriv)
+	statement.Promises = []SignedReceiptTimestamp{srt}
+
+	second, err := client.VerifyReceipt(receipt, VerifyOptions{Logs: logs, Cache: cache, KeyStatements: []*KeyStatement{statement}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Valid || !containsPolicy(second.ErrorCodes, ErrorCodeKeyCompromised) {
+		t.Fatalf("compromise statement ignored by a cached verification: %v", second.ErrorCodes)
+	}
+}
@@ -0,0 +1,267 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// KeyEvent is a key lifecycle event
+type KeyEvent string
+
+const (
+	// KeyIntroduced announces a new signing key
+	KeyIntroduced KeyEvent = "introduced"
+
+	// KeyRetired retires a key: receipts it signs from the effective time
+	// on are refused. Retirements take effect no earlier than they were
+	// logged
+	KeyRetired KeyEvent = "retired"
+
+	// KeyCompromised revokes a key from the effective time on, which may
+	// precede the statement being logged
+	KeyCompromised KeyEvent = "compromised"
+)
+
+// KeyStatementEntryType is the log entry type key statements are anchored
+// under
+const KeyStatementEntryType = "key_rotation"
+
+// AnnotationReattested is the type of the annotation a compromised key's
+// successor counter-signs a genuine receipt with
+const AnnotationReattested = "reattested"
+
+// Error codes for receipts signed by retired or compromised keys
+const (
+	ErrorCodeKeyRetired     = "key_retired"
+	ErrorCodeKeyCompromised = "key_compromised"
+)
+
+const keyStatementVersion = "TECP-KEY-0.1"
+
+// KeyStatement is a signed key lifecycle statement an issuer submits to a
+// transparency log, so that every verifier sees the same history of its
+// keys
+type KeyStatement struct {
+	Version string   `json:"v"`
+	Event   KeyEvent `json:"event"`
+
+	// PublicKey is the base64 key the statement is about
+	PublicKey string `json:"key"`
+
+	// Issuer optionally names the key's issuer
+	Issuer string `json:"issuer,omitempty"`
+
+	// Effective is when the event takes effect, in Unix milliseconds
+	Effective int64  `json:"effective"`
+	Reason    string `json:"reason,omitempty"`
+
+	// Successor is the base64 Ed25519 key that replaces a retired or
+	// compromised key and re-attests the receipts it signed. Verifiers
+	// honor it only in statements signed by a key authority, since whoever
+	// holds a compromised key could otherwise name their own
+	Successor string `json:"successor,omitempty"`
+
+	// SignedBy is the base64 Ed25519 key that signed the statement
+	SignedBy  string `json:"signed_by"`
+	Signature string `json:"sig,omitempty"`
+
+	// Promises and Proofs anchor the statement in transparency logs. They
+	// are not signed, and are added after the statement is logged
+	Promises []SignedReceiptTimestamp `json:"srts,omitempty"`
+	Proofs   []InclusionProof         `json:"inclusion,omitempty"`
+}
+
+// SigningPayload returns the bytes covered by the statement signature: its
+// JSON encoding without the signature and anchors
+func (s *KeyStatement) SigningPayload() []byte {
+	unsigned := *s
+	unsigned.Signature = ""
+	unsigned.Promises, unsigned.Proofs = nil, nil
+	payload, _ := json.Marshal(&unsigned)
+	return payload
+}
+
+// EntryContent returns the statement as submitted to a log: its signed JSON
+// encoding without the anchors
+func (s *KeyStatement) EntryContent() []byte {
+	logged := *s
+	logged.Promises, logged.Proofs = nil, nil
+	content, _ := json.Marshal(&logged)
+	return content
+}
+
+// EntryHash returns the leaf the statement is anchored under
+func (s *KeyStatement) EntryHash() []byte {
+	return LogEntryHash(KeyStatementEntryType, s.EntryContent())
+}
+
+// SignKeyStatement signs a statement. Retirements and compromises must be
+// signed by the key itself or by a key verifiers trust for its issuer
+func SignKeyStatement(statement *KeyStatement, key ed25519.PrivateKey) {
+	statement.Version = keyStatementVersion
+	statement.SignedBy = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	statement.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, statement.SigningPayload()))
+}
+
+// VerifyKeyStatement checks a statement's form and signature. It does not
+// check who signed it or that it was logged
+func VerifyKeyStatement(statement *KeyStatement) error {
+	if statement.Version != keyStatementVersion {
+		return fmt.Errorf("unsupported key statement version: %s", statement.Version)
+	}
+	switch statement.Event {
+	case KeyIntroduced, KeyRetired, KeyCompromised:
+	default:
+		return fmt.Errorf("unknown key event %q", statement.Event)
+	}
+	if _, _, err := DecodeBinary(statement.PublicKey, 0); err != nil || statement.PublicKey == "" {
+		return fmt.Errorf("invalid key statement key")
+	}
+	signer, err := base64.StdEncoding.DecodeString(statement.SignedBy)
+	if err != nil || len(signer) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid key statement signer")
+	}
+	signature, err := base64.StdEncoding.DecodeString(statement.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid key statement signature encoding: %w", err)
+	}
+	if !ed25519.Verify(signer, statement.SigningPayload(), signature) {
+		return fmt.Errorf("key statement signature verification failed")
+	}
+	return nil
+}
+
+// LoggedAt returns when the earliest trusted log accepted the statement,
+// from its valid promises and inclusion proofs. It reports false when no
+// trusted log anchors it
+func (s *KeyStatement) LoggedAt(logs []TrustedLog) (time.Time, bool) {
+	entry := s.EntryHash()
+	trusted := make(map[string]TrustedLog, len(logs))
+	for _, log := range logs {
+		trusted[log.KeyID] = log
+	}
+
+	var earliest int64
+	logged := func(timestamp int64) {
+		if earliest == 0 || timestamp < earliest {
+			earliest = timestamp
+		}
+	}
+	for _, srt := range s.Promises {
+		log, ok := trusted[srt.KeyID]
+		if ok && srt.Entry == hex.EncodeToString(entry) && VerifyReceiptTimestamp(&srt, log.PublicKey) == nil {
+			logged(srt.Timestamp)
+		}
+	}
+	for _, proof := range s.Proofs {
+		log, ok := trusted[proof.STH.KeyID]
+		if ok && proof.Verify(entry, log.PublicKey) == nil {
+			logged(proof.STH.Timestamp)
+		}
+	}
+	if earliest == 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(earliest), true
+}
+
+// about reports whether the statement concerns publicKey, in any encoding
+func (s *KeyStatement) about(publicKey []byte) bool {
+	key, _, err := DecodeBinary(s.PublicKey, 0)
+	return err == nil && bytes.Equal(key, publicKey)
+}
+
+// authorized reports whether the statement's signer may make it: the key
+// itself, or one of the VerifyOptions.KeyAuthorities, which it also reports
+func (s *KeyStatement) authorized(authorities []ed25519.PublicKey) (ok, byAuthority bool) {
+	signer, err := base64.StdEncoding.DecodeString(s.SignedBy)
+	if err != nil {
+		return false, false
+	}
+	for _, authority := range authorities {
+		if bytes.Equal(signer, authority) {
+			return true, true
+		}
+	}
+	return s.about(signer), false
+}
+
+// reattested reports whether a receipt carries a valid reattested
+// annotation from a key authority or, when trusted, the statement's
+// successor
+func (s *KeyStatement) reattested(receipt *Receipt, authorities []ed25519.PublicKey, trustSuccessor bool) bool {
+	annotations, err := Annotations(receipt)
+	if err != nil {
+		return false
+	}
+	for i := range annotations {
+		annotation := &annotations[i]
+		if annotation.Type != AnnotationReattested || VerifyAnnotation(receipt, annotation) != nil {
+			continue
+		}
+		if trustSuccessor && s.Successor != "" && annotation.Annotator == s.Successor {
+			return true
+		}
+		for _, authority := range authorities {
+			if annotation.Annotator == base64.StdEncoding.EncodeToString(authority) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkKeyStatements refuses receipts signed by a key after its logged
+// retirement or compromise. Receipts a compromised key signed before then
+// may have been backdated, and are warned about unless re-attested
+func checkKeyStatements(v *Verification, result *CheckResult) {
+	size, _ := v.Receipt.keySizes()
+	publicKey, _, err := DecodeBinary(v.Receipt.PublicKey, size)
+	if err != nil {
+		return
+	}
+	for _, statement := range v.Options.KeyStatements {
+		if statement.Event == KeyIntroduced || !statement.about(publicKey) {
+			continue
+		}
+		// Statements anyone can publish must not deny service, so bad
+		// ones are ignored rather than failing the receipt
+		if err := VerifyKeyStatement(statement); err != nil {
+			result.Warn(WarningKeyStatementIgnored, fmt.Sprintf("key statement ignored: %v", err))
+			continue
+		}
+		authorized, byAuthority := statement.authorized(v.Options.KeyAuthorities)
+		if !authorized {
+			result.Warn(WarningKeyStatementIgnored, "key statement ignored: not signed by the key or a key authority")
+			continue
+		}
+		loggedAt, ok := statement.LoggedAt(v.Options.Logs)
+		if !ok {
+			result.Warn(WarningKeyStatementIgnored, fmt.Sprintf("key %s statement ignored: not anchored in a trusted log", statement.Event))
+			continue
+		}
+		if v.Postdates(loggedAt) {
+			continue
+		}
+
+		cutoff := time.UnixMilli(statement.Effective)
+		code := ErrorCodeKeyCompromised
+		if statement.Event == KeyRetired {
+			code = ErrorCodeKeyRetired
+			if cutoff.Before(loggedAt) {
+				cutoff = loggedAt
+			}
+		}
+		switch {
+		case !time.UnixMilli(v.Receipt.Timestamp).Before(cutoff):
+			result.Fail(code, fmt.Sprintf("signing key was %s at %s", statement.Event, cutoff.UTC().Format(time.RFC3339)))
+		case statement.Event == KeyCompromised && !statement.reattested(v.Receipt, v.Options.KeyAuthorities, byAuthority):
+			result.Warn(WarningKeyNotReattested, fmt.Sprintf("signing key was later compromised, at %s, and the receipt is not re-attested", cutoff.UTC().Format(time.RFC3339)))
+		}
+	}
+}
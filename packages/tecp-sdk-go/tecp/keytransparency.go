@@ -0,0 +1,88 @@
+package tecp
+
+import (
+	"fmt"
+	"time"
+)
+
+// KeyRegistrationExtension carries the logged introduction statement of
+// the receipt's signing key, with its inclusion proofs, so key
+// transparency can be checked offline. It is not signed: the statement
+// and proofs carry their own signatures
+const KeyRegistrationExtension = "key_registration"
+
+// ErrorCodeKeyNotLogged marks receipts whose signing key is not registered
+// in a trusted key transparency log when VerifyOptions requires it
+const ErrorCodeKeyNotLogged = "key_not_logged"
+
+// KeyRegistration returns the key introduction statement embedded in the
+// receipt, if any
+func (r *Receipt) KeyRegistration() (*KeyStatement, bool) {
+	var statement KeyStatement
+	if found, err := decodeExtension(r, KeyRegistrationExtension, &statement); err != nil || !found {
+		return nil, false
+	}
+	return &statement, true
+}
+
+// attachKeyRegistration embeds the client's key registration in a receipt
+// signed with the registered key
+func attachKeyRegistration(receipt *Receipt, statement *KeyStatement, publicKey []byte) {
+	if statement != nil && statement.Event == KeyIntroduced && statement.about(publicKey) {
+		receipt.Extensions[KeyRegistrationExtension] = statement
+	}
+}
+
+// IncludedAt returns the earliest tree head timestamp of the statement's
+// valid inclusion proofs from trusted logs. Unlike LoggedAt it ignores
+// promises, which are not yet proof the statement is public
+func (s *KeyStatement) IncludedAt(logs []TrustedLog) (time.Time, bool) {
+	anchored := *s
+	anchored.Promises = nil
+	return anchored.LoggedAt(logs)
+}
+
+// checkKeyTransparency requires, under VerifyOptions.RequireKeyTransparency,
+// that the signing key was introduced before the receipt by a statement
+// included in a trusted log, from VerifyOptions.KeyStatements or embedded
+// in the receipt
+func checkKeyTransparency(v *Verification, result *CheckResult) {
+	if !v.Options.RequireKeyTransparency {
+		return
+	}
+	size, _ := v.Receipt.keySizes()
+	publicKey, _, err := DecodeBinary(v.Receipt.PublicKey, size)
+	if err != nil {
+		result.Fail(ErrorCodeKeyNotLogged, "signing key is not registered in a key transparency log")
+		return
+	}
+
+	candidates := v.Options.KeyStatements
+	if embedded, ok := v.Receipt.KeyRegistration(); ok {
+		candidates = append(append([]*KeyStatement(nil), candidates...), embedded)
+	}
+	reason := "signing key is not registered in a key transparency log"
+	for _, statement := range candidates {
+		if statement.Event != KeyIntroduced || !statement.about(publicKey) || VerifyKeyStatement(statement) != nil {
+			continue
+		}
+		if ok, _ := statement.authorized(v.Options.KeyAuthorities); !ok {
+			continue
+		}
+		includedAt, ok := statement.IncludedAt(v.Options.Logs)
+		if !ok {
+			reason = "signing key registration has no inclusion proof from a trusted log"
+			continue
+		}
+		if v.Postdates(includedAt) {
+			reason = fmt.Sprintf("signing key registration was logged at %s, after %s", includedAt.UTC().Format(time.RFC3339), v.Now.UTC().Format(time.RFC3339))
+			continue
+		}
+		if statement.Effective > v.Receipt.Timestamp {
+			reason = fmt.Sprintf("signing key was registered at %s, after the receipt", time.UnixMilli(statement.Effective).UTC().Format(time.RFC3339))
+			continue
+		}
+		return
+	}
+	result.Fail(ErrorCodeKeyNotLogged, reason)
+}
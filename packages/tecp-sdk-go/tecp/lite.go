@@ -0,0 +1,40 @@
+package tecp
+
+import "time"
+
+// liteClient verifies signatures for VerifyLite, which needs no client
+// configuration
+var liteClient = &Client{}
+
+// liteSkipped is the reason recorded for the checks VerifyLite leaves out
+const liteSkipped = "not run by VerifyLite"
+
+// VerifyLite checks only the receipt's structure and signature, for edge
+// devices that cannot run the full pipeline. It makes no network requests
+// and does not check freshness, the issuer's trust, evidence, policies or
+// log proofs. The result is marked Partial, with every other check of
+// DefaultChecks recorded as skipped, so it is never mistaken for a full
+// verification
+func VerifyLite(receipt *Receipt) *VerificationResult {
+	now := time.Now()
+	v := &Verification{Client: liteClient, Receipt: receipt, Profile: ProfileLite, Now: now}
+	checks := DefaultChecks()
+	result := &VerificationResult{Profile: ProfileLite, VerifiedAt: now.UnixMilli(), Partial: true}
+	result.Checks = make([]CheckResult, 0, len(checks))
+	for _, check := range checks {
+		outcome := CheckResult{Name: check.Name()}
+		switch outcome.Name {
+		case CheckStructure, CheckSignature:
+			check.Run(v, &outcome)
+		default:
+			outcome.Skip(liteSkipped)
+		}
+		result.Checks = append(result.Checks, outcome)
+		result.Errors = append(result.Errors, outcome.Errors...)
+		result.Warnings = append(result.Warnings, outcome.Warnings...)
+		result.ErrorCodes = append(result.ErrorCodes, outcome.ErrorCodes...)
+		result.WarningCodes = append(result.WarningCodes, outcome.WarningCodes...)
+	}
+	result.Valid = len(result.Errors) == 0
+	return result
+}
@@ -0,0 +1,125 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+)
+
+// SignedTreeHead is a transparency log's signed commitment to the root of its
+// Merkle tree at a given size
+type SignedTreeHead struct {
+	Size      uint64 `json:"size"`
+	Root      string `json:"root"`
+	Timestamp int64  `json:"timestamp"`
+	Signature string `json:"sig"`
+	KeyID     string `json:"kid"`
+}
+
+// logEntryDomain prefixes the hashed content of log entries other than
+// receipts. Receipt hashes cover a canonical map, which never starts with
+// this prefix
+const logEntryDomain = "TECP-LOG-ENTRY-V1\x00"
+
+// LogEntryHash returns the leaf a transparency log anchors an artifact
+// other than a receipt under: a SHA-256 over a domain prefix, the entry
+// type and the artifact, so an entry of one type never passes for another
+func LogEntryHash(entryType string, content []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(logEntryDomain))
+	h.Write([]byte(entryType))
+	h.Write([]byte{0})
+	h.Write(content)
+	return h.Sum(nil)
+}
+
+// SigningPayload returns the bytes covered by the tree head signature, in the
+// format used by the reference log service
+func (s *SignedTreeHead) SigningPayload() []byte {
+	payload, _ := json.Marshal(struct {
+		RootHash  string `json:"root_hash"`
+		TreeSize  uint64 `json:"tree_size"`
+		Timestamp int64  `json:"timestamp"`
+		KeyID     string `json:"kid"`
+	}{s.Root, s.Size, s.Timestamp, s.KeyID})
+	return payload
+}
+
+// SignTreeHead signs a tree head with a log key
+func SignTreeHead(sth *SignedTreeHead, key ed25519.PrivateKey) {
+	sth.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, sth.SigningPayload()))
+}
+
+// VerifyTreeHead checks a tree head's signature against a log key
+func VerifyTreeHead(sth *SignedTreeHead, publicKey ed25519.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(sth.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid tree head signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, sth.SigningPayload(), signature) {
+		return fmt.Errorf("tree head signature verification failed")
+	}
+	return nil
+}
+
+// TreeHeadSource fetches a log's current tree head and consistency proofs
+// between tree sizes
+type TreeHeadSource interface {
+	TreeHead() (*SignedTreeHead, error)
+	ConsistencyProof(first, second uint64) ([][]byte, error)
+}
+
+// errLogFork reports a log whose current tree does not extend an older
+// tree head it signed
+var errLogFork = errors.New("log tree is inconsistent with the proof's tree head")
+
+// refreshTreeHead checks that a tree head older than maxAge is extended by
+// a current tree head of the log, signed within maxAge. Failures to obtain a
+// fresh tree head are returned as is; a fresh tree head that does not
+// extend the old one returns errLogFork. Historical verifications cannot
+// use a tree head signed after now
+func refreshTreeHead(log TrustedLog, old *SignedTreeHead, maxAge time.Duration, now time.Time, historical bool) error {
+	if log.TreeHeads == nil {
+		return fmt.Errorf("tree head is older than %s", maxAge)
+	}
+	fresh, err := log.TreeHeads.TreeHead()
+	if err != nil {
+		return fmt.Errorf("could not fetch a fresh tree head: %w", err)
+	}
+	if err := VerifyTreeHead(fresh, log.PublicKey); err != nil {
+		return fmt.Errorf("fresh tree head: %w", err)
+	}
+	if historical && time.UnixMilli(fresh.Timestamp).After(now) {
+		return fmt.Errorf("log's current tree head postdates %s", now.UTC().Format(time.RFC3339))
+	}
+	if now.Sub(time.UnixMilli(fresh.Timestamp)) > maxAge {
+		return fmt.Errorf("log's current tree head is older than %s", maxAge)
+	}
+	if fresh.Size < old.Size {
+		return errLogFork
+	}
+
+	proof, err := log.TreeHeads.ConsistencyProof(old.Size, fresh.Size)
+	if err != nil {
+		return fmt.Errorf("could not fetch a consistency proof: %w", err)
+	}
+	oldRoot, err := hex.DecodeString(old.Root)
+	if err != nil {
+		return fmt.Errorf("invalid tree head root encoding: %w", err)
+	}
+	freshRoot, err := hex.DecodeString(fresh.Root)
+	if err != nil {
+		return fmt.Errorf("invalid tree head root encoding: %w", err)
+	}
+	if err := merkle.VerifyConsistency(old.Size, fresh.Size, proof, oldRoot, freshRoot); err != nil {
+		return errLogFork
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package tecp
+
+import "fmt"
+
+// MeteringExtension binds a receipt to the metering record its computation
+// was billed under
+const MeteringExtension = "metering"
+
+// Metering is the metering extension
+type Metering struct {
+	// RecordID identifies the metering record in the billing system
+	RecordID string `json:"record_id"`
+
+	// Usage is the metered usage by unit, such as "input_tokens"
+	Usage map[string]int64 `json:"usage"`
+
+	// PlanHash is the digest of the pricing plan in force, as returned by
+	// Digest over its canonical document
+	PlanHash string `json:"plan_hash,omitempty"`
+}
+
+// Validate checks that the record is identified and its usage and plan
+// hash well formed
+func (m *Metering) Validate() error {
+	if m.RecordID == "" {
+		return fmt.Errorf("metering record has no record_id")
+	}
+	for unit, quantity := range m.Usage {
+		if unit == "" || quantity < 0 {
+			return fmt.Errorf("metering record has invalid usage %q=%d", unit, quantity)
+		}
+	}
+	if m.PlanHash != "" {
+		if _, err := parseDigest(m.PlanHash); err != nil {
+			return fmt.Errorf("invalid plan_hash: %w", err)
+		}
+	}
+	return nil
+}
+
+// Metering returns the receipt's metering record. Only a record covered by
+// the signature is returned; the signature check verifies it
+func (r *Receipt) Metering() (*Metering, bool) {
+	if !r.signsExtension(MeteringExtension) {
+		return nil, false
+	}
+	var metering Metering
+	if found, err := decodeExtension(r, MeteringExtension, &metering); err != nil || !found {
+		return nil, false
+	}
+	return &metering, true
+}
+
+// verifyMetering checks that any metering record is well formed and signed
+func verifyMetering(receipt *Receipt) (warnings []string, err error) {
+	var metering Metering
+	found, err := decodeExtension(receipt, MeteringExtension, &metering)
+	if err != nil || !found {
+		return nil, err
+	}
+	if err := metering.Validate(); err != nil {
+		return nil, err
+	}
+	if !receipt.signsExtension(MeteringExtension) {
+		warnings = append(warnings, "metering record is not covered by the signature and is ignored")
+	}
+	return warnings, nil
+}
@@ -0,0 +1,45 @@
+package tecp
+
+import "fmt"
+
+// NoNetworkExtension carries sandbox evidence for the no_network policy, as
+// produced by the sandbox package
+const NoNetworkExtension = "no_network"
+
+// noNetworkEvidence mirrors sandbox.Evidence
+type noNetworkEvidence struct {
+	Restrictions      []string          `json:"restrictions"`
+	DeniedSyscalls    map[string]uint64 `json:"denied_syscalls"`
+	CountersAvailable bool              `json:"counters_available"`
+	StartedAt         int64             `json:"started_at"`
+	FinishedAt        int64             `json:"finished_at"`
+}
+
+// verifyNoNetworkEvidence checks that signed no_network evidence records
+// applied restrictions for a computation that finished before the receipt
+// was issued
+func verifyNoNetworkEvidence(receipt *Receipt) error {
+	var evidence noNetworkEvidence
+	found, err := decodeExtension(receipt, NoNetworkExtension, &evidence)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no_network policy claimed without sandbox evidence")
+	}
+	// Unsigned evidence could have been attached by anyone
+	if !receipt.signsExtension(NoNetworkExtension) {
+		return fmt.Errorf("sandbox evidence is not covered by the signature")
+	}
+
+	if len(evidence.Restrictions) == 0 {
+		return fmt.Errorf("sandbox evidence records no restrictions")
+	}
+	if evidence.StartedAt > evidence.FinishedAt {
+		return fmt.Errorf("sandbox evidence has inverted execution window")
+	}
+	if evidence.FinishedAt > receipt.Timestamp+MaxClockSkewMS {
+		return fmt.Errorf("sandboxed computation finished after receipt issuance")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"time"
+)
+
+// Option configures a Client, at construction or for a single call
+type Option interface {
+	apply(options *ClientOptions)
+}
+
+type optionFunc func(options *ClientOptions)
+
+func (f optionFunc) apply(options *ClientOptions) { f(options) }
+
+// apply lets a ClientOptions struct be passed to NewClient; it replaces every
+// setting made by earlier options
+func (o ClientOptions) apply(options *ClientOptions) { *options = o }
+
+// WithSigner signs receipts with key
+func WithSigner(key ed25519.PrivateKey) Option {
+	return optionFunc(func(o *ClientOptions) { o.PrivateKey, o.Signer = key, nil })
+}
+
+// WithReceiptSigner signs receipts with signer, such as an ECDSA or HSM
+// key, in place of an Ed25519 private key
+func WithReceiptSigner(signer Signer) Option {
+	return optionFunc(func(o *ClientOptions) { o.PrivateKey, o.Signer = nil, signer })
+}
+
+// WithCanonicalization encodes the signed payload of receipts with mode
+func WithCanonicalization(mode Canonicalization) Option {
+	return optionFunc(func(o *ClientOptions) { o.Canonicalization = mode })
+}
+
+// WithTimestampPrecision rounds issued timestamps down to precision
+func WithTimestampPrecision(precision time.Duration) Option {
+	return optionFunc(func(o *ClientOptions) { o.TimestampPrecision = precision })
+}
+
+// WithPolicySnapshots embeds snapshots of the descriptors of claimed
+// policies, resolved with resolver
+func WithPolicySnapshots(resolver PolicyResolver) Option {
+	return optionFunc(func(o *ClientOptions) { o.PolicySnapshots = resolver })
+}
+
+// WithKeyUsage accounts signatures per key in usage and enforces its quota
+func WithKeyUsage(usage *KeyUsage) Option {
+	return optionFunc(func(o *ClientOptions) { o.KeyUsage = usage })
+}
+
+// WithHashingKeys hashes payloads under their tenant's key from keys
+func WithHashingKeys(keys HashingKeys) Option {
+	return optionFunc(func(o *ClientOptions) { o.HashingKeys = keys })
+}
+
+// WithStrictCreation rejects receipt options that fail
+// CreateReceiptOptions.Validate at issuance
+func WithStrictCreation() Option {
+	return optionFunc(func(o *ClientOptions) { o.StrictCreation = true })
+}
+
+// WithProfile sets the profile receipts are verified under by default
+func WithProfile(profile Profile) Option {
+	return optionFunc(func(o *ClientOptions) { o.Profile = profile })
+}
+
+// WithLog submits every receipt to a transparency log and embeds the log's
+// promise in the srt extension
+func WithLog(log LogPromiser) Option {
+	return optionFunc(func(o *ClientOptions) { o.Log = log })
+}
+
+// WithLogs submits every receipt to further independent transparency logs,
+// embedding each log's promise in the srt extension
+func WithLogs(logs ...LogPromiser) Option {
+	return optionFunc(func(o *ClientOptions) { o.Logs = append(o.Logs, logs...) })
+}
+
+// WithDegradationPolicy sets what CreateReceipt does when a log cannot be
+// reached
+func WithDegradationPolicy(policy DegradationPolicy) Option {
+	return optionFunc(func(o *ClientOptions) { o.DegradationPolicy = policy })
+}
+
+// WithAcceleratorCollector records the accelerators collector reports in
+// every receipt
+func WithAcceleratorCollector(collector AcceleratorCollector) Option {
+	return optionFunc(func(o *ClientOptions) { o.AcceleratorCollector = collector })
+}
+
+// WithSequencer numbers receipts per signing key with seq
+func WithSequencer(seq Sequencer) Option {
+	return optionFunc(func(o *ClientOptions) { o.Sequencer = seq })
+}
+
+// WithLogURL sets the transparency log URL
+func WithLogURL(url string) Option {
+	return optionFunc(func(o *ClientOptions) { o.LogURL = url })
+}
+
+// WithCertificateChain embeds the chain certifying the signing key (leaf
+// first) in every receipt as the x5c extension
+func WithCertificateChain(chain ...*x509.Certificate) Option {
+	return optionFunc(func(o *ClientOptions) { o.CertificateChain = chain })
+}
+
+// WithKeyRegistration embeds the signing key's logged introduction
+// statement in every receipt, for verifiers requiring key transparency
+func WithKeyRegistration(statement *KeyStatement) Option {
+	return optionFunc(func(o *ClientOptions) { o.KeyRegistration = statement })
+}
+
+// WithKeyless enables OIDC-bound keyless signing when no signer is set
+func WithKeyless(keyless *KeylessOptions) Option {
+	return optionFunc(func(o *ClientOptions) { o.Keyless = keyless })
+}
+
+// with returns a client whose configuration is c's with overrides applied
+func (c *Client) with(overrides []Option) *Client {
+	if len(overrides) == 0 {
+		return c
+	}
+	options := c.options
+	for _, override := range overrides {
+		override.apply(&options)
+	}
+	return NewClient(options)
+}
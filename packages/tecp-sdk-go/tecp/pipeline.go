@@ -0,0 +1,662 @@
+package tecp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Names of the built-in verification checks, in pipeline order
+const (
+	CheckStructure   = "structure"
+	CheckTrustBundle = "trust_bundle"
+	CheckTimestamp   = "timestamp"
+	CheckSignature   = "signature"
+	CheckIssuer      = "issuer"
+	CheckKeyState    = "key_state"
+	CheckKeyExpiry   = "key_expiry"
+	CheckAttestation = "attestation"
+	CheckPolicy      = "policy"
+	CheckLog         = "log"
+)
+
+// Error codes reported in VerificationResult.ErrorCodes, for callers that
+// branch on the kind of failure rather than its message
+const (
+	ErrorCodeInvalidSignature          = "invalid_signature"
+	ErrorCodeSignedExtensionMismatch   = "signed_extension_mismatch"
+	ErrorCodeUnknownCriticalExtension  = "unknown_critical_extension"
+	ErrorCodeUnsignedCriticalExtension = "unsigned_critical_extension"
+	ErrorCodeRequiredCheck             = "required_check"
+	ErrorCodeAlgorithmNotAllowed       = "algorithm_not_allowed"
+)
+
+// Verification is the state shared by the checks of one VerifyReceipt call
+type Verification struct {
+	Client  *Client
+	Receipt *Receipt
+
+	// Options are the call's options, with any trust bundle applied
+	Options VerifyOptions
+	Profile Profile
+
+	// Now is the instant time-dependent checks are evaluated at: the
+	// current time, or the past instant passed to VerifyAt
+	Now time.Time
+
+	// historical is set by VerifyAt, whose checks disregard evidence
+	// logged after Now
+	historical bool
+
+	// MaxAge and MaxSkew are the receipt age and clock skew limits after
+	// profile, per-call and policy TTL adjustments
+	MaxAge  time.Duration
+	MaxSkew time.Duration
+
+	// Deadline is VerifyOptions.Deadline, for network checks that bound
+	// their own requests
+	Deadline time.Time
+
+	bundleErrors []string
+}
+
+// Postdates reports whether t is after the instant a VerifyAt call is
+// evaluated at, so evidence from then was not available as of that
+// instant. It is always false for VerifyReceipt
+func (v *Verification) Postdates(t time.Time) bool {
+	return v.historical && t.After(v.Now)
+}
+
+// CheckResult is the outcome of one check. Cached results were reused from
+// VerifyOptions.Cache and took no time
+type CheckResult struct {
+	Name         string        `json:"name"`
+	Errors       []string      `json:"errors,omitempty"`
+	Warnings     []string      `json:"warnings,omitempty"`
+	ErrorCodes   []string      `json:"error_codes,omitempty"`
+	WarningCodes []string      `json:"warning_codes,omitempty"`
+	Duration     time.Duration `json:"duration"`
+	Cached       bool          `json:"cached,omitempty"`
+
+	// Skipped, when set, is why the check had nothing to verify
+	Skipped string `json:"skipped,omitempty"`
+
+	// TimedOut marks a network check abandoned for exceeding its time
+	// budget
+	TimedOut bool `json:"timed_out,omitempty"`
+
+	// failOn are the VerifyOptions.FailOn entries
+	failOn []string
+}
+
+// Fail records an error, with an error code when code is not empty
+func (r *CheckResult) Fail(code, message string) {
+	r.Errors = append(r.Errors, message)
+	if code != "" {
+		r.ErrorCodes = append(r.ErrorCodes, code)
+	}
+}
+
+// Warn records a warning, with a warning code when code is not empty. The
+// warning is recorded as an error instead when VerifyOptions.FailOn names
+// the check or the code
+func (r *CheckResult) Warn(code, message string) {
+	if containsPolicy(r.failOn, r.Name) || (code != "" && containsPolicy(r.failOn, code)) {
+		r.Fail(code, message)
+		return
+	}
+	r.Warnings = append(r.Warnings, message)
+	if code != "" {
+		r.WarningCodes = append(r.WarningCodes, code)
+	}
+}
+
+// Skip records that the check had nothing to verify
+func (r *CheckResult) Skip(reason string) {
+	r.Skipped = reason
+}
+
+// Passed reports whether the check found no errors
+func (r *CheckResult) Passed() bool {
+	return len(r.Errors) == 0
+}
+
+// Check is one named step of receipt verification
+type Check interface {
+	Name() string
+	Run(v *Verification, result *CheckResult)
+}
+
+type checkFunc struct {
+	name string
+	run  func(v *Verification, result *CheckResult)
+
+	// cacheable checks do not depend on the current time
+	cacheable bool
+
+	// network checks make network requests and are time budgeted
+	network bool
+}
+
+func (c checkFunc) Name() string { return c.name }
+
+func (c checkFunc) Run(v *Verification, result *CheckResult) { c.run(v, result) }
+
+// CheckFunc returns a check running fn
+func CheckFunc(name string, fn func(v *Verification, result *CheckResult)) Check {
+	return checkFunc{name: name, run: fn}
+}
+
+// DefaultChecks returns the built-in pipeline of VerifyReceipt. The
+// signature, issuer, attestation and policy checks do not depend on the
+// current time and are cached by VerifyOptions.Cache. The attestation, policy and
+// log checks may make network requests and are time budgeted
+func DefaultChecks() []Check {
+	return []Check{
+		checkFunc{name: CheckStructure, run: checkStructure},
+		checkFunc{name: CheckTrustBundle, run: checkTrustBundle},
+		checkFunc{name: CheckTimestamp, run: checkTimestamp},
+		checkFunc{name: CheckSignature, run: checkSignature, cacheable: true},
+		checkFunc{name: CheckIssuer, run: checkIssuer, cacheable: true},
+		checkFunc{name: CheckKeyState, run: checkKeyState},
+		checkFunc{name: CheckKeyExpiry, run: checkKeyExpiry},
+		checkFunc{name: CheckAttestation, run: checkAttestation, cacheable: true, network: true},
+		checkFunc{name: CheckModel, run: checkModel},
+		checkFunc{name: CheckPolicy, run: checkPolicy, cacheable: true, network: true},
+		checkFunc{name: CheckLog, run: checkLog, network: true},
+	}
+}
+
+// InsertCheck returns a copy of pipeline with check inserted after the
+// check named after, or appended when there is none
+func InsertCheck(pipeline []Check, after string, check Check) []Check {
+	out := make([]Check, 0, len(pipeline)+1)
+	inserted := false
+	for _, c := range pipeline {
+		out = append(out, c)
+		if !inserted && c.Name() == after {
+			out = append(out, check)
+			inserted = true
+		}
+	}
+	if !inserted {
+		out = append(out, check)
+	}
+	return out
+}
+
+// newVerification resolves the profile, limits and trust configuration of
+// a VerifyReceipt call
+func (c *Client) newVerification(receipt *Receipt, options VerifyOptions, now time.Time) *Verification {
+	maxAge := int64(MaxReceiptAgeMS)
+	maxSkew := int64(MaxClockSkewMS)
+
+	// Adjust limits based on profile
+	profile := options.Profile
+	if profile == "" {
+		profile = c.profile
+	}
+
+	switch profile {
+	case ProfileLite:
+		maxAge = 7 * 24 * 60 * 60 * 1000 // 7 days
+		maxSkew = 15 * 60 * 1000         // 15 minutes
+	case ProfileStrict:
+		maxAge = 60 * 60 * 1000 // 1 hour
+		maxSkew = 60 * 1000     // 1 minute
+	}
+
+	// Apply per-call overrides
+	if options.MaxAge > 0 {
+		maxAge = options.MaxAge.Milliseconds()
+	}
+	if options.MaxSkew > 0 {
+		maxSkew = options.MaxSkew.Milliseconds()
+	}
+
+	// Claimed TTL policies can only tighten the acceptable age
+	if !options.IgnorePolicyTTL {
+		if ttl, ok := shortestPolicyTTL(receipt.PolicyIDs); ok && ttl.Milliseconds() < maxAge {
+			maxAge = ttl.Milliseconds()
+		}
+	}
+
+	v := &Verification{
+		Client:   c,
+		Receipt:  receipt,
+		Profile:  profile,
+		Now:      now,
+		MaxAge:   time.Duration(maxAge) * time.Millisecond,
+		MaxSkew:  time.Duration(maxSkew) * time.Millisecond,
+		Deadline: options.Deadline,
+	}
+
+	// Apply the offline trust configuration
+	if options.TrustBundle != nil {
+		options, v.bundleErrors = applyTrustBundle(options, now)
+	}
+	v.Options = options
+	return v
+}
+
+// run runs the pipeline, reusing and filling the cache for cacheable checks
+func (v *Verification) run(pipeline []Check) *VerificationResult {
+	options := v.Options
+	var cached map[string]CheckResult
+	var cacheKey string
+	if options.Cache != nil {
+		// Escalated warnings are cached as errors, so FailOn is part of the key
+		policyVersion := options.PolicyVersion
+		if len(options.FailOn) > 0 {
+			failOn := append([]string(nil), options.FailOn...)
+			sort.Strings(failOn)
+			policyVersion += "+fail_on:" + strings.Join(failOn, ",")
+		}
+		if key, err := verificationCacheKey(v.Receipt, v.Profile, policyVersion); err == nil {
+			cacheKey = key
+			if entry, ok := options.Cache.Get(key); ok {
+				cached = make(map[string]CheckResult, len(entry.Checks))
+				for _, check := range entry.Checks {
+					cached[check.Name] = check
+				}
+			}
+		}
+	}
+
+	result := &VerificationResult{Profile: v.Profile, VerifiedAt: v.Now.UnixMilli()}
+	started := time.Now()
+	var fresh bool
+	for _, check := range pipeline {
+		name := check.Name()
+		if containsPolicy(options.DisabledChecks, name) {
+			continue
+		}
+		builtin, _ := check.(checkFunc)
+
+		var outcome CheckResult
+		if hit, ok := cached[name]; ok && builtin.cacheable {
+			outcome = hit
+			outcome.Duration = 0
+			outcome.Cached = true
+		} else {
+			outcome = CheckResult{Name: name, failOn: options.FailOn}
+			start := time.Now()
+			if budget, bounded := v.checkBudget(name); bounded && builtin.network {
+				v.runBudgeted(check, &outcome, budget)
+			} else {
+				check.Run(v, &outcome)
+			}
+			outcome.Duration = time.Since(start)
+			if builtin.cacheable && !outcome.TimedOut {
+				if cached == nil {
+					cached = make(map[string]CheckResult)
+				}
+				cached[name] = outcome
+				fresh = true
+			}
+		}
+
+		result.Checks = append(result.Checks, outcome)
+		result.Errors = append(result.Errors, outcome.Errors...)
+		result.Warnings = append(result.Warnings, outcome.Warnings...)
+		result.ErrorCodes = append(result.ErrorCodes, outcome.ErrorCodes...)
+		result.WarningCodes = append(result.WarningCodes, outcome.WarningCodes...)
+		if outcome.TimedOut {
+			result.TimedOut = append(result.TimedOut, name)
+		}
+	}
+	if v.budgeted() {
+		result.Elapsed = time.Since(started)
+	}
+
+	// Required checks must have run and found something to verify
+	for _, name := range options.RequiredChecks {
+		var outcome *CheckResult
+		for i := range result.Checks {
+			if result.Checks[i].Name == name {
+				outcome = &result.Checks[i]
+				break
+			}
+		}
+		switch {
+		case outcome == nil:
+			result.Errors = append(result.Errors, fmt.Sprintf("required check %s did not run", name))
+			result.ErrorCodes = append(result.ErrorCodes, ErrorCodeRequiredCheck)
+		case outcome.Skipped != "":
+			result.Errors = append(result.Errors, fmt.Sprintf("required check %s was skipped: %s", name, outcome.Skipped))
+			result.ErrorCodes = append(result.ErrorCodes, ErrorCodeRequiredCheck)
+		}
+	}
+	result.Valid = len(result.Errors) == 0
+
+	if cacheKey != "" && fresh {
+		entry := &CachedVerification{}
+		for _, check := range cached {
+			check.Cached = false
+			entry.Checks = append(entry.Checks, check)
+		}
+		options.Cache.Put(cacheKey, entry)
+	}
+	return result
+}
+
+// checkStructure validates structure under the rules of the declared
+// version, and the receipt's signed metadata: tenancy, sequence number,
+// determinism and metering record
+func checkStructure(v *Verification, result *CheckResult) {
+	schema, warning, err := resolveSchema(v.Receipt.Version)
+	if err != nil {
+		result.Fail("", err.Error())
+		return
+	}
+	if warning != "" {
+		result.Warn(WarningNewerVersion, warning)
+	}
+	if schema.Validate != nil {
+		for _, violation := range schema.Validate(v.Receipt) {
+			result.Fail("", violation)
+		}
+	}
+	checkEncodings(v, result)
+	if err := checkTimestampPrecision(v.Receipt); err != nil {
+		result.Fail("", err.Error())
+	}
+
+	// Signed metadata must be well formed
+	validators := []func(*Receipt) ([]string, error){
+		func(receipt *Receipt) ([]string, error) { return verifyTenancy(receipt, v.Options.Tenant) },
+		verifySequence,
+		verifyDeterminism,
+		verifyTransform,
+		verifyHashSource,
+		verifyCommitment,
+		verifyMetering,
+	}
+	for _, validate := range validators {
+		warnings, err := validate(v.Receipt)
+		for _, warning := range warnings {
+			result.Warn(WarningUnsigned, warning)
+		}
+		if err != nil {
+			result.Fail("", err.Error())
+		}
+	}
+}
+
+// checkTrustBundle reports problems with the trust bundle in force
+func checkTrustBundle(v *Verification, result *CheckResult) {
+	if v.Options.TrustBundle == nil {
+		result.Skip("no trust bundle")
+		return
+	}
+	for _, message := range v.bundleErrors {
+		result.Fail("", message)
+	}
+	bundle := v.Options.TrustBundle
+	warnExpiry(v, result, WarningTrustBundleNearExpiry, fmt.Sprintf("trust bundle %s", bundle.KeyID), time.UnixMilli(bundle.NotAfter))
+}
+
+// checkTimestamp checks the receipt's age and clock skew
+func checkTimestamp(v *Verification, result *CheckResult) {
+	now := v.Now.UnixMilli()
+	age := now - v.Receipt.Timestamp
+	skew := v.Receipt.Timestamp - now
+	maxSkew := v.MaxSkew.Milliseconds()
+
+	// A rounded timestamp makes the receipt look up to its precision older
+	maxAge := v.MaxAge.Milliseconds() + v.Receipt.TimestampPrecision
+
+	if age > maxAge {
+		result.Fail("", fmt.Sprintf("receipt too old: %dms > %dms", age, maxAge))
+	} else if skew > maxSkew {
+		result.Fail("", fmt.Sprintf("receipt timestamp in future: %dms > %dms", skew, maxSkew))
+	}
+}
+
+// checkSignature verifies the signature, the extensions it covers and the
+// critical extensions
+func checkSignature(v *Verification, result *CheckResult) {
+	receipt := v.Receipt
+	if err := checkAlgorithm(receipt.SignatureAlgorithm(), v.Options.Algorithms, v.Profile); err != nil {
+		result.Fail(ErrorCodeAlgorithmNotAllowed, err.Error())
+		return
+	}
+	if err := v.Client.verifySignature(receipt); err != nil {
+		result.Fail(ErrorCodeInvalidSignature, fmt.Sprintf("signature verification failed: %v", err))
+	}
+
+	// Verify the extensions covered by the signature
+	if err := verifySignedExtensions(receipt); err != nil {
+		result.Fail(ErrorCodeSignedExtensionMismatch, fmt.Sprintf("signed extension verification failed: %v", err))
+	}
+
+	// Reject critical extensions this verifier does not understand
+	if code, err := verifyCriticalExtensions(receipt, v.Options.KnownExtensions); err != nil {
+		result.Fail(code, fmt.Sprintf("critical extension verification failed: %v", err))
+	}
+}
+
+// checkIssuer verifies the signing key against the trust bundle, trusted
+// roots, expected identities and logged key statements
+func checkIssuer(v *Verification, result *CheckResult) {
+	receipt, options := v.Receipt, v.Options
+	if options.TrustBundle == nil && options.Roots == nil && len(options.Identities) == 0 {
+		result.Skip("no trusted issuers configured")
+		return
+	}
+
+	// Verify the signing key is a trust bundle issuer
+	if options.TrustBundle != nil {
+		if err := verifyBundleIssuer(receipt, options.TrustBundle); err != nil {
+			result.Fail("", fmt.Sprintf("issuer verification failed: %v", err))
+		}
+	}
+
+	// Verify the signing key chains to a trusted root
+	if options.Roots != nil {
+		if err := verifyCertificateChain(receipt, options.Roots); err != nil {
+			result.Fail("", fmt.Sprintf("certificate chain verification failed: %v", err))
+		}
+	}
+
+	// Verify the OIDC identity bound to a keyless signing certificate
+	if len(options.Identities) > 0 {
+		if options.Roots == nil {
+			result.Fail("", "identity verification requires trusted roots")
+		} else if err := verifyIdentity(receipt, options.Identities); err != nil {
+			result.Fail("", fmt.Sprintf("identity verification failed: %v", err))
+		}
+	}
+}
+
+// checkKeyState refuses receipts signed by retired or compromised keys
+// and, under RequireKeyTransparency, by unlogged keys. It is separate from
+// the cached issuer check, so newly supplied statements take effect at once
+func checkKeyState(v *Verification, result *CheckResult) {
+	if len(v.Options.KeyStatements) == 0 && !v.Options.RequireKeyTransparency {
+		result.Skip("no key statements")
+		return
+	}
+	checkKeyStatements(v, result)
+	checkKeyTransparency(v, result)
+}
+
+// keylessCertificateLifetime is the longest validity of a keyless signing
+// certificate, such as Fulcio's ten-minute certificates, which outlive
+// their one signature by design
+const keylessCertificateLifetime = time.Hour
+
+// checkKeyExpiry warns when the signing certificate expires within
+// VerifyOptions.ExpiryWarning. It is separate from the cached issuer check
+// since it depends on the time
+func checkKeyExpiry(v *Verification, result *CheckResult) {
+	if v.Options.Roots == nil || v.Options.ExpiryWarning <= 0 {
+		result.Skip("no expiry warning window configured")
+		return
+	}
+	chain, err := CertificateChain(v.Receipt)
+	if err != nil || len(chain) == 0 {
+		result.Skip("no signing certificate")
+		return
+	}
+	leaf := chain[0]
+	if leaf.NotAfter.Sub(leaf.NotBefore) <= keylessCertificateLifetime {
+		result.Skip("keyless signing certificate")
+		return
+	}
+	warnExpiry(v, result, WarningKeyNearExpiry, "signing certificate", leaf.NotAfter)
+}
+
+// checkAttestation verifies the evidence backing claimed policies
+func checkAttestation(v *Verification, result *CheckResult) {
+	receipt, profile, options := v.Receipt, v.Profile, v.Options
+	if !claimsEvidence(receipt.PolicyIDs) {
+		result.Skip("no evidence-backed policy claimed")
+		return
+	}
+
+	// Validate key erasure evidence when the policy is claimed
+	if containsPolicy(receipt.PolicyIDs, KeyErasureExtension) {
+		if _, ok := receipt.Extensions[KeyErasureExtension]; !ok && profile != ProfileStrict {
+			result.Warn(WarningEvidenceMissing, "key_erasure policy claimed without erasure evidence")
+		} else if err := verifyErasureEvidence(receipt); err != nil {
+			result.Fail("", fmt.Sprintf("key erasure evidence invalid: %v", err))
+		}
+	}
+
+	// The no_network policy must be backed by sandbox evidence
+	if containsPolicy(receipt.PolicyIDs, NoNetworkExtension) {
+		if _, ok := receipt.Extensions[NoNetworkExtension]; !ok && profile != ProfileStrict {
+			result.Warn(WarningEvidenceMissing, "no_network policy claimed without sandbox evidence")
+		} else if err := verifyNoNetworkEvidence(receipt); err != nil {
+			result.Fail("", fmt.Sprintf("no_network evidence invalid: %v", err))
+		}
+	}
+
+	// Residency policies must be backed by matching evidence
+	for _, id := range receipt.PolicyIDs {
+		jurisdiction, ok := RegionPolicies[id]
+		if !ok {
+			continue
+		}
+		if _, ok := receipt.Extensions[ResidencyExtension]; !ok && profile != ProfileStrict {
+			result.Warn(WarningEvidenceMissing, fmt.Sprintf("%s policy claimed without residency evidence", id))
+		} else if err := verifyResidencyEvidence(receipt, jurisdiction, options.ResidencyAuthorities); err != nil {
+			result.Fail("", fmt.Sprintf("%s residency evidence invalid: %v", id, err))
+		} else if len(options.ResidencyAuthorities) == 0 {
+			result.Warn(WarningEvidenceUnappraised, fmt.Sprintf("%s residency evidence signer not anchored to a trusted authority", id))
+		}
+	}
+
+	// Confidential GPU execution must be backed by bound attestation evidence
+	if containsPolicy(receipt.PolicyIDs, ConfidentialGPUPolicy) {
+		if err := verifyConfidentialGPU(receipt, options.GPUAttestationVerifier); err != nil {
+			result.Fail("", fmt.Sprintf("confidential GPU evidence invalid: %v", err))
+		} else if options.GPUAttestationVerifier == nil {
+			result.Warn(WarningEvidenceUnappraised, "confidential GPU attestation evidence not appraised")
+		}
+	}
+
+	// The AI Act policy requires a complete transparency record
+	if containsPolicy(receipt.PolicyIDs, AIActPolicy) {
+		if _, ok := receipt.Extensions[AIActExtension]; !ok && profile != ProfileStrict && profile != ProfileAIAct {
+			result.Warn(WarningEvidenceMissing, fmt.Sprintf("%s policy claimed without transparency record", AIActPolicy))
+		} else if err := verifyAIActTransparency(receipt); err != nil {
+			result.Fail("", fmt.Sprintf("AI Act transparency record invalid: %v", err))
+		}
+	}
+}
+
+// claimsEvidence reports whether any of the policies must be backed by
+// evidence
+func claimsEvidence(policyIDs []string) bool {
+	for _, id := range policyIDs {
+		switch id {
+		case KeyErasureExtension, NoNetworkExtension, AIActPolicy, ConfidentialGPUPolicy:
+			return true
+		}
+		if _, ok := RegionPolicies[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPolicy applies the profile's policy requirements and validates and
+// resolves policy identifiers
+func checkPolicy(v *Verification, result *CheckResult) {
+	receipt, profile, options := v.Receipt, v.Profile, v.Options
+
+	// Validate policies (profile-dependent)
+	if profile == ProfileStrict && len(receipt.PolicyIDs) == 0 {
+		result.Fail("", "TECP-STRICT requires at least one policy")
+	}
+	if profile == ProfileAIAct && !containsPolicy(receipt.PolicyIDs, AIActPolicy) {
+		result.Fail("", fmt.Sprintf("TECP-AI-ACT requires the %s policy", AIActPolicy))
+	}
+	if profile == ProfileStrict {
+		if _, ok := receipt.Determinism(); !ok {
+			result.Fail("", "TECP-STRICT requires a signed determinism declaration")
+		}
+	}
+	if len(receipt.PolicyIDs) == 0 && result.Passed() {
+		result.Skip("no policies claimed")
+		return
+	}
+	for _, conflict := range PolicyConflicts(receipt.PolicyIDs) {
+		result.Fail(ErrorCodePolicyConflict, conflict.Error())
+	}
+
+	// Validate policy identifiers and resolve custom policies
+	resolved := make(map[string]*PolicyDescriptor)
+	for _, id := range receipt.PolicyIDs {
+		policy, err := ParsePolicyID(id)
+		if err != nil {
+			if profile == ProfileStrict {
+				result.Fail("", err.Error())
+			} else {
+				result.Warn(WarningPolicyMalformed, err.Error())
+			}
+			continue
+		}
+		if options.PolicyResolver != nil && !policy.IsRegistry() {
+			descriptor, err := resolvePolicy(options.PolicyResolver, policy, v.Client.options.HTTPClient)
+			if err != nil {
+				result.Warn(WarningPolicyUnresolved, fmt.Sprintf("policy %s could not be resolved: %v", id, err))
+				continue
+			}
+			resolved[id] = descriptor
+		}
+	}
+	checkPolicySnapshots(v, result, resolved)
+}
+
+// checkLog verifies transparency log promises and overdue inclusions
+func checkLog(v *Verification, result *CheckResult) {
+	errors, warnings := verifyLogPromises(v)
+	for _, message := range errors {
+		result.Fail("", message)
+	}
+	for _, warning := range warnings {
+		result.Warn(warning.code, warning.message)
+	}
+
+	// Receipts issued with logs unreachable are settled by inclusion proofs
+	if degradation, found, err := ReceiptDegradation(v.Receipt); err != nil {
+		result.Fail("", fmt.Sprintf("degraded extension invalid: %v", err))
+	} else if found {
+		if proofs, _ := receiptInclusionProofs(v.Receipt); len(proofs) == 0 {
+			result.Warn(WarningLogUnreachable, fmt.Sprintf("receipt was issued while a transparency log was unreachable (%s)", degradation.Mode))
+		}
+	}
+	if len(result.Errors) == 0 && len(result.Warnings) == 0 {
+		if len(v.Options.Logs) == 0 {
+			result.Skip("no trusted logs")
+		} else if srts, _ := receiptSRTs(v.Receipt); len(srts) == 0 {
+			if proofs, _ := receiptInclusionProofs(v.Receipt); len(proofs) == 0 {
+				result.Skip("no log promise")
+			}
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package tecp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	registryPolicyPattern  = regexp.MustCompile(`^[a-z0-9_]+$`)
+	policyNamespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+	policyNamePattern      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	policyVersionPattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+-]{0,31}$`)
+)
+
+// PolicyID is a parsed policy identifier. Three forms are accepted:
+//
+//	no_retention                      registry policy (Name only)
+//	org.example/No-PII-Export@v2      namespaced policy, optionally versioned
+//	https://policies.example.org/pii  policy published at a URI
+type PolicyID struct {
+	Namespace string
+	Name      string
+	Version   string
+	URI       string
+}
+
+// ParsePolicyID parses and validates a policy identifier
+func ParsePolicyID(id string) (PolicyID, error) {
+	switch {
+	case registryPolicyPattern.MatchString(id):
+		return PolicyID{Name: id}, nil
+
+	case strings.Contains(id, "://") || strings.HasPrefix(id, "urn:"):
+		u, err := url.Parse(id)
+		if err != nil {
+			return PolicyID{}, fmt.Errorf("invalid policy URI %q: %w", id, err)
+		}
+		if u.Scheme != "https" && u.Scheme != "urn" {
+			return PolicyID{}, fmt.Errorf("policy URI %q must use https or urn", id)
+		}
+		if u.Fragment != "" || u.User != nil {
+			return PolicyID{}, fmt.Errorf("policy URI %q must not carry credentials or a fragment", id)
+		}
+		return PolicyID{URI: id}, nil
+	}
+
+	namespace, rest, ok := strings.Cut(id, "/")
+	if !ok {
+		return PolicyID{}, fmt.Errorf("invalid policy ID %q", id)
+	}
+	if !policyNamespacePattern.MatchString(namespace) {
+		return PolicyID{}, fmt.Errorf("invalid policy namespace %q", namespace)
+	}
+
+	name, version, versioned := strings.Cut(rest, "@")
+	if !policyNamePattern.MatchString(name) {
+		return PolicyID{}, fmt.Errorf("invalid policy name %q", name)
+	}
+	if versioned && !policyVersionPattern.MatchString(version) {
+		return PolicyID{}, fmt.Errorf("invalid policy version %q", version)
+	}
+
+	return PolicyID{Namespace: namespace, Name: name, Version: version}, nil
+}
+
+// ValidatePolicyIDs checks that every policy identifier is well formed
+func ValidatePolicyIDs(ids []string) error {
+	for _, id := range ids {
+		if _, err := ParsePolicyID(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the canonical form of the policy identifier
+func (p PolicyID) String() string {
+	switch {
+	case p.URI != "":
+		return p.URI
+	case p.Namespace == "":
+		return p.Name
+	case p.Version == "":
+		return p.Namespace + "/" + p.Name
+	default:
+		return p.Namespace + "/" + p.Name + "@" + p.Version
+	}
+}
+
+// IsRegistry reports whether the policy is defined by the TECP policy registry
+func (p PolicyID) IsRegistry() bool {
+	return p.URI == "" && p.Namespace == ""
+}
+
+// PolicyDescriptor describes a policy, mirroring a TECP policy registry entry
+type PolicyDescriptor struct {
+	Description      string   `json:"description"`
+	EnforcementType  string   `json:"enforcement_type"`
+	MachineCheck     string   `json:"machine_check"`
+	ComplianceTags   []string `json:"compliance_tags"`
+	TechnicalDetails string   `json:"technical_details,omitempty"`
+}
+
+// PolicyResolver looks up the descriptor of a custom policy
+type PolicyResolver interface {
+	ResolvePolicy(id PolicyID) (*PolicyDescriptor, error)
+}
+
+// HTTPPolicyResolver fetches policy descriptors published over HTTPS.
+// URI policies are fetched from their URI; namespaced policies from the
+// location returned by Locate, defaulting to DefaultPolicyLocation
+type HTTPPolicyResolver struct {
+	HTTPClient *http.Client
+	Locate     func(id PolicyID) (string, error)
+}
+
+// DefaultPolicyLocation maps a namespaced policy to a well-known URL on the
+// namespace's domain: org.example/No-PII-Export@v2 is published at
+// https://example.org/.well-known/tecp-policies/No-PII-Export@v2.json
+func DefaultPolicyLocation(id PolicyID) (string, error) {
+	if id.URI != "" {
+		return id.URI, nil
+	}
+	if id.Namespace == "" {
+		return "", fmt.Errorf("registry policy %q has no published location", id.Name)
+	}
+
+	labels := strings.Split(id.Namespace, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	file := id.Name
+	if id.Version != "" {
+		file += "@" + id.Version
+	}
+	return "https://" + strings.Join(labels, ".") + "/.well-known/tecp-policies/" + url.PathEscape(file) + ".json", nil
+}
+
+// UseHTTPClient fetches descriptors with client when the resolver has no
+// HTTPClient of its own
+func (r *HTTPPolicyResolver) UseHTTPClient(client *http.Client) {
+	if r.HTTPClient == nil {
+		r.HTTPClient = client
+	}
+}
+
+// MaxPolicyDescriptorSize bounds fetched policy descriptors
+const MaxPolicyDescriptorSize = 64 * 1024
+
+// ResolvePolicy fetches the descriptor for id
+func (r *HTTPPolicyResolver) ResolvePolicy(id PolicyID) (*PolicyDescriptor, error) {
+	locate := r.Locate
+	if locate == nil {
+		locate = DefaultPolicyLocation
+	}
+	location, err := locate(id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(location, "https://") {
+		return nil, fmt.Errorf("policy %s is not resolvable over https", id)
+	}
+
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+
+	resp, err := httpClient.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("policy %s: %s returned status %d", id, location, resp.StatusCode)
+	}
+
+	var descriptor PolicyDescriptor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, MaxPolicyDescriptorSize)).Decode(&descriptor); err != nil {
+		return nil, fmt.Errorf("policy %s: invalid descriptor: %w", id, err)
+	}
+	return &descriptor, nil
+}
+
+// PolicyFailureTTL is the longest a CachedPolicyResolver caches a failure,
+// so publishers recovering from an outage are retried promptly
+const PolicyFailureTTL = 30 * time.Second
+
+// CachedPolicyResolver memoizes another resolver's results for a fixed TTL.
+// Failures are cached too, for at most PolicyFailureTTL, so unreachable
+// publishers are not hammered
+type CachedPolicyResolver struct {
+	resolver PolicyResolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]policyCacheEntry
+}
+
+type policyCacheEntry struct {
+	descriptor *PolicyDescriptor
+	err        error
+	expires    time.Time
+}
+
+// NewCachedPolicyResolver wraps resolver with a cache holding results for ttl
+func NewCachedPolicyResolver(resolver PolicyResolver, ttl time.Duration) *CachedPolicyResolver {
+	return &CachedPolicyResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  make(map[string]policyCacheEntry),
+	}
+}
+
+// UseHTTPClient passes client on to the wrapped resolver
+func (c *CachedPolicyResolver) UseHTTPClient(client *http.Client) {
+	if user, ok := c.resolver.(HTTPClientUser); ok {
+		user.UseHTTPClient(client)
+	}
+}
+
+// ResolvePolicy returns the cached descriptor for id, resolving it on a miss
+func (c *CachedPolicyResolver) ResolvePolicy(id PolicyID) (*PolicyDescriptor, error) {
+	return c.resolve(id, nil)
+}
+
+// resolve is ResolvePolicy, resolving misses through client as
+// resolvePolicy does
+func (c *CachedPolicyResolver) resolve(id PolicyID, client *http.Client) (*PolicyDescriptor, error) {
+	key := id.String()
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.descriptor, entry.err
+	}
+
+	descriptor, err := resolvePolicy(c.resolver, id, client)
+	ttl := c.ttl
+	if err != nil && ttl > PolicyFailureTTL {
+		ttl = PolicyFailureTTL
+	}
+
+	c.mu.Lock()
+	c.entries[key] = policyCacheEntry{descriptor: descriptor, err: err, expires: now.Add(ttl)}
+	c.mu.Unlock()
+
+	return descriptor, err
+}
+
+// resolvePolicy resolves id with resolver, fetching through client when an
+// HTTPPolicyResolver, bare or behind a CachedPolicyResolver, has no
+// HTTPClient. Verification options are shared between calls, so the
+// resolver itself is left untouched
+func resolvePolicy(resolver PolicyResolver, id PolicyID, client *http.Client) (*PolicyDescriptor, error) {
+	switch r := resolver.(type) {
+	case *HTTPPolicyResolver:
+		if r.HTTPClient == nil && client != nil {
+			shared := *r
+			shared.HTTPClient = client
+			return shared.ResolvePolicy(id)
+		}
+	case *CachedPolicyResolver:
+		return r.resolve(id, client)
+	}
+	return resolver.ResolvePolicy(id)
+}
+
+// PolicyTTL returns the lifetime bound expressed by a ttl_* policy ID such as
+// ttl_5s, ttl_60s, ttl_15m, ttl_1h or ttl_7d
+func PolicyTTL(policyID string) (time.Duration, bool) {
+	spec, ok := strings.CutPrefix(policyID, "ttl_")
+	if !ok || spec == "" {
+		return 0, false
+	}
+
+	// time.ParseDuration has no day unit. Day counts whose duration
+	// overflows are rejected rather than wrapped
+	if days, ok := strings.CutSuffix(spec, "d"); ok {
+		n, err := strconv.ParseUint(days, 10, 32)
+		if err != nil || n == 0 || n > uint64(math.MaxInt64/int64(24*time.Hour)) {
+			return 0, false
+		}
+		return time.Duration(n) * 24 * time.Hour, true
+	}
+
+	ttl, err := time.ParseDuration(spec)
+	if err != nil || ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// shortestPolicyTTL returns the tightest TTL among the claimed policies
+func shortestPolicyTTL(policyIDs []string) (time.Duration, bool) {
+	var shortest time.Duration
+	found := false
+	for _, id := range policyIDs {
+		ttl, ok := PolicyTTL(id)
+		if ok && (!found || ttl < shortest) {
+			shortest = ttl
+			found = true
+		}
+	}
+	return shortest, found
+}
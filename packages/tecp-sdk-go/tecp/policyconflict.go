@@ -0,0 +1,94 @@
+package tecp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrorCodePolicyConflict marks receipts claiming contradictory policies
+const ErrorCodePolicyConflict = "policy_conflict"
+
+// PolicyConflict is a pair of policies no single computation can honour
+// together. Patterns ending in "*" match every policy ID with that prefix
+type PolicyConflict struct {
+	Policy        string
+	ConflictsWith string
+	Reason        string
+}
+
+// Error describes the conflict
+func (c PolicyConflict) Error() string {
+	return fmt.Sprintf("policy %s conflicts with %s: %s", c.Policy, c.ConflictsWith, c.Reason)
+}
+
+var (
+	policyConflictsMu sync.RWMutex
+	policyConflicts   = []PolicyConflict{
+		{"no_retention", "retention_*", "data cannot be both discarded and retained"},
+		{"no_network", "external_api_call", "a computation without network access cannot call external APIs"},
+	}
+)
+
+// RegisterPolicyConflict adds a pair of contradictory policies to the
+// compatibility matrix. Either pattern may end in "*" to match a family of
+// policies such as retention_*
+func RegisterPolicyConflict(policy, conflictsWith, reason string) {
+	policyConflictsMu.Lock()
+	defer policyConflictsMu.Unlock()
+	policyConflicts = append(policyConflicts, PolicyConflict{policy, conflictsWith, reason})
+}
+
+// matchPolicy reports whether id matches a conflict pattern
+func matchPolicy(pattern, id string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(id, prefix) && len(id) > len(prefix)
+	}
+	return id == pattern
+}
+
+// PolicyConflicts returns the contradictory pairs among ids, with the
+// policies as claimed. Residency policies for different jurisdictions
+// always conflict
+func PolicyConflicts(ids []string) []PolicyConflict {
+	policyConflictsMu.RLock()
+	matrix := policyConflicts
+	policyConflictsMu.RUnlock()
+
+	var conflicts []PolicyConflict
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			if a == b {
+				continue
+			}
+			if ja, ok := RegionPolicies[a]; ok {
+				if jb, ok := RegionPolicies[b]; ok && ja != jb {
+					conflicts = append(conflicts, PolicyConflict{a, b, fmt.Sprintf("processing cannot be confined to both %s and %s", ja, jb)})
+					continue
+				}
+			}
+			for _, rule := range matrix {
+				if matchPolicy(rule.Policy, a) && matchPolicy(rule.ConflictsWith, b) ||
+					matchPolicy(rule.Policy, b) && matchPolicy(rule.ConflictsWith, a) {
+					conflicts = append(conflicts, PolicyConflict{a, b, rule.Reason})
+					break
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// ValidatePolicySet checks that a set of policy IDs contains no
+// contradictory combinations, reporting every conflict found
+func ValidatePolicySet(ids []string) error {
+	conflicts := PolicyConflicts(ids)
+	if len(conflicts) == 0 {
+		return nil
+	}
+	messages := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		messages[i] = conflict.Error()
+	}
+	return fmt.Errorf("contradictory policies: %s", strings.Join(messages, "; "))
+}
@@ -0,0 +1,168 @@
+package tecp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// PolicySnapshotsExtension embeds hash-pinned snapshots of the descriptors
+// of the namespaced and URI policies a receipt claims, so what a policy
+// meant at issuance cannot be silently changed by its publisher later
+const PolicySnapshotsExtension = "policy_snapshots"
+
+// ErrorCodePolicyChanged reports a policy descriptor that differs from its
+// pin. A descriptor that merely differs from its snapshot is reported with
+// WarningPolicyChanged
+const ErrorCodePolicyChanged = "policy_changed"
+
+// PolicySnapshot is a policy descriptor as resolved at issuance
+type PolicySnapshot struct {
+	Descriptor *PolicyDescriptor `json:"descriptor"`
+
+	// Hash is the descriptor's PolicyDescriptorHash
+	Hash string `json:"hash"`
+}
+
+// PolicyDescriptorHash returns the base64 SHA-256 hash of a descriptor's
+// RFC 8785 canonical JSON, which pins its meaning
+func PolicyDescriptorHash(descriptor *PolicyDescriptor) (string, error) {
+	data, err := json.Marshal(descriptor)
+	if err != nil {
+		return "", err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", err
+	}
+	canonical, err := canonicalJSON(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to canonicalize policy descriptor: %w", err)
+	}
+	hash := sha256.Sum256(canonical)
+	return base64.StdEncoding.EncodeToString(hash[:]), nil
+}
+
+// PinPolicies resolves the descriptors of policy IDs with resolver and
+// returns their hashes, for VerifyOptions.PolicyPins. Registry policies
+// are skipped
+func PinPolicies(resolver PolicyResolver, ids ...string) (map[string]string, error) {
+	pins := make(map[string]string, len(ids))
+	for _, id := range ids {
+		policy, err := ParsePolicyID(id)
+		if err != nil {
+			return nil, err
+		}
+		if policy.IsRegistry() {
+			continue
+		}
+		descriptor, err := resolver.ResolvePolicy(policy)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s could not be resolved: %w", id, err)
+		}
+		if pins[id], err = PolicyDescriptorHash(descriptor); err != nil {
+			return nil, err
+		}
+	}
+	return pins, nil
+}
+
+// ReceiptPolicySnapshots returns the policy snapshots embedded in a
+// receipt, by policy ID
+func ReceiptPolicySnapshots(receipt *Receipt) (map[string]PolicySnapshot, bool, error) {
+	var snapshots map[string]PolicySnapshot
+	found, err := decodeExtension(receipt, PolicySnapshotsExtension, &snapshots)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	return snapshots, true, nil
+}
+
+// snapshotPolicies resolves the receipt's namespaced and URI policies and
+// embeds their snapshots. It reports whether any were embedded
+func snapshotPolicies(receipt *Receipt, resolver PolicyResolver) (bool, error) {
+	snapshots := make(map[string]PolicySnapshot)
+	for _, id := range receipt.PolicyIDs {
+		policy, err := ParsePolicyID(id)
+		if err != nil {
+			return false, err
+		}
+		if policy.IsRegistry() {
+			continue
+		}
+		descriptor, err := resolver.ResolvePolicy(policy)
+		if err != nil {
+			return false, fmt.Errorf("failed to snapshot policy %s: %w", id, err)
+		}
+		hash, err := PolicyDescriptorHash(descriptor)
+		if err != nil {
+			return false, err
+		}
+		snapshots[id] = PolicySnapshot{Descriptor: descriptor, Hash: hash}
+	}
+	if len(snapshots) == 0 {
+		return false, nil
+	}
+	receipt.Extensions[PolicySnapshotsExtension] = snapshots
+	return true, nil
+}
+
+// checkPolicySnapshots verifies the receipt's policy snapshots and holds
+// its policies to VerifyOptions.PolicyPins. resolved are the descriptors
+// the verifier resolved itself, by policy ID
+func checkPolicySnapshots(v *Verification, result *CheckResult, resolved map[string]*PolicyDescriptor) {
+	receipt := v.Receipt
+	snapshots, found, err := ReceiptPolicySnapshots(receipt)
+	if err != nil {
+		result.Fail("", err.Error())
+		return
+	}
+	if found && !receipt.signsExtension(PolicySnapshotsExtension) {
+		result.Fail("", "policy snapshots are not covered by the signature")
+		return
+	}
+
+	ids := make([]string, 0, len(snapshots))
+	for id := range snapshots {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		snapshot := snapshots[id]
+		if !containsPolicy(receipt.PolicyIDs, id) {
+			result.Fail("", fmt.Sprintf("policy snapshot for %s, which the receipt does not claim", id))
+			continue
+		}
+		hash, err := PolicyDescriptorHash(snapshot.Descriptor)
+		if err != nil || snapshot.Descriptor == nil || hash != snapshot.Hash {
+			result.Fail("", fmt.Sprintf("policy snapshot for %s does not match its hash", id))
+			continue
+		}
+		if descriptor := resolved[id]; descriptor != nil && v.Options.PolicyPins[id] == "" {
+			if current, err := PolicyDescriptorHash(descriptor); err == nil && current != snapshot.Hash {
+				result.Warn(WarningPolicyChanged, fmt.Sprintf("policy %s has changed since the receipt was issued", id))
+			}
+		}
+	}
+
+	for _, id := range receipt.PolicyIDs {
+		pin, ok := v.Options.PolicyPins[id]
+		if !ok {
+			continue
+		}
+		var hash string
+		if snapshot, ok := snapshots[id]; ok {
+			hash = snapshot.Hash
+		} else if descriptor := resolved[id]; descriptor != nil {
+			hash, _ = PolicyDescriptorHash(descriptor)
+		} else {
+			result.Warn(WarningPolicyPinUnchecked, fmt.Sprintf("policy %s has no snapshot and could not be resolved to check its pin", id))
+			continue
+		}
+		if hash != pin {
+			result.Fail(ErrorCodePolicyChanged, fmt.Sprintf("policy %s does not match its pinned descriptor", id))
+		}
+	}
+}
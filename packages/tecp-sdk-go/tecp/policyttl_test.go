@@ -0,0 +1,70 @@
+package tecp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyTTLEnforcedByDefault(t *testing.T) {
+	priv, _, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := NewClient(WithSigner(priv)).CreateReceipt(CreateReceiptOptions{
+		Input:    []byte("in"),
+		Output:   []byte("out"),
+		CodeRef:  "git:abc",
+		Policies: []string{"ttl_60s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued := time.UnixMilli(receipt.Timestamp)
+	client := NewClient()
+
+	within, err := client.VerifyAt(receipt, issued.Add(30*time.Second), VerifyOptions{})
+	if err != nil || !within.Valid {
+		t.Fatalf("receipt within its TTL rejected: %v %v", err, within.Errors)
+	}
+
+	expired, err := client.VerifyAt(receipt, issued.Add(2*time.Minute), VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired.Valid {
+		t.Fatal("receipt past its ttl_60s policy accepted by default")
+	}
+
+	ignored, err := client.VerifyAt(receipt, issued.Add(2*time.Minute), VerifyOptions{IgnorePolicyTTL: true})
+	if err != nil || !ignored.Valid {
+		t.Fatalf("IgnorePolicyTTL did not restore the profile limit: %v %v", err, ignored.Errors)
+	}
+}
+
+func TestPolicyTTL(t *testing.T) {
+	tests := []struct {
+		id   string
+		want time.Duration
+		ok   bool
+	}{
+		{"ttl_5s", 5 * time.Second, true},
+		{"ttl_15m", 15 * time.Minute, true},
+		{"ttl_1h", time.Hour, true},
+		{"ttl_7d", 7 * 24 * time.Hour, true},
+		{"ttl_106751d", 106751 * 24 * time.Hour, true},
+		{"ttl_106752d", 0, false},
+		{"ttl_200000d", 0, false},
+		{"ttl_0d", 0, false},
+		{"ttl_0s", 0, false},
+		{"ttl_-5s", 0, false},
+		{"ttl_", 0, false},
+		{"ttl_xd", 0, false},
+		{"no_retention", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := PolicyTTL(tt.id)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("PolicyTTL(%q) = %v, %v; want %v, %v", tt.id, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ChallengeNonceSize is the size of a possession challenge nonce
+const ChallengeNonceSize = 32
+
+// DefaultChallengeTTL is how long a challenge may be answered
+const DefaultChallengeTTL = 5 * time.Minute
+
+// possessionDomain separates possession proofs from receipt signatures
+// made with the same key
+const possessionDomain = "TECP-POP-V1\x00"
+
+// Challenge asks a receipt's issuer to prove it still controls the
+// receipt's signing key, for receipts presented long after issuance
+type Challenge struct {
+	// ReceiptHash is the hex ReceiptHash of the receipt in question
+	ReceiptHash string `json:"receipt_hash"`
+
+	// Nonce is the verifier's fresh base64 nonce
+	Nonce string `json:"nonce"`
+
+	// Expires is when the challenge lapses, in Unix milliseconds
+	Expires int64 `json:"expires"`
+}
+
+// PossessionProof answers a Challenge: the issuer's signature over
+// receipt_hash||nonce with the receipt's signing key
+type PossessionProof struct {
+	ReceiptHash string `json:"receipt_hash"`
+	Nonce       string `json:"nonce"`
+	Signature   string `json:"sig"`
+}
+
+// NewChallenge returns a challenge for receipt with a fresh nonce, to be
+// answered within ttl, or DefaultChallengeTTL when ttl is zero
+func NewChallenge(receipt *Receipt, ttl time.Duration) (*Challenge, error) {
+	if ttl <= 0 {
+		ttl = DefaultChallengeTTL
+	}
+	hash, err := ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, ChallengeNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge nonce: %w", err)
+	}
+	return &Challenge{
+		ReceiptHash: hex.EncodeToString(hash),
+		Nonce:       base64.StdEncoding.EncodeToString(nonce),
+		Expires:     time.Now().Add(ttl).UnixMilli(),
+	}, nil
+}
+
+// possessionPayload returns the bytes a possession proof signs
+func possessionPayload(receiptHash, nonce string) ([]byte, error) {
+	hash, err := hex.DecodeString(receiptHash)
+	if err != nil || len(hash) != 32 {
+		return nil, fmt.Errorf("invalid challenge receipt hash")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(nonce)
+	if err != nil || len(decoded) != ChallengeNonceSize {
+		return nil, fmt.Errorf("invalid challenge nonce")
+	}
+	payload := append([]byte(possessionDomain), hash...)
+	return append(payload, decoded...), nil
+}
+
+// AnswerChallenge proves the client controls receipt's signing key. It
+// answers only unexpired challenges for receipts whose signature verifies
+// under the client's own key, so it never vouches for a forgery. Keyless
+// clients cannot answer, their keys being discarded after one receipt
+func (c *Client) AnswerChallenge(receipt *Receipt, challenge *Challenge) (*PossessionProof, error) {
+	if c.options.Signer == nil && c.privateKey == nil {
+		return nil, fmt.Errorf("a persistent signing key is required to answer challenges")
+	}
+	signer, _, _, err := c.receiptSigner()
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().UnixMilli() > challenge.Expires {
+		return nil, fmt.Errorf("challenge expired")
+	}
+	hash, err := ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.ReceiptHash != hex.EncodeToString(hash) {
+		return nil, fmt.Errorf("challenge is for another receipt")
+	}
+	publicKey, _, err := DecodeBinary(receipt.PublicKey, len(signer.PublicKey()))
+	if err != nil || !bytes.Equal(publicKey, signer.PublicKey()) {
+		return nil, fmt.Errorf("receipt was not signed with this client's key")
+	}
+	if err := c.verifySignature(receipt); err != nil {
+		return nil, err
+	}
+
+	payload, err := possessionPayload(challenge.ReceiptHash, challenge.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign possession proof: %w", err)
+	}
+	return &PossessionProof{
+		ReceiptHash: challenge.ReceiptHash,
+		Nonce:       challenge.Nonce,
+		Signature:   base64.StdEncoding.EncodeToString(signature),
+	}, nil
+}
+
+// VerifyPossession checks that proof answers challenge for receipt with a
+// signature by the receipt's signing key. Answers are accepted until the
+// challenge expires
+func VerifyPossession(receipt *Receipt, challenge *Challenge, proof *PossessionProof) error {
+	if time.Now().UnixMilli() > challenge.Expires {
+		return fmt.Errorf("challenge expired")
+	}
+	if proof.ReceiptHash != challenge.ReceiptHash || proof.Nonce != challenge.Nonce {
+		return fmt.Errorf("possession proof answers another challenge")
+	}
+	hash, err := ReceiptHash(receipt)
+	if err != nil {
+		return err
+	}
+	if challenge.ReceiptHash != hex.EncodeToString(hash) {
+		return fmt.Errorf("challenge is for another receipt")
+	}
+
+	publicKeySize, signatureSize := receipt.keySizes()
+	publicKey, err := decodeBinaryField("public key", receipt.PublicKey, publicKeySize)
+	if err != nil {
+		return err
+	}
+	signature, err := decodeBinaryField("possession proof signature", proof.Signature, signatureSize)
+	if err != nil {
+		return err
+	}
+	payload, err := possessionPayload(proof.ReceiptHash, proof.Nonce)
+	if err != nil {
+		return err
+	}
+	if err := verifyAlgorithmSignature(receipt.SignatureAlgorithm(), publicKey, payload, signature); err != nil {
+		return fmt.Errorf("possession proof: %w", err)
+	}
+	return nil
+}
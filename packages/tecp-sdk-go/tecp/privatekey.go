@@ -0,0 +1,153 @@
+package tecp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+var (
+	oidPublicKeyECDSA  = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidNamedCurveS256K = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// pkcs8 is a PKCS #8 private key, which crypto/x509 parses for every curve
+// but secp256k1
+type pkcs8 struct {
+	Version    int
+	Algorithm  pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// ecPrivateKey is a SEC 1 elliptic curve private key
+type ecPrivateKey struct {
+	Version    int
+	PrivateKey []byte
+	NamedCurve asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// LoadPrivateKey reads a PEM private key file. See ParsePrivateKey
+func LoadPrivateKey(path string) (crypto.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePrivateKey parses a PEM private key: PKCS #8, as written by
+// "openssl genpkey", or SEC 1 "EC PRIVATE KEY", as written by "openssl
+// ecparam -genkey". It returns an ed25519.PrivateKey, an *ecdsa.PrivateKey
+// on P-256 or P-384, or a *secp256k1.PrivateKey; NewSigner signs with any
+// of them
+func ParsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("not a PEM private key")
+	}
+
+	var key crypto.PrivateKey
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			key, err = parseSecp256k1PKCS8(block.Bytes, err)
+		}
+	case "EC PRIVATE KEY":
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			key, err = parseSecp256k1SEC1(block.Bytes, nil, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case ed25519.PrivateKey, *secp256k1.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		if _, err := NewECDSASigner(key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// parseSecp256k1PKCS8 parses a PKCS #8 secp256k1 key, returning parseErr
+// for anything else
+func parseSecp256k1PKCS8(der []byte, parseErr error) (crypto.PrivateKey, error) {
+	var info pkcs8
+	if _, err := asn1.Unmarshal(der, &info); err != nil || !info.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, parseErr
+	}
+	var curve asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &curve); err != nil {
+		return nil, parseErr
+	}
+	return parseSecp256k1SEC1(info.PrivateKey, curve, parseErr)
+}
+
+// parseSecp256k1SEC1 parses a SEC 1 secp256k1 key whose curve is named in
+// the key or, for PKCS #8, by curve, returning parseErr for anything else
+func parseSecp256k1SEC1(der []byte, curve asn1.ObjectIdentifier, parseErr error) (crypto.PrivateKey, error) {
+	var key ecPrivateKey
+	if _, err := asn1.Unmarshal(der, &key); err != nil {
+		return nil, parseErr
+	}
+	if curve == nil {
+		curve = key.NamedCurve
+	}
+	if !curve.Equal(oidNamedCurveS256K) {
+		return nil, parseErr
+	}
+	if len(key.PrivateKey) != secp256k1.PrivKeyBytesLen {
+		return nil, fmt.Errorf("invalid secp256k1 private key size: %d", len(key.PrivateKey))
+	}
+	return secp256k1.PrivKeyFromBytes(key.PrivateKey), nil
+}
+
+// NewSigner returns the Signer for a private key: an ed25519.PrivateKey, a
+// *secp256k1.PrivateKey, or a crypto.Signer with an ECDSA public key, as
+// NewECDSASigner
+func NewSigner(key crypto.PrivateKey) (Signer, error) {
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return NewEd25519Signer(key), nil
+	case *secp256k1.PrivateKey:
+		return NewSecp256k1Signer(key), nil
+	case crypto.Signer:
+		return NewECDSASigner(key)
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// LoadSigner reads a PEM private key file and returns its Signer
+func LoadSigner(path string) (Signer, error) {
+	key, err := LoadPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(key)
+}
+
+// VerifySignature verifies a signature made by a Signer under alg over
+// payload, for documents signed outside receipts
+func VerifySignature(alg Algorithm, publicKey, payload, signature []byte) error {
+	return verifyAlgorithmSignature(alg, publicKey, payload, signature)
+}
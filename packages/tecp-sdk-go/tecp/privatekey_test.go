@@ -0,0 +1,101 @@
+package tecp
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func encodePEM(t *testing.T, blockType string, der []byte, err error) []byte {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	k1, _ := secp256k1.GeneratePrivateKey()
+
+	// crypto/x509 cannot marshal secp256k1 keys
+	curve, _ := asn1.Marshal(oidNamedCurveS256K)
+	k1SEC1, err := asn1.Marshal(ecPrivateKey{Version: 1, PrivateKey: k1.Serialize(), NamedCurve: oidNamedCurveS256K})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1Inner, _ := asn1.Marshal(ecPrivateKey{Version: 1, PrivateKey: k1.Serialize()})
+	k1PKCS8, err := asn1.Marshal(pkcs8{
+		Algorithm:  pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: curve}},
+		PrivateKey: k1Inner,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256SEC1, err := x509.MarshalECPrivateKey(p256)
+	pkcs8PEM := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		return encodePEM(t, "PRIVATE KEY", der, err)
+	}
+
+	tests := []struct {
+		name string
+		pem  []byte
+		alg  Algorithm // empty when the key must be rejected
+	}{
+		{"Ed25519 PKCS #8", pkcs8PEM(edKey), AlgEdDSA},
+		{"P-256 PKCS #8", pkcs8PEM(p256), AlgES256},
+		{"P-384 PKCS #8", pkcs8PEM(p384), AlgES384},
+		{"P-256 SEC 1", encodePEM(t, "EC PRIVATE KEY", p256SEC1, err), AlgES256},
+		{"secp256k1 PKCS #8", encodePEM(t, "PRIVATE KEY", k1PKCS8, nil), AlgES256K},
+		{"secp256k1 SEC 1", encodePEM(t, "EC PRIVATE KEY", k1SEC1, nil), AlgES256K},
+		{"P-224", pkcs8PEM(p224), ""},
+		{"RSA", pkcs8PEM(rsaKey), ""},
+		{"public key", encodePEM(t, "PUBLIC KEY", []byte{0x30, 0}, nil), ""},
+		{"not PEM", []byte("-----"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePrivateKey(tt.pem)
+			if tt.alg == "" {
+				if err == nil {
+					t.Fatalf("parsed %T", key)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer, err := NewSigner(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signer.Algorithm() != tt.alg {
+				t.Fatalf("algorithm = %s, want %s", signer.Algorithm(), tt.alg)
+			}
+			signature, err := signer.Sign([]byte("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifySignature(tt.alg, signer.PublicKey(), []byte("payload"), signature); err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifySignature(tt.alg, signer.PublicKey(), []byte("other"), signature); err == nil {
+				t.Fatal("signature verified over another payload")
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// VerificationReportVersion identifies the verification report format
+const VerificationReportVersion = "TECP-VR-0.1"
+
+// VerificationReport is the canonical document form of a verification: the
+// receipt checked, the verifier, the configuration it applied, when, and
+// the outcome of every check. Reports encode to JSON or CBOR and can be
+// signed by the verifier; both encodings carry the same signature
+type VerificationReport struct {
+	Version          string        `json:"version" cbor:"version"`
+	ReceiptHash      string        `json:"receipt_hash" cbor:"receipt_hash"`
+	ReceiptTimestamp int64         `json:"receipt_timestamp" cbor:"receipt_timestamp"`
+	VerifiedAt       int64         `json:"verified_at" cbor:"verified_at"`
+	Policy           ReportPolicy  `json:"policy" cbor:"policy"`
+	Valid            bool          `json:"valid" cbor:"valid"`
+	Errors           []string      `json:"errors,omitempty" cbor:"errors,omitempty"`
+	ErrorCodes       []string      `json:"error_codes,omitempty" cbor:"error_codes,omitempty"`
+	Checks           []ReportCheck `json:"checks,omitempty" cbor:"checks,omitempty"`
+
+	// VerifierID optionally names the verifier, as a host name or URI
+	VerifierID string `json:"verifier_id,omitempty" cbor:"verifier_id,omitempty"`
+
+	// Verifier is the base64 key that signed the report
+	Verifier  string `json:"verifier,omitempty" cbor:"verifier,omitempty"`
+	Signature string `json:"sig,omitempty" cbor:"sig,omitempty"`
+}
+
+// ReportPolicy is the verification configuration a report was produced
+// under
+type ReportPolicy struct {
+	Profile        Profile  `json:"profile" cbor:"profile"`
+	PolicyVersion  string   `json:"policy_version,omitempty" cbor:"policy_version,omitempty"`
+	TrustBundle    string   `json:"trust_bundle,omitempty" cbor:"trust_bundle,omitempty"`
+	RequireLog     bool     `json:"require_log,omitempty" cbor:"require_log,omitempty"`
+	MinLogs        int      `json:"min_logs,omitempty" cbor:"min_logs,omitempty"`
+	MaxSTHAgeMS    int64    `json:"max_sth_age_ms,omitempty" cbor:"max_sth_age_ms,omitempty"`
+	Tenant         string   `json:"tenant,omitempty" cbor:"tenant,omitempty"`
+	RequiredChecks []string `json:"required_checks,omitempty" cbor:"required_checks,omitempty"`
+	FailOn         []string `json:"fail_on,omitempty" cbor:"fail_on,omitempty"`
+	DisabledChecks []string `json:"disabled_checks,omitempty" cbor:"disabled_checks,omitempty"`
+}
+
+// ReportCheck is one check's outcome in a report
+type ReportCheck struct {
+	Name         string   `json:"name" cbor:"name"`
+	Passed       bool     `json:"passed" cbor:"passed"`
+	Skipped      string   `json:"skipped,omitempty" cbor:"skipped,omitempty"`
+	Cached       bool     `json:"cached,omitempty" cbor:"cached,omitempty"`
+	DurationUS   int64    `json:"duration_us" cbor:"duration_us"`
+	Errors       []string `json:"errors,omitempty" cbor:"errors,omitempty"`
+	Warnings     []string `json:"warnings,omitempty" cbor:"warnings,omitempty"`
+	ErrorCodes   []string `json:"error_codes,omitempty" cbor:"error_codes,omitempty"`
+	WarningCodes []string `json:"warning_codes,omitempty" cbor:"warning_codes,omitempty"`
+}
+
+// NewVerificationReport builds the unsigned report of a VerifyReceipt call
+// from its receipt, options and result
+func NewVerificationReport(receipt *Receipt, options VerifyOptions, result *VerificationResult) (*VerificationReport, error) {
+	if result == nil {
+		return nil, fmt.Errorf("verification result required")
+	}
+	receiptHash, err := ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerificationReport{
+		Version:          VerificationReportVersion,
+		ReceiptHash:      base64.StdEncoding.EncodeToString(receiptHash),
+		ReceiptTimestamp: receipt.Timestamp,
+		VerifiedAt:       result.VerifiedAt,
+		Policy: ReportPolicy{
+			Profile:        result.Profile,
+			PolicyVersion:  options.PolicyVersion,
+			RequireLog:     options.RequireLog,
+			MinLogs:        options.MinLogs,
+			MaxSTHAgeMS:    options.MaxSTHAge.Milliseconds(),
+			Tenant:         options.Tenant,
+			RequiredChecks: options.RequiredChecks,
+			FailOn:         options.FailOn,
+			DisabledChecks: options.DisabledChecks,
+		},
+		Valid:      result.Valid,
+		Errors:     result.Errors,
+		ErrorCodes: result.ErrorCodes,
+	}
+	if options.TrustBundle != nil {
+		report.Policy.TrustBundle = options.TrustBundle.KeyID
+	}
+	for _, check := range result.Checks {
+		report.Checks = append(report.Checks, ReportCheck{
+			Name:         check.Name,
+			Passed:       check.Passed(),
+			Skipped:      check.Skipped,
+			Cached:       check.Cached,
+			DurationUS:   check.Duration.Microseconds(),
+			Errors:       check.Errors,
+			Warnings:     check.Warnings,
+			ErrorCodes:   check.ErrorCodes,
+			WarningCodes: check.WarningCodes,
+		})
+	}
+	return report, nil
+}
+
+// SignReport signs a report with the client's key, recording the key as
+// the report's verifier
+func (c *Client) SignReport(report *VerificationReport) error {
+	if c.privateKey == nil {
+		return fmt.Errorf("private key required for report signing")
+	}
+	report.Verifier = base64.StdEncoding.EncodeToString(c.privateKey.Public().(ed25519.PublicKey))
+	payload, err := report.signingPayload()
+	if err != nil {
+		return err
+	}
+	report.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(c.privateKey, payload))
+	return nil
+}
+
+// VerifyReport checks a signed report's signature and, when receipt is
+// non-nil, that it refers to that receipt
+func VerifyReport(report *VerificationReport, receipt *Receipt) error {
+	if report.Version != VerificationReportVersion {
+		return fmt.Errorf("invalid report version: %s", report.Version)
+	}
+
+	if receipt != nil {
+		receiptHash, err := ReceiptHash(receipt)
+		if err != nil {
+			return err
+		}
+		claimed, err := base64.StdEncoding.DecodeString(report.ReceiptHash)
+		if err != nil || !bytes.Equal(claimed, receiptHash) {
+			return fmt.Errorf("report does not refer to this receipt")
+		}
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(report.Verifier)
+	if err != nil {
+		return fmt.Errorf("invalid verifier key encoding: %w", err)
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid verifier key size: %d", len(publicKey))
+	}
+	signature, err := base64.StdEncoding.DecodeString(report.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	payload, err := report.signingPayload()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(publicKey), payload, signature) {
+		return fmt.Errorf("report signature verification failed")
+	}
+	return nil
+}
+
+// MarshalCBOR encodes the report as canonical CBOR
+func (r *VerificationReport) MarshalCBOR() ([]byte, error) {
+	type plain VerificationReport
+	em, err := cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+	return em.Marshal((*plain)(r))
+}
+
+// ParseVerificationReport decodes a report from its JSON or CBOR encoding
+func ParseVerificationReport(data []byte) (*VerificationReport, error) {
+	var report VerificationReport
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &report); err != nil {
+			return nil, fmt.Errorf("invalid report JSON: %w", err)
+		}
+		return &report, nil
+	}
+	if err := cbor.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid report CBOR: %w", err)
+	}
+	return &report, nil
+}
+
+// signingPayload returns the canonical CBOR covered by the signature: the
+// report without its signature, normalized through JSON so that reports
+// decoded from either encoding sign alike
+func (r *VerificationReport) signingPayload() ([]byte, error) {
+	unsigned := *r
+	unsigned.Signature = ""
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	payload, err := canonicalCBOR(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,167 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// ResidencyExtension carries evidence for data-residency policies
+const ResidencyExtension = "residency"
+
+// Residency evidence sources
+const (
+	ResidencySourceProvider    = "provider"
+	ResidencySourceAttestation = "attestation"
+)
+
+const (
+	residencySigningVersion = "TECP-RESIDENCY-0.1"
+	maxResidencyDelayMS     = 5 * 60 * 1000
+)
+
+// RegionPolicies maps residency policy IDs to the jurisdiction their evidence
+// must assert. Deployments may register additional policies
+var RegionPolicies = map[string]string{
+	"eu_region": "EU",
+	"us_region": "US",
+	"uk_region": "UK",
+	"ch_region": "CH",
+	"ca_region": "CA",
+}
+
+// ResidencyEvidence is a region assertion signed by the infrastructure
+// provider, or by a service that derived it from an attestation document
+type ResidencyEvidence struct {
+	Jurisdiction      string `json:"jurisdiction"`
+	Region            string `json:"region"`
+	Provider          string `json:"provider"`
+	Source            string `json:"source"`
+	AttestationDigest string `json:"attestation_digest,omitempty"`
+	Timestamp         int64  `json:"ts"`
+	Signer            string `json:"signer"`
+	Signature         string `json:"sig"`
+}
+
+// RegionAsserter produces residency evidence bound to a receipt nonce
+type RegionAsserter interface {
+	AssertRegion(nonce string) (*ResidencyEvidence, error)
+}
+
+// StaticRegionAsserter signs a fixed region assertion with a provider key,
+// for sidecars or metadata services that know where the workload runs
+type StaticRegionAsserter struct {
+	Key          ed25519.PrivateKey
+	Jurisdiction string
+	Region       string
+	Provider     string
+}
+
+// AssertRegion signs the configured region for the given receipt nonce
+func (s *StaticRegionAsserter) AssertRegion(nonce string) (*ResidencyEvidence, error) {
+	return SignResidency(s.Key, &ResidencyEvidence{
+		Jurisdiction: s.Jurisdiction,
+		Region:       s.Region,
+		Provider:     s.Provider,
+		Source:       ResidencySourceProvider,
+	}, nonce)
+}
+
+// SignResidency completes and signs residency evidence for a receipt nonce
+func SignResidency(key ed25519.PrivateKey, evidence *ResidencyEvidence, nonce string) (*ResidencyEvidence, error) {
+	if key == nil {
+		return nil, fmt.Errorf("signing key required for residency evidence")
+	}
+
+	signed := *evidence
+	if signed.Timestamp == 0 {
+		signed.Timestamp = time.Now().UnixMilli()
+	}
+	signed.Signer = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+
+	payload, err := canonicalCBOR(residencySigningPayload(&signed, nonce))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	signed.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return &signed, nil
+}
+
+// verifyResidencyEvidence checks the residency extension against a claimed
+// jurisdiction and, when authorities are given, the signer's identity
+func verifyResidencyEvidence(receipt *Receipt, jurisdiction string, authorities []ed25519.PublicKey) error {
+	var evidence ResidencyEvidence
+	found, err := decodeExtension(receipt, ResidencyExtension, &evidence)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no residency evidence")
+	}
+
+	if evidence.Jurisdiction != jurisdiction {
+		return fmt.Errorf("evidence asserts jurisdiction %q, policy requires %q", evidence.Jurisdiction, jurisdiction)
+	}
+	switch evidence.Source {
+	case ResidencySourceProvider:
+	case ResidencySourceAttestation:
+		if evidence.AttestationDigest == "" {
+			return fmt.Errorf("attestation-derived evidence has no attestation digest")
+		}
+	default:
+		return fmt.Errorf("unknown residency evidence source: %q", evidence.Source)
+	}
+
+	delay := evidence.Timestamp - receipt.Timestamp
+	if delay < -maxResidencyDelayMS || delay > maxResidencyDelayMS {
+		return fmt.Errorf("residency evidence not fresh: %dms from issuance", delay)
+	}
+
+	signerBytes, err := base64.StdEncoding.DecodeString(evidence.Signer)
+	if err != nil || len(signerBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid residency signer key")
+	}
+	signer := ed25519.PublicKey(signerBytes)
+
+	if len(authorities) > 0 {
+		trusted := false
+		for _, authority := range authorities {
+			if authority.Equal(signer) {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			return fmt.Errorf("residency evidence signed by untrusted key")
+		}
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(evidence.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid residency signature encoding: %w", err)
+	}
+	payload, err := canonicalCBOR(residencySigningPayload(&evidence, receipt.Nonce))
+	if err != nil {
+		return fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	if !ed25519.Verify(signer, payload, signature) {
+		return fmt.Errorf("residency evidence signature invalid")
+	}
+	return nil
+}
+
+// residencySigningPayload binds residency evidence to a single receipt
+func residencySigningPayload(evidence *ResidencyEvidence, nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"version":            residencySigningVersion,
+		"jurisdiction":       evidence.Jurisdiction,
+		"region":             evidence.Region,
+		"provider":           evidence.Provider,
+		"source":             evidence.Source,
+		"attestation_digest": evidence.AttestationDigest,
+		"ts":                 evidence.Timestamp,
+		"signer":             evidence.Signer,
+		"nonce":              nonce,
+	}
+}
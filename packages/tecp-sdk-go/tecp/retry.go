@@ -0,0 +1,249 @@
+package tecp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Retry policy defaults
+const (
+	DefaultRetryAttempts       = 3
+	DefaultRetryInitialBackoff = 200 * time.Millisecond
+	DefaultRetryMaxBackoff     = 5 * time.Second
+	DefaultRetryJitter         = 0.2
+)
+
+// RetryPolicy retries failed outbound requests with exponential backoff.
+// Set in ClientOptions, it applies to every request made through the
+// client's HTTP client: log submissions and tree heads, certificate
+// authorities, JWKS and policy fetches, and any other component sharing
+// it through HTTPClientUser. Requests whose body cannot be replayed are
+// not retried, and no retry outlives the request's context or the HTTP
+// client's timeout. The zero value uses the defaults
+type RetryPolicy struct {
+	// MaxAttempts bounds the attempts per request, the first included
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry, doubling up to
+	// MaxBackoff. A Retry-After response header overrides it, within
+	// MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Jitter randomizes each wait by up to this fraction
+	Jitter float64
+
+	// RetryStatusClasses are retryable response status classes, by their
+	// first digit: 5 retries every 5xx response. Defaults to 5
+	RetryStatusClasses []int
+
+	// RetryStatuses are further retryable statuses. Defaults to 429
+	RetryStatuses []int
+
+	mu    sync.Mutex
+	stats map[string]*RetryStats
+}
+
+// RetryStats counts the retries made to one host
+type RetryStats struct {
+	Host string `json:"host"`
+
+	// Requests is the number of requests, however many attempts each took
+	Requests uint64 `json:"requests"`
+
+	// Retries is the number of attempts after the first
+	Retries uint64 `json:"retries"`
+
+	// Exhausted is the number of requests that still failed after their
+	// last attempt
+	Exhausted uint64 `json:"exhausted"`
+}
+
+// WithRetryPolicy retries outbound requests under policy
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return optionFunc(func(o *ClientOptions) { o.Retry = policy })
+}
+
+// Transport returns a RoundTripper that sends requests through base, nil
+// meaning the SDK's default, retrying them under the policy
+func (p *RetryPolicy) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = defaultTransport
+	}
+	return &retryTransport{policy: p, base: base}
+}
+
+// Stats returns the retries made to each host, by host
+func (p *RetryPolicy) Stats() []RetryStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]RetryStats, 0, len(p.stats))
+	for _, host := range p.stats {
+		stats = append(stats, *host)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
+
+// WriteMetrics writes the retry counts per host in the Prometheus text
+// exposition format
+func (p *RetryPolicy) WriteMetrics(w io.Writer) error {
+	stats := p.Stats()
+	for _, metric := range []struct {
+		name, help string
+		value      func(RetryStats) uint64
+	}{
+		{"tecp_http_requests_total", "Outbound requests, by host.", func(s RetryStats) uint64 { return s.Requests }},
+		{"tecp_http_retries_total", "Outbound request retries, by host.", func(s RetryStats) uint64 { return s.Retries }},
+		{"tecp_http_retries_exhausted_total", "Outbound requests that failed after every attempt, by host.", func(s RetryStats) uint64 { return s.Exhausted }},
+	} {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", metric.name, metric.help, metric.name); err != nil {
+			return err
+		}
+		for _, host := range stats {
+			if _, err := fmt.Fprintf(w, "%s{host=%q} %d\n", metric.name, host.Host, metric.value(host)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// wrap returns client sending requests under the policy. Clients already
+// wrapped by it are returned unchanged
+func (p *RetryPolicy) wrap(client *http.Client) *http.Client {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	if transport, ok := client.Transport.(*retryTransport); ok && transport.policy == p {
+		return client
+	}
+	wrapped := *client
+	wrapped.Transport = p.Transport(client.Transport)
+	return &wrapped
+}
+
+// retryable reports whether a response status should be retried
+func (p *RetryPolicy) retryable(status int) bool {
+	classes, statuses := p.RetryStatusClasses, p.RetryStatuses
+	if classes == nil && statuses == nil {
+		classes, statuses = []int{5}, []int{http.StatusTooManyRequests}
+	}
+	for _, class := range classes {
+		if status/100 == class {
+			return true
+		}
+	}
+	for _, retryable := range statuses {
+		if status == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before retry number attempt, from one
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	initial, max, jitter := p.InitialBackoff, p.MaxBackoff, p.Jitter
+	if initial <= 0 {
+		initial = DefaultRetryInitialBackoff
+	}
+	if max <= 0 {
+		max = DefaultRetryMaxBackoff
+	}
+	if jitter <= 0 {
+		jitter = DefaultRetryJitter
+	}
+
+	wait := time.Duration(float64(initial) * math.Pow(2, float64(attempt-1)))
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+	}
+	if wait > max || wait <= 0 {
+		wait = max
+	}
+	return time.Duration(float64(wait) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+func (p *RetryPolicy) count(host string, retries int, exhausted bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stats == nil {
+		p.stats = make(map[string]*RetryStats)
+	}
+	stats, ok := p.stats[host]
+	if !ok {
+		stats = &RetryStats{Host: host}
+		p.stats[host] = stats
+	}
+	stats.Requests++
+	stats.Retries += uint64(retries)
+	if exhausted {
+		stats.Exhausted++
+	}
+}
+
+// retryTransport retries requests under a RetryPolicy
+type retryTransport struct {
+	policy *RetryPolicy
+	base   http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := t.policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultRetryAttempts
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		retry := err == nil && t.policy.retryable(resp.StatusCode) ||
+			err != nil && !errors.Is(err, req.Context().Err())
+		if !retry || attempt >= attempts {
+			t.policy.count(req.URL.Host, attempt-1, retry)
+			return resp, err
+		}
+
+		wait := t.policy.backoff(attempt, resp)
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < wait {
+			// The deadline would expire before the next attempt
+			t.policy.count(req.URL.Host, attempt-1, true)
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			t.policy.count(req.URL.Host, attempt-1, true)
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
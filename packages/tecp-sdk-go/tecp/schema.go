@@ -0,0 +1,148 @@
+package tecp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SchemaVersion is a receipt layout version, as in "TECP-0.1"
+type SchemaVersion struct {
+	Major int
+	Minor int
+}
+
+// String returns the version as carried in receipts
+func (v SchemaVersion) String() string {
+	return fmt.Sprintf("TECP-%d.%d", v.Major, v.Minor)
+}
+
+// ParseSchemaVersion parses a receipt version such as "TECP-1.0". A patch
+// component is accepted and ignored, as patches never change the layout
+func ParseSchemaVersion(version string) (SchemaVersion, error) {
+	number, ok := strings.CutPrefix(version, "TECP-")
+	if !ok {
+		return SchemaVersion{}, fmt.Errorf("invalid version: %s", version)
+	}
+	parts := strings.Split(number, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return SchemaVersion{}, fmt.Errorf("invalid version: %s", version)
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return SchemaVersion{}, fmt.Errorf("invalid version: %s", version)
+		}
+		numbers[i] = n
+	}
+	return SchemaVersion{Major: numbers[0], Minor: numbers[1]}, nil
+}
+
+// SchemaVersion returns the receipt's declared layout version
+func (r *Receipt) SchemaVersion() (SchemaVersion, error) {
+	return ParseSchemaVersion(r.Version)
+}
+
+// Schema describes how receipts of one layout version are read and checked
+type Schema struct {
+	Version SchemaVersion
+
+	// Decode migrates a JSON receipt in this layout to a Receipt. When nil
+	// the current layout is decoded as is
+	Decode func(data []byte) (*Receipt, error)
+
+	// Validate returns the violations of this version's structural rules
+	Validate func(receipt *Receipt) []string
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = map[SchemaVersion]*Schema{
+		{0, 1}: {Version: SchemaVersion{0, 1}, Validate: validateV01},
+	}
+)
+
+// RegisterSchema adds support for a receipt layout version, replacing any
+// schema registered for it
+func RegisterSchema(schema Schema) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	schemas[schema.Version] = &schema
+}
+
+// resolveSchema returns the schema whose rules apply to a receipt version.
+// From 1.0 on, minor versions are backward compatible, so a receipt from a
+// newer minor version is checked under the newest known one of its major
+// version, with a warning. Pre-1.0 versions must match exactly
+func resolveSchema(version string) (*Schema, string, error) {
+	declared, err := ParseSchemaVersion(version)
+	if err != nil {
+		return nil, "", err
+	}
+
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	if schema, ok := schemas[declared]; ok {
+		return schema, "", nil
+	}
+	if declared.Major >= 1 {
+		var best *Schema
+		for v, schema := range schemas {
+			if v.Major == declared.Major && v.Minor < declared.Minor && (best == nil || v.Minor > best.Version.Minor) {
+				best = schema
+			}
+		}
+		if best != nil {
+			return best, fmt.Sprintf("receipt version %s checked under %s rules", declared, best.Version), nil
+		}
+	}
+	return nil, "", fmt.Errorf("invalid version: %s", version)
+}
+
+// Decode reads a receipt in any supported layout and encoding: JSON in any
+// registered schema version, or the compact encoding of EncodeCompact
+func Decode(data []byte) (*Receipt, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		return DecodeCompact(string(data))
+	}
+
+	var header struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid receipt: %w", err)
+	}
+	schema, _, err := resolveSchema(header.Version)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Decode != nil {
+		return schema.Decode(data)
+	}
+	return FromJSON(data)
+}
+
+// validateV01 checks that a TECP-0.1 receipt carries its required fields
+func validateV01(receipt *Receipt) []string {
+	var errors []string
+	for _, field := range []struct{ name, value string }{
+		{"nonce", receipt.Nonce},
+		{"input_hash", receipt.InputHash},
+		{"output_hash", receipt.OutputHash},
+		{"pubkey", receipt.PublicKey},
+		{"sig", receipt.Signature},
+	} {
+		if field.value == "" {
+			errors = append(errors, fmt.Sprintf("missing required field: %s", field.name))
+		}
+	}
+	if receipt.Timestamp <= 0 {
+		errors = append(errors, "missing required field: ts")
+	}
+	return errors
+}
@@ -0,0 +1,218 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// selfTestDuration bounds each throughput measurement of SelfTest
+const selfTestDuration = 50 * time.Millisecond
+
+// SelfTestReport is the outcome of SelfTest
+type SelfTestReport struct {
+	// SignaturesPerSecond and VerificationsPerSecond are the measured
+	// receipt signing and verification throughput
+	SignaturesPerSecond    float64 `json:"signatures_per_second"`
+	VerificationsPerSecond float64 `json:"verifications_per_second"`
+
+	// Vectors is the number of known-answer vectors checked
+	Vectors int `json:"vectors"`
+
+	// Failures describes every check that failed
+	Failures []string `json:"failures,omitempty"`
+
+	Duration time.Duration `json:"duration"`
+}
+
+// OK reports whether every check passed
+func (r *SelfTestReport) OK() bool {
+	return len(r.Failures) == 0
+}
+
+// canonicalVector is a known answer for canonicalCBOR
+type canonicalVector struct {
+	name  string
+	value map[string]interface{}
+	cbor  string
+}
+
+var canonicalVectors = []canonicalVector{
+	{
+		name:  "key order",
+		value: map[string]interface{}{"b": 1, "a": 2, "aa": 3},
+		cbor:  "a361610261620162616103",
+	},
+	{
+		name:  "nested",
+		value: map[string]interface{}{"z": []interface{}{"x", int64(-1)}, "m": map[string]interface{}{"k": true}},
+		cbor:  "a2616da1616bf5617a82617820",
+	},
+}
+
+// receiptVector is the known-answer receipt: its signing payload's canonical
+// CBOR digest and Ed25519 signature under a fixed key
+var receiptVector = struct {
+	seed, payloadHash, signature string
+}{
+	seed:        "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+	payloadHash: "fa89d7cc4ec93cca61317e4149388054dee6a7fccd5af0c0f01a7a7758f1c823",
+	signature:   "WxL4USHXn6HrOhlSsUOStGP2Xu+KfO0c5xOh/VyG+UraUuv9Cvn2hQmgDctBysxKzndJdd3xgqDHGzGQ/FC+AQ==",
+}
+
+// SelfTest checks the crypto stack: canonical CBOR against known answers,
+// receipt signing and verification against a known-answer receipt, and the
+// health of the entropy source, and measures signing and verification
+// throughput. Services can run it at startup and refuse to serve when it
+// fails. The error summarizes the failures; the report is always returned
+func SelfTest() (*SelfTestReport, error) {
+	start := time.Now()
+	report := &SelfTestReport{}
+	fail := func(format string, args ...interface{}) {
+		report.Failures = append(report.Failures, fmt.Sprintf(format, args...))
+	}
+
+	for _, vector := range canonicalVectors {
+		report.Vectors++
+		encoded, err := canonicalCBOR(vector.value)
+		if err != nil {
+			fail("canonical CBOR vector %q: %v", vector.name, err)
+		} else if hex.EncodeToString(encoded) != vector.cbor {
+			fail("canonical CBOR vector %q: got %x, want %s", vector.name, encoded, vector.cbor)
+		}
+	}
+
+	receipt, privateKey, err := selfTestReceipt()
+	report.Vectors++
+	if err != nil {
+		fail("receipt vector: %v", err)
+	} else {
+		checkReceiptVector(receipt, fail)
+		report.SignaturesPerSecond = measure(func() { signReceipt(receipt, NewEd25519Signer(privateKey)) })
+		report.VerificationsPerSecond = measure(func() { NewClient().verifySignature(receipt) })
+	}
+
+	if err := checkEntropy(); err != nil {
+		fail("entropy source: %v", err)
+	}
+
+	report.Duration = time.Since(start)
+	if !report.OK() {
+		return report, fmt.Errorf("self-test failed: %s", report.Failures[0])
+	}
+	return report, nil
+}
+
+// selfTestReceipt returns the known-answer receipt, unsigned, and its key
+func selfTestReceipt() (*Receipt, ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(receiptVector.seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	inputHash := sha256.Sum256([]byte("hello world"))
+	outputHash := sha256.Sum256([]byte("Hello, World!"))
+	receipt := &Receipt{
+		Version:    TECPVersion,
+		CodeRef:    "git:abc123def456",
+		Timestamp:  1692115200000,
+		Nonce:      base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x5a}, NonceSize)),
+		InputHash:  base64.StdEncoding.EncodeToString(inputHash[:]),
+		OutputHash: base64.StdEncoding.EncodeToString(outputHash[:]),
+		PolicyIDs:  []string{"no_retention"},
+		PublicKey:  base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)),
+	}
+	return receipt, privateKey, nil
+}
+
+// checkReceiptVector signs the known-answer receipt, compares the payload
+// digest and signature with the known answers, and checks that the
+// signature verifies and a tampered receipt does not
+func checkReceiptVector(receipt *Receipt, fail func(format string, args ...interface{})) {
+	payload, err := canonicalCBOR(signingPayload(receipt))
+	if err != nil {
+		fail("receipt vector: %v", err)
+		return
+	}
+	if hash := sha256.Sum256(payload); hex.EncodeToString(hash[:]) != receiptVector.payloadHash {
+		fail("receipt vector: signing payload digest %x, want %s", hash, receiptVector.payloadHash)
+	}
+
+	seed, _ := hex.DecodeString(receiptVector.seed)
+	if err := signReceipt(receipt, NewEd25519Signer(ed25519.NewKeyFromSeed(seed))); err != nil {
+		fail("receipt vector: %v", err)
+		return
+	}
+	if receipt.Signature != receiptVector.signature {
+		fail("receipt vector: signature %s, want %s", receipt.Signature, receiptVector.signature)
+	}
+
+	verifier := NewClient()
+	if err := verifier.verifySignature(receipt); err != nil {
+		fail("receipt vector: valid signature rejected: %v", err)
+	}
+	tampered := *receipt
+	tampered.Timestamp++
+	if verifier.verifySignature(&tampered) == nil {
+		fail("receipt vector: tampered receipt accepted")
+	}
+}
+
+// measure returns how many times per second fn runs
+func measure(fn func()) float64 {
+	start := time.Now()
+	n := 0
+	for time.Since(start) < selfTestDuration {
+		fn()
+		n++
+	}
+	return float64(n) / time.Since(start).Seconds()
+}
+
+// checkEntropy draws samples from crypto/rand and rejects a source that
+// repeats itself, is stuck, or is grossly biased
+func checkEntropy() error {
+	const samples, size = 8, 64
+	drawn := make([][]byte, samples)
+	ones := 0
+	for i := range drawn {
+		drawn[i] = make([]byte, size)
+		if _, err := rand.Read(drawn[i]); err != nil {
+			return err
+		}
+		for j := 0; j < i; j++ {
+			if bytes.Equal(drawn[i], drawn[j]) {
+				return fmt.Errorf("repeated output")
+			}
+		}
+
+		// Repetition count: 8 equal bytes in a row is a 2^-56 event
+		run := 1
+		for j := 1; j < size; j++ {
+			if drawn[i][j] == drawn[i][j-1] {
+				if run++; run >= 8 {
+					return fmt.Errorf("stuck output")
+				}
+			} else {
+				run = 1
+			}
+		}
+		for _, b := range drawn[i] {
+			ones += bits.OnesCount8(b)
+		}
+	}
+
+	// Monobit: 4096 bits should have 2048 ones, within six standard
+	// deviations
+	total := samples * size * 8
+	if deviation := ones - total/2; deviation > 192 || deviation < -192 {
+		return fmt.Errorf("biased output: %d of %d bits set", ones, total)
+	}
+	return nil
+}
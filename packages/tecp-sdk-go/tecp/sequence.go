@@ -0,0 +1,239 @@
+package tecp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// SequenceExtension carries a receipt's position in its signing key's
+// sequence, so a collected set of receipts can be checked for gaps
+const SequenceExtension = "seq"
+
+// Sequencer issues monotonic sequence numbers per signing key, starting at 1
+type Sequencer interface {
+	// Next returns the next sequence number for a base64 public key
+	Next(publicKey string) (uint64, error)
+}
+
+// MemorySequencer is a Sequencer whose counters last for the process. Use a
+// FileSequencer, or one backed by a database, when a key outlives a process
+type MemorySequencer struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// Next returns the next sequence number for publicKey
+func (s *MemorySequencer) Next(publicKey string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[string]uint64)
+	}
+	s.counters[publicKey]++
+	return s.counters[publicKey], nil
+}
+
+// FileSequencer is a Sequencer persisting its counters to a JSON file,
+// which is rewritten atomically before each number is issued, so numbers
+// are never reused across restarts
+type FileSequencer struct {
+	path string
+
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// NewFileSequencer returns a sequencer persisting to path, resuming from
+// the counters stored there
+func NewFileSequencer(path string) (*FileSequencer, error) {
+	counters := make(map[string]uint64)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &counters); err != nil {
+			return nil, fmt.Errorf("invalid sequence file %s: %w", path, err)
+		}
+	}
+	return &FileSequencer{path: path, counters: counters}, nil
+}
+
+// Next persists and returns the next sequence number for publicKey
+func (s *FileSequencer) Next(publicKey string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.counters[publicKey] + 1
+	s.counters[publicKey] = next
+	if err := s.save(); err != nil {
+		s.counters[publicKey] = next - 1
+		return 0, fmt.Errorf("failed to persist sequence number: %w", err)
+	}
+	return next, nil
+}
+
+func (s *FileSequencer) save() error {
+	data, err := json.Marshal(s.counters)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".seq-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Sequence returns the receipt's sequence number. Only a number covered by
+// the signature is returned; the signature check verifies it
+func (r *Receipt) Sequence() (uint64, bool) {
+	if !r.signsExtension(SequenceExtension) {
+		return 0, false
+	}
+	var seq uint64
+	if found, err := decodeExtension(r, SequenceExtension, &seq); err != nil || !found {
+		return 0, false
+	}
+	return seq, true
+}
+
+// verifySequence checks that any sequence number is well formed and signed
+func verifySequence(receipt *Receipt) (warnings []string, err error) {
+	var seq uint64
+	found, err := decodeExtension(receipt, SequenceExtension, &seq)
+	if err != nil || !found {
+		return nil, err
+	}
+	if seq == 0 {
+		return nil, fmt.Errorf("invalid sequence number 0")
+	}
+	if !receipt.signsExtension(SequenceExtension) {
+		warnings = append(warnings, "sequence number is not covered by the signature and is ignored")
+	}
+	return warnings, nil
+}
+
+// SequenceGap is a run of missing sequence numbers, inclusive
+type SequenceGap struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// KeySequence is the sequence coverage of one signing key's receipts
+type KeySequence struct {
+	PublicKey string `json:"pubkey"`
+
+	// First and Last are the lowest and highest sequence numbers seen
+	First uint64 `json:"first"`
+	Last  uint64 `json:"last"`
+
+	// Count is the number of distinct sequenced receipts
+	Count int `json:"count"`
+
+	// Gaps are the numbers between First and Last no receipt carries
+	Gaps []SequenceGap `json:"gaps,omitempty"`
+
+	// Duplicates are the numbers carried by more than one distinct receipt
+	Duplicates []uint64 `json:"duplicates,omitempty"`
+
+	// Unsequenced is the number of receipts without a signed sequence number
+	Unsequenced int `json:"unsequenced,omitempty"`
+}
+
+// Complete reports whether the key's receipts have no gaps, duplicates or
+// unsequenced receipts
+func (k *KeySequence) Complete() bool {
+	return len(k.Gaps) == 0 && len(k.Duplicates) == 0 && k.Unsequenced == 0
+}
+
+// SequenceReport is the outcome of CheckSequences
+type SequenceReport struct {
+	Keys []KeySequence `json:"keys"`
+}
+
+// Complete reports whether every key's receipts are complete
+func (r *SequenceReport) Complete() bool {
+	for i := range r.Keys {
+		if !r.Keys[i].Complete() {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckSequences groups receipts by signing key and reports the gaps and
+// duplicates in each key's sequence. The same receipt collected twice is
+// not a duplicate. Receipts should be verified first; only signed
+// sequence numbers are considered
+func CheckSequences(receipts []*Receipt) (*SequenceReport, error) {
+	type keyState struct {
+		seen        map[uint64]string
+		duplicates  map[uint64]bool
+		unsequenced int
+	}
+	keys := make(map[string]*keyState)
+	for _, receipt := range receipts {
+		state, ok := keys[receipt.PublicKey]
+		if !ok {
+			state = &keyState{seen: make(map[uint64]string), duplicates: make(map[uint64]bool)}
+			keys[receipt.PublicKey] = state
+		}
+		seq, ok := receipt.Sequence()
+		if !ok {
+			state.unsequenced++
+			continue
+		}
+		hash, err := ReceiptHash(receipt)
+		if err != nil {
+			return nil, err
+		}
+		if previous, ok := state.seen[seq]; ok && previous != string(hash) {
+			state.duplicates[seq] = true
+			continue
+		}
+		state.seen[seq] = string(hash)
+	}
+
+	report := &SequenceReport{}
+	for publicKey, state := range keys {
+		k := KeySequence{PublicKey: publicKey, Count: len(state.seen), Unsequenced: state.unsequenced}
+		numbers := make([]uint64, 0, len(state.seen))
+		for seq := range state.seen {
+			numbers = append(numbers, seq)
+		}
+		sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+		for i, seq := range numbers {
+			if i > 0 && seq > numbers[i-1]+1 {
+				k.Gaps = append(k.Gaps, SequenceGap{From: numbers[i-1] + 1, To: seq - 1})
+			}
+		}
+		if len(numbers) > 0 {
+			k.First, k.Last = numbers[0], numbers[len(numbers)-1]
+		}
+		for seq := range state.duplicates {
+			k.Duplicates = append(k.Duplicates, seq)
+		}
+		sort.Slice(k.Duplicates, func(i, j int) bool { return k.Duplicates[i] < k.Duplicates[j] })
+		report.Keys = append(report.Keys, k)
+	}
+	sort.Slice(report.Keys, func(i, j int) bool { return report.Keys[i].PublicKey < report.Keys[j].PublicKey })
+	return report, nil
+}
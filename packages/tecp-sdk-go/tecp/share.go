@@ -0,0 +1,226 @@
+package tecp
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ReceiptURLParam is the query parameter carrying a shared receipt
+const ReceiptURLParam = "r"
+
+// MaxCompactReceiptSize bounds the decompressed size of a shared receipt
+const MaxCompactReceiptSize = 64 * 1024
+
+// compactReceipt is the CBOR form of a shared receipt. Integer keys and raw
+// bytes for base64 fields keep the encoding small enough for a QR code
+type compactReceipt struct {
+	Version     string                 `cbor:"1,keyasint"`
+	CodeRef     string                 `cbor:"2,keyasint"`
+	Timestamp   int64                  `cbor:"3,keyasint"`
+	Nonce       interface{}            `cbor:"4,keyasint"`
+	InputHash   interface{}            `cbor:"5,keyasint"`
+	OutputHash  interface{}            `cbor:"6,keyasint"`
+	PolicyIDs   []string               `cbor:"7,keyasint"`
+	Signature   interface{}            `cbor:"8,keyasint"`
+	PublicKey   interface{}            `cbor:"9,keyasint"`
+	Extensions  map[string]interface{} `cbor:"10,keyasint,omitempty"`
+	SignedExt   *SignedExtensions      `cbor:"11,keyasint,omitempty"`
+	Algorithm   Algorithm              `cbor:"12,keyasint,omitempty"`
+	Canon       Canonicalization       `cbor:"13,keyasint,omitempty"`
+	TSPrecision int64                  `cbor:"14,keyasint,omitempty"`
+}
+
+// ToURL returns a link to baseVerifier carrying the whole receipt in the
+// ReceiptURLParam query parameter, for sharing by message or QR code
+func (r *Receipt) ToURL(baseVerifier string) (string, error) {
+	encoded, err := EncodeCompact(r)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(baseVerifier)
+	if err != nil {
+		return "", fmt.Errorf("invalid verifier URL: %w", err)
+	}
+	query := u.Query()
+	query.Set(ReceiptURLParam, encoded)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// ParseReceiptURL extracts the receipt from a link produced by ToURL
+func ParseReceiptURL(rawURL string) (*Receipt, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt URL: %w", err)
+	}
+	encoded := u.Query().Get(ReceiptURLParam)
+	if encoded == "" {
+		return nil, fmt.Errorf("URL has no %q receipt parameter", ReceiptURLParam)
+	}
+	return DecodeCompact(encoded)
+}
+
+// EncodeCompact encodes a receipt as DEFLATE-compressed CBOR in unpadded
+// base64url
+func EncodeCompact(receipt *Receipt) (string, error) {
+	encoded, err := marshalCompact(receipt)
+	if err != nil {
+		return "", err
+	}
+
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(encoded); err != nil {
+		return "", err
+	}
+	if err := fw.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(compressed.Bytes()), nil
+}
+
+// DecodeCompact decodes a receipt encoded by EncodeCompact
+func DecodeCompact(encoded string) (*Receipt, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compact receipt encoding: %w", err)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed)), MaxCompactReceiptSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compact receipt compression: %w", err)
+	}
+	if len(data) > MaxCompactReceiptSize {
+		return nil, fmt.Errorf("compact receipt exceeds %d bytes", MaxCompactReceiptSize)
+	}
+	return unmarshalCompact(data)
+}
+
+// marshalCompact encodes a receipt as canonical CBOR in its compact form
+func marshalCompact(receipt *Receipt) ([]byte, error) {
+	compact := compactReceipt{
+		Version:     receipt.Version,
+		CodeRef:     receipt.CodeRef,
+		Timestamp:   receipt.Timestamp,
+		Nonce:       packBase64(receipt.Nonce),
+		InputHash:   packBase64(receipt.InputHash),
+		OutputHash:  packBase64(receipt.OutputHash),
+		PolicyIDs:   receipt.PolicyIDs,
+		Signature:   packBase64(receipt.Signature),
+		PublicKey:   packBase64(receipt.PublicKey),
+		SignedExt:   receipt.SignedExt,
+		Algorithm:   receipt.Algorithm,
+		Canon:       receipt.Canonicalization,
+		TSPrecision: receipt.TimestampPrecision,
+	}
+
+	// Typed extension values are shared in their JSON form, as a JSON round
+	// trip would leave them
+	if len(receipt.Extensions) > 0 {
+		data, err := json.Marshal(receipt.Extensions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode extensions: %w", err)
+		}
+		if err := json.Unmarshal(data, &compact.Extensions); err != nil {
+			return nil, fmt.Errorf("failed to encode extensions: %w", err)
+		}
+	}
+
+	em, err := cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := em.Marshal(compact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode receipt: %w", err)
+	}
+	return encoded, nil
+}
+
+// unmarshalCompact decodes a receipt encoded by marshalCompact
+func unmarshalCompact(data []byte) (*Receipt, error) {
+	dm, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	if err != nil {
+		return nil, err
+	}
+	var compact compactReceipt
+	if err := dm.Unmarshal(data, &compact); err != nil {
+		return nil, fmt.Errorf("invalid compact receipt: %w", err)
+	}
+
+	receipt := &Receipt{
+		Version:            compact.Version,
+		CodeRef:            compact.CodeRef,
+		Timestamp:          compact.Timestamp,
+		PolicyIDs:          compact.PolicyIDs,
+		SignedExt:          compact.SignedExt,
+		Algorithm:          compact.Algorithm,
+		Canonicalization:   compact.Canon,
+		TimestampPrecision: compact.TSPrecision,
+	}
+	fields := []struct {
+		value  interface{}
+		target *string
+	}{
+		{compact.Nonce, &receipt.Nonce},
+		{compact.InputHash, &receipt.InputHash},
+		{compact.OutputHash, &receipt.OutputHash},
+		{compact.Signature, &receipt.Signature},
+		{compact.PublicKey, &receipt.PublicKey},
+	}
+	for _, f := range fields {
+		if *f.target, err = unpackBase64(f.value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Normalize extensions to what JSON decoding would produce
+	if len(compact.Extensions) > 0 {
+		data, err := json.Marshal(compact.Extensions)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compact receipt extensions: %w", err)
+		}
+		if err := json.Unmarshal(data, &receipt.Extensions); err != nil {
+			return nil, fmt.Errorf("invalid compact receipt extensions: %w", err)
+		}
+	}
+
+	return receipt, nil
+}
+
+// packBase64 returns the raw bytes of a standard base64 value, or the value
+// itself when it would not round-trip exactly
+func packBase64(value string) interface{} {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || base64.StdEncoding.EncodeToString(raw) != value {
+		return value
+	}
+	return raw
+}
+
+// unpackBase64 reverses packBase64
+func unpackBase64(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("invalid compact receipt field type %T", value)
+	}
+}
@@ -0,0 +1,100 @@
+package tecp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// SignedExtensions commits the receipt signature to a subset of its
+// extensions: Hash is the base64 SHA-256 of the canonical CBOR of the named
+// extensions, in their JSON form. Critical lists the signed extensions a
+// verifier must understand to accept the receipt
+type SignedExtensions struct {
+	Names    []string `json:"names" cbor:"names"`
+	Hash     string   `json:"hash" cbor:"hash"`
+	Critical []string `json:"critical,omitempty" cbor:"critical,omitempty"`
+}
+
+// signingMap returns the form covered by the receipt signature
+func (s *SignedExtensions) signingMap() map[string]interface{} {
+	names := make([]interface{}, len(s.Names))
+	for i, name := range s.Names {
+		names[i] = name
+	}
+	signing := map[string]interface{}{"names": names, "hash": s.Hash}
+	if len(s.Critical) > 0 {
+		critical := make([]interface{}, len(s.Critical))
+		for i, name := range s.Critical {
+			critical[i] = name
+		}
+		signing["critical"] = critical
+	}
+	return signing
+}
+
+// signExtensions commits to the named extensions, which must be present
+func signExtensions(receipt *Receipt, names []string) (*SignedExtensions, error) {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	for i, name := range sorted {
+		if i > 0 && sorted[i-1] == name {
+			return nil, fmt.Errorf("extension %s listed twice for signing", name)
+		}
+		if _, ok := receipt.Extensions[name]; !ok {
+			return nil, fmt.Errorf("extension %s to sign is not present", name)
+		}
+	}
+	hash, err := extensionsHash(receipt, sorted)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedExtensions{Names: sorted, Hash: hash}, nil
+}
+
+// verifySignedExtensions checks that the signed extensions were neither
+// stripped nor altered
+func verifySignedExtensions(receipt *Receipt) error {
+	if receipt.SignedExt == nil {
+		return nil
+	}
+	for _, name := range receipt.SignedExt.Names {
+		if _, ok := receipt.Extensions[name]; !ok {
+			return fmt.Errorf("signed extension %s is missing", name)
+		}
+	}
+	hash, err := extensionsHash(receipt, receipt.SignedExt.Names)
+	if err != nil {
+		return err
+	}
+	if hash != receipt.SignedExt.Hash {
+		return fmt.Errorf("signed extensions do not match their hash")
+	}
+	return nil
+}
+
+// extensionsHash hashes the named extensions. Values are normalized through
+// JSON, so typed values on fresh receipts and the generic maps of decoded
+// receipts hash alike. They are encoded under the receipt's canonicalization
+func extensionsHash(receipt *Receipt, names []string) (string, error) {
+	subset := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		subset[name] = receipt.Extensions[name]
+	}
+	data, err := json.Marshal(subset)
+	if err != nil {
+		return "", fmt.Errorf("invalid signed extension: %w", err)
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return "", fmt.Errorf("invalid signed extension: %w", err)
+	}
+	encoded, err := canonicalPayload(receipt, normalized)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(encoded)
+	return base64.StdEncoding.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,236 @@
+package tecp
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SRTExtension carries the log promises (signed receipt timestamps) embedded
+// in a receipt
+const SRTExtension = "srt"
+
+const srtSigningVersion = "TECP-SRT-0.1"
+
+// SignedReceiptTimestamp is a transparency log's signed promise to include an
+// entry in its tree within the maximum merge delay (MMD)
+type SignedReceiptTimestamp struct {
+	// Entry is the hex receipt hash the log accepted
+	Entry     string `json:"entry"`
+	Timestamp int64  `json:"timestamp"`
+
+	// MMD is the maximum merge delay in milliseconds
+	MMD       int64  `json:"mmd"`
+	KeyID     string `json:"kid"`
+	Signature string `json:"sig"`
+
+	// Log is the URL of the log that issued the promise. It is not signed
+	// and only tells verifiers where to check inclusion
+	Log string `json:"log,omitempty"`
+}
+
+// Deadline returns the time by which the log promised to include the entry
+func (s *SignedReceiptTimestamp) Deadline() time.Time {
+	return time.UnixMilli(s.Timestamp + s.MMD)
+}
+
+// SigningPayload returns the bytes covered by the promise signature
+func (s *SignedReceiptTimestamp) SigningPayload() []byte {
+	payload, _ := json.Marshal(struct {
+		Version   string `json:"v"`
+		Entry     string `json:"entry"`
+		Timestamp int64  `json:"timestamp"`
+		MMD       int64  `json:"mmd"`
+		KeyID     string `json:"kid"`
+	}{srtSigningVersion, s.Entry, s.Timestamp, s.MMD, s.KeyID})
+	return payload
+}
+
+// SignReceiptTimestamp signs a promise with a log key
+func SignReceiptTimestamp(srt *SignedReceiptTimestamp, key ed25519.PrivateKey) {
+	srt.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, srt.SigningPayload()))
+}
+
+// VerifyReceiptTimestamp checks a promise's signature against a log key
+func VerifyReceiptTimestamp(srt *SignedReceiptTimestamp, publicKey ed25519.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(srt.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid SRT signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, srt.SigningPayload(), signature) {
+		return fmt.Errorf("SRT signature verification failed")
+	}
+	return nil
+}
+
+// LogPromiser submits receipt hashes to a transparency log and returns its
+// promise to include them
+type LogPromiser interface {
+	Promise(entry []byte) (*SignedReceiptTimestamp, error)
+}
+
+// ReceiptPromiser is implemented by LogPromisers that can submit the
+// receipt itself, for logs that validate submissions before accepting them.
+// Issuers use it in preference to Promise
+type ReceiptPromiser interface {
+	PromiseReceipt(receipt *Receipt) (*SignedReceiptTimestamp, error)
+}
+
+// InclusionChecker reports whether a log has included an entry, after
+// verifying the log's inclusion proof and tree head
+type InclusionChecker interface {
+	CheckInclusion(entry []byte) (bool, error)
+}
+
+// TrustedLog is a transparency log whose promises a verifier accepts
+type TrustedLog struct {
+	KeyID     string
+	PublicKey ed25519.PublicKey
+
+	// Inclusion, when set, confirms that promises past their MMD were kept
+	Inclusion InclusionChecker
+
+	// TreeHeads, when set, supplies fresh tree heads for embedded inclusion
+	// proofs older than VerifyOptions.MaxSTHAge
+	TreeHeads TreeHeadSource
+}
+
+// EmbedSRT adds a log promise to a receipt. Extensions are not covered by the
+// receipt signature, so promises can be added after signing
+func EmbedSRT(receipt *Receipt, srt *SignedReceiptTimestamp) error {
+	srts, err := receiptSRTs(receipt)
+	if err != nil {
+		return err
+	}
+	if receipt.Extensions == nil {
+		receipt.Extensions = make(map[string]interface{})
+	}
+	receipt.Extensions[SRTExtension] = append(srts, *srt)
+	return nil
+}
+
+// receiptSRTs returns the promises embedded in a receipt
+func receiptSRTs(receipt *Receipt) ([]SignedReceiptTimestamp, error) {
+	var srts []SignedReceiptTimestamp
+	if _, err := decodeExtension(receipt, SRTExtension, &srts); err != nil {
+		return nil, err
+	}
+	return srts, nil
+}
+
+// verifyLogPromises checks the receipt's promises and inclusion proofs from
+// trusted logs and, for promises past their MMD with no embedded proof, that
+// the log kept them. RequireLog and MinLogs count the distinct trusted logs
+// vouching for the receipt. It runs on every verification since its outcome
+// depends on the current time. Promises and proofs postdating a VerifyAt
+// instant are ignored
+func verifyLogPromises(v *Verification) (errors []string, warnings []codedWarning) {
+	receipt, options, now := v.Receipt, v.Options, v.Now
+	required := options.MinLogs
+	if required == 0 && options.RequireLog {
+		required = 1
+	}
+
+	srts, err := receiptSRTs(receipt)
+	if err != nil {
+		return []string{fmt.Sprintf("log promises invalid: %v", err)}, nil
+	}
+	proofs, err := receiptInclusionProofs(receipt)
+	if err != nil {
+		return []string{fmt.Sprintf("inclusion proofs invalid: %v", err)}, nil
+	}
+	switch {
+	case len(srts) == 0 && len(proofs) == 0 && required > 0:
+		return []string{"receipt has no transparency log promise"}, nil
+	case len(options.Logs) == 0 && required > 0:
+		return []string{"transparency log verification requires trusted logs"}, nil
+	case len(srts) == 0 && len(proofs) == 0 || len(options.Logs) == 0:
+		return nil, nil
+	}
+
+	hash, err := ReceiptHash(receipt)
+	if err != nil {
+		return []string{fmt.Sprintf("log promises invalid: %v", err)}, nil
+	}
+	entry := hex.EncodeToString(hash)
+
+	trusted := make(map[string]TrustedLog, len(options.Logs))
+	for _, log := range options.Logs {
+		trusted[log.KeyID] = log
+	}
+
+	// vouching are the trusted logs with a valid promise or proof
+	vouching := make(map[string]bool)
+	included := make(map[string]bool)
+	for _, proof := range proofs {
+		log, ok := trusted[proof.STH.KeyID]
+		if !ok {
+			warnings = append(warnings, codedWarning{WarningLogUntrusted, fmt.Sprintf("inclusion proof from untrusted log %q ignored", proof.STH.KeyID)})
+			continue
+		}
+		if err := proof.Verify(hash, log.PublicKey); err != nil {
+			errors = append(errors, fmt.Sprintf("inclusion proof from %q invalid: %v", proof.STH.KeyID, err))
+			continue
+		}
+		if v.Postdates(time.UnixMilli(proof.STH.Timestamp)) {
+			warnings = append(warnings, codedWarning{WarningLogUnchecked, fmt.Sprintf("inclusion proof from %q ignored: its tree head postdates %s", log.KeyID, now.UTC().Format(time.RFC3339))})
+			continue
+		}
+		if options.MaxSTHAge > 0 && now.Sub(time.UnixMilli(proof.STH.Timestamp)) > options.MaxSTHAge {
+			if err := refreshTreeHead(log, &proof.STH, options.MaxSTHAge, now, v.historical); err != nil {
+				if err == errLogFork {
+					errors = append(errors, fmt.Sprintf("inclusion proof from %q: %v", log.KeyID, err))
+				} else {
+					warnings = append(warnings, codedWarning{WarningLogUnchecked, fmt.Sprintf("inclusion proof from %q ignored: %v", log.KeyID, err)})
+				}
+				continue
+			}
+		}
+		vouching[log.KeyID] = true
+		included[log.KeyID] = true
+	}
+
+	for _, srt := range srts {
+		log, ok := trusted[srt.KeyID]
+		if !ok {
+			warnings = append(warnings, codedWarning{WarningLogUntrusted, fmt.Sprintf("log promise from untrusted log %q ignored", srt.KeyID)})
+			continue
+		}
+		if srt.Entry != entry {
+			errors = append(errors, fmt.Sprintf("log promise from %q is for a different receipt", srt.KeyID))
+			continue
+		}
+		if err := VerifyReceiptTimestamp(&srt, log.PublicKey); err != nil {
+			errors = append(errors, fmt.Sprintf("log promise from %q invalid: %v", srt.KeyID, err))
+			continue
+		}
+		if v.Postdates(time.UnixMilli(srt.Timestamp)) {
+			warnings = append(warnings, codedWarning{WarningLogUnchecked, fmt.Sprintf("log promise from %q ignored: it postdates %s", srt.KeyID, now.UTC().Format(time.RFC3339))})
+			continue
+		}
+		vouching[log.KeyID] = true
+
+		// Inclusion is only owed once the merge delay has passed
+		if included[log.KeyID] || log.Inclusion == nil || now.Before(srt.Deadline()) {
+			continue
+		}
+		ok, err := log.Inclusion.CheckInclusion(hash)
+		switch {
+		case err != nil:
+			warnings = append(warnings, codedWarning{WarningLogUnchecked, fmt.Sprintf("could not check inclusion in log %q: %v", srt.KeyID, err)})
+		case !ok:
+			errors = append(errors, fmt.Sprintf("log %q did not include receipt within its MMD", srt.KeyID))
+		}
+	}
+
+	switch {
+	case required == 1 && len(vouching) == 0:
+		errors = append(errors, "receipt has no valid promise from a trusted log")
+	case len(vouching) < required:
+		errors = append(errors, fmt.Sprintf("receipt is vouched for by %d trusted logs, %d required", len(vouching), required))
+	}
+	return errors, warnings
+}
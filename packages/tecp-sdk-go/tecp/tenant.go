@@ -0,0 +1,156 @@
+package tecp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TenantExtension names the customer a receipt was issued for, so one
+// issuing service can keep each tenant's receipts apart
+const TenantExtension = "tenant"
+
+// LabelsExtension carries key=value metadata set at issuance
+const LabelsExtension = "labels"
+
+// maxTenantLength bounds tenant IDs and label keys and values
+const maxTenantLength = 256
+
+// Tenant returns the receipt's tenant. Only a tenant covered by the
+// signature is returned; the signature check verifies it
+func (r *Receipt) Tenant() string {
+	if !r.signsExtension(TenantExtension) {
+		return ""
+	}
+	var tenant string
+	if _, err := decodeExtension(r, TenantExtension, &tenant); err != nil {
+		return ""
+	}
+	return tenant
+}
+
+// Labels returns the receipt's labels. Only labels covered by the signature
+// are returned; the signature check verifies them
+func (r *Receipt) Labels() map[string]string {
+	if !r.signsExtension(LabelsExtension) {
+		return nil
+	}
+	var labels map[string]string
+	if _, err := decodeExtension(r, LabelsExtension, &labels); err != nil {
+		return nil
+	}
+	return labels
+}
+
+// HasLabels reports whether the receipt carries every label in selector
+func (r *Receipt) HasLabels(selector map[string]string) bool {
+	if len(selector) == 0 {
+		return true
+	}
+	labels := r.Labels()
+	for key, value := range selector {
+		if got, ok := labels[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatLabels renders labels as sorted, comma-separated key=value pairs
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (r *Receipt) signsExtension(name string) bool {
+	return r.SignedExt != nil && containsPolicy(r.SignedExt.Names, name)
+}
+
+// attachTenancy adds the tenant and labels to a receipt, returning the
+// extension names to sign
+func attachTenancy(receipt *Receipt, tenant string, labels map[string]string) ([]string, error) {
+	var names []string
+	if tenant != "" {
+		if err := validateTenant(tenant); err != nil {
+			return nil, err
+		}
+		receipt.Extensions[TenantExtension] = tenant
+		names = append(names, TenantExtension)
+	}
+	if len(labels) > 0 {
+		if err := validateLabels(labels); err != nil {
+			return nil, err
+		}
+		copied := make(map[string]string, len(labels))
+		for key, value := range labels {
+			copied[key] = value
+		}
+		receipt.Extensions[LabelsExtension] = copied
+		names = append(names, LabelsExtension)
+	}
+	return names, nil
+}
+
+// verifyTenancy checks that any tenant and labels are well formed and
+// signed, and that the receipt belongs to the required tenant
+func verifyTenancy(receipt *Receipt, required string) (warnings []string, err error) {
+	var tenant string
+	found, err := decodeExtension(receipt, TenantExtension, &tenant)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if err := validateTenant(tenant); err != nil {
+			return nil, err
+		}
+		if !receipt.signsExtension(TenantExtension) {
+			warnings = append(warnings, "tenant is not covered by the signature and is ignored")
+		}
+	}
+
+	var labels map[string]string
+	found, err = decodeExtension(receipt, LabelsExtension, &labels)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if err := validateLabels(labels); err != nil {
+			return nil, err
+		}
+		if !receipt.signsExtension(LabelsExtension) {
+			warnings = append(warnings, "labels are not covered by the signature and are ignored")
+		}
+	}
+
+	if required != "" && receipt.Tenant() != required {
+		return warnings, fmt.Errorf("receipt was not issued for tenant %q", required)
+	}
+	return warnings, nil
+}
+
+func validateTenant(tenant string) error {
+	if tenant == "" || len(tenant) > maxTenantLength {
+		return fmt.Errorf("invalid tenant: must be 1-%d bytes", maxTenantLength)
+	}
+	return nil
+}
+
+// validateLabels requires non-empty keys, and keys and values that can be
+// rendered unambiguously as key=value pairs
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		switch {
+		case key == "" || len(key) > maxTenantLength || len(value) > maxTenantLength:
+			return fmt.Errorf("invalid label %q: keys must be 1-%d bytes and values at most %d", key, maxTenantLength, maxTenantLength)
+		case strings.ContainsAny(key, "=,"):
+			return fmt.Errorf("invalid label %q: keys cannot contain '=' or ','", key)
+		case strings.Contains(value, ","):
+			return fmt.Errorf("invalid label %q: values cannot contain ','", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package tecp
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxTimestampPrecision is the coarsest timestamp precision verifiers
+// accept
+const MaxTimestampPrecision = 24 * time.Hour
+
+// IssuanceWindow returns the interval the receipt was issued in: its
+// timestamp and, for receipts issued with a coarse timestamp precision,
+// the end of the precision interval. Exact timestamps return an empty
+// interval
+func (r *Receipt) IssuanceWindow() (from, to time.Time) {
+	from = time.UnixMilli(r.Timestamp)
+	return from, from.Add(time.Duration(r.TimestampPrecision) * time.Millisecond)
+}
+
+// roundTimestamp rounds a Unix millisecond timestamp down to precision,
+// returning it with the precision in milliseconds to record. Precisions
+// below a millisecond leave the timestamp exact
+func roundTimestamp(timestamp int64, precision time.Duration) (int64, int64, error) {
+	ms := precision.Milliseconds()
+	switch {
+	case ms <= 1:
+		return timestamp, 0, nil
+	case precision > MaxTimestampPrecision:
+		return 0, 0, fmt.Errorf("timestamp precision %s exceeds %s", precision, MaxTimestampPrecision)
+	}
+	return timestamp - timestamp%ms, ms, nil
+}
+
+// checkTimestampPrecision validates a receipt's timestamp precision
+func checkTimestampPrecision(receipt *Receipt) error {
+	precision := receipt.TimestampPrecision
+	switch {
+	case precision == 0:
+		return nil
+	case precision < 0 || time.Duration(precision)*time.Millisecond > MaxTimestampPrecision:
+		return fmt.Errorf("invalid timestamp precision %dms", precision)
+	case receipt.Timestamp%precision != 0:
+		return fmt.Errorf("timestamp %d is not a multiple of its precision %dms", receipt.Timestamp, precision)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TransformExtension records, under the signature, how the input was
+// normalized before it was hashed
+const TransformExtension = "transform"
+
+// Transform identifies a pre-hash input normalization: one or more steps
+// separated by "|", applied in order. Issuers hash the transformed input
+// and record the transform, and verifiers apply the same transform before
+// comparing, so the input hash survives innocuous differences such as
+// credentials, formatting or volatile fields
+type Transform string
+
+// Transform steps
+const (
+	// TransformStripAuth removes credential headers (Authorization,
+	// Proxy-Authorization, Cookie and X-Api-Key) from an HTTP/1.x message,
+	// as written by httputil.DumpRequest
+	TransformStripAuth Transform = "strip-auth"
+
+	// TransformWhitespace collapses every run of whitespace into a single
+	// space and trims the ends
+	TransformWhitespace Transform = "whitespace"
+
+	// TransformJCS canonicalizes JSON per RFC 8785
+	TransformJCS Transform = "jcs"
+)
+
+const dropPrefix = "drop:"
+
+// credentialHeaders are the headers TransformStripAuth removes
+var credentialHeaders = []string{"authorization", "proxy-authorization", "cookie", "x-api-key"}
+
+// DropFields removes the members at the given JSON Pointers (RFC 6901)
+// from a JSON input, which is then canonicalized as by TransformJCS.
+// Pointers missing from the input are ignored, and must not contain "," or
+// "|"
+func DropFields(pointers ...string) Transform {
+	return Transform(dropPrefix + strings.Join(pointers, ","))
+}
+
+// Transforms chains transforms, applied in order
+func Transforms(transforms ...Transform) Transform {
+	steps := make([]string, 0, len(transforms))
+	for _, t := range transforms {
+		if t != "" {
+			steps = append(steps, string(t))
+		}
+	}
+	return Transform(strings.Join(steps, "|"))
+}
+
+// Steps returns the transform's steps
+func (t Transform) Steps() []Transform {
+	if t == "" {
+		return nil
+	}
+	fields := strings.Split(string(t), "|")
+	steps := make([]Transform, len(fields))
+	for i, field := range fields {
+		steps[i] = Transform(field)
+	}
+	return steps
+}
+
+// Validate checks the transform's form. Unknown steps are invalid, since a
+// verifier could not reproduce them
+func (t Transform) Validate() error {
+	if t == "" {
+		return fmt.Errorf("empty transform")
+	}
+	for _, step := range t.Steps() {
+		switch {
+		case step == TransformStripAuth, step == TransformWhitespace, step == TransformJCS:
+		case strings.HasPrefix(string(step), dropPrefix):
+			for _, pointer := range strings.Split(strings.TrimPrefix(string(step), dropPrefix), ",") {
+				if !strings.HasPrefix(pointer, "/") {
+					return fmt.Errorf("invalid transform %q: %q is not a JSON Pointer to a member", t, pointer)
+				}
+			}
+		default:
+			return fmt.Errorf("invalid transform %q: unknown step %q", t, step)
+		}
+	}
+	return nil
+}
+
+// Apply transforms data
+func (t Transform) Apply(data []byte) ([]byte, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	for _, step := range t.Steps() {
+		var err error
+		switch {
+		case step == TransformStripAuth:
+			data = stripCredentialHeaders(data)
+		case step == TransformWhitespace:
+			data = bytes.Join(bytes.Fields(data), []byte{' '})
+		case step == TransformJCS:
+			data, err = transformJSON(data, nil)
+		default:
+			data, err = transformJSON(data, strings.Split(strings.TrimPrefix(string(step), dropPrefix), ","))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("transform %s: %w", step, err)
+		}
+	}
+	return data, nil
+}
+
+// stripCredentialHeaders removes credential header lines from the header
+// section of an HTTP/1.x message
+func stripCredentialHeaders(data []byte) []byte {
+	var out bytes.Buffer
+	headers := true
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		data = data[len(line):]
+		if headers {
+			if len(bytes.TrimRight(line, "\r\n")) == 0 {
+				headers = false
+			} else if name, _, ok := bytes.Cut(line, []byte{':'}); ok && containsPolicy(credentialHeaders, strings.ToLower(strings.TrimSpace(string(name)))) {
+				continue
+			}
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
+
+// transformJSON drops the members at pointers from a JSON document and
+// canonicalizes it
+func transformJSON(data []byte, pointers []string) ([]byte, error) {
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("input is not JSON: %w", err)
+	}
+	for _, pointer := range pointers {
+		document = dropPointer(document, strings.Split(pointer, "/")[1:])
+	}
+	return canonicalJSON(document)
+}
+
+// dropPointer removes the value at the JSON Pointer reference tokens path
+func dropPointer(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	token := strings.NewReplacer("~1", "/", "~0", "~").Replace(path[0])
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(v, token)
+		} else if child, ok := v[token]; ok {
+			v[token] = dropPointer(child, path[1:])
+		}
+	case []interface{}:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(v) {
+			return v
+		}
+		if len(path) == 1 {
+			return append(v[:i:i], v[i+1:]...)
+		}
+		v[i] = dropPointer(v[i], path[1:])
+	}
+	return value
+}
+
+// Transform returns the transform the receipt's input was normalized with.
+// Only a transform covered by the signature is returned; the signature
+// check verifies it
+func (r *Receipt) Transform() (Transform, bool) {
+	if !r.signsExtension(TransformExtension) {
+		return "", false
+	}
+	var transform Transform
+	if found, err := decodeExtension(r, TransformExtension, &transform); err != nil || !found {
+		return "", false
+	}
+	return transform, true
+}
+
+// MatchesInput reports whether input hashes to the receipt's input hash
+// once normalized with the receipt's signed transform, if any. Salted and
+// keyed commitments are opened with OpenInput instead
+func (r *Receipt) MatchesInput(input []byte) (bool, error) {
+	if r.commitment() != "" {
+		return false, fmt.Errorf("receipt input hash is a commitment under %s; open it with OpenInput", r.commitment())
+	}
+	if transform, ok := r.Transform(); ok {
+		var err error
+		if input, err = transform.Apply(input); err != nil {
+			return false, err
+		}
+	}
+	hash := sha256.Sum256(input)
+	return r.canonicalBinaryFields()["input_hash"] == base64.StdEncoding.EncodeToString(hash[:]), nil
+}
+
+// verifyTransform checks that any transform is well formed and signed
+func verifyTransform(receipt *Receipt) (warnings []string, err error) {
+	var transform Transform
+	found, err := decodeExtension(receipt, TransformExtension, &transform)
+	if err != nil || !found {
+		return nil, err
+	}
+	if err := transform.Validate(); err != nil {
+		return nil, err
+	}
+	if !receipt.signsExtension(TransformExtension) {
+		warnings = append(warnings, "input transform is not covered by the signature and is ignored")
+	}
+	return warnings, nil
+}
@@ -0,0 +1,95 @@
+package tecp
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultHTTPTimeout bounds each outbound request made by a client built
+// with NewHTTPClient
+const DefaultHTTPTimeout = 10 * time.Second
+
+// defaultTransport is http.DefaultTransport with connection setup bounded
+// well inside DefaultHTTPTimeout
+var defaultTransport = &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: DefaultHTTPTimeout,
+	ExpectContinueTimeout: time.Second,
+}
+
+var defaultHTTPClient = NewHTTPClient(nil)
+
+// NewHTTPClient returns an HTTP client with the SDK's default timeouts
+// sending requests through transport, such as a proxying, tracing or
+// custom TLS RoundTripper. A nil transport uses the SDK's default
+func NewHTTPClient(transport http.RoundTripper) *http.Client {
+	if transport == nil {
+		transport = defaultTransport
+	}
+	return &http.Client{Transport: transport, Timeout: DefaultHTTPTimeout}
+}
+
+// DefaultHTTPClient returns the shared client used for outbound requests
+// when none is configured
+func DefaultHTTPClient() *http.Client {
+	return defaultHTTPClient
+}
+
+// HTTPClientUser is implemented by components that make outbound requests,
+// such as transparency log clients, certificate authorities and policy
+// resolvers. UseHTTPClient adopts client unless the component was
+// configured with its own
+type HTTPClientUser interface {
+	UseHTTPClient(client *http.Client)
+}
+
+// WithHTTPClient sends every outbound request, to logs, certificate
+// authorities, policy publishers and other services, through client
+func WithHTTPClient(client *http.Client) Option {
+	return optionFunc(func(o *ClientOptions) { o.HTTPClient = client })
+}
+
+// WithTransport sends every outbound request through transport, with the
+// SDK's default timeouts
+func WithTransport(transport http.RoundTripper) Option {
+	return optionFunc(func(o *ClientOptions) { o.HTTPClient = NewHTTPClient(transport) })
+}
+
+// HTTPClient returns the client's HTTP client, for configuring components
+// such as issuer resolvers to share it
+func (c *Client) HTTPClient() *http.Client {
+	if c.options.HTTPClient != nil {
+		return c.options.HTTPClient
+	}
+	return defaultHTTPClient
+}
+
+// shareHTTPClient passes the configured HTTP client to the components in
+// options that make outbound requests. VerifyOptions.PolicyResolver is
+// given it per verification by resolvePolicy
+func shareHTTPClient(options *ClientOptions) {
+	if options.Retry != nil {
+		options.HTTPClient = options.Retry.wrap(options.HTTPClient)
+	}
+	if options.HTTPClient == nil {
+		return
+	}
+	components := []interface{}{options.Log, options.PolicySnapshots}
+	for _, log := range options.Logs {
+		components = append(components, log)
+	}
+	if options.Keyless != nil {
+		components = append(components, options.Keyless.CA)
+	}
+	for _, component := range components {
+		if user, ok := component.(HTTPClientUser); ok {
+			user.UseHTTPClient(options.HTTPClient)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const trustBundleVersion = "TECP-TRUST-BUNDLE-0.1"
+
+// TrustBundle is a signed, self-contained trust configuration for offline
+// verifiers: the accepted issuer, log and witness keys, a snapshot of the
+// policy registry, and the window in which the bundle may be used. Rotating
+// trust means distributing a new bundle
+type TrustBundle struct {
+	Version string `json:"v"`
+
+	// Issuers are the keys receipts may be signed with
+	Issuers JWKS `json:"issuers"`
+
+	// Logs are the transparency logs whose promises are accepted
+	Logs JWKS `json:"logs"`
+
+	// Witnesses are the keys trusted to cosign log tree heads
+	Witnesses JWKS `json:"witnesses"`
+
+	// Policies is a snapshot of policy descriptors, by policy ID
+	Policies map[string]*PolicyDescriptor `json:"policies,omitempty"`
+
+	// NotBefore and NotAfter bound the bundle's validity, in Unix milliseconds
+	NotBefore int64 `json:"not_before"`
+	NotAfter  int64 `json:"not_after"`
+
+	KeyID     string `json:"kid"`
+	Signature string `json:"sig,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set of Ed25519 keys
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is an Ed25519 JSON Web Key
+type JWK struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	KeyID   string `json:"kid"`
+}
+
+// NewJWK returns the JWK of an Ed25519 public key
+func NewJWK(keyID string, publicKey ed25519.PublicKey) JWK {
+	return JWK{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(publicKey), KeyID: keyID}
+}
+
+// PublicKeys returns the set's keys by key ID
+func (s JWKS) PublicKeys() (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey, len(s.Keys))
+	for _, key := range s.Keys {
+		if key.KeyType != "OKP" || key.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported key %q: %s %s", key.KeyID, key.KeyType, key.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid key %q", key.KeyID)
+		}
+		keys[key.KeyID] = ed25519.PublicKey(x)
+	}
+	return keys, nil
+}
+
+// SigningPayload returns the bytes covered by the bundle signature: its JSON
+// encoding without the signature
+func (b *TrustBundle) SigningPayload() []byte {
+	unsigned := *b
+	unsigned.Signature = ""
+	payload, _ := json.Marshal(&unsigned)
+	return payload
+}
+
+// ValidAt reports whether t falls within the bundle's validity window
+func (b *TrustBundle) ValidAt(t time.Time) bool {
+	ms := t.UnixMilli()
+	return ms >= b.NotBefore && ms <= b.NotAfter
+}
+
+// ResolvePolicy looks a policy up in the bundle's registry snapshot
+func (b *TrustBundle) ResolvePolicy(id PolicyID) (*PolicyDescriptor, error) {
+	descriptor, ok := b.Policies[id.String()]
+	if !ok {
+		return nil, fmt.Errorf("policy %s not in trust bundle", id)
+	}
+	return descriptor, nil
+}
+
+// SignTrustBundle signs a bundle with a distribution key
+func SignTrustBundle(bundle *TrustBundle, keyID string, key ed25519.PrivateKey) {
+	bundle.Version = trustBundleVersion
+	bundle.KeyID = keyID
+	bundle.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, bundle.SigningPayload()))
+}
+
+// VerifyTrustBundle checks a bundle's signature and keys. It does not check
+// the validity window, which VerifyReceipt enforces at verification time
+func VerifyTrustBundle(bundle *TrustBundle, publicKey ed25519.PublicKey) error {
+	if bundle.Version != trustBundleVersion {
+		return fmt.Errorf("unsupported trust bundle version: %s", bundle.Version)
+	}
+	signature, err := base64.StdEncoding.DecodeString(bundle.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid trust bundle signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, bundle.SigningPayload(), signature) {
+		return fmt.Errorf("trust bundle signature verification failed")
+	}
+	for name, set := range map[string]JWKS{"issuer": bundle.Issuers, "log": bundle.Logs, "witness": bundle.Witnesses} {
+		if _, err := set.PublicKeys(); err != nil {
+			return fmt.Errorf("trust bundle %s keys: %w", name, err)
+		}
+	}
+	if bundle.NotAfter < bundle.NotBefore {
+		return fmt.Errorf("trust bundle validity window is empty")
+	}
+	return nil
+}
+
+// ParseTrustBundle decodes a bundle and verifies it against the distribution
+// key, which verifiers pin out of band
+func ParseTrustBundle(data []byte, publicKey ed25519.PublicKey) (*TrustBundle, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var bundle TrustBundle
+	if err := decoder.Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("invalid trust bundle: %w", err)
+	}
+	if err := VerifyTrustBundle(&bundle, publicKey); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// LoadTrustBundle reads a bundle file and verifies it against the
+// distribution key
+func LoadTrustBundle(path string, publicKey ed25519.PublicKey) (*TrustBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust bundle: %w", err)
+	}
+	return ParseTrustBundle(data, publicKey)
+}
+
+// applyTrustBundle folds a bundle into the verification options: its logs
+// join the trusted logs, its registry snapshot resolves policies unless a
+// resolver is set, and its signature scopes cached results
+func applyTrustBundle(options VerifyOptions, now time.Time) (VerifyOptions, []string) {
+	bundle := options.TrustBundle
+	var errors []string
+	if !bundle.ValidAt(now) {
+		errors = append(errors, fmt.Sprintf("trust bundle %s not valid at %s", bundle.KeyID, now.UTC().Format(time.RFC3339)))
+	}
+
+	logs, err := bundle.Logs.PublicKeys()
+	if err != nil {
+		errors = append(errors, fmt.Sprintf("trust bundle log keys: %v", err))
+	}
+	options.Logs = append([]TrustedLog(nil), options.Logs...)
+	for keyID, publicKey := range logs {
+		options.Logs = append(options.Logs, TrustedLog{KeyID: keyID, PublicKey: publicKey})
+	}
+
+	if options.PolicyResolver == nil {
+		options.PolicyResolver = bundle
+	}
+	options.PolicyVersion += "+bundle:" + bundle.Signature
+	return options, errors
+}
+
+// verifyBundleIssuer checks that the receipt was signed by a bundle issuer
+func verifyBundleIssuer(receipt *Receipt, bundle *TrustBundle) error {
+	publicKey, err := receiptPublicKey(receipt)
+	if err != nil {
+		return err
+	}
+	issuers, err := bundle.Issuers.PublicKeys()
+	if err != nil {
+		return err
+	}
+	for _, issuer := range issuers {
+		if publicKey.Equal(issuer) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signing key not in trust bundle")
+}
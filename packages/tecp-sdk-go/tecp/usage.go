@@ -0,0 +1,196 @@
+package tecp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Key usage defaults
+const (
+	DefaultUsageBucket    = time.Hour
+	DefaultUsageRetention = 7 * 24 * time.Hour
+	DefaultQuotaWarnAt    = 0.9
+)
+
+// ErrKeyQuotaExhausted is returned by CreateReceipt once a key has signed
+// KeyUsage.MaxSignatures receipts; the key must be rotated
+var ErrKeyQuotaExhausted = errors.New("signing key quota exhausted; rotate the key")
+
+// KeyUsage accounts the receipts each signing key signs, in time buckets,
+// and enforces a signing quota per key, as some PKI policies require.
+// Counts last for the process; Seed restores totals persisted elsewhere.
+// The zero value accounts without a quota
+type KeyUsage struct {
+	// MaxSignatures, when set, is how many receipts a key may sign. Once
+	// reached, CreateReceipt fails with ErrKeyQuotaExhausted
+	MaxSignatures uint64
+
+	// WarnAt is the fraction of MaxSignatures after which OnWarning is
+	// called for each signature; it defaults to DefaultQuotaWarnAt
+	WarnAt float64
+
+	// OnWarning, when set, is called as a key approaches its quota, with
+	// the base64 public key and its signature count so far
+	OnWarning func(publicKey string, signed, max uint64)
+
+	// Bucket is the accounting granularity and Retention how long buckets
+	// are kept; they default to DefaultUsageBucket and DefaultUsageRetention
+	Bucket    time.Duration
+	Retention time.Duration
+
+	mu   sync.Mutex
+	keys map[string]*keyUsage
+}
+
+type keyUsage struct {
+	total   uint64
+	buckets map[int64]uint64
+}
+
+// UsageBucket is the number of receipts a key signed in one time bucket
+type UsageBucket struct {
+	Start      time.Time `json:"start"`
+	Signatures uint64    `json:"signatures"`
+}
+
+// KeyUsageStats is a key's signing activity
+type KeyUsageStats struct {
+	PublicKey string `json:"pubkey"`
+
+	// Signatures is the total the key has signed, including seeded counts
+	Signatures uint64 `json:"signatures"`
+
+	// Remaining is how many more receipts the key may sign; zero without a
+	// quota
+	Remaining uint64 `json:"remaining,omitempty"`
+
+	// Buckets are the retained buckets, oldest first
+	Buckets []UsageBucket `json:"buckets"`
+}
+
+// Seed sets a key's total signature count, such as one persisted across
+// restarts or read from a FileSequencer
+func (u *KeyUsage) Seed(publicKey string, signatures uint64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.key(publicKey).total = signatures
+}
+
+// reserve accounts one signature by publicKey at now, or fails when the
+// key's quota is exhausted
+func (u *KeyUsage) reserve(publicKey string, now time.Time) error {
+	u.mu.Lock()
+	usage := u.key(publicKey)
+	if u.MaxSignatures > 0 && usage.total >= u.MaxSignatures {
+		u.mu.Unlock()
+		return fmt.Errorf("%w: %d of %d signatures used", ErrKeyQuotaExhausted, usage.total, u.MaxSignatures)
+	}
+	usage.total++
+	bucket := u.bucket()
+	usage.buckets[now.Truncate(bucket).UnixMilli()]++
+	cutoff := now.Add(-u.retention()).Truncate(bucket).UnixMilli()
+	for start := range usage.buckets {
+		if start < cutoff {
+			delete(usage.buckets, start)
+		}
+	}
+	signed := usage.total
+	u.mu.Unlock()
+
+	if u.OnWarning != nil && u.MaxSignatures > 0 {
+		warnAt := u.WarnAt
+		if warnAt <= 0 {
+			warnAt = DefaultQuotaWarnAt
+		}
+		if float64(signed) >= warnAt*float64(u.MaxSignatures) {
+			u.OnWarning(publicKey, signed, u.MaxSignatures)
+		}
+	}
+	return nil
+}
+
+// Usage returns a key's signing activity
+func (u *KeyUsage) Usage(publicKey string) KeyUsageStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.stats(publicKey, u.keys[publicKey])
+}
+
+// All returns the signing activity of every key, by public key
+func (u *KeyUsage) All() []KeyUsageStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	all := make([]KeyUsageStats, 0, len(u.keys))
+	for publicKey, usage := range u.keys {
+		all = append(all, u.stats(publicKey, usage))
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].PublicKey < all[j].PublicKey })
+	return all
+}
+
+// WriteMetrics writes each key's signature count and remaining quota in the
+// Prometheus text exposition format
+func (u *KeyUsage) WriteMetrics(w io.Writer) error {
+	all := u.All()
+	if _, err := fmt.Fprintf(w, "# HELP tecp_key_signatures_total Receipts signed, by signing key.\n# TYPE tecp_key_signatures_total counter\n"); err != nil {
+		return err
+	}
+	for _, stats := range all {
+		fmt.Fprintf(w, "tecp_key_signatures_total{key=%q} %d\n", stats.PublicKey, stats.Signatures)
+	}
+	if u.MaxSignatures == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "# HELP tecp_key_signatures_remaining Signatures left before the key must be rotated.\n# TYPE tecp_key_signatures_remaining gauge\n")
+	for _, stats := range all {
+		fmt.Fprintf(w, "tecp_key_signatures_remaining{key=%q} %d\n", stats.PublicKey, stats.Remaining)
+	}
+	return nil
+}
+
+func (u *KeyUsage) stats(publicKey string, usage *keyUsage) KeyUsageStats {
+	stats := KeyUsageStats{PublicKey: publicKey, Buckets: []UsageBucket{}}
+	if usage == nil {
+		usage = &keyUsage{}
+	}
+	stats.Signatures = usage.total
+	if u.MaxSignatures > usage.total {
+		stats.Remaining = u.MaxSignatures - usage.total
+	}
+	for start, signatures := range usage.buckets {
+		stats.Buckets = append(stats.Buckets, UsageBucket{Start: time.UnixMilli(start).UTC(), Signatures: signatures})
+	}
+	sort.Slice(stats.Buckets, func(i, j int) bool { return stats.Buckets[i].Start.Before(stats.Buckets[j].Start) })
+	return stats
+}
+
+// key returns a key's usage, creating it; u.mu must be held
+func (u *KeyUsage) key(publicKey string) *keyUsage {
+	if u.keys == nil {
+		u.keys = make(map[string]*keyUsage)
+	}
+	usage, ok := u.keys[publicKey]
+	if !ok {
+		usage = &keyUsage{buckets: make(map[int64]uint64)}
+		u.keys[publicKey] = usage
+	}
+	return usage
+}
+
+func (u *KeyUsage) bucket() time.Duration {
+	if u.Bucket <= 0 {
+		return DefaultUsageBucket
+	}
+	return u.Bucket
+}
+
+func (u *KeyUsage) retention() time.Duration {
+	if u.Retention <= 0 {
+		return DefaultUsageRetention
+	}
+	return u.Retention
+}
@@ -0,0 +1,116 @@
+package tecp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxExtensionsSize bounds the JSON size of a receipt's extensions under
+// strict creation, keeping receipts within MaxReceiptSizeKB
+const MaxExtensionsSize = MaxReceiptSizeKB * 1024
+
+// codeRefPattern matches scheme:reference code references, such as
+// git:3f2a9c1 or oci:registry.example.com/model@sha256:...
+var codeRefPattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*:\S+$`)
+
+// Validate lints the options for inputs that produce receipts downstream
+// verifiers reject or that are likely mistakes: an empty output, duplicate,
+// malformed or contradictory policy IDs, a malformed code reference, signed
+// or critical extensions that are not set, invalid signed metadata, and
+// extensions larger than MaxExtensionsSize. Every problem found is reported
+func (o *CreateReceiptOptions) Validate() error {
+	return o.validate(false)
+}
+
+// validate lints the options, for CreateReceiptFromHashes when external
+// is set, where the output is not supplied
+func (o *CreateReceiptOptions) validate(external bool) error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(o.Output) == 0 && !external {
+		fail("output is empty")
+	}
+
+	seen := make(map[string]bool, len(o.Policies))
+	for _, id := range o.Policies {
+		if seen[id] {
+			fail("duplicate policy ID %q", id)
+		}
+		seen[id] = true
+		if _, err := ParsePolicyID(id); err != nil {
+			fail("%v", err)
+		}
+	}
+	for _, conflict := range PolicyConflicts(o.Policies) {
+		fail("%v", conflict)
+	}
+
+	if o.CodeRef != "" && !codeRefPattern.MatchString(o.CodeRef) {
+		fail("malformed code_ref %q: want scheme:reference", o.CodeRef)
+	}
+
+	for _, names := range []struct {
+		kind  string
+		names []string
+	}{{"signed", o.SignedExtensions}, {"critical", o.CriticalExtensions}} {
+		for _, name := range names.names {
+			if _, ok := o.Extensions[name]; !ok && !implicitExtension(name) {
+				fail("%s extension %q is not set", names.kind, name)
+			}
+		}
+	}
+
+	if o.Determinism != "" {
+		if err := o.Determinism.Validate(); err != nil {
+			fail("%v", err)
+		}
+	}
+	if o.Transform != "" {
+		if err := o.Transform.Validate(); err != nil {
+			fail("%v", err)
+		}
+	}
+	if o.AI != nil {
+		if err := o.AI.Validate(); err != nil {
+			fail("%v", err)
+		}
+	}
+	if o.Metering != nil {
+		if err := o.Metering.Validate(); err != nil {
+			fail("%v", err)
+		}
+	}
+
+	if len(o.Extensions) > 0 {
+		encoded, err := json.Marshal(o.Extensions)
+		switch {
+		case err != nil:
+			fail("extensions cannot be encoded: %v", err)
+		case len(encoded) > MaxExtensionsSize:
+			fail("extensions are %d bytes, more than %d", len(encoded), MaxExtensionsSize)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid receipt options: %s", strings.Join(problems, "; "))
+}
+
+// implicitExtension reports whether CreateReceipt sets the named extension
+// itself, from options or client configuration
+func implicitExtension(name string) bool {
+	switch name {
+	case TenantExtension, LabelsExtension, DeterminismExtension, AIExtension,
+		MeteringExtension, SequenceExtension, EnvironmentExtension, X5CExtension,
+		ResidencyExtension, KeyErasureExtension, AIActExtension, PolicySnapshotsExtension,
+		TransformExtension, HashSourceExtension, CommitmentExtension:
+		return true
+	}
+	return false
+}
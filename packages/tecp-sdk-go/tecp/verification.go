@@ -0,0 +1,162 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// VerificationAttestationVersion identifies the verification attestation format
+const VerificationAttestationVersion = "TECP-VA-0.1"
+
+// VerificationAttestation is a verifier's signed statement that it checked a
+// receipt: who verified it, when, under which profile, and with what outcome.
+// Attestations can be chained through Previous to form an audit trail
+type VerificationAttestation struct {
+	Version     string   `json:"version" cbor:"version"`
+	ReceiptHash string   `json:"receipt_hash" cbor:"receipt_hash"`
+	Verifier    string   `json:"verifier" cbor:"verifier"`
+	VerifiedAt  int64    `json:"verified_at" cbor:"verified_at"`
+	Profile     Profile  `json:"profile" cbor:"profile"`
+	Valid       bool     `json:"valid" cbor:"valid"`
+	Errors      []string `json:"errors,omitempty" cbor:"errors,omitempty"`
+	Warnings    []string `json:"warnings,omitempty" cbor:"warnings,omitempty"`
+	Previous    string   `json:"previous,omitempty" cbor:"previous,omitempty"`
+	Signature   string   `json:"sig" cbor:"sig"`
+}
+
+// SignVerification produces a verification attestation for a receipt signed
+// by the client's key
+func (c *Client) SignVerification(receipt *Receipt, result *VerificationResult) (*VerificationAttestation, error) {
+	return c.signVerification(receipt, result, "")
+}
+
+// ChainVerification produces a verification attestation linked to a previous
+// one, so a sequence of checks forms a tamper-evident chain
+func (c *Client) ChainVerification(previous *VerificationAttestation, receipt *Receipt, result *VerificationResult) (*VerificationAttestation, error) {
+	hash, err := previous.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return c.signVerification(receipt, result, base64.StdEncoding.EncodeToString(hash))
+}
+
+func (c *Client) signVerification(receipt *Receipt, result *VerificationResult, previous string) (*VerificationAttestation, error) {
+	if c.privateKey == nil {
+		return nil, fmt.Errorf("private key required for verification attestation")
+	}
+	if result == nil {
+		return nil, fmt.Errorf("verification result required")
+	}
+
+	receiptHash, err := ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := c.privateKey.Public().(ed25519.PublicKey)
+	attestation := &VerificationAttestation{
+		Version:     VerificationAttestationVersion,
+		ReceiptHash: base64.StdEncoding.EncodeToString(receiptHash),
+		Verifier:    base64.StdEncoding.EncodeToString(publicKey),
+		VerifiedAt:  time.Now().UnixMilli(),
+		Profile:     result.Profile,
+		Valid:       result.Valid,
+		Errors:      result.Errors,
+		Warnings:    result.Warnings,
+		Previous:    previous,
+	}
+
+	payload, err := canonicalCBOR(attestation.signingPayload())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	attestation.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(c.privateKey, payload))
+
+	return attestation, nil
+}
+
+// VerifyAttestation checks a verification attestation's signature and, when
+// receipt is non-nil, that it refers to that receipt
+func VerifyAttestation(attestation *VerificationAttestation, receipt *Receipt) error {
+	if attestation.Version != VerificationAttestationVersion {
+		return fmt.Errorf("invalid attestation version: %s", attestation.Version)
+	}
+
+	if receipt != nil {
+		receiptHash, err := ReceiptHash(receipt)
+		if err != nil {
+			return err
+		}
+		claimed, err := base64.StdEncoding.DecodeString(attestation.ReceiptHash)
+		if err != nil || !bytes.Equal(claimed, receiptHash) {
+			return fmt.Errorf("attestation does not refer to this receipt")
+		}
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(attestation.Verifier)
+	if err != nil {
+		return fmt.Errorf("invalid verifier key encoding: %w", err)
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid verifier key size: %d", len(publicKey))
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attestation.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	payload, err := canonicalCBOR(attestation.signingPayload())
+	if err != nil {
+		return fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(publicKey), payload, signature) {
+		return fmt.Errorf("attestation signature verification failed")
+	}
+	return nil
+}
+
+// Hash returns the SHA-256 digest of the signed attestation, used to chain
+// later attestations to it
+func (a *VerificationAttestation) Hash() ([]byte, error) {
+	data := a.signingPayload()
+	data["sig"] = a.Signature
+
+	encoded, err := canonicalCBOR(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canonical CBOR: %w", err)
+	}
+	hash := sha256.Sum256(encoded)
+	return hash[:], nil
+}
+
+// signingPayload returns the attestation fields covered by the signature
+func (a *VerificationAttestation) signingPayload() map[string]interface{} {
+	data := map[string]interface{}{
+		"version":      a.Version,
+		"receipt_hash": a.ReceiptHash,
+		"verifier":     a.Verifier,
+		"verified_at":  a.VerifiedAt,
+		"profile":      string(a.Profile),
+		"valid":        a.Valid,
+		"errors":       stringsOrEmpty(a.Errors),
+		"warnings":     stringsOrEmpty(a.Warnings),
+	}
+	if a.Previous != "" {
+		data["previous"] = a.Previous
+	}
+	return data
+}
+
+// stringsOrEmpty normalizes nil slices so they encode identically to empty
+// ones after a JSON round trip
+func stringsOrEmpty(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,57 @@
+package tecp
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+// TestVerifyAtIgnoresLaterRevocation checks that a compromise logged after
+// the VerifyAt instant is not known as of that instant, including when a
+// cached result from another instant is at hand
+func TestVerifyAtIgnoresLaterRevocation(t *testing.T) {
+	priv, _, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := NewClient(WithSigner(priv)).CreateReceipt(CreateReceiptOptions{Input: []byte("in"), Output: []byte("out"), CodeRef: "git:abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued := time.UnixMilli(receipt.Timestamp)
+
+	// The key is revoked from before the receipt, but only logged an hour
+	// after it was issued
+	logPriv, logPub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statement := &KeyStatement{Event: KeyCompromised, PublicKey: receipt.PublicKey, Effective: receipt.Timestamp - 1000}
+	SignKeyStatement(statement, priv)
+	srt := SignedReceiptTimestamp{Entry: hex.EncodeToString(statement.EntryHash()), Timestamp: issued.Add(time.Hour).UnixMilli(), MMD: 60000, KeyID: "log-1"}
+	SignReceiptTimestamp(&srt, logPriv)
+	statement.Promises = []SignedReceiptTimestamp{srt}
+
+	options := VerifyOptions{
+		KeyStatements: []*KeyStatement{statement},
+		Logs:          []TrustedLog{{KeyID: "log-1", PublicKey: logPub}},
+		Cache:         NewLRUVerificationCache(10, time.Hour),
+	}
+	client := NewClient()
+
+	before, err := client.VerifyAt(receipt, issued.Add(time.Minute), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !before.Valid {
+		t.Fatalf("revocation logged after asOf was applied: %v", before.Errors)
+	}
+
+	after, err := client.VerifyAt(receipt, issued.Add(2*time.Hour), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Valid || !containsPolicy(after.ErrorCodes, ErrorCodeKeyCompromised) {
+		t.Fatalf("revocation logged before asOf was not applied: %v", after.ErrorCodes)
+	}
+}
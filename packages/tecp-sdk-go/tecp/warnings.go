@@ -0,0 +1,102 @@
+package tecp
+
+import (
+	"fmt"
+	"time"
+)
+
+// Warning codes reported in VerificationResult.WarningCodes. They parallel
+// the error codes: each names one kind of degradation and never changes,
+// so automated systems can alert on, or FailOn, a specific code without
+// matching warning messages
+const (
+	// WarningLogUnreachable: the receipt was issued while a transparency
+	// log was unreachable and no inclusion proof has settled it yet
+	WarningLogUnreachable = "W-LOG-001"
+
+	// WarningLogUntrusted: a promise or inclusion proof from a log that is
+	// not trusted was ignored
+	WarningLogUntrusted = "W-LOG-002"
+
+	// WarningLogUnchecked: a log's inclusion of the receipt, or its tree
+	// head, could not be checked
+	WarningLogUnchecked = "W-LOG-003"
+
+	// WarningIssuerKeysStale: the issuer's published keys could not be
+	// refreshed and a cached set was used
+	WarningIssuerKeysStale = "W-KEY-001"
+
+	// WarningKeyNearExpiry: the signing certificate expires within
+	// VerifyOptions.ExpiryWarning
+	WarningKeyNearExpiry = "W-KEY-002"
+
+	// WarningTrustBundleNearExpiry: the trust bundle expires within
+	// VerifyOptions.ExpiryWarning
+	WarningTrustBundleNearExpiry = "W-KEY-003"
+
+	// WarningKeyStatementIgnored: a retirement or compromise statement
+	// about the signing key was ignored, being invalid, signed by neither
+	// the key nor a key authority, or not anchored in a trusted log
+	WarningKeyStatementIgnored = "W-KEY-004"
+
+	// WarningKeyNotReattested: the receipt predates its key's compromise
+	// but was not re-attested by the key's successor, so it may have been
+	// backdated. FailOn it to accept only re-attested receipts
+	WarningKeyNotReattested = "W-KEY-005"
+
+	// WarningUnsigned: receipt metadata not covered by the signature was
+	// ignored
+	WarningUnsigned = "W-SIG-001"
+
+	// WarningNonCanonicalEncoding: a binary field is not base64 encoded
+	WarningNonCanonicalEncoding = "W-ENC-001"
+
+	// WarningNewerVersion: the receipt's minor version is newer than any
+	// known one and was checked under older rules
+	WarningNewerVersion = "W-SCHEMA-001"
+
+	// WarningPolicyMalformed: a claimed policy ID is malformed
+	WarningPolicyMalformed = "W-POL-001"
+
+	// WarningPolicyUnresolved: a claimed policy could not be resolved
+	WarningPolicyUnresolved = "W-POL-002"
+
+	// WarningPolicyPinUnchecked: a policy pin could not be checked for
+	// lack of a snapshot or resolvable descriptor
+	WarningPolicyPinUnchecked = "W-POL-003"
+
+	// WarningPolicyChanged: a policy's published descriptor differs from
+	// its snapshot in the receipt
+	WarningPolicyChanged = "W-POL-004"
+
+	// WarningEvidenceMissing: a policy was claimed without its evidence
+	WarningEvidenceMissing = "W-EVID-001"
+
+	// WarningEvidenceUnappraised: evidence was present but could not be
+	// anchored to a trusted authority or appraised
+	WarningEvidenceUnappraised = "W-EVID-002"
+
+	// WarningIssuerProfile: the issuer's directory entry does not list the
+	// verification profile
+	WarningIssuerProfile = "W-ISS-001"
+
+	// WarningCheckTimedOut: a network check was abandoned for exceeding
+	// its time budget or VerifyOptions.Deadline
+	WarningCheckTimedOut = "W-TIME-001"
+)
+
+// codedWarning is a warning with its code, for helpers that report
+// warnings to a check
+type codedWarning struct {
+	code, message string
+}
+
+// warnExpiry warns with code when notAfter falls within the
+// VerifyOptions.ExpiryWarning window
+func warnExpiry(v *Verification, result *CheckResult, code, what string, notAfter time.Time) {
+	window := v.Options.ExpiryWarning
+	if window <= 0 || notAfter.Before(v.Now) || notAfter.Sub(v.Now) > window {
+		return
+	}
+	result.Warn(code, fmt.Sprintf("%s expires at %s", what, notAfter.UTC().Format(time.RFC3339)))
+}
@@ -0,0 +1,126 @@
+package tecp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// X5CExtension is the receipt extension carrying the signing key's
+// certificate chain as base64-encoded DER certificates, leaf first (RFC 7515 §4.1.6)
+const X5CExtension = "x5c"
+
+// CertificateChain returns the certificate chain embedded in a receipt's x5c
+// extension, or nil if the receipt carries none
+func CertificateChain(receipt *Receipt) ([]*x509.Certificate, error) {
+	raw, ok := receipt.Extensions[X5CExtension]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	// Receipts decoded from JSON carry []interface{} rather than []string
+	var encoded []string
+	switch v := raw.(type) {
+	case []string:
+		encoded = v
+	case []interface{}:
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("x5c entry %d is not a string", i)
+			}
+			encoded = append(encoded, s)
+		}
+	default:
+		return nil, fmt.Errorf("x5c extension must be an array of strings")
+	}
+
+	chain := make([]*x509.Certificate, 0, len(encoded))
+	for i, s := range encoded {
+		der, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("x5c entry %d: invalid base64: %w", i, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("x5c entry %d: %w", i, err)
+		}
+		chain = append(chain, cert)
+	}
+	return chain, nil
+}
+
+// encodeCertificateChain encodes certificates for the x5c extension
+func encodeCertificateChain(chain []*x509.Certificate) []string {
+	encoded := make([]string, len(chain))
+	for i, cert := range chain {
+		encoded[i] = base64.StdEncoding.EncodeToString(cert.Raw)
+	}
+	return encoded
+}
+
+// certifiedKey returns a certificate's public key as carried in receipts,
+// and the algorithm receipts signed with it use
+func certifiedKey(key crypto.PublicKey) ([]byte, Algorithm, error) {
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return key, AlgEdDSA, nil
+	case *ecdsa.PublicKey:
+		for _, alg := range []Algorithm{AlgES256, AlgES384, AlgES256K} {
+			if curve, _ := ecdsaParams(alg); curve == key.Curve {
+				return elliptic.Marshal(key.Curve, key.X, key.Y), alg, nil
+			}
+		}
+		return nil, "", fmt.Errorf("unsupported leaf certificate curve %s", key.Curve.Params().Name)
+	}
+	return nil, "", fmt.Errorf("unsupported leaf certificate key %T", key)
+}
+
+// verifyCertificateChain checks that the receipt's x5c chain certifies its
+// signing key and chains to one of roots at the receipt's issuance time
+func verifyCertificateChain(receipt *Receipt, roots *x509.CertPool) error {
+	chain, err := CertificateChain(receipt)
+	if err != nil {
+		return err
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("receipt has no %s certificate chain", X5CExtension)
+	}
+
+	leaf := chain[0]
+	leafKey, alg, err := certifiedKey(leaf.PublicKey)
+	if err != nil {
+		return err
+	}
+	if alg != receipt.SignatureAlgorithm() {
+		return fmt.Errorf("leaf certificate key is for %s, but the receipt is signed with %s", alg, receipt.SignatureAlgorithm())
+	}
+	size, _ := receipt.keySizes()
+	publicKey, err := decodeBinaryField("public key", receipt.PublicKey, size)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(leafKey, publicKey) {
+		return fmt.Errorf("leaf certificate does not certify the receipt public key")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	// Validity is judged at issuance so receipts outlive short-lived certificates
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.UnixMilli(receipt.Timestamp),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
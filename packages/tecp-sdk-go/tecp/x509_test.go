@@ -0,0 +1,103 @@
+package tecp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCertificateChainKeyAlgorithms(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, rootPub, rootPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := x509.ParseCertificate(rootDER)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	certify := func(key crypto.PublicKey) *x509.Certificate {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "issuer"},
+			NotBefore:    now.Add(-time.Minute),
+			NotAfter:     now.Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, root, key, rootPriv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, _ := x509.ParseCertificate(der)
+		return cert
+	}
+	ecdsaSigner := func(curve elliptic.Curve) (Signer, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := NewECDSASigner(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signer, key
+	}
+
+	edPriv, edPub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	es256, es256Key := ecdsaSigner(elliptic.P256())
+	es384, es384Key := ecdsaSigner(elliptic.P384())
+	_, otherKey := ecdsaSigner(elliptic.P256())
+
+	tests := []struct {
+		name   string
+		signer Signer
+		leaf   *x509.Certificate
+		valid  bool
+	}{
+		{"EdDSA", NewEd25519Signer(edPriv), certify(edPub), true},
+		{"ES256", es256, certify(es256Key.Public()), true},
+		{"ES384", es384, certify(es384Key.Public()), true},
+		{"ES256 other key", es256, certify(otherKey.Public()), false},
+		{"ES256 Ed25519 leaf", es256, certify(edPub), false},
+		{"EdDSA P-256 leaf", NewEd25519Signer(edPriv), certify(es256Key.Public()), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(WithReceiptSigner(tt.signer), WithCertificateChain(tt.leaf, root))
+			receipt, err := client.CreateReceipt(CreateReceiptOptions{Input: []byte("in"), Output: []byte("out"), CodeRef: "git:abc"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewClient().VerifyReceipt(receipt, VerifyOptions{Roots: roots})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Valid != tt.valid {
+				t.Fatalf("valid = %v, want %v: %v", result.Valid, tt.valid, result.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package tecphttp
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Defaults for receipt caching
+const (
+	// DefaultReceiptMaxAge is the freshness lifetime of served receipts
+	// that never expire. Receipts are immutable, so it is long
+	DefaultReceiptMaxAge = 365 * 24 * time.Hour
+
+	// DefaultReferenceCacheSize is the number of receipts a ReferenceCache
+	// keeps
+	DefaultReferenceCacheSize = 1000
+)
+
+// CacheOptions configures the caching headers of served receipts
+type CacheOptions struct {
+	// Expiry returns when a receipt stops being served, such as when its
+	// retention ends (retention.Manager.Expiry), or false when it never
+	// does. Receipts are cacheable until then
+	Expiry func(*tecp.Receipt) (time.Time, bool)
+
+	// MaxAge caps the freshness lifetime; defaults to DefaultReceiptMaxAge
+	MaxAge time.Duration
+}
+
+// receiptETag returns the strong entity tag of the receipt stored under
+// key. Stored receipts are immutable and keyed by their hash, so the key
+// identifies the representation
+func receiptETag(key string) string {
+	return `"` + key + `"`
+}
+
+// cacheControl returns the Cache-Control header for a receipt
+func (o CacheOptions) cacheControl(receipt *tecp.Receipt, now time.Time) string {
+	maxAge := o.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultReceiptMaxAge
+	}
+	if o.Expiry != nil {
+		if expiry, ok := o.Expiry(receipt); ok {
+			remaining := expiry.Sub(now)
+			if remaining <= 0 {
+				return "public, max-age=0, must-revalidate"
+			}
+			if remaining < maxAge {
+				maxAge = remaining
+			}
+		}
+	}
+	return "public, max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10) + ", immutable"
+}
+
+// ReferenceCache keeps receipts fetched through references, serving them
+// from memory while fresh and revalidating them with If-None-Match once
+// stale, so repeated references cost at most a 304 Not Modified
+type ReferenceCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*referenceEntry
+}
+
+type referenceEntry struct {
+	receipt *tecp.Receipt
+	etag    string
+	expires time.Time
+}
+
+// NewReferenceCache returns a cache keeping up to size receipts, or
+// DefaultReferenceCacheSize when size is zero
+func NewReferenceCache(size int) *ReferenceCache {
+	if size <= 0 {
+		size = DefaultReferenceCacheSize
+	}
+	return &ReferenceCache{size: size, entries: make(map[string]*referenceEntry)}
+}
+
+// Fetch retrieves the receipt a reference points to as FetchReference
+// does, from the cache when possible
+func (c *ReferenceCache) Fetch(ref string, base *url.URL, client *http.Client) (*tecp.Receipt, error) {
+	target, err := resolveReference(ref, base)
+	if err != nil {
+		return nil, err
+	}
+	key := target.String()
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, cached := c.entries[key]
+	c.mu.Unlock()
+	if cached && now.Before(entry.expires) {
+		return entry.receipt, nil
+	}
+
+	etag := ""
+	if cached {
+		etag = entry.etag
+	}
+	receipt, header, err := fetchReference(target, etag, client)
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		receipt = entry.receipt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lifetime, storable := freshness(header)
+	if !storable {
+		delete(c.entries, key)
+		return receipt, nil
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.size {
+			return receipt, nil
+		}
+	}
+	if tag := header.Get("ETag"); tag != "" {
+		etag = tag
+	}
+	c.entries[key] = &referenceEntry{receipt: receipt, etag: etag, expires: now.Add(lifetime)}
+	return receipt, nil
+}
+
+// freshness returns how long a response may be served from the cache, from
+// its Cache-Control header, and whether it may be stored at all
+func freshness(header http.Header) (time.Duration, bool) {
+	var lifetime time.Duration
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			return 0, true
+		case "max-age":
+			if seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64); err == nil && seconds > 0 {
+				lifetime = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return lifetime, true
+}
@@ -0,0 +1,97 @@
+package tecphttp
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// HeaderIdempotencyKey identifies retries of one logical request
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// Defaults applied by NewIdempotencyCache
+const (
+	DefaultIdempotencyTTL  = 24 * time.Hour
+	DefaultIdempotencySize = 10000
+)
+
+// IdempotencyCache remembers the receipt issued for each idempotency key, so
+// a retried request that produces the same response gets the same receipt.
+// Retries with a different response get a new receipt with the same nonce
+type IdempotencyCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*idempotentEntry
+}
+
+type idempotentEntry struct {
+	receipt *tecp.Receipt
+	input   [sha256.Size]byte
+	output  [sha256.Size]byte
+	expires time.Time
+}
+
+// NewIdempotencyCache returns a cache keeping up to size receipts for ttl
+func NewIdempotencyCache(ttl time.Duration, size int) *IdempotencyCache {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	if size <= 0 {
+		size = DefaultIdempotencySize
+	}
+	return &IdempotencyCache{ttl: ttl, size: size, entries: make(map[string]*idempotentEntry)}
+}
+
+// idempotencyScope returns the idempotency key of a request, scoped to its
+// method and path so one key reused across endpoints does not collide
+func idempotencyScope(r *http.Request) string {
+	key := r.Header.Get(HeaderIdempotencyKey)
+	if key == "" {
+		return ""
+	}
+	return r.Method + " " + r.URL.Path + " " + key
+}
+
+// get returns the receipt cached for scope if it covers the same exchange
+func (c *IdempotencyCache) get(scope string, input, output []byte) (*tecp.Receipt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[scope]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	if entry.input != sha256.Sum256(input) || entry.output != sha256.Sum256(output) {
+		return nil, false
+	}
+	return entry.receipt, true
+}
+
+// put caches the receipt for scope, dropping expired entries when full
+func (c *IdempotencyCache) put(scope string, receipt *tecp.Receipt, input, output []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= c.size {
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= c.size {
+			return
+		}
+	}
+	c.entries[scope] = &idempotentEntry{
+		receipt: receipt,
+		input:   sha256.Sum256(input),
+		output:  sha256.Sum256(output),
+		expires: now.Add(c.ttl),
+	}
+}
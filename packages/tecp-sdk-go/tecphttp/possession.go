@@ -0,0 +1,88 @@
+package tecphttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// challengeRequest is the body of a possession challenge
+type challengeRequest struct {
+	Receipt   json.RawMessage `json:"receipt"`
+	Challenge *tecp.Challenge `json:"challenge"`
+}
+
+// ChallengeHandler answers possession challenges for receipts issued with
+// client's key. Verifiers POST the receipt and a tecp.Challenge as JSON,
+// as ProvePossession does, and get back a tecp.PossessionProof:
+//
+//	mux.Handle("/tecp/challenge", tecphttp.ChallengeHandler(client))
+func ChallengeHandler(client *tecp.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var request challengeRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, tecp.MaxCompactReceiptSize)).Decode(&request); err != nil || request.Challenge == nil {
+			http.Error(w, "invalid challenge", http.StatusBadRequest)
+			return
+		}
+		receipt, err := tecp.Decode(request.Receipt)
+		if err != nil {
+			http.Error(w, "invalid receipt", http.StatusBadRequest)
+			return
+		}
+		proof, err := client.AnswerChallenge(receipt, request.Challenge)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proof)
+	})
+}
+
+// ProvePossession challenges the issuer's ChallengeHandler at url to prove
+// it still controls receipt's signing key, and verifies the answer. A nil
+// client uses tecp.DefaultHTTPClient
+func ProvePossession(url string, receipt *tecp.Receipt, ttl time.Duration, client *http.Client) error {
+	challenge, err := tecp.NewChallenge(receipt, ttl)
+	if err != nil {
+		return err
+	}
+	data, err := receipt.ToJSON()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(&challengeRequest{Receipt: data, Challenge: challenge})
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = tecp.DefaultHTTPClient()
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("tecphttp: possession challenge failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("tecphttp: possession challenge refused: status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	var proof tecp.PossessionProof
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&proof); err != nil {
+		return fmt.Errorf("tecphttp: invalid possession proof: %w", err)
+	}
+	if err := tecp.VerifyPossession(receipt, challenge, &proof); err != nil {
+		return fmt.Errorf("tecphttp: %w", err)
+	}
+	return nil
+}
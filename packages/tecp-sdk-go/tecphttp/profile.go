@@ -0,0 +1,109 @@
+package tecphttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Profile negotiation headers. A client lists the profiles it accepts in
+// HeaderAcceptProfile, most preferred first. A server answers with the
+// profile its receipt was issued for in HeaderProfile or, when the client
+// did not ask, every profile it supports
+const (
+	HeaderAcceptProfile = "TECP-Accept-Profile"
+	HeaderProfile       = "TECP-Profile"
+)
+
+type profileKey struct{}
+
+// ProfileFromContext returns the profile negotiated for the request whose
+// context is ctx, for handlers and Options.Extensions that add what the
+// profile requires, such as a determinism declaration for tecp-strict
+func ProfileFromContext(ctx context.Context) (tecp.Profile, bool) {
+	profile, ok := ctx.Value(profileKey{}).(tecp.Profile)
+	return profile, ok
+}
+
+// ParseProfiles parses a profile negotiation header
+func ParseProfiles(header string) []tecp.Profile {
+	var profiles []tecp.Profile
+	for _, field := range strings.Split(header, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			profiles = append(profiles, tecp.Profile(strings.ToLower(field)))
+		}
+	}
+	return profiles
+}
+
+// formatProfiles formats profiles for a negotiation header
+func formatProfiles(profiles []tecp.Profile) string {
+	fields := make([]string, len(profiles))
+	for i, profile := range profiles {
+		fields[i] = string(profile)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// negotiate picks the profile to issue a request's receipt for: the first
+// the client accepts that the server supports, or the server's first when
+// the client did not ask. It reports false when no accepted profile is
+// supported
+func negotiate(r *http.Request, supported []tecp.Profile) (tecp.Profile, bool) {
+	accepted := ParseProfiles(r.Header.Get(HeaderAcceptProfile))
+	if len(accepted) == 0 {
+		return supported[0], true
+	}
+	for _, profile := range accepted {
+		for _, offered := range supported {
+			if profile == offered {
+				return profile, true
+			}
+		}
+	}
+	return "", false
+}
+
+// negotiateProfile applies Options.Profiles, or those of the route's
+// template, to a request, returning it with the negotiated profile in its
+// context. It answers requests accepting no supported profile with 406 and
+// reports false
+func (is issuer) negotiateProfile(w http.ResponseWriter, r *http.Request, template *Template) (*http.Request, bool) {
+	supported := is.options.Profiles
+	if template != nil && len(template.Profiles) > 0 {
+		supported = template.Profiles
+	}
+	if len(supported) == 0 {
+		return r, true
+	}
+	profile, ok := negotiate(r, supported)
+	if !ok {
+		w.Header().Set(HeaderProfile, formatProfiles(supported))
+		http.Error(w, "none of the accepted TECP profiles is supported", http.StatusNotAcceptable)
+		return r, false
+	}
+	if r.Header.Get(HeaderAcceptProfile) == "" {
+		w.Header().Set(HeaderProfile, formatProfiles(supported))
+	} else {
+		w.Header().Set(HeaderProfile, string(profile))
+	}
+	return r.WithContext(context.WithValue(r.Context(), profileKey{}, profile)), true
+}
+
+// responseProfile returns the profile to verify a response's receipt
+// under: the one the server announced, which must be accepted, or the
+// least preferred accepted profile when the server announced none
+func responseProfile(resp *http.Response, accepted []tecp.Profile) (tecp.Profile, bool) {
+	announced := ParseProfiles(resp.Header.Get(HeaderProfile))
+	if len(announced) == 0 {
+		return accepted[len(accepted)-1], true
+	}
+	for _, profile := range accepted {
+		if announced[0] == profile {
+			return profile, true
+		}
+	}
+	return announced[0], false
+}
@@ -0,0 +1,222 @@
+package tecphttp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// HeaderReceiptRef replaces HeaderReceipt when a receipt is too large for a
+// header. It holds the receipt's retrieval URL, or its hex hash when no
+// URL is configured
+const HeaderReceiptRef = "TECP-Receipt-Ref"
+
+// ReferenceExtension records where reference-mode retrievals of a receipt
+// are served
+const ReferenceExtension = "http_reference"
+
+// Reference is the http_reference extension
+type Reference struct {
+	// URL is the Options.ReferenceURL the receipt's references start with
+	URL string `json:"url"`
+}
+
+// DefaultMaxHeaderSize is the largest encoded receipt sent inline. Proxies
+// commonly cap single headers at 4-8 KB
+const DefaultMaxHeaderSize = 4096
+
+// setReceipt attaches a receipt to the response headers, switching to
+// reference mode when the encoding exceeds the header limit
+func (is issuer) setReceipt(header http.Header, receipt *tecp.Receipt, encoded string) error {
+	limit := is.options.MaxHeaderSize
+	if limit <= 0 {
+		limit = DefaultMaxHeaderSize
+	}
+	if len(encoded) <= limit {
+		header.Set(HeaderReceipt, encoded)
+		return nil
+	}
+
+	if is.options.Store == nil {
+		return fmt.Errorf("tecphttp: receipt of %d bytes exceeds header limit and no store is configured", len(encoded))
+	}
+	key, err := is.options.Store.Put(receipt)
+	if err != nil {
+		return fmt.Errorf("tecphttp: failed to store receipt: %w", err)
+	}
+	ref := key
+	if is.options.ReferenceURL != "" {
+		ref = strings.TrimSuffix(is.options.ReferenceURL, "/") + "/" + key
+	}
+	header.Set(HeaderReceiptRef, ref)
+	return nil
+}
+
+// reference returns the http_reference extension for receipts that may be
+// sent in reference mode, or nil
+func (is issuer) reference() *Reference {
+	if is.options.Store == nil || is.options.ReferenceURL == "" {
+		return nil
+	}
+	return &Reference{URL: strings.TrimSuffix(is.options.ReferenceURL, "/") + "/"}
+}
+
+// checkReference rejects a receipt fetched from target when its signed
+// http_reference extension names another location
+func checkReference(receipt *tecp.Receipt, target *url.URL) error {
+	if receipt.SignedExt == nil || !containsString(receipt.SignedExt.Names, ReferenceExtension) {
+		return nil
+	}
+	data, err := json.Marshal(receipt.Extensions[ReferenceExtension])
+	if err != nil {
+		return fmt.Errorf("tecphttp: invalid %s extension: %w", ReferenceExtension, err)
+	}
+	var reference Reference
+	if err := json.Unmarshal(data, &reference); err != nil {
+		return fmt.Errorf("tecphttp: invalid %s extension: %w", ReferenceExtension, err)
+	}
+	// Relative reference URLs are served by the same origin
+	served, err := url.Parse(reference.URL)
+	if err != nil {
+		return fmt.Errorf("tecphttp: invalid %s extension: %w", ReferenceExtension, err)
+	}
+	served = target.ResolveReference(served)
+	if !strings.HasPrefix(target.String(), served.String()) {
+		return fmt.Errorf("tecphttp: receipt fetched from %s is served under %s", target, served)
+	}
+	return nil
+}
+
+// ReceiptHandler serves stored receipts as JSON at paths ending in their
+// hex key, for reference-mode retrieval:
+//
+//	mux.Handle("/receipts/", tecphttp.ReceiptHandler(receipts))
+func ReceiptHandler(s store.ReceiptStore) http.Handler {
+	return CachingReceiptHandler(s, CacheOptions{})
+}
+
+// CachingReceiptHandler is ReceiptHandler with the lifetime of served
+// receipts bounded by options, so CDNs and clients can cache them until
+// they expire. Receipts carry their hash as a strong ETag and conditional
+// requests are answered with 304 Not Modified:
+//
+//	mux.Handle("/receipts/", tecphttp.CachingReceiptHandler(archive, tecphttp.CacheOptions{
+//	    Expiry: retentionManager.Expiry,
+//	}))
+func CachingReceiptHandler(s store.ReceiptStore, options CacheOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		key := path.Base(r.URL.Path)
+		if _, err := hex.DecodeString(key); err != nil || len(key) != 64 {
+			http.Error(w, "invalid receipt key", http.StatusBadRequest)
+			return
+		}
+
+		receipt, err := s.Get(key)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "receipt not found", http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, "failed to load receipt", http.StatusInternalServerError)
+			return
+		}
+
+		data, err := receipt.ToJSON()
+		if err != nil {
+			http.Error(w, "failed to encode receipt", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", receiptETag(key))
+		w.Header().Set("Cache-Control", options.cacheControl(receipt, time.Now()))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	})
+}
+
+// FetchReference retrieves the receipt a reference-mode response points to.
+// The reference must be a URL, resolved against base when relative, and
+// the fetched receipt must hash to the key it ends in
+func FetchReference(ref string, base *url.URL, client *http.Client) (*tecp.Receipt, error) {
+	target, err := resolveReference(ref, base)
+	if err != nil {
+		return nil, err
+	}
+	receipt, _, err := fetchReference(target, "", client)
+	return receipt, err
+}
+
+// resolveReference returns the URL a receipt reference points to
+func resolveReference(ref string, base *url.URL) (*url.URL, error) {
+	// A bare hash identifies the receipt but not where to fetch it
+	target, err := url.Parse(ref)
+	if err != nil || !strings.Contains(ref, "/") || (!target.IsAbs() && base == nil) {
+		return nil, fmt.Errorf("tecphttp: receipt reference %q is not retrievable", ref)
+	}
+	if base != nil {
+		target = base.ResolveReference(target)
+	}
+	return target, nil
+}
+
+// fetchReference retrieves the receipt at target, which must hash to the
+// key it ends in. With an etag, the request is conditional and a nil
+// receipt is returned when the server answers 304 Not Modified
+func fetchReference(target *url.URL, etag string, client *http.Client) (*tecp.Receipt, http.Header, error) {
+	if client == nil {
+		client = tecp.DefaultHTTPClient()
+	}
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tecphttp: failed to fetch receipt: %w", err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tecphttp: failed to fetch receipt: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, resp.Header, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("tecphttp: failed to fetch receipt: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, tecp.MaxCompactReceiptSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("tecphttp: failed to fetch receipt: %w", err)
+	}
+
+	receipt, err := tecp.FromJSON(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tecphttp: invalid referenced receipt: %w", err)
+	}
+	key, err := store.Key(receipt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key != path.Base(target.Path) {
+		return nil, nil, fmt.Errorf("tecphttp: referenced receipt does not match its reference")
+	}
+	if err := checkReference(receipt, target); err != nil {
+		return nil, nil, err
+	}
+	return receipt, resp.Header, nil
+}
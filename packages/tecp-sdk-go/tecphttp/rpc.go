@@ -0,0 +1,124 @@
+package tecphttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// RPCExtension records the RPC a receipt covers
+const RPCExtension = "rpc"
+
+// RPC protocols recorded in the extension
+const (
+	ProtocolConnect = "connect"
+	ProtocolTwirp   = "twirp"
+)
+
+// Call is the rpc extension
+type Call struct {
+	Protocol  string `json:"protocol"`
+	Procedure string `json:"procedure"`
+}
+
+// Connect wraps a Connect-go service handler so unary calls get receipts.
+// It takes and returns the generated constructor's values:
+//
+//	mux.Handle(tecphttp.Connect(greetv1connect.NewGreetServiceHandler(svc), options))
+//
+// Unary calls use the request body as input, or the encoded message query
+// parameter for GET requests. Streaming calls and the gRPC protocols need
+// trailers and unbuffered bodies, so they pass through without receipts
+func Connect(path string, handler http.Handler, options Options) (string, http.Handler) {
+	is := issuer{
+		options: options,
+		extension: func(r *http.Request, status int) (string, interface{}) {
+			return RPCExtension, &Call{Protocol: ProtocolConnect, Procedure: r.URL.Path}
+		},
+		input: func(r *http.Request, body []byte) []byte {
+			if r.Method == http.MethodGet {
+				return []byte(r.URL.Query().Get("message"))
+			}
+			return body
+		},
+	}
+	wrapped := is.wrap(handler)
+
+	return path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !connectUnary(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		wrapped.ServeHTTP(w, r)
+	})
+}
+
+// connectUnary reports whether r is a Connect protocol unary call
+func connectUnary(r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return r.URL.Query().Get("connect") == "v1"
+	}
+	contentType := r.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, "application/") &&
+		!strings.HasPrefix(contentType, "application/connect+") &&
+		!strings.HasPrefix(contentType, "application/grpc")
+}
+
+// Twirp wraps a generated Twirp server so every successful call gets a
+// receipt. The procedure is the path below the server's prefix, as in
+// "/twirp/example.Haberdasher/MakeHat"
+func Twirp(server http.Handler, options Options) http.Handler {
+	return issuer{options: options, extension: func(r *http.Request, status int) (string, interface{}) {
+		return RPCExtension, &Call{Protocol: ProtocolTwirp, Procedure: r.URL.Path}
+	}}.wrap(server)
+}
+
+// HTTPClient is the client interface accepted by Connect-go and Twirp
+// generated clients
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// VerifyingClient verifies the receipt of every response it receives. Pass it
+// as the HTTP client of a Connect-go or Twirp generated client
+type VerifyingClient struct {
+	// HTTPClient performs the requests; defaults to http.DefaultClient
+	HTTPClient HTTPClient
+
+	Verifier *tecp.Client
+	Options  tecp.VerifyOptions
+
+	// Required rejects successful responses without a receipt
+	Required bool
+
+	// AcceptProfiles negotiates profiles as TransportOptions.AcceptProfiles
+	AcceptProfiles []tecp.Profile
+
+	// References caches referenced receipts as TransportOptions.References
+	References *ReferenceCache
+}
+
+// Do performs req and verifies the response's receipt. Responses with an
+// invalid receipt are rejected
+func (c *VerifyingClient) Do(req *http.Request) (*http.Response, error) {
+	inner := c.HTTPClient
+	if inner == nil {
+		inner = http.DefaultClient
+	}
+	if len(c.AcceptProfiles) > 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set(HeaderAcceptProfile, formatProfiles(c.AcceptProfiles))
+	}
+	resp, err := inner.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	_, failure := checkResponse(resp, TransportOptions{Verifier: c.Verifier, Options: c.Options, Required: c.Required, AcceptProfiles: c.AcceptProfiles, References: c.References})
+	if failure != nil {
+		resp.Body.Close()
+		return nil, failure
+	}
+	return resp, nil
+}
@@ -0,0 +1,435 @@
+// Package tecphttp issues and verifies TECP receipts for HTTP exchanges.
+//
+// Handler wraps a net/http handler so every successful response carries a
+// receipt over the request and response bodies in the TECP-Receipt header,
+// in the compact encoding of tecp.EncodeCompact. Receipts too large for a
+// header are stored and referenced by TECP-Receipt-Ref instead.
+// VerifyResponse checks such a receipt on the client side. Connect and Twirp services are served by
+// net/http handlers and have dedicated wrappers in this package.
+package tecphttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// HeaderReceipt carries the compact-encoded receipt of a response
+const HeaderReceipt = "TECP-Receipt"
+
+// Extension records the HTTP exchange a receipt covers
+const Extension = "http"
+
+func init() {
+	tecp.RegisterExtension(Extension)
+	tecp.RegisterExtension(RPCExtension)
+	tecp.RegisterExtension(ReferenceExtension)
+}
+
+// WarningBodyUnbound: the receipt's output hash is a salted or keyed
+// commitment, so VerifyResponse cannot check that it covers the body
+const WarningBodyUnbound = "W-HTTP-001"
+
+// DefaultMaxBodySize bounds the bodies buffered for hashing
+const DefaultMaxBodySize = 10 << 20
+
+// Options configures receipt issuance
+type Options struct {
+	// Client signs the receipts
+	Client *tecp.Client
+
+	Policies []string
+	CodeRef  string
+
+	// MaxBodySize bounds buffered request and response bodies. Larger
+	// requests are rejected; larger responses are streamed without a receipt
+	MaxBodySize int64
+
+	// Extensions, when set, adds extensions for a request
+	Extensions func(r *http.Request) map[string]interface{}
+
+	// OnReceipt, when set, is called with every issued receipt
+	OnReceipt func(r *http.Request, receipt *tecp.Receipt)
+
+	// OnError, when set, is called when a receipt cannot be issued; the
+	// response is then sent without one
+	OnError func(r *http.Request, err error)
+
+	// Route, when set, names the matched route pattern of a request, as
+	// recorded by routers, once the handler has run
+	Route func(r *http.Request) string
+
+	// MaxHeaderSize is the largest encoded receipt sent in HeaderReceipt;
+	// defaults to DefaultMaxHeaderSize
+	MaxHeaderSize int
+
+	// Store keeps receipts too large for a header, enabling reference mode
+	Store store.ReceiptStore
+
+	// ReferenceURL is the base URL of a ReceiptHandler serving Store. When
+	// empty, references carry only the receipt's hex hash
+	ReferenceURL string
+
+	// Idempotency, when set, returns the same receipt for retries of a
+	// request carrying an Idempotency-Key header. Requests with the header
+	// always get a nonce derived from the key, cache or not
+	Idempotency *IdempotencyCache
+
+	// Profiles, when set, are the profiles the issued receipts satisfy,
+	// most preferred first. They are negotiated with clients through
+	// HeaderAcceptProfile and HeaderProfile, and requests accepting none of
+	// them are answered with 406
+	Profiles []tecp.Profile
+
+	// Templates, when set, are the claims required per route, keyed by
+	// path pattern as in "/v1/phi/" or "POST /v1/phi/notes". Every
+	// matching template applies, the more specific ones taking precedence
+	Templates map[string]Template
+
+	// Transform, when set, normalizes the receipt input before it is
+	// hashed, such as tecp.DropFields for volatile request fields
+	Transform tecp.Transform
+}
+
+// Exchange is the http extension
+type Exchange struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Route  string `json:"route,omitempty"`
+	Status int    `json:"status"`
+
+	// Profile is the negotiated profile, when Options.Profiles is set
+	Profile tecp.Profile `json:"profile,omitempty"`
+}
+
+type contextKey struct{}
+
+// receiptSlot is filled with the issued receipt once the handler returns
+type receiptSlot struct {
+	receipt *tecp.Receipt
+}
+
+// ReceiptFromContext returns the receipt issued for the request whose
+// context is ctx. Receipts are issued after the handler returns, so it is
+// only set for code that runs after the wrapped handler, such as outer
+// middleware
+func ReceiptFromContext(ctx context.Context) (*tecp.Receipt, bool) {
+	slot, ok := ctx.Value(contextKey{}).(*receiptSlot)
+	if !ok || slot.receipt == nil {
+		return nil, false
+	}
+	return slot.receipt, true
+}
+
+// WithReceiptSlot returns a copy of r whose context will carry the receipt
+// issued for it, for adapters that hand the request to an outer router
+func WithReceiptSlot(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(contextKey{}).(*receiptSlot); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, &receiptSlot{}))
+}
+
+// Handler returns a handler that issues a receipt for every 2xx response of
+// next
+func Handler(next http.Handler, options Options) http.Handler {
+	return issuer{options: options, extension: func(r *http.Request, status int) (string, interface{}) {
+		exchange := &Exchange{Method: r.Method, Path: r.URL.Path, Status: status}
+		if options.Route != nil {
+			exchange.Route = options.Route(r)
+		}
+		exchange.Profile, _ = ProfileFromContext(r.Context())
+		return Extension, exchange
+	}}.wrap(next)
+}
+
+// Middleware returns Handler as middleware
+func Middleware(options Options) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return Handler(next, options)
+	}
+}
+
+// issuer wraps handlers with receipt issuance, recording the exchange under
+// the extension returned by extension
+type issuer struct {
+	options   Options
+	extension func(r *http.Request, status int) (string, interface{})
+
+	// input, when set, selects the receipt input instead of the request body
+	input func(r *http.Request, body []byte) []byte
+}
+
+func (is issuer) wrap(next http.Handler) http.Handler {
+	limit := is.options.MaxBodySize
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		template := is.options.template(r)
+		r, ok := is.negotiateProfile(w, r, template)
+		if !ok {
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) > limit {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r = WithReceiptSlot(r)
+
+		rec := &recorder{w: w, header: make(http.Header), limit: limit}
+		next.ServeHTTP(rec, r)
+		if rec.streaming {
+			return
+		}
+
+		status := rec.statusCode()
+		if status >= 200 && status < 300 {
+			input := body
+			if is.input != nil {
+				input = is.input(r, body)
+			}
+			if receipt, encoded, err := is.issue(r, template, status, input, rec.body.Bytes()); err != nil {
+				if is.options.OnError != nil {
+					is.options.OnError(r, err)
+				}
+			} else if err := is.setReceipt(rec.header, receipt, encoded); err != nil {
+				if is.options.OnError != nil {
+					is.options.OnError(r, err)
+				}
+			} else {
+				r.Context().Value(contextKey{}).(*receiptSlot).receipt = receipt
+			}
+		}
+		rec.flush()
+	})
+}
+
+// issue creates and encodes the receipt for an exchange, with the claims
+// of the route's template
+func (is issuer) issue(r *http.Request, template *Template, status int, input, output []byte) (*tecp.Receipt, string, error) {
+	if is.options.Client == nil {
+		return nil, "", fmt.Errorf("tecphttp: client required")
+	}
+
+	extensions := make(map[string]interface{})
+	if is.options.Extensions != nil {
+		for name, value := range is.options.Extensions(r) {
+			extensions[name] = value
+		}
+	}
+	// The exchange and reference descriptor tie the receipt to this
+	// response, so they are signed
+	name, value := is.extension(r, status)
+	extensions[name] = value
+	signed := []string{name}
+	if reference := is.reference(); reference != nil {
+		extensions[ReferenceExtension] = reference
+		signed = append(signed, ReferenceExtension)
+	}
+
+	// A retry with the same exchange gets the receipt issued the first time
+	scope := idempotencyScope(r)
+	cache := is.options.Idempotency
+	receipt, cached := (*tecp.Receipt)(nil), false
+	if scope != "" && cache != nil {
+		receipt, cached = cache.get(scope, input, output)
+	}
+
+	if !cached {
+		options := tecp.CreateReceiptOptions{
+			Input:            input,
+			Output:           output,
+			Policies:         is.options.Policies,
+			CodeRef:          is.options.CodeRef,
+			Extensions:       extensions,
+			SignedExtensions: signed,
+			IdempotencyKey:   scope,
+			Transform:        is.options.Transform,
+		}
+		if err := template.apply(&options); err != nil {
+			return nil, "", err
+		}
+		var err error
+		receipt, err = is.options.Client.CreateReceipt(options)
+		if err != nil {
+			return nil, "", err
+		}
+		if scope != "" && cache != nil {
+			cache.put(scope, receipt, input, output)
+		}
+	}
+	encoded, err := tecp.EncodeCompact(receipt)
+	if err != nil {
+		return nil, "", err
+	}
+	if is.options.OnReceipt != nil {
+		is.options.OnReceipt(r, receipt)
+	}
+	return receipt, encoded, nil
+}
+
+// recorder buffers a response so the receipt header can be set after the
+// handler has written the body. Once the body outgrows the limit the
+// response is streamed through without a receipt
+type recorder struct {
+	w         http.ResponseWriter
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	limit     int64
+	streaming bool
+}
+
+func (r *recorder) Header() http.Header {
+	if r.streaming {
+		return r.w.Header()
+	}
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.streaming {
+		r.w.WriteHeader(status)
+		return
+	}
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.streaming {
+		return r.w.Write(p)
+	}
+	if int64(r.body.Len()+len(p)) > r.limit {
+		r.streaming = true
+		r.flush()
+		return r.w.Write(p)
+	}
+	return r.body.Write(p)
+}
+
+// Flush streams the response from here on, so it goes out without a receipt
+func (r *recorder) Flush() {
+	if !r.streaming {
+		r.streaming = true
+		r.flush()
+	}
+	if flusher, ok := r.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *recorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// flush sends the buffered headers and body
+func (r *recorder) flush() {
+	header := r.w.Header()
+	for key, values := range r.header {
+		header[key] = values
+	}
+	if !r.streaming {
+		header.Set("Content-Length", strconv.Itoa(r.body.Len()))
+	}
+	r.w.WriteHeader(r.statusCode())
+	r.w.Write(r.body.Bytes())
+	r.body.Reset()
+}
+
+// VerifyResponse reads resp's body, restoring it for the caller, and
+// verifies the receipt in its TECP-Receipt header or trailer, or fetched
+// through its TECP-Receipt-Ref header: the receipt's output hash must match
+// the body and the receipt must pass verifier.VerifyReceipt. Commitment
+// hashes cannot be checked without their secret and are reported with
+// WarningBodyUnbound instead. It returns a nil receipt when the response
+// carries none. A nil verifier verifies with default options
+func VerifyResponse(resp *http.Response, verifier *tecp.Client, options tecp.VerifyOptions) (*tecp.Receipt, *tecp.VerificationResult, error) {
+	return verifyResponse(resp, verifier, options, nil)
+}
+
+// verifyResponse is VerifyResponse, fetching referenced receipts through
+// references when it is set
+func verifyResponse(resp *http.Response, verifier *tecp.Client, options tecp.VerifyOptions, references *ReferenceCache) (*tecp.Receipt, *tecp.VerificationResult, error) {
+	if verifier == nil {
+		verifier = tecp.NewClient()
+	}
+	encoded := resp.Header.Get(HeaderReceipt)
+	ref := resp.Header.Get(HeaderReceiptRef)
+	_, trailer := resp.Trailer[http.CanonicalHeaderKey(HeaderReceipt)]
+	if encoded == "" && ref == "" && !trailer {
+		return nil, nil, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("tecphttp: failed to read response body: %w", err)
+	}
+
+	// Trailers are only filled in once the body has been read
+	if encoded == "" && ref == "" {
+		if encoded = resp.Trailer.Get(HeaderReceipt); encoded == "" {
+			return nil, nil, nil
+		}
+	}
+
+	var receipt *tecp.Receipt
+	if encoded != "" {
+		if receipt, err = tecp.DecodeCompact(encoded); err != nil {
+			return nil, nil, fmt.Errorf("tecphttp: invalid receipt header: %w", err)
+		}
+	} else {
+		var base *url.URL
+		if resp.Request != nil {
+			base = resp.Request.URL
+		}
+		if references != nil {
+			receipt, err = references.Fetch(ref, base, nil)
+		} else {
+			receipt, err = FetchReference(ref, base, nil)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	result, err := verifier.VerifyReceipt(receipt, options)
+	if err != nil {
+		return receipt, nil, err
+	}
+	if receipt.Salted() || receipt.Keyed() {
+		result.Warnings = append(result.Warnings, "cannot verify body binding: receipt output hash is a commitment")
+		result.WarningCodes = append(result.WarningCodes, WarningBodyUnbound)
+		return receipt, result, nil
+	}
+	outputHash := sha256.Sum256(body)
+	claimed, _, err := tecp.DecodeBinary(receipt.OutputHash, sha256.Size)
+	if err != nil || !bytes.Equal(claimed, outputHash[:]) {
+		result.Valid = false
+		result.Errors = append(result.Errors, "receipt output hash does not match response body")
+	}
+	return receipt, result, nil
+}
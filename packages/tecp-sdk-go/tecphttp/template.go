@@ -0,0 +1,120 @@
+package tecphttp
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Template is the claims required of the receipts issued for a route, so
+// one gateway can issue strict receipts for some endpoints and lite ones
+// elsewhere
+type Template struct {
+	// Policies are claimed in addition to Options.Policies
+	Policies []string
+
+	// Extensions are added to every receipt, after Options.Extensions
+	Extensions map[string]interface{}
+
+	// RequiredExtensions must be supplied by Options.Extensions; receipts
+	// missing one are not issued, and OnError is called
+	RequiredExtensions []string
+
+	// Profiles, when set, replace Options.Profiles
+	Profiles []tecp.Profile
+
+	// Transform, when set, replaces Options.Transform
+	Transform tecp.Transform
+}
+
+// template returns the merged templates of the patterns in
+// Options.Templates matching r, or nil when none match. Patterns are
+// paths, optionally preceded by a method and a space as in "POST /v1/phi".
+// A pattern ending in a slash matches every path under it, as with
+// http.ServeMux; others match the path exactly. Templates are merged from
+// the least to the most specific, so a route keeps the claims of the
+// subtrees it is in: policies and required extensions accumulate, and the
+// most specific extension values, profiles and transform win
+func (o *Options) template(r *http.Request) *Template {
+	type match struct {
+		template    Template
+		specificity int
+	}
+	var matches []match
+	for pattern, template := range o.Templates {
+		path := pattern
+		if method, rest, ok := strings.Cut(pattern, " "); ok {
+			if method != r.Method {
+				continue
+			}
+			path = strings.TrimSpace(rest)
+		}
+		if r.URL.Path != path && !(strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path)) {
+			continue
+		}
+		// Method-specific patterns win over equally long general ones
+		specificity := 2 * len(path)
+		if path != pattern {
+			specificity++
+		}
+		matches = append(matches, match{template, specificity})
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].specificity < matches[j].specificity })
+
+	merged := &Template{Extensions: make(map[string]interface{})}
+	for _, m := range matches {
+		merged.Policies = append(merged.Policies, m.template.Policies...)
+		merged.RequiredExtensions = append(merged.RequiredExtensions, m.template.RequiredExtensions...)
+		for name, value := range m.template.Extensions {
+			merged.Extensions[name] = value
+		}
+		if len(m.template.Profiles) > 0 {
+			merged.Profiles = m.template.Profiles
+		}
+		if m.template.Transform != "" {
+			merged.Transform = m.template.Transform
+		}
+	}
+	return merged
+}
+
+// apply adds the template's claims to a receipt's options
+func (t *Template) apply(options *tecp.CreateReceiptOptions) error {
+	if t == nil {
+		return nil
+	}
+	policies := append([]string(nil), options.Policies...)
+	for _, id := range t.Policies {
+		if !containsString(policies, id) {
+			policies = append(policies, id)
+		}
+	}
+	options.Policies = policies
+	for _, name := range t.RequiredExtensions {
+		if _, ok := options.Extensions[name]; !ok {
+			return fmt.Errorf("tecphttp: route requires the %s extension", name)
+		}
+	}
+	for name, value := range t.Extensions {
+		options.Extensions[name] = value
+	}
+	if t.Transform != "" {
+		options.Transform = t.Transform
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package tecphttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Verification is the outcome of checking a response's receipt
+type Verification struct {
+	// Receipt is nil when the response carried none
+	Receipt *tecp.Receipt
+	Result  *tecp.VerificationResult
+
+	// Profile is the profile the receipt was verified under, when
+	// TransportOptions.AcceptProfiles is set
+	Profile tecp.Profile
+
+	// Err is set when the receipt could not be decoded, fetched or verified
+	Err error
+}
+
+// Valid reports whether the response carried a receipt that verified
+func (v *Verification) Valid() bool {
+	return v.Err == nil && v.Result != nil && v.Result.Valid
+}
+
+// TransportOptions configures a verifying transport
+type TransportOptions struct {
+	// Verifier verifies receipts; defaults to a client with default options
+	Verifier *tecp.Client
+	Options  tecp.VerifyOptions
+
+	// Required treats successful responses without a receipt as failures
+	Required bool
+
+	// FailClosed turns failures into round trip errors. Otherwise the
+	// response is returned and the failure recorded in its Verification
+	FailClosed bool
+
+	// AcceptProfiles, when set, are sent in HeaderAcceptProfile, most
+	// preferred first. Receipts are verified under the profile the server
+	// announces in HeaderProfile, which must be one of them, or under the
+	// last of them when the server does not negotiate
+	AcceptProfiles []tecp.Profile
+
+	// References, when set, caches receipts fetched through
+	// TECP-Receipt-Ref references, revalidating them once stale
+	References *ReferenceCache
+}
+
+type verificationKey struct{}
+
+// VerificationFromResponse returns the verification recorded for a response
+// received through a verifying transport
+func VerificationFromResponse(resp *http.Response) (*Verification, bool) {
+	if resp.Request == nil {
+		return nil, false
+	}
+	return VerificationFromContext(resp.Request.Context())
+}
+
+// VerificationFromContext returns the verification recorded in the context
+// of a response's request
+func VerificationFromContext(ctx context.Context) (*Verification, bool) {
+	v, ok := ctx.Value(verificationKey{}).(*Verification)
+	return v, ok
+}
+
+// NewVerifyingTransport returns a transport that verifies the receipt of
+// every response, from its TECP-Receipt header or trailer or its
+// TECP-Receipt-Ref reference. The outcome is available from
+// VerificationFromResponse. A nil inner transport uses
+// http.DefaultTransport
+func NewVerifyingTransport(inner http.RoundTripper, options TransportOptions) http.RoundTripper {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	return &verifyingTransport{inner: inner, options: options}
+}
+
+type verifyingTransport struct {
+	inner   http.RoundTripper
+	options TransportOptions
+}
+
+func (t *verifyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.options.AcceptProfiles) > 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set(HeaderAcceptProfile, formatProfiles(t.options.AcceptProfiles))
+	}
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	verification, failure := checkResponse(resp, t.options)
+	if failure != nil && t.options.FailClosed {
+		resp.Body.Close()
+		return nil, failure
+	}
+	if resp.Request != nil {
+		resp.Request = resp.Request.WithContext(context.WithValue(resp.Request.Context(), verificationKey{}, verification))
+	}
+	return resp, nil
+}
+
+// checkResponse verifies a response's receipt and describes why it should be
+// rejected, if it should
+func checkResponse(resp *http.Response, options TransportOptions) (*Verification, error) {
+	path := ""
+	if resp.Request != nil {
+		path = resp.Request.URL.Path
+	}
+	var profile tecp.Profile
+	if len(options.AcceptProfiles) > 0 {
+		var ok bool
+		if profile, ok = responseProfile(resp, options.AcceptProfiles); !ok {
+			err := fmt.Errorf("tecphttp: response from %s is for profile %s, which was not accepted", path, profile)
+			if resp.StatusCode == http.StatusNotAcceptable {
+				err = fmt.Errorf("tecphttp: %s supports none of the accepted profiles, only %s", path, resp.Header.Get(HeaderProfile))
+			}
+			return &Verification{Err: err}, err
+		}
+		options.Options.Profile = profile
+	}
+
+	receipt, result, err := verifyResponse(resp, options.Verifier, options.Options, options.References)
+	verification := &Verification{Receipt: receipt, Result: result, Profile: profile, Err: err}
+	switch {
+	case err != nil:
+		return verification, err
+	case receipt == nil && options.Required && resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return verification, fmt.Errorf("tecphttp: response from %s has no receipt", path)
+	case result != nil && !result.Valid:
+		return verification, fmt.Errorf("tecphttp: invalid receipt from %s: %s", path, strings.Join(result.Errors, "; "))
+	}
+	return verification, nil
+}
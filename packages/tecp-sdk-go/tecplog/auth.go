@@ -0,0 +1,200 @@
+package tecplog
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Endpoint names a log API endpoint for authentication
+type Endpoint string
+
+const (
+	EndpointEntries Endpoint = "entries"
+	EndpointProof   Endpoint = "proof"
+	EndpointLeaves  Endpoint = "leaves"
+	EndpointSTH     Endpoint = "sth"
+	EndpointJWKS    Endpoint = "jwks"
+)
+
+// Method is a set of accepted authentication methods
+type Method int
+
+const (
+	// MethodAPIKey accepts a static API key in the X-API-Key header or as a
+	// bearer token
+	MethodAPIKey Method = 1 << iota
+
+	// MethodMTLS accepts a verified client certificate with an allowed
+	// SPIFFE ID
+	MethodMTLS
+)
+
+// Auth restricts which issuers may call a log's endpoints
+type Auth struct {
+	// APIKeys maps accepted API keys to the issuer each identifies
+	APIKeys map[string]string
+
+	// SPIFFEIDs lists accepted client certificate SPIFFE IDs. An entry
+	// ending in "/*" accepts every ID below that path
+	SPIFFEIDs []string
+
+	// Endpoints lists the methods accepted by each protected endpoint.
+	// Endpoints not listed are public. Defaults to protecting submissions
+	// with either method
+	Endpoints map[Endpoint]Method
+}
+
+// authenticator enforces an Auth configuration
+type authenticator struct {
+	apiKeys   map[string]string
+	spiffeIDs []string
+	endpoints map[Endpoint]Method
+}
+
+func newAuthenticator(config Auth) (*authenticator, error) {
+	for _, id := range config.SPIFFEIDs {
+		if !strings.HasPrefix(id, "spiffe://") {
+			return nil, fmt.Errorf("tecplog: invalid SPIFFE ID %q", id)
+		}
+	}
+	endpoints := config.Endpoints
+	if endpoints == nil {
+		endpoints = map[Endpoint]Method{EndpointEntries: MethodAPIKey | MethodMTLS}
+	}
+	return &authenticator{
+		apiKeys:   config.APIKeys,
+		spiffeIDs: config.SPIFFEIDs,
+		endpoints: endpoints,
+	}, nil
+}
+
+// authenticate returns the issuer making the request. The status is 0 when
+// the request may proceed, 401 when it carries no credential and 403 when
+// its credential is not accepted
+func (a *authenticator) authenticate(endpoint Endpoint, r *http.Request) (string, int) {
+	methods, protected := a.endpoints[endpoint]
+	if !protected {
+		return "", 0
+	}
+
+	presented := false
+	if methods&MethodMTLS != 0 {
+		if id, ok := peerSPIFFEID(r); ok {
+			presented = true
+			if a.allowedSPIFFEID(id) {
+				return id, 0
+			}
+		}
+	}
+	if methods&MethodAPIKey != 0 {
+		if key := apiKey(r); key != "" {
+			presented = true
+			if issuer, ok := a.lookupAPIKey(key); ok {
+				return issuer, 0
+			}
+		}
+	}
+
+	if !presented {
+		return "", http.StatusUnauthorized
+	}
+	return "", http.StatusForbidden
+}
+
+// lookupAPIKey compares against every key in constant time
+func (a *authenticator) lookupAPIKey(key string) (string, bool) {
+	issuer, found := "", false
+	for candidate, name := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			issuer, found = name, true
+		}
+	}
+	return issuer, found
+}
+
+func (a *authenticator) allowedSPIFFEID(id string) bool {
+	for _, allowed := range a.spiffeIDs {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(id, prefix+"/") {
+				return true
+			}
+		} else if id == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// peerSPIFFEID returns the SPIFFE ID of a verified client certificate
+func peerSPIFFEID(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", false
+	}
+	return spiffeID(r.TLS.VerifiedChains[0][0])
+}
+
+// spiffeID returns a certificate's SPIFFE ID. SVIDs carry exactly one
+func spiffeID(cert *x509.Certificate) (string, bool) {
+	var id string
+	for _, uri := range cert.URIs {
+		if uri.Scheme != "spiffe" {
+			continue
+		}
+		if id != "" {
+			return "", false
+		}
+		id = uri.String()
+	}
+	return id, id != ""
+}
+
+// ServerTLSConfig returns a TLS configuration that serves cert and verifies
+// client certificates against clientCAs. Client certificates stay optional
+// at the TLS layer so public endpoints remain reachable; Auth decides which
+// endpoints require them
+func ServerTLSConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// ClientTLSConfig returns a TLS configuration that presents cert to the log
+// and verifies the server against roots. When serverID is set the server's
+// certificate must carry that SPIFFE ID
+func ClientTLSConfig(cert tls.Certificate, roots *x509.CertPool, serverID string) *tls.Config {
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if serverID != "" {
+		// SPIFFE SVIDs identify workloads by URI rather than DNS name, so
+		// the chain is verified here instead of by hostname
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("tecplog: server presented no certificate")
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			leaf := state.PeerCertificates[0]
+			if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+				return fmt.Errorf("tecplog: server certificate: %w", err)
+			}
+			if id, ok := spiffeID(leaf); !ok || id != serverID {
+				return fmt.Errorf("tecplog: server SPIFFE ID %q does not match %q", id, serverID)
+			}
+			return nil
+		}
+	}
+	return config
+}
@@ -0,0 +1,115 @@
+package tecplog
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// withPeer attaches a verified client certificate carrying uris
+func withPeer(r *http.Request, uris ...string) *http.Request {
+	cert := &x509.Certificate{}
+	for _, raw := range uris {
+		u, _ := url.Parse(raw)
+		cert.URIs = append(cert.URIs, u)
+	}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	a, err := newAuthenticator(Auth{
+		APIKeys:   map[string]string{"key-a": "issuer-a", "key-b": "issuer-b"},
+		SPIFFEIDs: []string{"spiffe://example.org/issuer", "spiffe://example.org/workers/*"},
+		Endpoints: map[Endpoint]Method{
+			EndpointEntries: MethodAPIKey | MethodMTLS,
+			EndpointLeaves:  MethodMTLS,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		endpoint Endpoint
+		request  func(r *http.Request) *http.Request
+		issuer   string
+		status   int
+	}{
+		{"public endpoint", EndpointSTH, func(r *http.Request) *http.Request { return r }, "", 0},
+		{"no credential", EndpointEntries, func(r *http.Request) *http.Request { return r }, "", http.StatusUnauthorized},
+		{"api key header", EndpointEntries, func(r *http.Request) *http.Request {
+			r.Header.Set("X-API-Key", "key-a")
+			return r
+		}, "issuer-a", 0},
+		{"bearer token", EndpointEntries, func(r *http.Request) *http.Request {
+			r.Header.Set("Authorization", "Bearer key-b")
+			return r
+		}, "issuer-b", 0},
+		{"unknown api key", EndpointEntries, func(r *http.Request) *http.Request {
+			r.Header.Set("X-API-Key", "key-c")
+			return r
+		}, "", http.StatusForbidden},
+		{"api key prefix", EndpointEntries, func(r *http.Request) *http.Request {
+			r.Header.Set("X-API-Key", "key-")
+			return r
+		}, "", http.StatusForbidden},
+		{"api key where only mTLS is accepted", EndpointLeaves, func(r *http.Request) *http.Request {
+			r.Header.Set("X-API-Key", "key-a")
+			return r
+		}, "", http.StatusUnauthorized},
+		{"exact SPIFFE ID", EndpointLeaves, func(r *http.Request) *http.Request {
+			return withPeer(r, "spiffe://example.org/issuer")
+		}, "spiffe://example.org/issuer", 0},
+		{"SPIFFE ID below wildcard", EndpointEntries, func(r *http.Request) *http.Request {
+			return withPeer(r, "spiffe://example.org/workers/7")
+		}, "spiffe://example.org/workers/7", 0},
+		{"wildcard parent", EndpointEntries, func(r *http.Request) *http.Request {
+			return withPeer(r, "spiffe://example.org/workers")
+		}, "", http.StatusForbidden},
+		{"wildcard sibling prefix", EndpointEntries, func(r *http.Request) *http.Request {
+			return withPeer(r, "spiffe://example.org/workers-evil/7")
+		}, "", http.StatusForbidden},
+		{"other trust domain", EndpointEntries, func(r *http.Request) *http.Request {
+			return withPeer(r, "spiffe://evil.org/issuer")
+		}, "", http.StatusForbidden},
+		{"certificate with two SPIFFE IDs", EndpointEntries, func(r *http.Request) *http.Request {
+			return withPeer(r, "spiffe://example.org/issuer", "spiffe://example.org/workers/1")
+		}, "", http.StatusUnauthorized},
+		{"rejected certificate falls back to api key", EndpointEntries, func(r *http.Request) *http.Request {
+			r.Header.Set("X-API-Key", "key-a")
+			return withPeer(r, "spiffe://evil.org/issuer")
+		}, "issuer-a", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.request(httptest.NewRequest(http.MethodPost, "/v1/log/entries", nil))
+			issuer, status := a.authenticate(tt.endpoint, r)
+			if issuer != tt.issuer || status != tt.status {
+				t.Fatalf("authenticate = %q, %d; want %q, %d", issuer, status, tt.issuer, tt.status)
+			}
+		})
+	}
+}
+
+func TestAuthDefaultsProtectSubmissions(t *testing.T) {
+	a, err := newAuthenticator(Auth{APIKeys: map[string]string{"key": "issuer"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/v1/log/entries", nil)
+	if _, status := a.authenticate(EndpointEntries, r); status != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated submission got status %d", status)
+	}
+	if _, status := a.authenticate(EndpointProof, r); status != 0 {
+		t.Fatalf("proof lookup got status %d", status)
+	}
+
+	if _, err := newAuthenticator(Auth{SPIFFEIDs: []string{"example.org/issuer"}}); err == nil {
+		t.Fatal("SPIFFE ID without scheme accepted")
+	}
+}
@@ -0,0 +1,226 @@
+package tecplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FaultKind is a failure injected by ChaosTransport
+type FaultKind string
+
+// Injectable faults
+const (
+	// FaultTimeout holds the request for Delay and fails it with a timeout
+	FaultTimeout FaultKind = "timeout"
+
+	// FaultServerError answers with Status, 503 by default
+	FaultServerError FaultKind = "server_error"
+
+	// FaultMalformedSTH passes the request through and corrupts the root
+	// of any tree head in the response, so its signature and proofs fail
+	FaultMalformedSTH FaultKind = "malformed_sth"
+)
+
+// Fault describes one injected failure
+type Fault struct {
+	Kind FaultKind
+
+	// Rate is the probability that a request starts the fault, in [0, 1]
+	Rate float64
+
+	// Burst is the number of consecutive requests a started fault affects;
+	// defaults to 1
+	Burst int
+
+	// Paths, when set, restricts the fault to requests whose path ends with
+	// one of these, such as "/v1/log/sth"
+	Paths []string
+
+	// Status is the FaultServerError status code
+	Status int
+
+	// Delay is how long FaultTimeout holds a request; defaults to 100ms.
+	// The request's context ending first fails it immediately
+	Delay time.Duration
+}
+
+// ChaosTransport is an http.RoundTripper that injects log failures, for
+// testing how integrations behave when their log misbehaves. Set it as the
+// Transport of a Client's HTTPClient:
+//
+//	client := &tecplog.Client{URL: url, HTTPClient: &http.Client{Transport: &tecplog.ChaosTransport{
+//		Faults: []tecplog.Fault{{Kind: tecplog.FaultServerError, Rate: 0.05, Burst: 20}},
+//	}}}
+type ChaosTransport struct {
+	// Base performs requests that are not failed; defaults to
+	// http.DefaultTransport
+	Base http.RoundTripper
+
+	Faults []Fault
+
+	// Seed makes the injected faults reproducible; 0 picks one
+	Seed int64
+
+	mu        sync.Mutex
+	rng       *rand.Rand
+	remaining []int
+	injected  map[FaultKind]int
+}
+
+// Injected returns the number of requests each kind of fault affected
+func (t *ChaosTransport) Injected() map[FaultKind]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[FaultKind]int, len(t.injected))
+	for kind, n := range t.injected {
+		counts[kind] = n
+	}
+	return counts
+}
+
+// RoundTrip performs a request, injecting the first fault that applies
+func (t *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := t.pick(req.URL.Path)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if fault == nil {
+		return base.RoundTrip(req)
+	}
+
+	switch fault.Kind {
+	case FaultTimeout:
+		delay := fault.Delay
+		if delay <= 0 {
+			delay = 100 * time.Millisecond
+		}
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		return nil, chaosTimeoutError{}
+
+	case FaultServerError:
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		body := `{"error":"injected fault"}`
+		return &http.Response{
+			StatusCode:    status,
+			Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+
+	case FaultMalformedSTH:
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		data = corruptTreeHeads(data)
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		resp.ContentLength = int64(len(data))
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	}
+	return base.RoundTrip(req)
+}
+
+// pick returns the fault affecting the next request to path, if any
+func (t *ChaosTransport) pick(path string) *Fault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rng == nil {
+		seed := t.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		t.rng = rand.New(rand.NewSource(seed))
+		t.remaining = make([]int, len(t.Faults))
+		t.injected = make(map[FaultKind]int)
+	}
+
+	for i := range t.Faults {
+		fault := &t.Faults[i]
+		if !faultApplies(fault, path) {
+			continue
+		}
+		if t.remaining[i] == 0 && t.rng.Float64() < fault.Rate {
+			t.remaining[i] = max(fault.Burst, 1)
+		}
+		if t.remaining[i] > 0 {
+			t.remaining[i]--
+			t.injected[fault.Kind]++
+			return fault
+		}
+	}
+	return nil
+}
+
+func faultApplies(fault *Fault, path string) bool {
+	if len(fault.Paths) == 0 {
+		return true
+	}
+	for _, suffix := range fault.Paths {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// corruptTreeHeads flips a digit of the root of the tree head a response
+// is or carries. Bodies that are not JSON objects are returned unchanged
+func corruptTreeHeads(data []byte) []byte {
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return data
+	}
+	sth := body
+	if nested, ok := body["sth"].(map[string]interface{}); ok {
+		sth = nested
+	}
+	root, ok := sth["root"].(string)
+	if !ok || root == "" {
+		return data
+	}
+	flipped := byte('0')
+	if root[0] == '0' {
+		flipped = '1'
+	}
+	sth["root"] = string(flipped) + root[1:]
+
+	corrupted, err := json.Marshal(body)
+	if err != nil {
+		return data
+	}
+	return corrupted
+}
+
+// chaosTimeoutError is the error of an injected timeout. It satisfies
+// net.Error, as the errors of real timeouts do
+type chaosTimeoutError struct{}
+
+func (chaosTimeoutError) Error() string   { return "tecplog: injected timeout" }
+func (chaosTimeoutError) Timeout() bool   { return true }
+func (chaosTimeoutError) Temporary() bool { return true }
@@ -0,0 +1,570 @@
+package tecplog
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Client talks to a transparency log server
+type Client struct {
+	// URL is the log server's base URL
+	URL string
+
+	// Tenant selects a tenant log hosted by the server; empty selects the
+	// default log
+	Tenant string
+
+	// PublicKey, when set, is required to have signed every tree head the
+	// client accepts
+	PublicKey ed25519.PublicKey
+
+	// APIKey, when set, authenticates requests to logs that require it.
+	// For mutual TLS, configure HTTPClient with ClientTLSConfig
+	APIKey string
+
+	HTTPClient *http.Client
+}
+
+// UseHTTPClient talks to the log through client unless HTTPClient, for
+// example one set up for mutual TLS, is already configured
+func (c *Client) UseHTTPClient(client *http.Client) {
+	if c.HTTPClient == nil {
+		c.HTTPClient = client
+	}
+}
+
+// ForTenant returns a copy of the client bound to a tenant log. Tenant logs
+// sign with their own keys, so publicKey replaces the client's key
+func (c *Client) ForTenant(id string, publicKey ed25519.PublicKey) *Client {
+	tenant := *c
+	tenant.Tenant = id
+	tenant.PublicKey = publicKey
+	return &tenant
+}
+
+// Submit appends an entry hash and verifies the returned inclusion proof
+func (c *Client) Submit(entry []byte) (*EntryResponse, error) {
+	return c.submit(entry, map[string]interface{}{})
+}
+
+// SubmitEntry appends an artifact of another entry type than receipts,
+// such as a revocation, and verifies the returned inclusion proof. The
+// entry's leaf is EntryHash(t, content)
+func (c *Client) SubmitEntry(t EntryType, content []byte) (*EntryResponse, error) {
+	entry, fields, err := entryFields(t, content)
+	if err != nil {
+		return nil, err
+	}
+	return c.submit(entry, fields)
+}
+
+// submit posts an entry request, with the artifact fields of fields
+func (c *Client) submit(entry []byte, fields map[string]interface{}) (*EntryResponse, error) {
+	fields["leaf"] = hex.EncodeToString(entry)
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var response EntryResponse
+	if err := c.do(http.MethodPost, "/v1/log/entries", bytes.NewReader(body), &response); err != nil {
+		return nil, err
+	}
+	if err := c.verifyEntry(entry, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Promise submits an entry hash and returns the log's verified promise to
+// include it within the maximum merge delay
+func (c *Client) Promise(entry []byte) (*tecp.SignedReceiptTimestamp, error) {
+	return c.promise(entry, map[string]interface{}{})
+}
+
+// PromiseReceipt submits a receipt with its hash, as logs with a Validator
+// require, and returns the log's verified promise to include it
+func (c *Client) PromiseReceipt(receipt *tecp.Receipt) (*tecp.SignedReceiptTimestamp, error) {
+	hash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+	return c.promise(hash, map[string]interface{}{"receipt": receipt})
+}
+
+// PromiseEntry submits an artifact of another entry type than receipts and
+// returns the log's verified promise to include it
+func (c *Client) PromiseEntry(t EntryType, content []byte) (*tecp.SignedReceiptTimestamp, error) {
+	entry, fields, err := entryFields(t, content)
+	if err != nil {
+		return nil, err
+	}
+	return c.promise(entry, fields)
+}
+
+// SubmitKeyStatement logs a signed key statement and embeds the log's
+// verified inclusion proof in it, replacing any earlier proof from this log
+func (c *Client) SubmitKeyStatement(statement *tecp.KeyStatement) error {
+	response, err := c.SubmitEntry(EntryKeyRotation, statement.EntryContent())
+	if err != nil {
+		return err
+	}
+	proof := response.InclusionProof(c.endpoint(""))
+	for i := range statement.Proofs {
+		if statement.Proofs[i].STH.KeyID == proof.STH.KeyID {
+			statement.Proofs[i] = *proof
+			return nil
+		}
+	}
+	statement.Proofs = append(statement.Proofs, *proof)
+	return nil
+}
+
+// RegisterKey registers an issuer's signing key for key transparency: it
+// logs an introduction statement signed by the key, effective now, and
+// returns it with its inclusion proof, ready for tecp.WithKeyRegistration
+func (c *Client) RegisterKey(key ed25519.PrivateKey, issuer string) (*tecp.KeyStatement, error) {
+	statement := &tecp.KeyStatement{
+		Event:     tecp.KeyIntroduced,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Issuer:    issuer,
+		Effective: time.Now().UnixMilli(),
+	}
+	tecp.SignKeyStatement(statement, key)
+	if err := c.SubmitKeyStatement(statement); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// entryFields returns the leaf and request fields of a non-receipt entry
+func entryFields(t EntryType, content []byte) ([]byte, map[string]interface{}, error) {
+	if t == "" || t == EntryReceipt {
+		return nil, nil, fmt.Errorf("tecplog: receipts are submitted with SubmitReceipt or PromiseReceipt")
+	}
+	entry, err := EntryHash(t, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, map[string]interface{}{"type": t, "entry": content}, nil
+}
+
+// promise posts an SRT request, with the artifact fields of fields
+func (c *Client) promise(entry []byte, fields map[string]interface{}) (*tecp.SignedReceiptTimestamp, error) {
+	fields["leaf"] = hex.EncodeToString(entry)
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var srt tecp.SignedReceiptTimestamp
+	if err := c.do(http.MethodPost, "/v1/log/srt", bytes.NewReader(body), &srt); err != nil {
+		return nil, err
+	}
+	if srt.Entry != hex.EncodeToString(entry) {
+		return nil, fmt.Errorf("tecplog: promise is for a different entry")
+	}
+	if c.PublicKey != nil {
+		if err := tecp.VerifyReceiptTimestamp(&srt, c.PublicKey); err != nil {
+			return nil, fmt.Errorf("tecplog: %w", err)
+		}
+	}
+	return &srt, nil
+}
+
+// CheckInclusion reports whether the log has included an entry hash,
+// verifying the inclusion proof when it has
+func (c *Client) CheckInclusion(entry []byte) (bool, error) {
+	_, err := c.Proof(entry)
+	var status *StatusError
+	if errors.As(err, &status) && status.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Proof fetches and verifies the inclusion proof for an entry hash
+func (c *Client) Proof(entry []byte) (*EntryResponse, error) {
+	var response EntryResponse
+	if err := c.do(http.MethodGet, "/v1/log/proof?leaf="+hex.EncodeToString(entry), nil, &response); err != nil {
+		return nil, err
+	}
+	if err := c.verifyEntry(entry, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ConsistencyProof fetches the proof that the tree of size first is a
+// prefix of the tree of size second. The proof is checked by the caller
+// against the two tree heads
+func (c *Client) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	var response ConsistencyResponse
+	path := fmt.Sprintf("/v1/log/consistency?first=%d&second=%d", first, second)
+	if err := c.do(http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+	if response.First != first || response.Second != second {
+		return nil, fmt.Errorf("tecplog: consistency proof is for a different range")
+	}
+	proof, err := hexDecodeAll(response.Proof)
+	if err != nil {
+		return nil, fmt.Errorf("tecplog: invalid proof encoding: %w", err)
+	}
+	return proof, nil
+}
+
+// Leaves fetches up to count leaf hashes starting at start and verifies
+// them against the returned tree head with the range proof
+func (c *Client) Leaves(start, count uint64) (*LeavesResponse, error) {
+	var response LeavesResponse
+	path := fmt.Sprintf("/v1/log/leaves?start=%d&count=%d", start, count)
+	if err := c.do(http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+	if response.STH == nil {
+		return nil, fmt.Errorf("tecplog: response has no tree head")
+	}
+	if err := c.verifyTreeHead(response.STH); err != nil {
+		return nil, err
+	}
+	if response.Start != start {
+		return nil, fmt.Errorf("tecplog: page starts at %d, requested %d", response.Start, start)
+	}
+
+	root, err := hex.DecodeString(response.STH.Root)
+	if err != nil {
+		return nil, fmt.Errorf("tecplog: invalid root encoding: %w", err)
+	}
+	leaves, err := hexDecodeAll(response.Leaves)
+	if err != nil {
+		return nil, fmt.Errorf("tecplog: invalid leaf encoding: %w", err)
+	}
+	proof, err := hexDecodeAll(response.Proof)
+	if err != nil {
+		return nil, fmt.Errorf("tecplog: invalid proof encoding: %w", err)
+	}
+	if err := merkle.VerifyRange(start, leaves, response.STH.Size, proof, root); err != nil {
+		return nil, fmt.Errorf("tecplog: range proof verification failed: %w", err)
+	}
+	return &response, nil
+}
+
+// WalkLeaves downloads and verifies every leaf from start up to the tree
+// size seen on the first page, calling fn for each in order. It returns the
+// tree head of the last page
+func (c *Client) WalkLeaves(start uint64, fn func(index uint64, leafHash []byte) error) (*tecp.SignedTreeHead, error) {
+	var sth *tecp.SignedTreeHead
+	for end := uint64(0); sth == nil || start < end; {
+		page, err := c.Leaves(start, MaxLeavesPerPage)
+		if err != nil {
+			return nil, err
+		}
+		if sth == nil {
+			end = page.STH.Size
+		}
+		sth = page.STH
+
+		for _, leaf := range page.Leaves {
+			if start >= end {
+				break
+			}
+			leafHash, _ := hex.DecodeString(leaf)
+			if err := fn(start, leafHash); err != nil {
+				return nil, err
+			}
+			start++
+		}
+	}
+	return sth, nil
+}
+
+// SubmitReceipt appends a receipt's hash to the log, sending the receipt
+// along for logs that validate submissions
+func (c *Client) SubmitReceipt(receipt *tecp.Receipt) (*EntryResponse, error) {
+	hash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return nil, err
+	}
+	return c.submit(hash, map[string]interface{}{"receipt": receipt})
+}
+
+// EmbedProof fetches and verifies a receipt's inclusion proof and embeds it
+// in the receipt's inclusion extension, replacing any earlier proof from
+// this log
+func (c *Client) EmbedProof(receipt *tecp.Receipt) error {
+	hash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return err
+	}
+	response, err := c.Proof(hash)
+	if err != nil {
+		return err
+	}
+	return tecp.EmbedInclusionProof(receipt, response.InclusionProof(c.endpoint("")))
+}
+
+// RefreshProof replaces this log's inclusion proof in a receipt with one
+// against the log's current tree head, for archives that keep receipts
+// verifiable against the latest tree. The current tree is first checked to
+// extend the old proof's tree head by a consistency proof. Receipts with
+// no proof from this log get one, as with EmbedProof
+func (c *Client) RefreshProof(receipt *tecp.Receipt) error {
+	hash, err := tecp.ReceiptHash(receipt)
+	if err != nil {
+		return err
+	}
+	proofs, err := receipt.InclusionProofs()
+	if err != nil {
+		return err
+	}
+	response, err := c.Proof(hash)
+	if err != nil {
+		return err
+	}
+	fresh := response.InclusionProof(c.endpoint(""))
+
+	old := -1
+	for i, proof := range proofs {
+		if proof.Log == fresh.Log || proof.STH.KeyID == fresh.STH.KeyID {
+			old = i
+			break
+		}
+	}
+	if old < 0 {
+		return tecp.EmbedInclusionProof(receipt, fresh)
+	}
+
+	if err := c.CheckConsistency(&proofs[old].STH, &fresh.STH); err != nil {
+		return err
+	}
+	proofs[old] = *fresh
+	receipt.SetExtension(tecp.InclusionExtension, proofs)
+	return nil
+}
+
+// CheckConsistency verifies, with a consistency proof from the log, that
+// the tree of a newer tree head extends the tree of an older one
+func (c *Client) CheckConsistency(old, current *tecp.SignedTreeHead) error {
+	for _, sth := range []*tecp.SignedTreeHead{old, current} {
+		if err := c.verifyTreeHead(sth); err != nil {
+			return err
+		}
+	}
+	if current.Size < old.Size {
+		return fmt.Errorf("tecplog: current tree of size %d is smaller than the proof's tree of size %d", current.Size, old.Size)
+	}
+	proof, err := c.ConsistencyProof(old.Size, current.Size)
+	if err != nil {
+		return err
+	}
+	oldRoot, err := hex.DecodeString(old.Root)
+	if err != nil {
+		return fmt.Errorf("tecplog: invalid root encoding: %w", err)
+	}
+	currentRoot, err := hex.DecodeString(current.Root)
+	if err != nil {
+		return fmt.Errorf("tecplog: invalid root encoding: %w", err)
+	}
+	if err := merkle.VerifyConsistency(old.Size, current.Size, proof, oldRoot, currentRoot); err != nil {
+		return fmt.Errorf("tecplog: consistency proof verification failed: %w", err)
+	}
+	return nil
+}
+
+// SubmitToLogs appends a receipt's hash to every log and embeds each log's
+// inclusion proof, so verifiers can require inclusion in several
+// independent logs with VerifyOptions.MinLogs
+func SubmitToLogs(receipt *tecp.Receipt, logs ...*Client) error {
+	for _, log := range logs {
+		response, err := log.SubmitReceipt(receipt)
+		if err != nil {
+			return fmt.Errorf("tecplog: %s: %w", log.endpoint(""), err)
+		}
+		if err := tecp.EmbedInclusionProof(receipt, response.InclusionProof(log.endpoint(""))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InclusionProof returns the response's proof in the form embedded in
+// receipts, recording logURL as the log to refresh it from
+func (r *EntryResponse) InclusionProof(logURL string) *tecp.InclusionProof {
+	return &tecp.InclusionProof{LeafIndex: r.LeafIndex, Proof: r.Proof, STH: *r.STH, Log: logURL}
+}
+
+// TreeHead fetches the log's current signed tree head
+func (c *Client) TreeHead() (*tecp.SignedTreeHead, error) {
+	var sth tecp.SignedTreeHead
+	if err := c.do(http.MethodGet, "/v1/log/sth", nil, &sth); err != nil {
+		return nil, err
+	}
+	if err := c.verifyTreeHead(&sth); err != nil {
+		return nil, err
+	}
+	return &sth, nil
+}
+
+// Health checks that the log server is serving
+func (c *Client) Health() error {
+	server := *c
+	server.Tenant = ""
+	var health struct {
+		Status string `json:"status"`
+	}
+	return server.do(http.MethodGet, "/health", nil, &health)
+}
+
+// Keys fetches the log's signing keys from its JWKS, by key ID
+func (c *Client) Keys() (map[string]ed25519.PublicKey, error) {
+	var jwks struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			Curve   string `json:"crv"`
+			X       string `json:"x"`
+			KeyID   string `json:"kid"`
+		} `json:"keys"`
+	}
+	if err := c.do(http.MethodGet, "/.well-known/tecp-log-jwks", nil, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]ed25519.PublicKey)
+	for _, key := range jwks.Keys {
+		if key.KeyType != "OKP" || key.Curve != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("tecplog: invalid JWKS key %q", key.KeyID)
+		}
+		keys[key.KeyID] = ed25519.PublicKey(x)
+	}
+	return keys, nil
+}
+
+// verifyEntry checks an inclusion proof against its tree head
+func (c *Client) verifyEntry(entry []byte, response *EntryResponse) error {
+	if response.STH == nil {
+		return fmt.Errorf("tecplog: response has no tree head")
+	}
+	if err := c.verifyTreeHead(response.STH); err != nil {
+		return err
+	}
+	return VerifyEntry(entry, response)
+}
+
+func (c *Client) verifyTreeHead(sth *tecp.SignedTreeHead) error {
+	if c.PublicKey == nil {
+		return nil
+	}
+	if err := tecp.VerifyTreeHead(sth, c.PublicKey); err != nil {
+		return fmt.Errorf("tecplog: %w", err)
+	}
+	return nil
+}
+
+// VerifyEntry checks that response proves inclusion of an entry hash in the
+// tree described by its tree head. The tree head signature is not checked
+func VerifyEntry(entry []byte, response *EntryResponse) error {
+	root, err := hex.DecodeString(response.STH.Root)
+	if err != nil {
+		return fmt.Errorf("tecplog: invalid root encoding: %w", err)
+	}
+	proof, err := hexDecodeAll(response.Proof)
+	if err != nil {
+		return fmt.Errorf("tecplog: invalid proof encoding: %w", err)
+	}
+	if err := merkle.VerifyInclusion(merkle.LeafHash(entry), response.LeafIndex, response.STH.Size, proof, root); err != nil {
+		return fmt.Errorf("tecplog: inclusion proof verification failed: %w", err)
+	}
+	return nil
+}
+
+func hexDecodeAll(values []string) ([][]byte, error) {
+	decoded := make([][]byte, len(values))
+	for i, value := range values {
+		var err error
+		if decoded[i], err = hex.DecodeString(value); err != nil {
+			return nil, err
+		}
+	}
+	return decoded, nil
+}
+
+// endpoint returns the URL of an API path on the selected log
+func (c *Client) endpoint(path string) string {
+	base := strings.TrimSuffix(c.URL, "/")
+	if c.Tenant != "" {
+		base += TenantPathPrefix + url.PathEscape(c.Tenant)
+	}
+	return base + path
+}
+
+// do performs a request and decodes a JSON response
+func (c *Client) do(method, path string, body io.Reader, out interface{}) error {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = tecp.DefaultHTTPClient()
+	}
+
+	req, err := http.NewRequest(method, c.endpoint(path), body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&failure)
+		return &StatusError{StatusCode: resp.StatusCode, Message: failure.Error, RetryAfter: resp.Header.Get("Retry-After")}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("tecplog: invalid response: %w", err)
+	}
+	return nil
+}
+
+// StatusError is returned when the log server rejects a request
+type StatusError struct {
+	StatusCode int
+	Message    string
+
+	// RetryAfter is the server's Retry-After header, set when rate limited
+	RetryAfter string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("tecplog: server returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("tecplog: server returned status %d: %s", e.StatusCode, e.Message)
+}
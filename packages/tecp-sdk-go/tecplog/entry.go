@@ -0,0 +1,53 @@
+package tecplog
+
+import (
+	"fmt"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// EntryType is the kind of artifact a log entry anchors. The type is bound
+// into the entry hash, so an entry of one type can never be presented as
+// another
+type EntryType string
+
+const (
+	// EntryReceipt entries are receipts, hashed with tecp.ReceiptHash as
+	// they always have been. It is the default type
+	EntryReceipt EntryType = "receipt"
+
+	// EntryKeyRotation entries are issuer key rotation statements
+	EntryKeyRotation EntryType = tecp.KeyStatementEntryType
+
+	// EntryRevocation entries revoke keys, receipts or statements
+	EntryRevocation EntryType = "revocation"
+
+	// EntryPolicy entries publish policy descriptors
+	EntryPolicy EntryType = "policy_publication"
+)
+
+// Valid reports whether t is a known entry type
+func (t EntryType) Valid() bool {
+	switch t {
+	case EntryReceipt, EntryKeyRotation, EntryRevocation, EntryPolicy:
+		return true
+	}
+	return false
+}
+
+// EntryHash returns the entry hash of an artifact, the leaf submitted to
+// the log. Receipts are decoded and hashed with tecp.ReceiptHash; other
+// artifacts are hashed as given with tecp.LogEntryHash
+func EntryHash(t EntryType, content []byte) ([]byte, error) {
+	switch {
+	case t == "" || t == EntryReceipt:
+		receipt, err := tecp.Decode(content)
+		if err != nil {
+			return nil, fmt.Errorf("tecplog: invalid receipt: %w", err)
+		}
+		return tecp.ReceiptHash(receipt)
+	case !t.Valid():
+		return nil, fmt.Errorf("tecplog: unknown entry type %q", t)
+	}
+	return tecp.LogEntryHash(string(t), content), nil
+}
@@ -0,0 +1,171 @@
+package tecplog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// ErrNoLogAvailable is returned when every log's circuit breaker is open
+var ErrNoLogAvailable = errors.New("tecplog: no log available")
+
+// Failover spreads submissions over several logs in order of preference.
+// A log that keeps failing is skipped until its cooldown ends and a health
+// check passes, so issuance never blocks on one log instance
+type Failover struct {
+	// FailureThreshold is the number of consecutive failures that opens a
+	// log's breaker; defaults to 3
+	FailureThreshold int
+
+	// Cooldown is how long an open breaker skips its log; defaults to 30s
+	Cooldown time.Duration
+
+	clients  []*Client
+	mu       sync.Mutex
+	breakers []breaker
+}
+
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// NewFailover returns a failover group trying clients in the given order
+func NewFailover(clients ...*Client) *Failover {
+	return &Failover{
+		clients:  clients,
+		breakers: make([]breaker, len(clients)),
+	}
+}
+
+// Promise obtains a promise from the first available log. The returned SRT's
+// Log field records which log issued it
+func (f *Failover) Promise(entry []byte) (*tecp.SignedReceiptTimestamp, error) {
+	var srt *tecp.SignedReceiptTimestamp
+	client, err := f.try(func(c *Client) (err error) {
+		srt, err = c.Promise(entry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	srt.Log = client.URL
+	return srt, nil
+}
+
+// PromiseReceipt obtains a promise for a receipt from the first available
+// log, sending the receipt along for logs that validate submissions
+func (f *Failover) PromiseReceipt(receipt *tecp.Receipt) (*tecp.SignedReceiptTimestamp, error) {
+	var srt *tecp.SignedReceiptTimestamp
+	client, err := f.try(func(c *Client) (err error) {
+		srt, err = c.PromiseReceipt(receipt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	srt.Log = client.URL
+	return srt, nil
+}
+
+// Submit appends an entry to the first available log and returns the URL of
+// the log it was anchored to
+func (f *Failover) Submit(entry []byte) (*EntryResponse, string, error) {
+	var response *EntryResponse
+	client, err := f.try(func(c *Client) (err error) {
+		response, err = c.Submit(entry)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return response, client.URL, nil
+}
+
+// try runs call against each log whose breaker admits it, returning the log
+// that succeeded
+func (f *Failover) try(call func(c *Client) error) (*Client, error) {
+	var failures []string
+	for i, client := range f.clients {
+		if !f.admit(i) {
+			continue
+		}
+		err := call(client)
+		if err == nil {
+			f.record(i, true)
+			return client, nil
+		}
+		if !retryable(err) {
+			return nil, err
+		}
+		f.record(i, false)
+		failures = append(failures, fmt.Sprintf("%s: %v", client.URL, err))
+	}
+
+	if len(failures) == 0 {
+		return nil, ErrNoLogAvailable
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoLogAvailable, strings.Join(failures, "; "))
+}
+
+// admit reports whether log i may be tried. A log whose cooldown has ended
+// must pass a health check first
+func (f *Failover) admit(i int) bool {
+	f.mu.Lock()
+	b := f.breakers[i]
+	f.mu.Unlock()
+
+	if b.failures < f.threshold() {
+		return true
+	}
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	if err := f.clients[i].Health(); err != nil {
+		f.record(i, false)
+		return false
+	}
+	return true
+}
+
+// record updates log i's breaker after a call
+func (f *Failover) record(i int, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b := &f.breakers[i]
+	if ok {
+		*b = breaker{}
+		return
+	}
+	b.failures++
+	if b.failures >= f.threshold() {
+		cooldown := f.Cooldown
+		if cooldown <= 0 {
+			cooldown = 30 * time.Second
+		}
+		b.openUntil = time.Now().Add(cooldown)
+	}
+}
+
+func (f *Failover) threshold() int {
+	if f.FailureThreshold <= 0 {
+		return 3
+	}
+	return f.FailureThreshold
+}
+
+// retryable reports whether another log might accept a failed request.
+// Client errors other than rate limiting would fail everywhere
+func retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= http.StatusInternalServerError || status.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
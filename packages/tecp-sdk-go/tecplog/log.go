@@ -0,0 +1,336 @@
+package tecplog
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Log is a single append-only transparency log with its own tree, signing
+// key and storage
+type Log struct {
+	id      string
+	key     ed25519.PrivateKey
+	keyID   string
+	storage Storage
+	mmd     time.Duration
+
+	mu      sync.Mutex
+	tree    merkle.Tree
+	index   map[string]uint64
+	pending [][]byte
+	queued  map[string]bool
+	sth     *tecp.SignedTreeHead
+	signed  uint64
+}
+
+// EntryResponse is returned for appended leaves and proof lookups
+type EntryResponse struct {
+	LeafIndex uint64               `json:"leaf_index"`
+	Proof     []string             `json:"proof"`
+	STH       *tecp.SignedTreeHead `json:"sth"`
+	Algorithm string               `json:"algo"`
+	Domain    map[string]string    `json:"domain"`
+
+	// AlreadyExists is set when a submission matched an existing leaf and
+	// nothing was appended
+	AlreadyExists bool `json:"already_exists"`
+}
+
+// LeavesResponse is a page of consecutive leaf hashes with a range proof
+// against the tree head
+type LeavesResponse struct {
+	Start  uint64               `json:"start"`
+	Leaves []string             `json:"leaves"`
+	Proof  []string             `json:"proof"`
+	STH    *tecp.SignedTreeHead `json:"sth"`
+}
+
+// ConsistencyResponse proves that the tree of size First is a prefix of the
+// tree of size Second
+type ConsistencyResponse struct {
+	First  uint64   `json:"first"`
+	Second uint64   `json:"second"`
+	Proof  []string `json:"proof"`
+}
+
+// TreeHeadRefreshInterval is how long a tree head is reused while the log
+// does not grow. Idle logs still sign recent tree heads, so verifiers can
+// require fresh ones
+const TreeHeadRefreshInterval = 5 * time.Minute
+
+// MaxLeavesPerPage bounds the leaves returned by one Leaves call
+const MaxLeavesPerPage = 1000
+
+// DefaultMaxMergeDelay bounds how long a promised entry may stay out of the tree
+const DefaultMaxMergeDelay = 24 * time.Hour
+
+// openLog loads a log from storage
+func openLog(id string, key ed25519.PrivateKey, keyID string, storage Storage, mmd time.Duration) (*Log, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("tecplog: log %q: private key required", id)
+	}
+	if keyID == "" {
+		keyID = "log-" + time.Now().UTC().Format("200601")
+	}
+	if storage == nil {
+		storage = &MemoryStorage{}
+	}
+	if mmd <= 0 {
+		mmd = DefaultMaxMergeDelay
+	}
+
+	l := &Log{
+		id:      id,
+		key:     key,
+		keyID:   keyID,
+		storage: storage,
+		mmd:     mmd,
+		index:   make(map[string]uint64),
+		queued:  make(map[string]bool),
+	}
+
+	leaves, err := storage.Load()
+	if err != nil {
+		return nil, fmt.Errorf("tecplog: log %q: failed to load: %w", id, err)
+	}
+	for _, leafHash := range leaves {
+		l.addLeaf(leafHash)
+	}
+	return l, nil
+}
+
+// ID returns the log's tenant ID, empty for a server's default log
+func (l *Log) ID() string {
+	return l.id
+}
+
+// PublicKey returns the key that verifies the log's tree heads
+func (l *Log) PublicKey() ed25519.PublicKey {
+	return l.key.Public().(ed25519.PublicKey)
+}
+
+// KeyID returns the identifier of the log's signing key
+func (l *Log) KeyID() string {
+	return l.keyID
+}
+
+// Size returns the number of leaves in the log
+func (l *Log) Size() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.integrate()
+	return l.tree.Size()
+}
+
+// Append adds an entry hash to the log and returns its inclusion proof. An
+// entry already in the log is not appended again; its original proof is
+// returned with AlreadyExists set
+func (l *Log) Append(entry []byte) (*EntryResponse, error) {
+	if len(entry) != merkle.HashSize {
+		return nil, fmt.Errorf("tecplog: leaf must be %d bytes", merkle.HashSize)
+	}
+	leafHash := merkle.LeafHash(entry)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Storage order is tree order, so promised entries go in first
+	l.integrate()
+	if index, ok := l.index[hex.EncodeToString(leafHash)]; ok {
+		response, err := l.entryResponse(index)
+		if err == nil {
+			response.AlreadyExists = true
+		}
+		return response, err
+	}
+
+	if err := l.storage.Append(leafHash); err != nil {
+		return nil, fmt.Errorf("tecplog: failed to store leaf: %w", err)
+	}
+	return l.entryResponse(l.addLeaf(leafHash))
+}
+
+// Promise durably accepts an entry hash and returns a signed promise to
+// include it within the log's maximum merge delay. Issuing a promise costs
+// one signature; the entry joins the tree before the next tree head or
+// proof is produced, so the promise is always kept. Resubmitted entries
+// get a fresh promise without being stored again
+func (l *Log) Promise(entry []byte) (*tecp.SignedReceiptTimestamp, error) {
+	if len(entry) != merkle.HashSize {
+		return nil, fmt.Errorf("tecplog: leaf must be %d bytes", merkle.HashSize)
+	}
+	leafHash := merkle.LeafHash(entry)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := hex.EncodeToString(leafHash)
+	if _, ok := l.index[key]; !ok && !l.queued[key] {
+		if err := l.storage.Append(leafHash); err != nil {
+			return nil, fmt.Errorf("tecplog: failed to store leaf: %w", err)
+		}
+		l.pending = append(l.pending, leafHash)
+		l.queued[key] = true
+	}
+
+	srt := &tecp.SignedReceiptTimestamp{
+		Entry:     hex.EncodeToString(entry),
+		Timestamp: time.Now().UnixMilli(),
+		MMD:       l.mmd.Milliseconds(),
+		KeyID:     l.keyID,
+	}
+	tecp.SignReceiptTimestamp(srt, l.key)
+	return srt, nil
+}
+
+// MaxMergeDelay returns the delay within which promised entries are included
+func (l *Log) MaxMergeDelay() time.Duration {
+	return l.mmd
+}
+
+// integrate adds promised entries to the tree. Callers hold l.mu
+func (l *Log) integrate() {
+	for _, leafHash := range l.pending {
+		l.addLeaf(leafHash)
+	}
+	l.pending = nil
+	clear(l.queued)
+}
+
+// addLeaf appends a leaf to the tree, indexing its first occurrence.
+// Callers hold l.mu
+func (l *Log) addLeaf(leafHash []byte) uint64 {
+	index := l.tree.Append(leafHash)
+	key := hex.EncodeToString(leafHash)
+	if _, exists := l.index[key]; !exists {
+		l.index[key] = index
+	}
+	return index
+}
+
+// Proof returns the inclusion proof for a previously appended entry hash
+func (l *Log) Proof(entry []byte) (*EntryResponse, bool, error) {
+	leafHash := merkle.LeafHash(entry)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.integrate()
+	index, ok := l.index[hex.EncodeToString(leafHash)]
+	if !ok {
+		return nil, false, nil
+	}
+	response, err := l.entryResponse(index)
+	return response, true, err
+}
+
+// Leaves returns up to count leaf hashes starting at start, with a range
+// proof against the current tree head
+func (l *Log) Leaves(start, count uint64) (*LeavesResponse, error) {
+	if count == 0 || count > MaxLeavesPerPage {
+		count = MaxLeavesPerPage
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.integrate()
+	sth := l.treeHead()
+	if start >= sth.Size {
+		return nil, fmt.Errorf("tecplog: start %d beyond tree size %d", start, sth.Size)
+	}
+	end := start + count
+	if end > sth.Size || end < start {
+		end = sth.Size
+	}
+
+	proof, err := l.tree.RangeProof(start, end, sth.Size)
+	if err != nil {
+		return nil, err
+	}
+	leaves := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		leafHash, err := l.tree.LeafHash(i)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, hex.EncodeToString(leafHash))
+	}
+	return &LeavesResponse{Start: start, Leaves: leaves, Proof: hexEncodeAll(proof), STH: sth}, nil
+}
+
+// ConsistencyProof proves that the tree of size first is a prefix of the
+// tree of size second
+func (l *Log) ConsistencyProof(first, second uint64) (*ConsistencyResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.integrate()
+	if first > second || second > l.tree.Size() {
+		return nil, fmt.Errorf("tecplog: invalid consistency range %d to %d", first, second)
+	}
+	proof, err := l.tree.ConsistencyProof(first, second)
+	if err != nil {
+		return nil, err
+	}
+	return &ConsistencyResponse{First: first, Second: second, Proof: hexEncodeAll(proof)}, nil
+}
+
+// TreeHead returns a signed tree head for the current log
+func (l *Log) TreeHead() *tecp.SignedTreeHead {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.integrate()
+	return l.treeHead()
+}
+
+// treeHead signs the current root, reusing the last tree head while the log
+// has not grown for up to TreeHeadRefreshInterval. Callers hold l.mu
+func (l *Log) treeHead() *tecp.SignedTreeHead {
+	size := l.tree.Size()
+	if l.sth != nil && l.signed == size && time.Since(time.UnixMilli(l.sth.Timestamp)) < TreeHeadRefreshInterval {
+		return l.sth
+	}
+
+	sth := &tecp.SignedTreeHead{
+		Size:      size,
+		Root:      hex.EncodeToString(l.tree.Root()),
+		Timestamp: time.Now().UnixMilli(),
+		KeyID:     l.keyID,
+	}
+	tecp.SignTreeHead(sth, l.key)
+	l.sth, l.signed = sth, size
+	return sth
+}
+
+// entryResponse builds the proof for index against the current tree head.
+// Callers hold l.mu
+func (l *Log) entryResponse(index uint64) (*EntryResponse, error) {
+	sth := l.treeHead()
+	proof, err := l.tree.InclusionProof(index, sth.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EntryResponse{
+		LeafIndex: index,
+		Proof:     hexEncodeAll(proof),
+		STH:       sth,
+		Algorithm: "sha256",
+		Domain:    map[string]string{"leaf": "00", "node": "01"},
+	}, nil
+}
+
+func hexEncodeAll(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, hash := range hashes {
+		encoded[i] = hex.EncodeToString(hash)
+	}
+	return encoded
+}
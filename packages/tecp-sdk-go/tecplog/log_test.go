@@ -0,0 +1,117 @@
+package tecplog
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+)
+
+func testLog(t *testing.T, storage Storage) *Log {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := openLog("", key, "test", storage, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func entryHash(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
+
+func TestLogDeduplicates(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// ops are appends ("a:x") or promises ("p:x") of entry x
+		ops []string
+
+		size   uint64
+		stored int
+	}{
+		{"distinct appends", []string{"a:1", "a:2", "a:3"}, 3, 3},
+		{"repeated append", []string{"a:1", "a:1", "a:1"}, 1, 1},
+		{"repeated promise", []string{"p:1", "p:1"}, 1, 1},
+		{"append after promise", []string{"p:1", "a:1"}, 1, 1},
+		{"promise after append", []string{"a:1", "p:1"}, 1, 1},
+		{"interleaved", []string{"a:1", "p:2", "a:2", "p:1", "a:3", "a:1"}, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &MemoryStorage{}
+			l := testLog(t, storage)
+			indexes := make(map[string]uint64)
+			for _, op := range tt.ops {
+				kind, entry := op[:1], entryHash(op[2:])
+				if kind == "p" {
+					if _, err := l.Promise(entry); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				response, err := l.Append(entry)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if index, seen := indexes[op]; seen {
+					if !response.AlreadyExists || response.LeafIndex != index {
+						t.Fatalf("%s: resubmission got index %d, already_exists %v; want %d, true", op, response.LeafIndex, response.AlreadyExists, index)
+					}
+				}
+				indexes[op] = response.LeafIndex
+				if err := verifyEntryResponse(l, entry, response); err != nil {
+					t.Fatalf("%s: %v", op, err)
+				}
+			}
+			if l.Size() != tt.size {
+				t.Fatalf("size %d, want %d", l.Size(), tt.size)
+			}
+			leaves, _ := storage.Load()
+			if len(leaves) != tt.stored {
+				t.Fatalf("%d leaves stored, want %d", len(leaves), tt.stored)
+			}
+		})
+	}
+}
+
+func TestLogReopenKeepsFirstIndex(t *testing.T) {
+	storage := &MemoryStorage{}
+
+	// Storage written before deduplication may hold repeated leaves
+	for _, s := range []string{"1", "2", "1"} {
+		storage.Append(merkle.LeafHash(entryHash(s)))
+	}
+	l := testLog(t, storage)
+	if l.Size() != 3 {
+		t.Fatalf("reopened log has %d leaves, want 3", l.Size())
+	}
+	response, found, err := l.Proof(entryHash("1"))
+	if err != nil || !found {
+		t.Fatalf("proof lookup failed: %v %v", found, err)
+	}
+	if response.LeafIndex != 0 {
+		t.Fatalf("repeated leaf indexed at %d, want its first occurrence", response.LeafIndex)
+	}
+	again, err := l.Append(entryHash("1"))
+	if err != nil || !again.AlreadyExists || l.Size() != 3 {
+		t.Fatalf("resubmission after reopening appended: %v", err)
+	}
+}
+
+// verifyEntryResponse checks a response's inclusion proof against its tree head
+func verifyEntryResponse(l *Log, entry []byte, response *EntryResponse) error {
+	proof := make([][]byte, len(response.Proof))
+	for i, p := range response.Proof {
+		proof[i], _ = parseLeaf(p)
+	}
+	root, _ := parseLeaf(response.STH.Root)
+	return merkle.VerifyInclusion(merkle.LeafHash(entry), response.LeafIndex, response.STH.Size, proof, root)
+}
@@ -0,0 +1,90 @@
+package tecplog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metrics holds the server's Prometheus counters and gauges
+type metrics struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+}
+
+type counter struct {
+	help   string
+	gauge  bool
+	values map[string]float64
+}
+
+func newMetrics() *metrics {
+	m := &metrics{counters: make(map[string]*counter)}
+	m.define("tecplog_submissions_total", "Log entry submissions by result.", false)
+	m.define("tecplog_rate_limited_total", "Submissions rejected by the rate limiter, by client identification.", false)
+	m.define("tecplog_auth_failures_total", "Requests rejected by authentication, by endpoint.", false)
+	m.define("tecplog_tree_size", "Number of leaves in the log.", true)
+	return m
+}
+
+func (m *metrics) define(name, help string, gauge bool) {
+	m.counters[name] = &counter{help: help, gauge: gauge, values: make(map[string]float64)}
+}
+
+// inc increments a counter; labels alternate names and values
+func (m *metrics) inc(name string, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name].values[formatLabels(labels)]++
+}
+
+// set sets a gauge
+func (m *metrics) set(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name].values[formatLabels(labels)] = value
+}
+
+// write renders the metrics in the Prometheus text exposition format
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.counters))
+	for name := range m.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c := m.counters[name]
+		kind := "counter"
+		if c.gauge {
+			kind = "gauge"
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, c.help, name, kind)
+
+		series := make([]string, 0, len(c.values))
+		for labels := range c.values {
+			series = append(series, labels)
+		}
+		sort.Strings(series)
+		for _, labels := range series {
+			fmt.Fprintf(w, "%s%s %g\n", name, labels, c.values[labels])
+		}
+	}
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
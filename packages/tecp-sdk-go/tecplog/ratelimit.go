@@ -0,0 +1,145 @@
+package tecplog
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit configures token-bucket limits on entry submission. Clients
+// presenting an API key listed in Quotas are limited per key; everyone else
+// is limited per IP address
+type RateLimit struct {
+	// Rate is the sustained number of submissions per second per client
+	Rate float64
+
+	// Burst is the number of submissions a client may make at once
+	Burst int
+
+	// Quotas override Rate and Burst for known API keys, sent in the
+	// X-API-Key header or as a bearer token
+	Quotas map[string]Quota
+
+	// TrustForwardedFor identifies clients by the first X-Forwarded-For
+	// address; enable only behind a proxy that sets it
+	TrustForwardedFor bool
+}
+
+// Quota is a per-key submission limit
+type Quota struct {
+	Rate  float64
+	Burst int
+}
+
+// bucketIdleTimeout is how long an untouched bucket is kept before sweeping
+const bucketIdleTimeout = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter enforces RateLimit with one token bucket per client
+type limiter struct {
+	config RateLimit
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newLimiter(config RateLimit) *limiter {
+	return &limiter{
+		config:  config,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// principal identifies the client of a request and the quota that applies
+func (l *limiter) principal(r *http.Request) (string, string, Quota) {
+	if key := apiKey(r); key != "" {
+		if quota, ok := l.config.Quotas[key]; ok {
+			return "key:" + key, "api_key", quota
+		}
+	}
+	return "ip:" + clientIP(r, l.config.TrustForwardedFor), "ip", Quota{Rate: l.config.Rate, Burst: l.config.Burst}
+}
+
+// allow takes a token from the client's bucket. When the bucket is empty it
+// returns how long until a token is available
+func (l *limiter) allow(id string, quota Quota) (bool, time.Duration) {
+	if quota.Rate <= 0 {
+		return true, 0
+	}
+	burst := float64(quota.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[id] = b
+	}
+
+	// Refill for the time elapsed since the last request
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*quota.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / quota.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets idle long enough to have refilled completely
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTimeout {
+		return
+	}
+	for id, b := range l.buckets {
+		if now.Sub(b.last) > bucketIdleTimeout {
+			delete(l.buckets, id)
+		}
+	}
+	l.lastSweep = now
+}
+
+// apiKey returns the API key presented with a request, if any
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// clientIP returns the address a request came from
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
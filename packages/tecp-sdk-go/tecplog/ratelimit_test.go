@@ -0,0 +1,129 @@
+package tecplog
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterBuckets(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	now := start
+	l := newLimiter(RateLimit{Rate: 1, Burst: 2})
+	l.now = func() time.Time { return now }
+	quota := Quota{Rate: 1, Burst: 2}
+
+	steps := []struct {
+		name    string
+		id      string
+		advance time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{"burst 1", "a", 0, true, 0},
+		{"burst 2", "a", 0, true, 0},
+		{"exhausted", "a", 0, false, time.Second},
+		{"other client unaffected", "b", 0, true, 0},
+		{"partial refill", "a", 500 * time.Millisecond, false, 500 * time.Millisecond},
+		{"refilled", "a", 500 * time.Millisecond, true, 0},
+		{"refill capped at burst", "a", time.Hour, true, 0},
+		{"second after long idle", "a", 0, true, 0},
+		{"burst spent again", "a", 0, false, time.Second},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		allowed, wait := l.allow(step.id, quota)
+		if allowed != step.allowed || wait != step.wait {
+			t.Fatalf("%s: allow = %v, %v; want %v, %v", step.name, allowed, wait, step.allowed, step.wait)
+		}
+	}
+
+	if ok, _ := l.allow("c", Quota{}); !ok {
+		t.Fatal("zero rate should be unlimited")
+	}
+	now = now.Add(2 * bucketIdleTimeout)
+	l.allow("d", quota)
+	if _, ok := l.buckets["a"]; ok {
+		t.Fatal("idle bucket not swept")
+	}
+}
+
+func TestLimiterPrincipal(t *testing.T) {
+	l := newLimiter(RateLimit{
+		Rate:   1,
+		Burst:  1,
+		Quotas: map[string]Quota{"premium": {Rate: 100, Burst: 50}},
+	})
+	trusting := newLimiter(RateLimit{Rate: 1, TrustForwardedFor: true})
+
+	tests := []struct {
+		name    string
+		limiter *limiter
+		header  map[string]string
+		id      string
+		kind    string
+		quota   Quota
+	}{
+		{"by address", l, nil, "ip:192.0.2.1", "ip", Quota{Rate: 1, Burst: 1}},
+		{"quota key", l, map[string]string{"X-API-Key": "premium"}, "key:premium", "api_key", Quota{Rate: 100, Burst: 50}},
+		{"quota bearer", l, map[string]string{"Authorization": "Bearer premium"}, "key:premium", "api_key", Quota{Rate: 100, Burst: 50}},
+		{"unknown key limited by address", l, map[string]string{"X-API-Key": "made-up"}, "ip:192.0.2.1", "ip", Quota{Rate: 1, Burst: 1}},
+		{"forwarded for ignored", l, map[string]string{"X-Forwarded-For": "203.0.113.9"}, "ip:192.0.2.1", "ip", Quota{Rate: 1, Burst: 1}},
+		{"forwarded for trusted", trusting, map[string]string{"X-Forwarded-For": "203.0.113.9, 10.0.0.1"}, "ip:203.0.113.9", "ip", Quota{Rate: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/log/entries", nil)
+			r.RemoteAddr = "192.0.2.1:4711"
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+			id, kind, quota := tt.limiter.principal(r)
+			if id != tt.id || kind != tt.kind || quota != tt.quota {
+				t.Fatalf("principal = %q, %q, %+v; want %q, %q, %+v", id, kind, quota, tt.id, tt.kind, tt.quota)
+			}
+		})
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(Config{PrivateKey: key, RateLimit: &RateLimit{Rate: 0.001, Burst: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func(i int) *httptest.ResponseRecorder {
+		leaf := make([]byte, 32)
+		leaf[0] = byte(i)
+		body := fmt.Sprintf(`{"leaf":%q}`, hex.EncodeToString(leaf))
+		r := httptest.NewRequest(http.MethodPost, "/v1/log/entries", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := submit(i); w.Code != http.StatusOK {
+			t.Fatalf("submission %d: status %d: %s", i, w.Code, w.Body)
+		}
+	}
+	w := submit(2)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third submission: status %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("rate limited response has no Retry-After")
+	}
+	if server.logs[""].Size() != 2 {
+		t.Fatalf("rate limited submission was appended")
+	}
+}
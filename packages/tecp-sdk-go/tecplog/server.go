@@ -0,0 +1,474 @@
+// Package tecplog implements a TECP transparency log server and client.
+//
+// The server is a Go port of the reference log service's unified API:
+//
+//	POST /v1/log/entries            append {"leaf": "<hex>"}, returns an inclusion proof
+//	POST /v1/log/srt                accept {"leaf": "<hex>"}, returns a signed promise (SRT)
+//	GET  /v1/log/proof?leaf=<hex>   inclusion proof for a previously appended leaf
+//	GET  /v1/log/leaves?start=&count= page of leaf hashes with a range proof
+//	GET  /v1/log/consistency?first=&second= consistency proof between tree sizes
+//	GET  /v1/log/sth                current signed tree head
+//	GET  /.well-known/tecp-log-jwks log signing key
+//
+// Endpoints can be restricted to approved issuers by static API key or by
+// mutual TLS with SPIFFE ID validation (see Auth). Submissions can carry the
+// receipt alongside its leaf, {"leaf": "<hex>", "receipt": {...}}, which
+// logs with a Validator require and check before accepting the leaf.
+//
+// Besides receipts, logs anchor the ecosystem's other artifacts: key
+// rotation statements, revocations and policy publications, submitted as
+// {"type": "revocation", "entry": "<base64>"}. Each type's leaves are
+// domain separated (see EntryHash).
+//
+// One server can host several isolated tenant logs, each with its own tree,
+// signing key and storage, serving the same API under /tenants/{id}/.
+//
+// Leaves are 32-byte entry hashes, normally tecp.ReceiptHash values, hashed
+// into an RFC 6962 tree (see package merkle). Leaf indexes start at 0.
+package tecplog
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+)
+
+// MaxRequestBody bounds submission request bodies
+const MaxRequestBody = 1 << 20
+
+// TenantPathPrefix prefixes the API of tenant logs
+const TenantPathPrefix = "/tenants/"
+
+// tenantIDPattern restricts tenant IDs to values safe in paths and file names
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Config configures a log server
+type Config struct {
+	// PrivateKey signs the default log's tree heads. When nil the server
+	// hosts tenant logs only
+	PrivateKey ed25519.PrivateKey
+
+	// KeyID identifies PrivateKey in tree heads and the JWKS
+	KeyID string
+
+	// Storage persists the default log's leaves; defaults to MemoryStorage
+	Storage Storage
+
+	// Tenants are additional isolated logs served under /tenants/{id}/
+	Tenants []TenantConfig
+
+	// RateLimit, when set, limits entry submissions per client and log
+	RateLimit *RateLimit
+
+	// Auth, when set, restricts endpoints of every log to approved issuers
+	Auth *Auth
+
+	// Validator, when set, gatekeeps submissions to every log: each must
+	// carry its receipt, which the validator accepts or rejects
+	Validator Validator
+
+	// MaxMergeDelay is the inclusion delay promised in SRTs; defaults to
+	// DefaultMaxMergeDelay
+	MaxMergeDelay time.Duration
+}
+
+// TenantConfig configures a tenant log. Tenants must not share keys or
+// storage with each other or with the default log
+type TenantConfig struct {
+	ID         string
+	PrivateKey ed25519.PrivateKey
+	KeyID      string
+	Storage    Storage
+
+	// Auth overrides the server's Auth for this tenant
+	Auth *Auth
+
+	// Validator overrides the server's Validator for this tenant
+	Validator Validator
+}
+
+// Server is a transparency log HTTP server
+type Server struct {
+	logs       map[string]*Log
+	handlers   map[string]http.Handler
+	auth       map[string]*authenticator
+	validators map[string]Validator
+	limiter    *limiter
+	metrics    *metrics
+	mux        *http.ServeMux
+}
+
+// NewServer loads the configured logs and returns a server for them
+func NewServer(config Config) (*Server, error) {
+	s := &Server{
+		logs:       make(map[string]*Log),
+		handlers:   make(map[string]http.Handler),
+		auth:       make(map[string]*authenticator),
+		validators: make(map[string]Validator),
+		metrics:    newMetrics(),
+	}
+	if config.RateLimit != nil {
+		s.limiter = newLimiter(*config.RateLimit)
+	}
+
+	if config.PrivateKey != nil {
+		l, err := openLog("", config.PrivateKey, config.KeyID, config.Storage, config.MaxMergeDelay)
+		if err != nil {
+			return nil, err
+		}
+		s.logs[""] = l
+		if err := s.configureAuth("", config.Auth); err != nil {
+			return nil, err
+		}
+		if config.Validator != nil {
+			s.validators[""] = config.Validator
+		}
+	}
+
+	for _, tenant := range config.Tenants {
+		if !tenantIDPattern.MatchString(tenant.ID) {
+			return nil, fmt.Errorf("tecplog: invalid tenant ID %q", tenant.ID)
+		}
+		if _, exists := s.logs[tenant.ID]; exists {
+			return nil, fmt.Errorf("tecplog: duplicate tenant ID %q", tenant.ID)
+		}
+		l, err := openLog(tenant.ID, tenant.PrivateKey, tenant.KeyID, tenant.Storage, config.MaxMergeDelay)
+		if err != nil {
+			return nil, err
+		}
+		s.logs[tenant.ID] = l
+
+		auth := config.Auth
+		if tenant.Auth != nil {
+			auth = tenant.Auth
+		}
+		if err := s.configureAuth(tenant.ID, auth); err != nil {
+			return nil, err
+		}
+
+		validator := config.Validator
+		if tenant.Validator != nil {
+			validator = tenant.Validator
+		}
+		if validator != nil {
+			s.validators[tenant.ID] = validator
+		}
+	}
+
+	if len(s.logs) == 0 {
+		return nil, fmt.Errorf("tecplog: no logs configured")
+	}
+	for id, l := range s.logs {
+		s.handlers[id] = s.logHandler(l)
+		s.metrics.set("tecplog_tree_size", float64(l.Size()), "log", logLabel(l))
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	s.mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "logs": len(s.logs)})
+	})
+	s.mux.HandleFunc(TenantPathPrefix, s.handleTenant)
+	if handler, ok := s.handlers[""]; ok {
+		s.mux.Handle("/", handler)
+	}
+	return s, nil
+}
+
+// configureAuth sets up authentication for a log; nil leaves it public
+func (s *Server) configureAuth(id string, config *Auth) error {
+	if config == nil {
+		return nil
+	}
+	a, err := newAuthenticator(*config)
+	if err != nil {
+		return err
+	}
+	s.auth[id] = a
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Log returns the tenant log with the given ID, or the default log for ""
+func (s *Server) Log(id string) (*Log, bool) {
+	l, ok := s.logs[id]
+	return l, ok
+}
+
+// Append adds an entry hash to the default log
+func (s *Server) Append(entry []byte) (*EntryResponse, error) {
+	l, ok := s.logs[""]
+	if !ok {
+		return nil, fmt.Errorf("tecplog: server has no default log")
+	}
+	return s.append(l, entry)
+}
+
+func (s *Server) append(l *Log, entry []byte) (*EntryResponse, error) {
+	response, err := l.Append(entry)
+	if err == nil {
+		s.metrics.set("tecplog_tree_size", float64(response.STH.Size), "log", logLabel(l))
+	}
+	return response, err
+}
+
+// handleTenant routes /tenants/{id}/... to the tenant's log
+func (s *Server) handleTenant(w http.ResponseWriter, r *http.Request) {
+	id, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, TenantPathPrefix), "/")
+	if _, ok := s.logs[id]; !ok || id == "" {
+		writeError(w, http.StatusNotFound, "unknown log")
+		return
+	}
+	http.StripPrefix(TenantPathPrefix+id, s.handlers[id]).ServeHTTP(w, r)
+}
+
+// logHandler serves the log API for one log
+func (s *Server) logHandler(l *Log) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/log/entries", s.authorize(l, EndpointEntries, func(w http.ResponseWriter, r *http.Request) {
+		s.handleSubmit(l, w, r, false)
+	}))
+	mux.Handle("/v1/log/srt", s.authorize(l, EndpointEntries, func(w http.ResponseWriter, r *http.Request) {
+		s.handleSubmit(l, w, r, true)
+	}))
+	mux.Handle("/v1/log/proof", s.authorize(l, EndpointProof, func(w http.ResponseWriter, r *http.Request) {
+		handleProof(l, w, r)
+	}))
+	mux.Handle("/v1/log/consistency", s.authorize(l, EndpointProof, func(w http.ResponseWriter, r *http.Request) {
+		handleConsistency(l, w, r)
+	}))
+	mux.Handle("/v1/log/leaves", s.authorize(l, EndpointLeaves, func(w http.ResponseWriter, r *http.Request) {
+		handleLeaves(l, w, r)
+	}))
+	mux.Handle("/v1/log/sth", s.authorize(l, EndpointSTH, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, l.TreeHead())
+	}))
+	mux.Handle("/.well-known/tecp-log-jwks", s.authorize(l, EndpointJWKS, func(w http.ResponseWriter, r *http.Request) {
+		handleJWKS(l, w, r)
+	}))
+	return mux
+}
+
+// authorize rejects requests the log's Auth does not admit to endpoint
+func (s *Server) authorize(l *Log, endpoint Endpoint, next http.HandlerFunc) http.Handler {
+	a, ok := s.auth[l.id]
+	if !ok {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer, status := a.authenticate(endpoint, r)
+		switch status {
+		case 0:
+			next(w, r.WithContext(context.WithValue(r.Context(), issuerKey{}, issuer)))
+			return
+		case http.StatusUnauthorized:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tecp-log"`)
+			writeError(w, status, "authentication required")
+		default:
+			writeError(w, status, "issuer not authorized")
+		}
+		s.metrics.inc("tecplog_auth_failures_total", "log", logLabel(l), "endpoint", string(endpoint))
+	})
+}
+
+// handleSubmit accepts an entry, returning an inclusion proof or, when
+// promise is set, an SRT
+func (s *Server) handleSubmit(l *Log, w http.ResponseWriter, r *http.Request, promise bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	label := logLabel(l)
+
+	// Rate limit before reading the body so rejected clients cost little
+	if s.limiter != nil {
+		id, kind, quota := s.limiter.principal(r)
+		if ok, wait := s.limiter.allow(l.id+"/"+id, quota); !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			s.metrics.inc("tecplog_submissions_total", "log", label, "result", "rate_limited")
+			s.metrics.inc("tecplog_rate_limited_total", "log", label, "principal", kind)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+				"error":       "rate limit exceeded",
+				"retry_after": retryAfter,
+			})
+			return
+		}
+	}
+
+	var request submissionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBody)).Decode(&request); err != nil {
+		s.metrics.inc("tecplog_submissions_total", "log", label, "result", "invalid")
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	submission, entry, err := request.parse()
+	if err != nil {
+		s.metrics.inc("tecplog_submissions_total", "log", label, "result", "invalid")
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	submission.Promise = promise
+	if status, message := s.validate(l, r, submission); status != 0 {
+		result := "rejected"
+		if status == http.StatusServiceUnavailable {
+			result = "validation_error"
+		}
+		s.metrics.inc("tecplog_submissions_total", "log", label, "result", result)
+		writeError(w, status, message)
+		return
+	}
+
+	if promise {
+		srt, err := l.Promise(entry)
+		if err != nil {
+			s.metrics.inc("tecplog_submissions_total", "log", label, "result", "error")
+			writeError(w, http.StatusInternalServerError, "append failed")
+			return
+		}
+		s.metrics.inc("tecplog_submissions_total", "log", label, "result", "promised")
+		writeJSON(w, http.StatusOK, srt)
+		return
+	}
+
+	response, err := s.append(l, entry)
+	if err != nil {
+		s.metrics.inc("tecplog_submissions_total", "log", label, "result", "error")
+		writeError(w, http.StatusInternalServerError, "append failed")
+		return
+	}
+	result := "accepted"
+	if response.AlreadyExists {
+		result = "duplicate"
+	}
+	s.metrics.inc("tecplog_submissions_total", "log", label, "result", result)
+	writeJSON(w, http.StatusOK, response)
+}
+
+func handleProof(l *Log, w http.ResponseWriter, r *http.Request) {
+	entry, ok := parseLeaf(r.URL.Query().Get("leaf"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "leaf must be 32-byte hex string")
+		return
+	}
+
+	response, found, err := l.Proof(entry)
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "proof failed")
+	case !found:
+		writeError(w, http.StatusNotFound, "leaf not found")
+	default:
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+func handleConsistency(l *Log, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	first, err := strconv.ParseUint(query.Get("first"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "first must be a tree size")
+		return
+	}
+	second, err := strconv.ParseUint(query.Get("second"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "second must be a tree size")
+		return
+	}
+	if first > second || second > l.Size() {
+		writeError(w, http.StatusBadRequest, "invalid tree size range")
+		return
+	}
+	response, err := l.ConsistencyProof(first, second)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "proof failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func handleLeaves(l *Log, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	start, err := strconv.ParseUint(query.Get("start"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "start must be a leaf index")
+		return
+	}
+	var count uint64
+	if value := query.Get("count"); value != "" {
+		if count, err = strconv.ParseUint(value, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "count must be a positive integer")
+			return
+		}
+	}
+
+	if start >= l.Size() {
+		writeError(w, http.StatusNotFound, "start beyond tree size")
+		return
+	}
+	response, err := l.Leaves(start, count)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "leaves failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func handleJWKS(l *Log, w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(l.PublicKey()),
+			"kid": l.KeyID(),
+		}},
+	})
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.write(w)
+}
+
+// logLabel names a log in metrics
+func logLabel(l *Log) string {
+	if l.id == "" {
+		return "default"
+	}
+	return l.id
+}
+
+// parseLeaf decodes a 32-byte hex leaf, optionally 0x-prefixed
+func parseLeaf(value string) ([]byte, bool) {
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	entry, err := hex.DecodeString(value)
+	if err != nil || len(entry) != merkle.HashSize {
+		return nil, false
+	}
+	return entry, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,48 @@
+package tecplog
+
+import (
+	"github.com/tecp-protocol/tecp-sdk-go/store"
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Spool keeps receipts issued while their logs were unreachable in a
+// receipt store until Flush submits them. It is the tecp.Spool of the
+// DegradeSpool policy; back it with a store.DirStore to survive restarts
+type Spool struct {
+	store store.ReceiptStore
+}
+
+// NewSpool returns a spool keeping receipts in st
+func NewSpool(st store.ReceiptStore) *Spool {
+	return &Spool{store: st}
+}
+
+// Enqueue adds a receipt to the spool
+func (s *Spool) Enqueue(receipt *tecp.Receipt) error {
+	_, err := s.store.Put(receipt)
+	return err
+}
+
+// Flush submits every spooled receipt to logs, embedding their inclusion
+// proofs, and hands each to deliver, when set, before removing it from the
+// spool. It stops at the first failure, leaving the rest spooled, and
+// returns the number of receipts flushed
+func (s *Spool) Flush(deliver func(receipt *tecp.Receipt) error, logs ...*Client) (int, error) {
+	flushed := 0
+	err := s.store.Walk(func(key string, receipt *tecp.Receipt) error {
+		if err := SubmitToLogs(receipt, logs...); err != nil {
+			return err
+		}
+		if deliver != nil {
+			if err := deliver(receipt); err != nil {
+				return err
+			}
+		}
+		if err := s.store.Delete(key); err != nil && err != store.ErrNotFound {
+			return err
+		}
+		flushed++
+		return nil
+	})
+	return flushed, err
+}
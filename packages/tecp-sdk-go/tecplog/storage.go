@@ -0,0 +1,114 @@
+package tecplog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tecp-protocol/tecp-sdk-go/merkle"
+)
+
+// Storage persists the log's leaf hashes in order
+type Storage interface {
+	// Load returns every stored leaf hash in append order
+	Load() ([][]byte, error)
+
+	// Append durably stores a leaf hash after the existing ones
+	Append(leafHash []byte) error
+}
+
+// MemoryStorage keeps leaves in memory only, for tests and ephemeral logs
+type MemoryStorage struct {
+	mu     sync.Mutex
+	leaves [][]byte
+}
+
+// Load returns the stored leaves
+func (m *MemoryStorage) Load() ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]byte(nil), m.leaves...), nil
+}
+
+// Append stores a leaf hash
+func (m *MemoryStorage) Append(leafHash []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leaves = append(m.leaves, append([]byte(nil), leafHash...))
+	return nil
+}
+
+// FileStorage appends raw leaf hashes to a single file, syncing every write
+type FileStorage struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileStorage opens or creates the leaf file at path
+func OpenFileStorage(path string) (*FileStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStorage{file: file}, nil
+}
+
+// Load reads every complete leaf hash. A torn trailing write is discarded
+func (f *FileStorage) Load() ([][]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f.file)
+	if err != nil {
+		return nil, err
+	}
+
+	complete := len(data) - len(data)%merkle.HashSize
+	if complete != len(data) {
+		if err := f.file.Truncate(int64(complete)); err != nil {
+			return nil, fmt.Errorf("failed to discard partial leaf: %w", err)
+		}
+	}
+
+	leaves := make([][]byte, 0, complete/merkle.HashSize)
+	for i := 0; i < complete; i += merkle.HashSize {
+		leaves = append(leaves, data[i:i+merkle.HashSize])
+	}
+	return leaves, nil
+}
+
+// Append writes and syncs a leaf hash
+func (f *FileStorage) Append(leafHash []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := f.file.Write(leafHash); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+// TenantStoragePath returns the leaf file of a log under a shared data
+// directory; each tenant gets its own prefix so logs never share files
+func TenantStoragePath(dir, tenant string) string {
+	if tenant == "" {
+		tenant = "default"
+	}
+	return filepath.Join(dir, "tenants", tenant, "leaves")
+}
+
+// Close closes the leaf file
+func (f *FileStorage) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,261 @@
+package tecplog
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tecp-protocol/tecp-sdk-go/tecp"
+)
+
+// Submission is an entry offered to a log with a Validator. Logs that
+// validate submissions require the artifact itself, not only its hash
+type Submission struct {
+	// Log is the tenant log's ID; empty for the default log
+	Log string `json:"log"`
+
+	// Issuer is the authenticated issuer, when the log requires auth
+	Issuer string `json:"issuer,omitempty"`
+
+	// Leaf is the artifact's EntryHash
+	Leaf string `json:"leaf"`
+
+	// Promise is set for SRT requests, which promise inclusion later
+	Promise bool `json:"promise,omitempty"`
+
+	Type EntryType `json:"type"`
+
+	// Receipt is set for receipt entries, Entry for the others
+	Receipt *tecp.Receipt `json:"receipt,omitempty"`
+	Entry   []byte        `json:"entry,omitempty"`
+}
+
+// Validator decides whether a log accepts a submission before the leaf is
+// appended or promised, so the log does not fill with malformed receipts,
+// receipts from unknown issuers or receipts claiming banned policies.
+// Returning a *Rejection refuses the submission with its reason; any other
+// error refuses it as the validator being unavailable
+type Validator interface {
+	Validate(ctx context.Context, submission *Submission) error
+}
+
+// ValidatorFunc adapts a function to a Validator
+type ValidatorFunc func(ctx context.Context, submission *Submission) error
+
+// Validate implements Validator
+func (f ValidatorFunc) Validate(ctx context.Context, submission *Submission) error {
+	return f(ctx, submission)
+}
+
+// Rejection is a Validator's refusal of a submission, reported to the
+// submitter with status 422
+type Rejection struct {
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return "entry rejected: " + r.Reason
+}
+
+// Reject returns a Rejection with a formatted reason
+func Reject(format string, args ...interface{}) error {
+	return &Rejection{Reason: fmt.Sprintf(format, args...)}
+}
+
+// ReceiptRules validates submissions in process: receipts must verify
+// under Options, which should name the accepted issuers through a trust
+// bundle, roots or a directory check, and must not claim a banned policy.
+// The log check is skipped, since the receipt is only now being logged
+type ReceiptRules struct {
+	// Verifier defaults to tecp.NewClient()
+	Verifier *tecp.Client
+	Options  tecp.VerifyOptions
+
+	// BannedPolicies are policy IDs the log refuses
+	BannedPolicies []string
+}
+
+// Validate implements Validator. Entries other than receipts are rejected
+func (v *ReceiptRules) Validate(ctx context.Context, submission *Submission) error {
+	receipt := submission.Receipt
+	if submission.Type != EntryReceipt {
+		return Reject("this log accepts receipts only")
+	}
+	for _, id := range receipt.PolicyIDs {
+		for _, banned := range v.BannedPolicies {
+			if id == banned {
+				return Reject("policy %s is not accepted by this log", id)
+			}
+		}
+	}
+
+	verifier := v.Verifier
+	if verifier == nil {
+		verifier = tecp.NewClient()
+	}
+	options := v.Options
+	options.DisabledChecks = append(append([]string(nil), options.DisabledChecks...), tecp.CheckLog)
+	result, err := verifier.VerifyReceipt(receipt, options)
+	if err != nil {
+		return Reject("%v", err)
+	}
+	if !result.Valid {
+		return Reject("%s", strings.Join(result.Errors, "; "))
+	}
+	return nil
+}
+
+// Webhook validates submissions by posting them as JSON to URL. A 2xx
+// response accepts the submission; a 4xx response rejects it, with the
+// reason in the body's "error" field. Other responses and transport
+// failures refuse the submission as unavailable unless FailOpen is set
+type Webhook struct {
+	URL string
+
+	// FailOpen accepts submissions the webhook could not decide on
+	FailOpen bool
+
+	HTTPClient *http.Client
+}
+
+// UseHTTPClient posts submissions through client when the webhook has no
+// HTTPClient
+func (w *Webhook) UseHTTPClient(client *http.Client) {
+	if w.HTTPClient == nil {
+		w.HTTPClient = client
+	}
+}
+
+// Validate implements Validator
+func (w *Webhook) Validate(ctx context.Context, submission *Submission) error {
+	err := w.call(ctx, submission)
+	var rejection *Rejection
+	if err != nil && w.FailOpen && !errors.As(err, &rejection) {
+		return nil
+	}
+	return err
+}
+
+func (w *Webhook) call(ctx context.Context, submission *Submission) error {
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := w.HTTPClient
+	if httpClient == nil {
+		httpClient = tecp.DefaultHTTPClient()
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("tecplog: validation webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 4:
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&failure)
+		if failure.Error == "" {
+			failure.Error = fmt.Sprintf("validation webhook returned status %d", resp.StatusCode)
+		}
+		return &Rejection{Reason: failure.Error}
+	default:
+		return fmt.Errorf("tecplog: validation webhook returned status %d", resp.StatusCode)
+	}
+}
+
+// submissionRequest is the body of entry and SRT requests. The artifact,
+// a receipt or the content of another entry type, is optional unless the
+// log validates submissions, and its hash must match the leaf when both
+// are sent
+type submissionRequest struct {
+	Leaf    string          `json:"leaf"`
+	Type    EntryType       `json:"type,omitempty"`
+	Receipt json.RawMessage `json:"receipt,omitempty"`
+	Entry   []byte          `json:"entry,omitempty"`
+}
+
+// parse returns the submission with its entry hash
+func (r *submissionRequest) parse() (*Submission, []byte, error) {
+	submission := &Submission{Type: r.Type}
+	if submission.Type == "" {
+		submission.Type = EntryReceipt
+	}
+	var hash []byte
+	switch {
+	case !submission.Type.Valid():
+		return nil, nil, fmt.Errorf("unknown entry type %q", r.Type)
+	case submission.Type == EntryReceipt && len(r.Entry) > 0:
+		return nil, nil, fmt.Errorf("receipts are sent as receipt, not entry")
+	case submission.Type != EntryReceipt && len(r.Receipt) > 0:
+		return nil, nil, fmt.Errorf("%s entries are sent as entry, not receipt", submission.Type)
+	case len(r.Receipt) > 0:
+		receipt, err := tecp.Decode(r.Receipt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid receipt: %v", err)
+		}
+		if hash, err = tecp.ReceiptHash(receipt); err != nil {
+			return nil, nil, fmt.Errorf("invalid receipt: %v", err)
+		}
+		submission.Receipt = receipt
+	case len(r.Entry) > 0:
+		hash, _ = EntryHash(submission.Type, r.Entry)
+		submission.Entry = r.Entry
+	}
+
+	entry := hash
+	if r.Leaf != "" || hash == nil {
+		var ok bool
+		if entry, ok = parseLeaf(r.Leaf); !ok {
+			return nil, nil, fmt.Errorf("leaf must be 32-byte hex string")
+		}
+		if hash != nil && !bytes.Equal(hash, entry) {
+			return nil, nil, fmt.Errorf("leaf is not the hash of the %s", submission.Type)
+		}
+	}
+	submission.Leaf = hex.EncodeToString(entry)
+	return submission, entry, nil
+}
+
+// validate runs a log's validator on a submission, returning the status
+// and message to refuse it with, or 0 to accept it
+func (s *Server) validate(l *Log, r *http.Request, submission *Submission) (int, string) {
+	validator, ok := s.validators[l.id]
+	if !ok {
+		return 0, ""
+	}
+	if submission.Receipt == nil && submission.Entry == nil {
+		return http.StatusUnprocessableEntity, fmt.Sprintf("this log requires the %s with each submission", submission.Type)
+	}
+	submission.Log = l.id
+	submission.Issuer, _ = r.Context().Value(issuerKey{}).(string)
+	err := validator.Validate(r.Context(), submission)
+	var rejection *Rejection
+	switch {
+	case err == nil:
+		return 0, ""
+	case errors.As(err, &rejection):
+		return http.StatusUnprocessableEntity, rejection.Error()
+	default:
+		return http.StatusServiceUnavailable, "receipt validation unavailable"
+	}
+}
+
+// issuerKey carries the authenticated issuer in request contexts
+type issuerKey struct{}